@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"fmt"
 	"nq/graph/model"
 
 	"github.com/google/uuid"
@@ -24,13 +23,18 @@ func (r *Neo4jRepository) CreateActivity(ctx context.Context, input model.Create
 				review: $review,
 				startedAt: $startedAt,
 				finishedAt: $finishedAt,
+				rewatch: $rewatch,
+				version: 1,
 				createdAt: datetime(),
 				updatedAt: datetime()
 			})
 			CREATE (u)-[:HAS_ACTIVITY]->(a)
 			CREATE (a)-[:ACTIVITY_FOR]->(m)
 			RETURN a.id as id, a.statusId as statusId, a.rating as rating,
-			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt
+			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt,
+			       a.rewatch as rewatch, a.count as count,
+			       a.progress as progress, a.progressUnit as progressUnit,
+			       a.version as version
 		`
 
 		params := map[string]any{
@@ -42,6 +46,7 @@ func (r *Neo4jRepository) CreateActivity(ctx context.Context, input model.Create
 			"review":     input.Review,
 			"startedAt":  input.StartedAt,
 			"finishedAt": input.FinishedAt,
+			"rewatch":    input.Rewatch,
 		}
 
 		result, err := tx.Run(ctx, query, params)
@@ -52,18 +57,23 @@ func (r *Neo4jRepository) CreateActivity(ctx context.Context, input model.Create
 		if result.Next(ctx) {
 			record := result.Record()
 			activity := &model.UserActivity{
-				ID:         activityID,
-				Status:     &model.ActivityStatus{ID: input.StatusID}, // TODO: Get actual status
-				Rating:     getFloat64Pointer(record.AsMap()["rating"]),
-				Review:     getStringPointer(record.AsMap()["review"]),
-				StartedAt:  getStringPointer(record.AsMap()["startedAt"]),
-				FinishedAt: getStringPointer(record.AsMap()["finishedAt"]),
+				ID:           activityID,
+				Status:       &model.ActivityStatus{ID: input.StatusID}, // TODO: Get actual status
+				Rating:       getFloat64Pointer(record.AsMap()["rating"]),
+				Review:       getStringPointer(record.AsMap()["review"]),
+				StartedAt:    getStringPointer(record.AsMap()["startedAt"]),
+				FinishedAt:   getStringPointer(record.AsMap()["finishedAt"]),
+				Rewatch:      getBoolPointer(record.AsMap()["rewatch"]),
+				Count:        getInt32Pointer(record.AsMap()["count"]),
+				Progress:     getFloat64Pointer(record.AsMap()["progress"]),
+				ProgressUnit: getStringPointer(record.AsMap()["progressUnit"]),
+				Version:      getInt32FromRecord(record, "version"),
 				// TODO: Populate User and Media from IDs
 			}
 			return activity, nil
 		}
 
-		return nil, fmt.Errorf("failed to create activity")
+		return nil, NotFoundError("user or media")
 	})
 
 	if err != nil {
@@ -82,7 +92,9 @@ func (r *Neo4jRepository) GetActivityByID(ctx context.Context, id uuid.UUID) (*m
 			OPTIONAL MATCH (a)-[:ACTIVITY_FOR]->(m:Media)
 			RETURN a.id as id, a.statusId as statusId, a.rating as rating,
 			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt,
-			       u.id as userId, m.id as mediaId
+			       a.rewatch as rewatch, a.count as count,
+			       a.progress as progress, a.progressUnit as progressUnit,
+			       coalesce(a.version, 0) as version, u.id as userId, m.id as mediaId
 		`
 
 		params := map[string]any{"id": id.String()}
@@ -95,18 +107,23 @@ func (r *Neo4jRepository) GetActivityByID(ctx context.Context, id uuid.UUID) (*m
 		if result.Next(ctx) {
 			record := result.Record()
 			activity := &model.UserActivity{
-				ID:         id,
-				Status:     &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
-				Rating:     getFloat64Pointer(record.AsMap()["rating"]),
-				Review:     getStringPointer(record.AsMap()["review"]),
-				StartedAt:  getStringPointer(record.AsMap()["startedAt"]),
-				FinishedAt: getStringPointer(record.AsMap()["finishedAt"]),
+				ID:           id,
+				Status:       &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
+				Rating:       getFloat64Pointer(record.AsMap()["rating"]),
+				Review:       getStringPointer(record.AsMap()["review"]),
+				StartedAt:    getStringPointer(record.AsMap()["startedAt"]),
+				FinishedAt:   getStringPointer(record.AsMap()["finishedAt"]),
+				Rewatch:      getBoolPointer(record.AsMap()["rewatch"]),
+				Count:        getInt32Pointer(record.AsMap()["count"]),
+				Progress:     getFloat64Pointer(record.AsMap()["progress"]),
+				ProgressUnit: getStringPointer(record.AsMap()["progressUnit"]),
+				Version:      getInt32FromRecord(record, "version"),
 				// TODO: Populate User and Media
 			}
 			return activity, nil
 		}
 
-		return nil, fmt.Errorf("activity not found")
+		return nil, NotFoundError("activity")
 	})
 
 	if err != nil {
@@ -124,7 +141,9 @@ func (r *Neo4jRepository) GetUserActivities(ctx context.Context, userID uuid.UUI
 			OPTIONAL MATCH (a)-[:ACTIVITY_FOR]->(m:Media)
 			RETURN a.id as id, a.statusId as statusId, a.rating as rating,
 			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt,
-			       m.id as mediaId
+			       a.rewatch as rewatch, a.count as count,
+			       a.progress as progress, a.progressUnit as progressUnit,
+			       coalesce(a.version, 0) as version, m.id as mediaId
 			ORDER BY a.createdAt DESC
 		`
 
@@ -144,12 +163,17 @@ func (r *Neo4jRepository) GetUserActivities(ctx context.Context, userID uuid.UUI
 			}
 
 			activity := &model.UserActivity{
-				ID:         activityID,
-				Status:     &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
-				Rating:     getFloat64Pointer(record.AsMap()["rating"]),
-				Review:     getStringPointer(record.AsMap()["review"]),
-				StartedAt:  getStringPointer(record.AsMap()["startedAt"]),
-				FinishedAt: getStringPointer(record.AsMap()["finishedAt"]),
+				ID:           activityID,
+				Status:       &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
+				Rating:       getFloat64Pointer(record.AsMap()["rating"]),
+				Review:       getStringPointer(record.AsMap()["review"]),
+				StartedAt:    getStringPointer(record.AsMap()["startedAt"]),
+				FinishedAt:   getStringPointer(record.AsMap()["finishedAt"]),
+				Rewatch:      getBoolPointer(record.AsMap()["rewatch"]),
+				Count:        getInt32Pointer(record.AsMap()["count"]),
+				Progress:     getFloat64Pointer(record.AsMap()["progress"]),
+				ProgressUnit: getStringPointer(record.AsMap()["progressUnit"]),
+				Version:      getInt32FromRecord(record, "version"),
 				// TODO: Populate User and Media
 			}
 			activities = append(activities, activity)
@@ -173,7 +197,9 @@ func (r *Neo4jRepository) GetMediaActivities(ctx context.Context, mediaID uuid.U
 			OPTIONAL MATCH (u:User)-[:HAS_ACTIVITY]->(a)
 			RETURN a.id as id, a.statusId as statusId, a.rating as rating,
 			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt,
-			       u.id as userId
+			       a.rewatch as rewatch, a.count as count,
+			       a.progress as progress, a.progressUnit as progressUnit,
+			       coalesce(a.version, 0) as version, u.id as userId
 			ORDER BY a.createdAt DESC
 		`
 
@@ -193,12 +219,17 @@ func (r *Neo4jRepository) GetMediaActivities(ctx context.Context, mediaID uuid.U
 			}
 
 			activity := &model.UserActivity{
-				ID:         activityID,
-				Status:     &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
-				Rating:     getFloat64Pointer(record.AsMap()["rating"]),
-				Review:     getStringPointer(record.AsMap()["review"]),
-				StartedAt:  getStringPointer(record.AsMap()["startedAt"]),
-				FinishedAt: getStringPointer(record.AsMap()["finishedAt"]),
+				ID:           activityID,
+				Status:       &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
+				Rating:       getFloat64Pointer(record.AsMap()["rating"]),
+				Review:       getStringPointer(record.AsMap()["review"]),
+				StartedAt:    getStringPointer(record.AsMap()["startedAt"]),
+				FinishedAt:   getStringPointer(record.AsMap()["finishedAt"]),
+				Rewatch:      getBoolPointer(record.AsMap()["rewatch"]),
+				Count:        getInt32Pointer(record.AsMap()["count"]),
+				Progress:     getFloat64Pointer(record.AsMap()["progress"]),
+				ProgressUnit: getStringPointer(record.AsMap()["progressUnit"]),
+				Version:      getInt32FromRecord(record, "version"),
 				// TODO: Populate User and Media
 			}
 			activities = append(activities, activity)
@@ -214,61 +245,86 @@ func (r *Neo4jRepository) GetMediaActivities(ctx context.Context, mediaID uuid.U
 	return result.([]*model.UserActivity), nil
 }
 
-// UpdateActivity updates an existing activity
-func (r *Neo4jRepository) UpdateActivity(ctx context.Context, id uuid.UUID, statusID *int32, rating *float64, review *string, finishedAt *string) (*model.UserActivity, error) {
+// UpdateActivity updates an existing activity. When expectedVersion is set
+// and the stored version differs, nothing is written and a conflict error
+// carrying the current activity is returned.
+func (r *Neo4jRepository) UpdateActivity(ctx context.Context, id uuid.UUID, input model.UpdateActivityInput, expectedVersion *int32) (*model.UserActivity, error) {
 	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Lock the activity before comparing versions so a concurrent update
+		// can't slip in between the check and the write
 		query := `
 			MATCH (a:UserActivity {id: $id})
-			SET a.updatedAt = datetime()
+			SET a.version = coalesce(a.version, 0)
+			RETURN a.id as id, a.statusId as statusId, a.rating as rating,
+			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt,
+			       a.rewatch as rewatch, a.count as count,
+			       a.progress as progress, a.progressUnit as progressUnit,
+			       a.version as version
 		`
 
 		params := map[string]any{"id": id.String()}
 
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			return nil, NotFoundError("activity")
+		}
+
+		current := activityFromRecord(id, result.Record())
+		if expectedVersion != nil && current.Version != *expectedVersion {
+			return nil, VersionConflictError("activity", current)
+		}
+		if input.FinishedAt != nil && finishedBeforeStart(current.StartedAt, *input.FinishedAt) {
+			return nil, ValidationFailedError("finishedAt must not be before the activity's startedAt %s", *current.StartedAt)
+		}
+
+		query = `
+			MATCH (a:UserActivity {id: $id})
+			SET a.updatedAt = datetime(), a.version = a.version + 1
+		`
+
 		// Add optional fields to SET clause
-		if statusID != nil {
+		if input.StatusID != nil {
 			query += ", a.statusId = $statusId"
-			params["statusId"] = *statusID
+			params["statusId"] = *input.StatusID
 		}
 
-		if rating != nil {
+		if input.Rating != nil {
 			query += ", a.rating = $rating"
-			params["rating"] = *rating
+			params["rating"] = *input.Rating
 		}
 
-		if review != nil {
+		if input.Review != nil {
 			query += ", a.review = $review"
-			params["review"] = *review
+			params["review"] = *input.Review
 		}
 
-		if finishedAt != nil {
+		if input.FinishedAt != nil {
 			query += ", a.finishedAt = $finishedAt"
-			params["finishedAt"] = *finishedAt
+			params["finishedAt"] = *input.FinishedAt
 		}
 
 		query += `
 			RETURN a.id as id, a.statusId as statusId, a.rating as rating,
-			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt
+			       a.review as review, a.startedAt as startedAt, a.finishedAt as finishedAt,
+			       a.rewatch as rewatch, a.count as count,
+			       a.progress as progress, a.progressUnit as progressUnit,
+			       a.version as version
 		`
 
-		result, err := tx.Run(ctx, query, params)
+		result, err = tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
 		}
 
 		if result.Next(ctx) {
-			record := result.Record()
-			activity := &model.UserActivity{
-				ID:         id,
-				Status:     &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
-				Rating:     getFloat64Pointer(record.AsMap()["rating"]),
-				Review:     getStringPointer(record.AsMap()["review"]),
-				StartedAt:  getStringPointer(record.AsMap()["startedAt"]),
-				FinishedAt: getStringPointer(record.AsMap()["finishedAt"]),
-			}
-			return activity, nil
+			return activityFromRecord(id, result.Record()), nil
 		}
 
-		return nil, fmt.Errorf("activity not found")
+		return nil, NotFoundError("activity")
 	})
 
 	if err != nil {
@@ -278,6 +334,20 @@ func (r *Neo4jRepository) UpdateActivity(ctx context.Context, id uuid.UUID, stat
 	return result.(*model.UserActivity), nil
 }
 
+// finishedBeforeStart reports whether finishedAt falls before a stored
+// startedAt. Times that can't be read are left to @constraint.
+func finishedBeforeStart(startedAt *string, finishedAt string) bool {
+	if startedAt == nil {
+		return false
+	}
+	started, ok := getTime(*startedAt)
+	if !ok {
+		return false
+	}
+	finished, ok := getTime(finishedAt)
+	return ok && finished.Before(started)
+}
+
 // DeleteActivity deletes an activity
 func (r *Neo4jRepository) DeleteActivity(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
@@ -299,6 +369,23 @@ func (r *Neo4jRepository) DeleteActivity(ctx context.Context, id uuid.UUID) erro
 	return err
 }
 
+// activityFromRecord builds an activity from a record returning the core activity fields
+func activityFromRecord(id uuid.UUID, record *neo4j.Record) *model.UserActivity {
+	return &model.UserActivity{
+		ID:           id,
+		Status:       &model.ActivityStatus{ID: getInt32FromRecord(record, "statusId")},
+		Rating:       getFloat64Pointer(record.AsMap()["rating"]),
+		Review:       getStringPointer(record.AsMap()["review"]),
+		StartedAt:    getStringPointer(record.AsMap()["startedAt"]),
+		FinishedAt:   getStringPointer(record.AsMap()["finishedAt"]),
+		Rewatch:      getBoolPointer(record.AsMap()["rewatch"]),
+		Count:        getInt32Pointer(record.AsMap()["count"]),
+		Progress:     getFloat64Pointer(record.AsMap()["progress"]),
+		ProgressUnit: getStringPointer(record.AsMap()["progressUnit"]),
+		Version:      getInt32FromRecord(record, "version"),
+	}
+}
+
 // Helper functions
 func getFloat64Pointer(value interface{}) *float64 {
 	if value == nil {
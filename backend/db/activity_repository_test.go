@@ -0,0 +1,30 @@
+package db
+
+import "testing"
+
+func TestFinishedBeforeStart(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	tests := []struct {
+		startedAt  *string
+		finishedAt string
+		want       bool
+	}{
+		{ptr("2024-03-01T10:00:00Z"), "2024-03-01T09:59:59Z", true},
+		{ptr("2024-03-01T10:00:00Z"), "2024-03-01T10:00:00Z", false},
+		{ptr("2024-03-01T10:00:00Z"), "2024-03-02T00:00:00Z", false},
+		// Imports store dates without a time
+		{ptr("2024-03-01"), "2024-02-28T23:00:00Z", true},
+		{ptr("2024-03-01T12:00:00+02:00"), "2024-03-01T10:30:00Z", false},
+		{nil, "2024-03-01T10:00:00Z", false},
+		{ptr("someday"), "2024-03-01T10:00:00Z", false},
+	}
+	for _, tt := range tests {
+		if got := finishedBeforeStart(tt.startedAt, tt.finishedAt); got != tt.want {
+			started := "<nil>"
+			if tt.startedAt != nil {
+				started = *tt.startedAt
+			}
+			t.Errorf("finishedBeforeStart(%s, %s) = %v, want %v", started, tt.finishedAt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Activity status IDs, stored as statusId on UserActivity nodes
+const (
+	StatusPlanned    int32 = 1
+	StatusInProgress int32 = 2
+	StatusCompleted  int32 = 3
+	StatusDropped    int32 = 4
+	StatusOnHold     int32 = 5
+)
+
+// activityStatuses names each status ID
+var activityStatuses = map[int32]string{
+	StatusPlanned:    "Planned",
+	StatusInProgress: "In Progress",
+	StatusCompleted:  "Completed",
+	StatusDropped:    "Dropped",
+	StatusOnHold:     "On Hold",
+}
+
+// SeedActivityStatuses creates an ActivityStatus node for every status ID
+func (db *Database) SeedActivityStatuses(ctx context.Context) error {
+	rows := make([]map[string]any, 0, len(activityStatuses))
+	for id, name := range activityStatuses {
+		rows = append(rows, map[string]any{"id": id, "name": name})
+	}
+
+	_, err := db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			UNWIND $rows AS row
+			MERGE (s:ActivityStatus {id: row.id})
+			SET s.name = row.name
+		`
+
+		result, err := tx.Run(ctx, query, map[string]any{"rows": rows})
+		if err != nil {
+			return nil, err
+		}
+		return result.Consume(ctx)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to seed activity statuses: %w", err)
+	}
+
+	return nil
+}
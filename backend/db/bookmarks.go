@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// BookmarkHeader is the HTTP header used to hand bookmarks to clients and
+// to accept them back, giving read-your-writes consistency across requests
+const BookmarkHeader = "X-NQ-Bookmark"
+
+// Limits on the bookmarks a client can send. A client echoes back the few
+// bookmarks of its last request, so anything past these is dropped.
+const (
+	maxBookmarks      = 16
+	maxBookmarkLength = 512
+)
+
+type bookmarkManagerKey struct{}
+
+// bookmarkManager tracks the bookmarks of a context's sessions, remembering
+// which it was seeded with so they can be dropped if the database rejects
+// them
+type bookmarkManager struct {
+	mu        sync.Mutex
+	bookmarks map[string]bool
+	seeded    map[string]bool
+}
+
+// UpdateBookmarks replaces the bookmarks a session started from with those
+// it ended with
+func (m *bookmarkManager) UpdateBookmarks(ctx context.Context, previous, next neo4j.Bookmarks) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(next) == 0 {
+		return nil
+	}
+	for _, bookmark := range previous {
+		delete(m.bookmarks, bookmark)
+	}
+	for _, bookmark := range next {
+		m.bookmarks[bookmark] = true
+	}
+	return nil
+}
+
+// GetBookmarks returns the bookmarks sessions wait for
+func (m *bookmarkManager) GetBookmarks(ctx context.Context) (neo4j.Bookmarks, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bookmarks := make(neo4j.Bookmarks, 0, len(m.bookmarks))
+	for bookmark := range m.bookmarks {
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
+
+// dropSeeded forgets the bookmarks the manager was seeded with and reports
+// whether it still had any
+func (m *bookmarkManager) dropSeeded() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dropped := false
+	for bookmark := range m.seeded {
+		if m.bookmarks[bookmark] {
+			delete(m.bookmarks, bookmark)
+			dropped = true
+		}
+	}
+	m.seeded = nil
+	return dropped
+}
+
+// WithBookmarks returns a context whose sessions share a single bookmark
+// manager, seeded with the given bookmarks. Reads issued with the returned
+// context wait for every write made through it, even when they are routed
+// to a different cluster member. Seeded bookmarks the database rejects are
+// dropped rather than failing the transaction.
+func WithBookmarks(ctx context.Context, bookmarks neo4j.Bookmarks) context.Context {
+	manager := &bookmarkManager{bookmarks: map[string]bool{}, seeded: map[string]bool{}}
+	for _, bookmark := range bookmarks {
+		manager.bookmarks[bookmark] = true
+		manager.seeded[bookmark] = true
+	}
+	return context.WithValue(ctx, bookmarkManagerKey{}, manager)
+}
+
+// Bookmarks returns the latest bookmarks tracked for the context, if any
+func Bookmarks(ctx context.Context) neo4j.Bookmarks {
+	manager := bookmarkManagerFromContext(ctx)
+	if manager == nil {
+		return nil
+	}
+
+	bookmarks, err := manager.GetBookmarks(ctx)
+	if err != nil {
+		return nil
+	}
+	return bookmarks
+}
+
+func bookmarkManagerFromContext(ctx context.Context) *bookmarkManager {
+	manager, _ := ctx.Value(bookmarkManagerKey{}).(*bookmarkManager)
+	return manager
+}
+
+// BookmarkMiddleware scopes a bookmark manager to each HTTP request. Any
+// bookmarks the client echoes back in BookmarkHeader are used as the
+// starting point, and the bookmarks after the request's own writes are
+// returned in the same header, which is exposed to cross-origin scripts.
+func BookmarkMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithBookmarks(r.Context(), parseBookmarks(r.Header.Values(BookmarkHeader)))
+		next.ServeHTTP(&bookmarkResponseWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+	})
+}
+
+// parseBookmarks reads the bookmarks a client sent, given as repeated or
+// comma-separated header values. Empty, overlong and malformed values are
+// dropped, as are any past maxBookmarks.
+func parseBookmarks(values []string) neo4j.Bookmarks {
+	var bookmarks neo4j.Bookmarks
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, bookmark := range strings.Split(value, ",") {
+			bookmark = strings.TrimSpace(bookmark)
+			if bookmark == "" || len(bookmark) > maxBookmarkLength || !printableASCII(bookmark) || seen[bookmark] {
+				continue
+			}
+			if len(bookmarks) == maxBookmarks {
+				return bookmarks
+			}
+			seen[bookmark] = true
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	return bookmarks
+}
+
+// printableASCII reports whether s only has visible ASCII characters, as
+// bookmarks do
+func printableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// bookmarkResponseWriter adds the request's bookmarks to the response
+// headers just before they are sent
+type bookmarkResponseWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+}
+
+func (w *bookmarkResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Add("Access-Control-Expose-Headers", BookmarkHeader)
+		for _, bookmark := range neo4j.BookmarksToRawValues(Bookmarks(w.ctx)) {
+			w.Header().Add(BookmarkHeader, bookmark)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *bookmarkResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming transports keep working through the wrapper
+func (w *bookmarkResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestParseBookmarksDropsUnusableValues(t *testing.T) {
+	values := []string{
+		"FB:a, FB:b",
+		"",
+		"FB:a",
+		"FB:" + strings.Repeat("x", maxBookmarkLength),
+		"FB:bad value\x00",
+		"FB:c",
+	}
+	got := parseBookmarks(values)
+	if want := (neo4j.Bookmarks{"FB:a", "FB:b", "FB:c"}); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseBookmarksCapsCount(t *testing.T) {
+	var values []string
+	for i := range maxBookmarks * 4 {
+		values = append(values, fmt.Sprintf("FB:%d", i))
+	}
+	got := parseBookmarks(values)
+	if len(got) != maxBookmarks || got[0] != "FB:0" {
+		t.Errorf("got %d bookmarks starting with %q, want the first %d", len(got), got[0], maxBookmarks)
+	}
+}
+
+func TestBookmarkMiddlewareRoundTrip(t *testing.T) {
+	var seen neo4j.Bookmarks
+	handler := BookmarkMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = Bookmarks(r.Context())
+		// A write replaces the bookmarks it started from with its own
+		bookmarkManagerFromContext(r.Context()).UpdateBookmarks(r.Context(), seen, neo4j.Bookmarks{"FB:written"})
+		w.Write([]byte("{}"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Add(BookmarkHeader, "FB:client")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !slices.Equal(seen, neo4j.Bookmarks{"FB:client"}) {
+		t.Errorf("handler saw %q, want the client's bookmark", seen)
+	}
+	if got := rec.Header().Values(BookmarkHeader); !slices.Equal(got, []string{"FB:written"}) {
+		t.Errorf("responded with %q, want the write's bookmark", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != BookmarkHeader {
+		t.Errorf("exposed %q, want %s", got, BookmarkHeader)
+	}
+}
+
+func TestBookmarkManagerDropsSeededBookmarks(t *testing.T) {
+	ctx := WithBookmarks(context.Background(), neo4j.Bookmarks{"FB:client"})
+	manager := bookmarkManagerFromContext(ctx)
+
+	if !manager.dropSeeded() {
+		t.Fatalf("no seeded bookmarks to drop")
+	}
+	if got := Bookmarks(ctx); len(got) != 0 {
+		t.Errorf("got %q after dropping", got)
+	}
+	if manager.dropSeeded() {
+		t.Errorf("dropped seeded bookmarks twice, which would retry forever")
+	}
+
+	// Bookmarks of the request's own writes are never dropped
+	ctx = WithBookmarks(context.Background(), neo4j.Bookmarks{"FB:client"})
+	manager = bookmarkManagerFromContext(ctx)
+	manager.UpdateBookmarks(ctx, neo4j.Bookmarks{"FB:client"}, neo4j.Bookmarks{"FB:written"})
+	if manager.dropSeeded() {
+		t.Errorf("dropped a bookmark the request wrote")
+	}
+	if got := Bookmarks(ctx); !slices.Equal(got, neo4j.Bookmarks{"FB:written"}) {
+		t.Errorf("got %q, want the written bookmark", got)
+	}
+}
@@ -0,0 +1,242 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Connection is a user's connected account in an integration
+type Connection struct {
+	UserID   uuid.UUID
+	Provider string
+	// Account identifies the user in the provider, e.g. a SteamID, if the
+	// provider needs one
+	Account string
+	// Cursor is where the next incremental sync picks up. Its format is up to
+	// the provider; "" means sync everything.
+	Cursor       string
+	ConnectedAt  time.Time
+	LastSyncedAt *time.Time
+}
+
+// SaveConnection creates or updates a user's connection to a provider. The
+// sync cursor is kept when an existing connection is updated.
+func (r *Neo4jRepository) SaveConnection(ctx context.Context, conn *Connection) (*Connection, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (u:User {id: $userID})
+			MERGE (c:Connection {userId: $userID, provider: $provider})
+			ON CREATE SET c.connectedAt = datetime(), c.cursor = ""
+			MERGE (u)-[:HAS_CONNECTION]->(c)
+			SET c.account = $account, c.updatedAt = datetime()
+			RETURN c
+		`
+
+		params := map[string]any{
+			"userID":   conn.UserID.String(),
+			"provider": conn.Provider,
+			"account":  conn.Account,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return connectionFromRecord(conn.UserID, result.Record()), nil
+		}
+
+		return nil, NotFoundError("user")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Connection), nil
+}
+
+// GetConnection returns a user's connection to a provider
+func (r *Neo4jRepository) GetConnection(ctx context.Context, userID uuid.UUID, provider string) (*Connection, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (c:Connection {userId: $userID, provider: $provider})
+			RETURN c
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return connectionFromRecord(userID, result.Record()), nil
+		}
+
+		return nil, NotFoundError(provider + " connection")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Connection), nil
+}
+
+// GetConnections returns all of a user's connections, ordered by provider
+func (r *Neo4jRepository) GetConnections(ctx context.Context, userID uuid.UUID) ([]*Connection, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (:User {id: $userID})-[:HAS_CONNECTION]->(c:Connection)
+			RETURN c
+			ORDER BY c.provider
+		`
+
+		params := map[string]any{"userID": userID.String()}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		connections := []*Connection{}
+		for result.Next(ctx) {
+			connections = append(connections, connectionFromRecord(userID, result.Record()))
+		}
+
+		return connections, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*Connection), nil
+}
+
+// GetAllConnections returns every user's connection to a provider
+func (r *Neo4jRepository) GetAllConnections(ctx context.Context, provider string) ([]*Connection, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (u:User)-[:HAS_CONNECTION]->(c:Connection {provider: $provider})
+			RETURN u.id as userId, c
+			ORDER BY c.connectedAt
+		`
+
+		params := map[string]any{"provider": provider}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		connections := []*Connection{}
+		for result.Next(ctx) {
+			record := result.Record()
+			userID, err := uuid.Parse(getString(record.AsMap()["userId"]))
+			if err != nil {
+				continue
+			}
+			connections = append(connections, connectionFromRecord(userID, record))
+		}
+
+		return connections, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*Connection), nil
+}
+
+// SaveSyncCursor records a finished sync of a user's connection and the
+// cursor the next sync starts from
+func (r *Neo4jRepository) SaveSyncCursor(ctx context.Context, userID uuid.UUID, provider, cursor string) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (c:Connection {userId: $userID, provider: $provider})
+			SET c.cursor = $cursor, c.lastSyncedAt = datetime()
+			RETURN c.provider as provider
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+			"cursor":   cursor,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return nil, nil
+		}
+
+		return nil, NotFoundError(provider + " connection")
+	})
+
+	return err
+}
+
+// DeleteConnection removes a user's connection to a provider along with any
+// tokens and credentials stored for it. Imported media and activities are
+// kept.
+func (r *Neo4jRepository) DeleteConnection(ctx context.Context, userID uuid.UUID, provider string) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (c:Connection {userId: $userID, provider: $provider})
+			OPTIONAL MATCH (t:OAuthToken {userId: $userID, provider: $provider})
+			OPTIONAL MATCH (k:Credential {userId: $userID, provider: $provider})
+			DETACH DELETE c, t, k
+			RETURN count(*) as count
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) && getInt32FromRecord(result.Record(), "count") > 0 {
+			return nil, nil
+		}
+
+		return nil, NotFoundError(provider + " connection")
+	})
+
+	return err
+}
+
+// connectionFromRecord builds a connection from a record returning it as c
+func connectionFromRecord(userID uuid.UUID, record *neo4j.Record) *Connection {
+	node, _ := record.AsMap()["c"].(neo4j.Node)
+	conn := &Connection{
+		UserID:   userID,
+		Provider: getString(node.Props["provider"]),
+		Account:  getString(node.Props["account"]),
+		Cursor:   getString(node.Props["cursor"]),
+	}
+	if connectedAt, ok := node.Props["connectedAt"].(time.Time); ok {
+		conn.ConnectedAt = connectedAt
+	}
+	if lastSyncedAt, ok := node.Props["lastSyncedAt"].(time.Time); ok {
+		conn.LastSyncedAt = &lastSyncedAt
+	}
+	return conn
+}
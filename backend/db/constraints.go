@@ -21,6 +21,9 @@ func (db *Database) CreateConstraints(ctx context.Context) error {
 		"CREATE CONSTRAINT book_id_unique IF NOT EXISTS FOR (b:Book) REQUIRE b.id IS UNIQUE",
 		"CREATE CONSTRAINT game_id_unique IF NOT EXISTS FOR (g:Game) REQUIRE g.id IS UNIQUE",
 		"CREATE CONSTRAINT musicalbum_id_unique IF NOT EXISTS FOR (ma:MusicAlbum) REQUIRE ma.id IS UNIQUE",
+		"CREATE CONSTRAINT video_id_unique IF NOT EXISTS FOR (v:Video) REQUIRE v.id IS UNIQUE",
+		"CREATE CONSTRAINT article_id_unique IF NOT EXISTS FOR (a:Article) REQUIRE a.id IS UNIQUE",
+		"CREATE CONSTRAINT stream_id_unique IF NOT EXISTS FOR (s:Stream) REQUIRE s.id IS UNIQUE",
 
 		// Creator constraints
 		"CREATE CONSTRAINT creator_id_unique IF NOT EXISTS FOR (c:Creator) REQUIRE c.id IS UNIQUE",
@@ -41,6 +44,33 @@ func (db *Database) CreateConstraints(ctx context.Context) error {
 
 		// Recommendation constraints
 		"CREATE CONSTRAINT recommendation_id_unique IF NOT EXISTS FOR (r:Recommendation) REQUIRE r.id IS UNIQUE",
+
+		// External identifier constraints - one node per source and value
+		"CREATE CONSTRAINT external_id_unique IF NOT EXISTS FOR (x:ExternalID) REQUIRE (x.source, x.value) IS UNIQUE",
+
+		// Match review constraints - one review per imported media and candidate
+		"CREATE CONSTRAINT match_review_id_unique IF NOT EXISTS FOR (r:MatchReview) REQUIRE r.id IS UNIQUE",
+		"CREATE CONSTRAINT match_review_pair_unique IF NOT EXISTS FOR (r:MatchReview) REQUIRE (r.mediaId, r.candidateId) IS UNIQUE",
+
+		// Edition constraints - an ISBN names one edition
+		"CREATE CONSTRAINT edition_id_unique IF NOT EXISTS FOR (e:Edition) REQUIRE e.id IS UNIQUE",
+		"CREATE CONSTRAINT edition_isbn13_unique IF NOT EXISTS FOR (e:Edition) REQUIRE e.isbn13 IS UNIQUE",
+		"CREATE CONSTRAINT edition_isbn10_unique IF NOT EXISTS FOR (e:Edition) REQUIRE e.isbn10 IS UNIQUE",
+
+		// Media redirect constraints - a merged ID redirects to one media
+		"CREATE CONSTRAINT media_redirect_unique IF NOT EXISTS FOR (d:MediaRedirect) REQUIRE d.fromId IS UNIQUE",
+
+		// OAuth constraints - one token set per user and provider
+		"CREATE CONSTRAINT oauth_token_unique IF NOT EXISTS FOR (t:OAuthToken) REQUIRE (t.userId, t.provider) IS UNIQUE",
+		"CREATE CONSTRAINT credential_unique IF NOT EXISTS FOR (c:Credential) REQUIRE (c.userId, c.provider, c.name) IS UNIQUE",
+		"CREATE CONSTRAINT connection_unique IF NOT EXISTS FOR (c:Connection) REQUIRE (c.userId, c.provider) IS UNIQUE",
+		"CREATE CONSTRAINT oauth_state_unique IF NOT EXISTS FOR (s:OAuthState) REQUIRE s.state IS UNIQUE",
+		"CREATE CONSTRAINT track_unique IF NOT EXISTS FOR (t:Track) REQUIRE (t.albumId, t.title) IS UNIQUE",
+
+		// Job constraints - only unfinished jobs carry activeKey
+		"CREATE CONSTRAINT job_id_unique IF NOT EXISTS FOR (j:Job) REQUIRE j.id IS UNIQUE",
+		"CREATE CONSTRAINT job_active_key_unique IF NOT EXISTS FOR (j:Job) REQUIRE j.activeKey IS UNIQUE",
+		"CREATE CONSTRAINT job_schedule_unique IF NOT EXISTS FOR (s:JobSchedule) REQUIRE s.name IS UNIQUE",
 	}
 
 	for _, constraint := range constraints {
@@ -71,6 +101,11 @@ func (db *Database) CreateIndexes(ctx context.Context) error {
 		"CREATE INDEX book_title_index IF NOT EXISTS FOR (b:Book) ON (b.title)",
 		"CREATE INDEX game_title_index IF NOT EXISTS FOR (g:Game) ON (g.title)",
 		"CREATE INDEX musicalbum_title_index IF NOT EXISTS FOR (ma:MusicAlbum) ON (ma.title)",
+		"CREATE INDEX video_title_index IF NOT EXISTS FOR (v:Video) ON (v.title)",
+		"CREATE INDEX article_title_index IF NOT EXISTS FOR (a:Article) ON (a.title)",
+		"CREATE INDEX stream_title_index IF NOT EXISTS FOR (s:Stream) ON (s.title)",
+		"CREATE INDEX book_isbn_index IF NOT EXISTS FOR (b:Book) ON (b.isbn)",
+		"CREATE INDEX media_normalized_title_index IF NOT EXISTS FOR (m:Media) ON (m.normalizedTitle)",
 
 		// User indexes
 		"CREATE INDEX user_name_index IF NOT EXISTS FOR (u:User) ON (u.name)",
@@ -90,6 +125,8 @@ func (db *Database) CreateIndexes(ctx context.Context) error {
 		"CREATE INDEX activity_user_index IF NOT EXISTS FOR (a:UserActivity) ON (a.userId)",
 		"CREATE INDEX activity_media_index IF NOT EXISTS FOR (a:UserActivity) ON (a.mediaId)",
 		"CREATE INDEX activity_status_index IF NOT EXISTS FOR (a:UserActivity) ON (a.statusId)",
+		"CREATE INDEX activity_import_key_index IF NOT EXISTS FOR (a:UserActivity) ON (a.importKey)",
+		"CREATE INDEX listen_import_key_index IF NOT EXISTS FOR (l:Listen) ON (l.importKey)",
 
 		// Rating indexes
 		"CREATE INDEX rating_user_index IF NOT EXISTS FOR (r:Rating) ON (r.userId)",
@@ -99,6 +136,16 @@ func (db *Database) CreateIndexes(ctx context.Context) error {
 		// Recommendation indexes
 		"CREATE INDEX recommendation_user_index IF NOT EXISTS FOR (r:Recommendation) ON (r.userId)",
 		"CREATE INDEX recommendation_media_index IF NOT EXISTS FOR (r:Recommendation) ON (r.mediaId)",
+
+		// Match review indexes
+		"CREATE INDEX match_review_status_index IF NOT EXISTS FOR (r:MatchReview) ON (r.status)",
+
+		// Media redirect indexes
+		"CREATE INDEX media_redirect_target_index IF NOT EXISTS FOR (d:MediaRedirect) ON (d.toId)",
+
+		// Job indexes
+		"CREATE INDEX job_state_index IF NOT EXISTS FOR (j:Job) ON (j.state, j.runAfter)",
+		"CREATE INDEX job_created_index IF NOT EXISTS FOR (j:Job) ON (j.createdAt)",
 	}
 
 	for _, index := range indexes {
@@ -118,7 +165,7 @@ func (db *Database) CreateIndexes(ctx context.Context) error {
 	return nil
 }
 
-// InitializeDatabase creates all constraints and indexes
+// InitializeDatabase creates all constraints and indexes and seeds lookup nodes
 func (db *Database) InitializeDatabase(ctx context.Context) error {
 	if err := db.CreateConstraints(ctx); err != nil {
 		return fmt.Errorf("failed to create constraints: %w", err)
@@ -128,5 +175,21 @@ func (db *Database) InitializeDatabase(ctx context.Context) error {
 		return fmt.Errorf("failed to create indexes: %w", err)
 	}
 
+	if err := db.SeedActivityStatuses(ctx); err != nil {
+		return err
+	}
+
+	if err := db.SeedCreatorRoles(ctx); err != nil {
+		return err
+	}
+
+	if err := db.BackfillNormalizedTitles(ctx); err != nil {
+		return err
+	}
+
+	if err := db.BackfillEditions(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"nq/graph/model"
+	"slices"
+	"strings"
+)
+
+// linkCreatorsQuery credits creators on media. A creator is found by one of
+// its external IDs, by its name and role when it is already credited on the
+// media, or by its name and role on other media of the same type when only
+// one creator fits. Anyone else sharing a name gets a creator of their own,
+// since people with the same name are often different people.
+//
+// Rows come from creatorCredits, which gives each creator one row so that
+// creators new to a batch are created once.
+const linkCreatorsQuery = `
+	UNWIND $rows AS row
+	OPTIONAL MATCH (known:Creator)-[:IDENTIFIED_BY]->(x:ExternalID)
+	WHERE any(id IN row.externalIds WHERE id.source = x.source AND id.value = x.value)
+	WITH row, collect(DISTINCT known) AS known
+	OPTIONAL MATCH (credited:Creator {name: row.name})-[:CREATED {role: row.role}]->(m:Media)
+	WHERE m.id IN row.mediaIds
+	WITH row, known, collect(DISTINCT credited) AS credited
+	OPTIONAL MATCH (peer:Creator {name: row.name})-[:CREATED {role: row.role}]->(other:Media)
+	WHERE row.label IN labels(other)
+	  AND NOT EXISTS {
+		MATCH (peer)-[:IDENTIFIED_BY]->(px:ExternalID)
+		WHERE any(id IN row.externalIds WHERE id.source = px.source)
+	  }
+	WITH row, known + credited AS found, collect(DISTINCT peer) AS peers
+	WITH row, CASE
+		WHEN size(found) > 0 THEN found[0]
+		WHEN size(peers) = 1 THEN peers[0]
+	END AS found
+	CALL {
+		WITH row, found
+		WITH row, found WHERE found IS NULL
+		CREATE (c:Creator {id: randomUUID(), name: row.name})
+		RETURN c
+		UNION
+		WITH row, found
+		WITH found WHERE found IS NOT NULL
+		RETURN found AS c
+	}
+	FOREACH (id IN row.externalIds |
+		MERGE (x:ExternalID {source: id.source, value: id.value})
+		MERGE (c)-[:IDENTIFIED_BY]->(x)
+	)
+	WITH row, c
+	OPTIONAL MATCH (r:CreatorRole {name: row.role})
+	FOREACH (role IN CASE WHEN r IS NULL THEN [] ELSE [r] END | MERGE (c)-[:HAS_ROLE]->(role))
+	WITH row, c
+	UNWIND row.mediaIds AS mediaId
+	MATCH (m:Media {id: mediaId})
+	MERGE (c)-[:CREATED {role: row.role}]->(m)
+`
+
+// creatorCredits collects the rows of linkCreatorsQuery for media of one type
+type creatorCredits struct {
+	label string
+	rows  []map[string]any
+	byKey map[string]map[string]any
+}
+
+// newCreatorCredits collects credits on media with the given label
+func newCreatorCredits(label string) *creatorCredits {
+	return &creatorCredits{label: label, byKey: map[string]map[string]any{}}
+}
+
+// add credits a creator on media. Creators without a name are skipped.
+func (c *creatorCredits) add(mediaID string, creator *model.CreatorInput) {
+	name, role := strings.TrimSpace(creator.Name), strings.TrimSpace(creator.Role)
+	if name == "" {
+		return
+	}
+
+	externalIDs := make([]map[string]any, 0, len(creator.ExternalIds))
+	keys := make([]string, 0, len(creator.ExternalIds))
+	for _, externalID := range creator.ExternalIds {
+		source, value := normalizeSource(externalID.Source), strings.TrimSpace(externalID.Value)
+		if source != "" && value != "" {
+			externalIDs = append(externalIDs, map[string]any{"source": source, "value": value})
+			keys = append(keys, source+":"+value)
+		}
+	}
+	slices.Sort(keys)
+
+	key := name + "\x00" + role + "\x00" + strings.Join(keys, "\x00")
+	row, ok := c.byKey[key]
+	if !ok {
+		row = map[string]any{
+			"label":       c.label,
+			"name":        name,
+			"role":        role,
+			"externalIds": externalIDs,
+			"mediaIds":    []string{},
+		}
+		c.byKey[key] = row
+		c.rows = append(c.rows, row)
+	}
+	if mediaIDs := row["mediaIds"].([]string); !slices.Contains(mediaIDs, mediaID) {
+		row["mediaIds"] = append(mediaIDs, mediaID)
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"nq/graph/model"
+	"slices"
+	"testing"
+)
+
+func TestCreatorCreditsGroupsRowsByCreator(t *testing.T) {
+	credits := newCreatorCredits("Movie")
+	personID := []*model.ExternalIDInput{{Source: " TMDB-Person ", Value: " 1 "}}
+
+	credits.add("a", &model.CreatorInput{Name: " Jane Doe ", Role: "Director"})
+	credits.add("b", &model.CreatorInput{Name: "Jane Doe", Role: "Director"})
+	credits.add("b", &model.CreatorInput{Name: "Jane Doe", Role: "Director"})
+	// Another role, or an external ID, is another row
+	credits.add("a", &model.CreatorInput{Name: "Jane Doe", Role: "Creator"})
+	credits.add("c", &model.CreatorInput{Name: "Jane Doe", Role: "Director", ExternalIds: personID})
+	credits.add("c", &model.CreatorInput{Name: "  ", Role: "Director"})
+
+	if len(credits.rows) != 3 {
+		t.Fatalf("got rows %v, want 3", credits.rows)
+	}
+	first := credits.rows[0]
+	if first["name"] != "Jane Doe" || first["label"] != "Movie" || !slices.Equal(first["mediaIds"].([]string), []string{"a", "b"}) {
+		t.Errorf("got %v, want Jane Doe directing a and b", first)
+	}
+	ids := credits.rows[2]["externalIds"].([]map[string]any)
+	if len(ids) != 1 || ids[0]["source"] != "tmdb-person" || ids[0]["value"] != "1" {
+		t.Errorf("got external IDs %v", ids)
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Creator role IDs, stored on CreatorRole nodes
+const (
+	RoleDirector  int32 = 1
+	RoleAuthor    int32 = 2
+	RoleArtist    int32 = 3
+	RoleDeveloper int32 = 4
+	RoleCreator   int32 = 5
+	RoleChannel   int32 = 6
+	RoleStreamer  int32 = 7
+)
+
+// creatorRoles names each role ID, as imports and enrichers give them
+var creatorRoles = map[int32]string{
+	RoleDirector:  "Director",
+	RoleAuthor:    "Author",
+	RoleArtist:    "Artist",
+	RoleDeveloper: "Developer",
+	RoleCreator:   "Creator",
+	RoleChannel:   "Channel",
+	RoleStreamer:  "Streamer",
+}
+
+// IsCreatorRole reports whether a name is one of the seeded creator roles
+func IsCreatorRole(name string) bool {
+	for _, role := range creatorRoles {
+		if role == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SeedCreatorRoles creates a CreatorRole node for every role ID, and links
+// creators that still carry a bare role property to their role instead
+func (db *Database) SeedCreatorRoles(ctx context.Context) error {
+	rows := make([]map[string]any, 0, len(creatorRoles))
+	for id, name := range creatorRoles {
+		rows = append(rows, map[string]any{"id": id, "name": name})
+	}
+
+	_, err := db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		queries := []string{`
+			UNWIND $rows AS row
+			MERGE (r:CreatorRole {id: row.id})
+			SET r.name = row.name
+		`, `
+			MATCH (c:Creator)
+			WHERE c.role IS NOT NULL
+			OPTIONAL MATCH (r:CreatorRole {name: c.role})
+			FOREACH (role IN CASE WHEN r IS NULL THEN [] ELSE [r] END | MERGE (c)-[:HAS_ROLE]->(role))
+			REMOVE c.role
+		`}
+
+		for _, query := range queries {
+			result, err := tx.Run(ctx, query, map[string]any{"rows": rows})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := result.Consume(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to seed creator roles: %w", err)
+	}
+
+	return nil
+}
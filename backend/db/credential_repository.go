@@ -0,0 +1,311 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nq/vault"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// CredentialAPIKey names a user's own API key for a provider
+const CredentialAPIKey = "apiKey"
+
+// rotateBatchSize is how many nodes RotateSecrets re-encrypts per transaction
+const rotateBatchSize = 100
+
+// errVaultDisabled is returned when a secret is stored without a master key
+var errVaultDisabled = ValidationFailedError("storing credentials is not enabled on this server")
+
+// sealedNodes lists the nodes holding sealed secrets and their secret
+// properties. Both carry userId and provider; credentials also a name.
+var sealedNodes = []struct {
+	label  string
+	fields []string
+}{
+	{"OAuthToken", []string{"accessToken", "refreshToken"}},
+	{"Credential", []string{"secret"}},
+}
+
+// SaveCredential stores a user's secret for a provider, such as their own API
+// key, replacing any previous one. Credentials are never returned through the
+// API.
+func (r *Neo4jRepository) SaveCredential(ctx context.Context, userID uuid.UUID, provider, name, secret string) error {
+	sealed, err := r.seal(secret, "Credential", userID.String(), provider, name, "secret")
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (u:User {id: $userID})
+			MERGE (c:Credential {userId: $userID, provider: $provider, name: $name})
+			ON CREATE SET c.createdAt = datetime()
+			MERGE (u)-[:HAS_CREDENTIAL]->(c)
+			SET c.secret = $secret,
+			    c.keyId = $keyId,
+			    c.sealedAt = datetime(),
+			    c.updatedAt = datetime()
+			RETURN c.name as name
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+			"name":     name,
+			"secret":   sealed,
+			"keyId":    r.vault.CurrentKeyID(),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return nil, nil
+		}
+
+		return nil, NotFoundError("user")
+	})
+
+	return err
+}
+
+// GetCredential returns a user's secret for a provider
+func (r *Neo4jRepository) GetCredential(ctx context.Context, userID uuid.UUID, provider, name string) (string, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (c:Credential {userId: $userID, provider: $provider, name: $name})
+			RETURN c.secret as secret
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+			"name":     name,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return getString(result.Record().AsMap()["secret"]), nil
+		}
+
+		return nil, NotFoundError(provider + " " + name)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return r.open(result.(string), "Credential", userID.String(), provider, name, "secret")
+}
+
+// RotateSecrets re-encrypts the data keys of secrets sealed with a previous
+// master key under the current one, and seals secrets stored before they
+// were encrypted. With reencrypt, every secret also gets a new data key. It
+// returns how many nodes were updated; nodes that can't be opened, e.g.
+// because their master key was dropped from the keyring, are skipped and
+// reported in the error.
+func (r *Neo4jRepository) RotateSecrets(ctx context.Context, reencrypt bool) (int, error) {
+	if r.vault == nil {
+		return 0, errVaultDisabled
+	}
+
+	startedAt := time.Now()
+	rotated := 0
+	var failures []error
+	for _, node := range sealedNodes {
+		failed := []string{}
+		for {
+			batch, err := r.readSealedBatch(ctx, node.label, node.fields, reencrypt, startedAt, failed)
+			if err != nil {
+				return rotated, err
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			rows := make([]map[string]any, 0, len(batch))
+			for _, record := range batch {
+				values, err := r.rotateFields(node.label, record, node.fields, reencrypt)
+				if err != nil {
+					failed = append(failed, record["id"].(string))
+					failures = append(failures, fmt.Errorf("%s of user %v for %v: %w", node.label, record["userId"], record["provider"], err))
+					continue
+				}
+				rows = append(rows, map[string]any{"id": record["id"], "old": storedFields(record), "new": values})
+			}
+
+			count, err := r.writeSealedBatch(ctx, node.label, rows)
+			if err != nil {
+				return rotated, err
+			}
+			rotated += count
+		}
+	}
+
+	if len(failures) > 0 {
+		return rotated, fmt.Errorf("%d secrets could not be rotated: %w", len(failures), errors.Join(failures...))
+	}
+	return rotated, nil
+}
+
+// readSealedBatch reads the next nodes with a label that still need
+// rotating, leaving out those that failed
+func (r *Neo4jRepository) readSealedBatch(ctx context.Context, label string, fields []string, reencrypt bool, startedAt time.Time, failed []string) ([]map[string]any, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (n:` + label + `)
+			WHERE (n.keyId IS NULL OR n.keyId <> $keyId
+			       OR ($reencrypt AND (n.sealedAt IS NULL OR n.sealedAt < $startedAt)))
+			  AND NOT elementId(n) IN $failed
+			RETURN elementId(n) as id, n.userId as userId, n.provider as provider,
+			       n.name as name, n {` + "." + strings.Join(fields, ", .") + `} as values
+			LIMIT $limit
+		`
+
+		params := map[string]any{
+			"keyId":     r.vault.CurrentKeyID(),
+			"reencrypt": reencrypt,
+			"startedAt": startedAt,
+			"failed":    failed,
+			"limit":     rotateBatchSize,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		var batch []map[string]any
+		for result.Next(ctx) {
+			batch = append(batch, result.Record().AsMap())
+		}
+		return batch, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]map[string]any), nil
+}
+
+// rotateFields returns the rotated values of a node's secret properties
+func (r *Neo4jRepository) rotateFields(label string, record map[string]any, fields []string, reencrypt bool) (map[string]any, error) {
+	values, _ := record["values"].(map[string]any)
+	rotated := make(map[string]any, len(fields))
+	for _, field := range fields {
+		value, ok := values[field].(string)
+		if !ok {
+			continue
+		}
+
+		scope := secretContext(label, getString(record["userId"]), getString(record["provider"]), getString(record["name"]), field)
+		var err error
+		switch {
+		case !vault.IsSealed(value):
+			// Stored before secrets were encrypted
+			rotated[field], err = r.vault.Seal([]byte(value), scope)
+		case reencrypt:
+			rotated[field], err = r.vault.Reencrypt(value, scope)
+		default:
+			rotated[field], err = r.vault.Rewrap(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rotated, nil
+}
+
+// storedFields returns the secret properties a node had when it was read,
+// leaving out those that aren't set
+func storedFields(record map[string]any) map[string]any {
+	values, _ := record["values"].(map[string]any)
+	stored := make(map[string]any, len(values))
+	for field, value := range values {
+		if value != nil {
+			stored[field] = value
+		}
+	}
+	return stored
+}
+
+// writeSealedBatch stores rotated secrets. Nodes whose secrets changed since
+// they were read, e.g. by a token refresh, are left alone; they were sealed
+// with the current key anyway.
+func (r *Neo4jRepository) writeSealedBatch(ctx context.Context, label string, rows []map[string]any) (int, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			UNWIND $rows AS row
+			MATCH (n:` + label + `)
+			WHERE elementId(n) = row.id AND all(field IN keys(row.old) WHERE n[field] = row.old[field])
+			SET n += row.new, n.keyId = $keyId, n.sealedAt = datetime()
+			RETURN count(n) as count
+		`
+
+		params := map[string]any{
+			"rows":  rows,
+			"keyId": r.vault.CurrentKeyID(),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return int(getInt32FromRecord(result.Record(), "count")), nil
+		}
+		return 0, result.Err()
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.(int), nil
+}
+
+// seal encrypts a secret for a property of a node
+func (r *Neo4jRepository) seal(secret, label, userID, provider, name, field string) (string, error) {
+	if r.vault == nil {
+		return "", errVaultDisabled
+	}
+	return r.vault.Seal([]byte(secret), secretContext(label, userID, provider, name, field))
+}
+
+// open decrypts a secret read from a property of a node. Values stored before
+// secrets were encrypted are returned as they are until RotateSecrets seals
+// them.
+func (r *Neo4jRepository) open(value, label, userID, provider, name, field string) (string, error) {
+	if !vault.IsSealed(value) {
+		return value, nil
+	}
+
+	plaintext, err := r.vault.Open(value, secretContext(label, userID, provider, name, field))
+	if err != nil {
+		return "", fmt.Errorf("%s %s for %s: %w", label, field, provider, err)
+	}
+	return string(plaintext), nil
+}
+
+// secretContext binds a sealed secret to the node and property it is stored
+// in, so it can't be opened after being copied elsewhere
+func secretContext(label, userID, provider, name, field string) []byte {
+	return []byte(strings.Join([]string{label, userID, provider, name, field}, "/"))
+}
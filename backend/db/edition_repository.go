@@ -0,0 +1,327 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nq/graph/model"
+	"nq/isbn"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// editionReturn returns an edition as e with the ID of its book
+const editionReturn = `
+	RETURN e.id as id, b.id as bookId, e.title as title, e.isbn13 as isbn13,
+	       e.isbn10 as isbn10, e.publisher as publisher, e.pages as pages,
+	       e.releaseDate as releaseDate, e.format as format
+`
+
+// linkEditionQuery adds an edition for each row's ISBN to the book row.id,
+// unless the ISBN already belongs to another book. A book without an ISBN
+// takes the edition's, along with its publisher and page count when it has
+// none.
+const linkEditionQuery = `
+	UNWIND $rows AS row
+	MATCH (b:Book {id: row.id})
+	MERGE (e:Edition {isbn13: row.isbn13})
+	ON CREATE SET e.id = randomUUID(), e.isbn10 = row.isbn10, e.publisher = row.publisher,
+	              e.pages = row.pages, e.releaseDate = row.releaseDate, e.createdAt = datetime()
+	WITH b, e, row
+	WHERE NOT EXISTS { MATCH (e)-[:EDITION_OF]->(other:Book) WHERE other <> b }
+	MERGE (e)-[:EDITION_OF]->(b)
+	FOREACH (_ IN CASE WHEN b.isbn IS NULL OR b.isbn = row.isbn13 THEN [1] ELSE [] END |
+		SET b.isbn = row.isbn13, b.isbn10 = row.isbn10,
+		    b.publisher = coalesce(b.publisher, row.publisher), b.pages = coalesce(b.pages, row.pages))
+`
+
+// editionFields are the book properties that describe one of its editions
+var editionFields = []string{"isbn", "isbn10", "publisher", "pages"}
+
+// canonicalISBN returns the ISBN-13 and ISBN-10 forms of an ISBN as property
+// values, nil when the ISBN is unset or, for the ISBN-10, when it has none
+func canonicalISBN(raw *string) (any, any, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return nil, nil, nil
+	}
+
+	isbn13, ok := isbn.To13(*raw)
+	if !ok {
+		return nil, nil, ValidationFailedError("isbn %q is not a valid ISBN-10 or ISBN-13", *raw)
+	}
+	if isbn10, ok := isbn.To10(*raw); ok {
+		return isbn13, isbn10, nil
+	}
+	return isbn13, nil, nil
+}
+
+// GetBookByISBN returns the book with an edition with an ISBN-10 or ISBN-13
+func (r *Neo4jRepository) GetBookByISBN(ctx context.Context, raw string) (*model.Book, error) {
+	isbn13, _, err := canonicalISBN(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Books stored before editions existed, and not backfilled because
+		// another book had their ISBN, are found by their own isbn
+		query := `
+			OPTIONAL MATCH (:Edition {isbn13: $isbn})-[:EDITION_OF]->(edition:Book)
+			OPTIONAL MATCH (own:Book {isbn: $isbn})
+			WITH coalesce(edition, own) AS b
+			WHERE b IS NOT NULL
+			RETURN labels(b) as labels, properties(b) as props
+			LIMIT 1
+		`
+
+		result, err := tx.Run(ctx, query, map[string]any{"isbn": isbn13})
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			record := result.Record()
+			props, _ := record.AsMap()["props"].(map[string]any)
+			if book, ok := mediaFromNode(getStringSlice(record.AsMap()["labels"]), props).(*model.Book); ok {
+				return book, nil
+			}
+		}
+
+		return nil, NotFoundError("book")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.Book), nil
+}
+
+// AddEdition adds an edition to a book. A book without an ISBN takes the
+// edition's as its own.
+func (r *Neo4jRepository) AddEdition(ctx context.Context, input model.AddEditionInput) (*model.Edition, error) {
+	isbn13, isbn10, err := canonicalISBN(&input.Isbn)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Lock the book, so a concurrent merge or edition can't slip in
+		// between the check and the write
+		result, err := tx.Run(ctx, `
+			MATCH (b:Book {id: $bookId})
+			SET b.editionLock = true
+			REMOVE b.editionLock
+			WITH b
+			OPTIONAL MATCH (e:Edition {isbn13: $isbn})-[:EDITION_OF]->(owner:Book)
+			RETURN owner.id as ownerId
+		`, map[string]any{"bookId": input.BookID.String(), "isbn": isbn13})
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+			return nil, NotFoundError("book")
+		}
+		switch ownerID := getString(result.Record().AsMap()["ownerId"]); {
+		case ownerID == input.BookID.String():
+			return nil, ConflictError("the book already has an edition with ISBN %s", isbn13)
+		case ownerID != "":
+			return nil, ConflictError("ISBN %s belongs to an edition of book %s", isbn13, ownerID)
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return nil, err
+		}
+
+		query := `
+			MATCH (b:Book {id: $bookId})
+			CREATE (e:Edition {
+				id: $id,
+				title: $title,
+				isbn13: $isbn13,
+				isbn10: $isbn10,
+				publisher: $publisher,
+				pages: $pages,
+				releaseDate: $releaseDate,
+				format: $format,
+				createdAt: datetime()
+			})-[:EDITION_OF]->(b)
+			FOREACH (_ IN CASE WHEN b.isbn IS NULL THEN [1] ELSE [] END |
+				SET b.isbn = $isbn13, b.isbn10 = $isbn10,
+				    b.publisher = coalesce(b.publisher, $publisher), b.pages = coalesce(b.pages, $pages),
+				    b.updatedAt = datetime())
+			WITH e, b
+		` + editionReturn
+
+		params := map[string]any{
+			"bookId":      input.BookID.String(),
+			"id":          uuid.New().String(),
+			"title":       input.Title,
+			"isbn13":      isbn13,
+			"isbn10":      isbn10,
+			"publisher":   input.Publisher,
+			"pages":       input.Pages,
+			"releaseDate": input.ReleaseDate,
+			"format":      input.Format,
+		}
+
+		result, err = tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return editionFromRecord(result.Record()), nil
+		}
+
+		return nil, fmt.Errorf("failed to add edition")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.Edition), nil
+}
+
+// GetEditions returns the editions of a book, oldest first
+func (r *Neo4jRepository) GetEditions(ctx context.Context, bookID uuid.UUID) ([]*model.Edition, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (e:Edition)-[:EDITION_OF]->(b:Book {id: $bookId})
+			WITH e, b
+			ORDER BY e.releaseDate IS NULL, e.releaseDate, e.createdAt
+		` + editionReturn
+
+		result, err := tx.Run(ctx, query, map[string]any{"bookId": bookID.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		editions := []*model.Edition{}
+		for result.Next(ctx) {
+			editions = append(editions, editionFromRecord(result.Record()))
+		}
+
+		return editions, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.Edition), nil
+}
+
+// editionFromRecord builds an edition from a record returned by
+// editionReturn
+func editionFromRecord(record *neo4j.Record) *model.Edition {
+	values := record.AsMap()
+	edition := &model.Edition{
+		Title:       getStringPointer(values["title"]),
+		Isbn13:      getString(values["isbn13"]),
+		Isbn10:      getStringPointer(values["isbn10"]),
+		Publisher:   getStringPointer(values["publisher"]),
+		Pages:       getInt32Pointer(values["pages"]),
+		ReleaseDate: getStringPointer(values["releaseDate"]),
+		Format:      getStringPointer(values["format"]),
+	}
+	edition.ID, _ = uuid.Parse(getString(values["id"]))
+	edition.BookID, _ = uuid.Parse(getString(values["bookId"]))
+	return edition
+}
+
+// BackfillEditions normalizes the ISBNs of books stored before editions
+// existed to ISBN-13 and ISBN-10 and gives each its edition. Books whose
+// ISBN is invalid, or already belongs to another book's edition, keep their
+// ISBN without an edition.
+func (db *Database) BackfillEditions(ctx context.Context) error {
+	after := ""
+	for {
+		last, err := db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			result, err := tx.Run(ctx, `
+				MATCH (b:Book)
+				WHERE b.isbn IS NOT NULL AND b.id > $after
+				  AND NOT EXISTS { (:Edition)-[:EDITION_OF]->(b) }
+				RETURN b.id AS id, b.isbn AS isbn, b.publisher AS publisher,
+				       b.pages AS pages, b.releaseDate AS releaseDate
+				ORDER BY b.id
+				LIMIT $limit
+			`, map[string]any{"after": after, "limit": DefaultImportBatchSize})
+			if err != nil {
+				return nil, err
+			}
+
+			var rows []map[string]any
+			last := ""
+			for result.Next(ctx) {
+				values := result.Record().AsMap()
+				last = getString(values["id"])
+				raw := getString(values["isbn"])
+				isbn13, isbn10, err := canonicalISBN(&raw)
+				if err != nil {
+					continue
+				}
+				rows = append(rows, map[string]any{
+					"id":          last,
+					"isbn13":      isbn13,
+					"isbn10":      isbn10,
+					"publisher":   values["publisher"],
+					"pages":       values["pages"],
+					"releaseDate": values["releaseDate"],
+				})
+			}
+			if err := result.Err(); err != nil || len(rows) == 0 {
+				return last, err
+			}
+
+			// Normalize the book's own ISBN first, so linkEditionQuery
+			// recognizes the edition as its own
+			result, err = tx.Run(ctx, `
+				UNWIND $rows AS row
+				MATCH (b:Book {id: row.id})
+				SET b.isbn = row.isbn13, b.isbn10 = row.isbn10
+			`, map[string]any{"rows": rows})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := result.Consume(ctx); err != nil {
+				return nil, err
+			}
+
+			result, err = tx.Run(ctx, linkEditionQuery, map[string]any{"rows": rows})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := result.Consume(ctx); err != nil {
+				return nil, err
+			}
+			return last, nil
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to backfill editions: %w", err)
+		}
+		if last.(string) == "" {
+			return nil
+		}
+		after = last.(string)
+	}
+}
+
+// editionRow is a linkEditionQuery row for the edition a book's properties
+// describe
+func editionRow(bookID string, props map[string]any, releaseDate any) map[string]any {
+	return map[string]any{
+		"id":          bookID,
+		"isbn13":      props["isbn"],
+		"isbn10":      props["isbn10"],
+		"publisher":   props["publisher"],
+		"pages":       props["pages"],
+		"releaseDate": releaseDate,
+	}
+}
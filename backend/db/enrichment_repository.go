@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nq/graph/model"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// UserFieldSource is the source of fields entered by hand. Enrichment never
+// overwrites them.
+const UserFieldSource = "user"
+
+// CreatorsField names the creators in field sources
+const CreatorsField = "creators"
+
+// commonEnrichableFields can be filled in by enrichment on every media type.
+// releaseYear holds the year of media whose exact release date is unknown.
+var commonEnrichableFields = []string{"description", "coverUrl", "releaseDate", "releaseYear"}
+
+// enrichableFields are the type-specific fields enrichment can fill in, by
+// node label
+var enrichableFields = map[string][]string{
+	"Movie":      {"runtime", "budget", "boxOffice"},
+	"TVShow":     {"seasons", "episodes", "status"},
+	"Book":       {"pages", "publisher"},
+	"Game":       {"genre", "esrbRating", "multiplayer"},
+	"MusicAlbum": {"trackCount", "duration", "label"},
+}
+
+// EnrichmentTarget is what enrichers know a media item by
+type EnrichmentTarget struct {
+	ID    uuid.UUID
+	Type  model.MediaType
+	Title string
+	// Year is the year of release, or "" when unknown
+	Year     string
+	ISBN     string
+	Creators []string
+	// ExternalIDs maps sources onto the media's identifiers in them
+	ExternalIDs map[string]string
+}
+
+// Enrichment is metadata an enricher found for a media item
+type Enrichment struct {
+	// Source names the enricher, e.g. "tmdb"
+	Source string
+	// Fields holds node properties, e.g. "runtime", by name. Fields that
+	// don't apply to the media type are ignored.
+	Fields      map[string]any
+	Creators    []*model.CreatorInput
+	ExternalIDs []*model.ExternalIDInput
+}
+
+// GetEnrichmentTarget returns what enrichers need to look a media item up,
+// following the redirect of media merged since
+func (r *Neo4jRepository) GetEnrichmentTarget(ctx context.Context, id uuid.UUID) (*EnrichmentTarget, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (m:Media {id: $id})
+			RETURN labels(m) AS labels, m.title AS title,
+			       coalesce(left(m.releaseDate, 4), toString(m.releaseYear)) AS year,
+			       m.isbn AS isbn,
+			       [(c:Creator)-[:CREATED]->(m) | c.name] AS creators,
+			       [(m)-[:IDENTIFIED_BY]->(x:ExternalID) | [x.source, x.value]] AS externalIds
+		`
+
+		result, err := tx.Run(ctx, query, map[string]any{"id": id.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			return nil, result.Err()
+		}
+
+		values := result.Record().AsMap()
+		target := &EnrichmentTarget{
+			ID:          id,
+			Title:       getString(values["title"]),
+			Year:        getString(values["year"]),
+			ISBN:        getString(values["isbn"]),
+			Creators:    getStringSlice(values["creators"]),
+			ExternalIDs: map[string]string{},
+		}
+		labels := getStringSlice(values["labels"])
+		for mediaType, label := range mediaLabels {
+			if slices.Contains(labels, label) {
+				target.Type = mediaType
+			}
+		}
+		pairs, _ := values["externalIds"].([]any)
+		for _, pair := range pairs {
+			if parts := getStringSlice(pair); len(parts) == 2 {
+				target.ExternalIDs[parts[0]] = parts[1]
+			}
+		}
+		return target, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return result.(*EnrichmentTarget), nil
+	}
+
+	target, err := r.mediaRedirect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if target == uuid.Nil {
+		return nil, NotFoundError("media")
+	}
+	return r.GetEnrichmentTarget(ctx, target)
+}
+
+// ApplyEnrichment writes what an enricher found onto a media item and returns
+// the fields it filled in. A field is only written when it is empty or was
+// filled in by enrichment before, so fields entered by hand or imported are
+// kept. Creators are only added to media that has none, and external IDs
+// only when no other media has them. Each field written records the
+// enrichment's source.
+func (r *Neo4jRepository) ApplyEnrichment(ctx context.Context, id uuid.UUID, enrichment *Enrichment) ([]string, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Lock the node before reading it, so concurrent edits can't slip in
+		// between the read and the write
+		result, err := tx.Run(ctx, `
+			MATCH (m:Media {id: $id})
+			SET m.enrichLock = true
+			REMOVE m.enrichLock
+			RETURN labels(m) AS labels, properties(m) AS props,
+			       EXISTS { (:Creator)-[:CREATED]->(m) } AS hasCreators
+		`, map[string]any{"id": id.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+			return nil, NotFoundError("media")
+		}
+		values := result.Record().AsMap()
+		props, _ := values["props"].(map[string]any)
+		hasCreators, _ := values["hasCreators"].(bool)
+
+		labels := getStringSlice(values["labels"])
+		sources := parseFieldSources(props["fieldSources"])
+		set, written := enrichedFields(props, labels, sources, enrichment)
+
+		creators := newCreatorCredits(mediaLabel(labels))
+		if !hasCreators {
+			for _, creator := range enrichment.Creators {
+				creators.add(id.String(), creator)
+			}
+			if len(creators.rows) > 0 {
+				sources[CreatorsField] = enrichment.Source
+				written = append(written, CreatorsField)
+			}
+		}
+
+		externalIDs := make([]map[string]any, 0, len(enrichment.ExternalIDs))
+		for _, externalID := range enrichment.ExternalIDs {
+			source, value := normalizeSource(externalID.Source), strings.TrimSpace(externalID.Value)
+			if source != "" && value != "" {
+				externalIDs = append(externalIDs, map[string]any{"source": source, "value": value})
+			}
+		}
+
+		params := map[string]any{
+			"id":           id.String(),
+			"set":          set,
+			"fieldSources": formatFieldSources(sources),
+			"rows":         creators.rows,
+			"externalIds":  externalIDs,
+		}
+
+		queries := []string{`
+			MATCH (m:Media {id: $id})
+			SET m += $set, m.fieldSources = $fieldSources, m.enrichedAt = datetime()
+			FOREACH (_ IN CASE WHEN size(keys($set)) > 0 THEN [1] ELSE [] END | SET m.updatedAt = datetime())
+		`, linkCreatorsQuery, `
+			MATCH (e:Edition)-[:EDITION_OF]->(m:Book {id: $id})
+			WHERE e.isbn13 = m.isbn
+			SET e.publisher = coalesce(e.publisher, m.publisher), e.pages = coalesce(e.pages, m.pages)
+		`, `
+			MATCH (m:Media {id: $id})
+			UNWIND $externalIds AS row
+			MERGE (x:ExternalID {source: row.source, value: row.value})
+			WITH m, x
+			WHERE NOT EXISTS { MATCH (other:Media)-[:IDENTIFIED_BY]->(x) WHERE other <> m }
+			MERGE (m)-[:IDENTIFIED_BY]->(x)
+		`}
+
+		for _, query := range queries {
+			result, err := tx.Run(ctx, query, params)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := result.Consume(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		return written, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]string), nil
+}
+
+// enrichedFields picks the fields an enrichment writes to media with props
+// and labels: those the media doesn't have yet, and those an enricher filled
+// in before that now have another value. Fields the user entered, or that
+// were set before sources were recorded, are left alone. The enrichment is
+// recorded in sources as the source of the fields it writes.
+func enrichedFields(props map[string]any, labels []string, sources map[string]string, enrichment *Enrichment) (map[string]any, []string) {
+	allowed := commonEnrichableFields
+	for _, label := range labels {
+		allowed = slices.Concat(allowed, enrichableFields[label])
+	}
+
+	set := map[string]any{}
+	var written []string
+	for _, field := range allowed {
+		value, ok := enrichment.Fields[field]
+		if !ok || isEmptyValue(value) {
+			continue
+		}
+		if current := props[field]; !isEmptyValue(current) {
+			source := sources[field]
+			if source == "" || source == UserFieldSource || fmt.Sprint(current) == fmt.Sprint(value) {
+				continue
+			}
+		}
+		set[field] = value
+		sources[field] = enrichment.Source
+		written = append(written, field)
+	}
+	return set, written
+}
+
+// GetFieldSources returns where each of a media item's recorded fields came
+// from, ordered by field
+func (r *Neo4jRepository) GetFieldSources(ctx context.Context, id uuid.UUID) ([]*model.FieldSource, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, `
+			MATCH (m:Media {id: $id})
+			RETURN m.fieldSources AS fieldSources
+		`, map[string]any{"id": id.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+			return nil, NotFoundError("media")
+		}
+
+		sources := parseFieldSources(result.Record().AsMap()["fieldSources"])
+		fields := make([]*model.FieldSource, 0, len(sources))
+		for field, source := range sources {
+			fields = append(fields, &model.FieldSource{Field: field, Source: source})
+		}
+		slices.SortFunc(fields, func(a, b *model.FieldSource) int {
+			return strings.Compare(a.Field, b.Field)
+		})
+		return fields, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.FieldSource), nil
+}
+
+// userFieldSources records the fields a create mutation was given as
+// entered by the user
+func userFieldSources(params map[string]any, fields ...string) []string {
+	sources := []string{}
+	for _, field := range fields {
+		if !isEmptyValue(params[field]) {
+			sources = append(sources, field+":"+UserFieldSource)
+		}
+	}
+	return sources
+}
+
+// parseFieldSources reads the fieldSources property, stored as a list of
+// "field:source" strings
+func parseFieldSources(value any) map[string]string {
+	sources := map[string]string{}
+	for _, entry := range getStringSlice(value) {
+		if field, source, ok := strings.Cut(entry, ":"); ok {
+			sources[field] = source
+		}
+	}
+	return sources
+}
+
+// formatFieldSources turns field sources back into their stored form
+func formatFieldSources(sources map[string]string) []string {
+	entries := make([]string, 0, len(sources))
+	for field, source := range sources {
+		entries = append(entries, field+":"+source)
+	}
+	slices.Sort(entries)
+	return entries
+}
+
+// isEmptyValue reports whether a property value is unset: nil, a nil
+// pointer, or an empty string or list
+func isEmptyValue(value any) bool {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Pointer:
+		return v.IsNil() || isEmptyValue(v.Elem().Interface())
+	case reflect.String, reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
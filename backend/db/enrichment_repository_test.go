@@ -0,0 +1,87 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEnrichedFieldsKeepsUserFields(t *testing.T) {
+	props := map[string]any{
+		"title":       "The Matrix",
+		"description": "Typed in by the user",
+		"coverUrl":    "https://example.com/old.jpg",
+		"runtime":     int64(130),
+		"budget":      int64(60000000),
+		"boxOffice":   int64(400000000),
+	}
+	sources := map[string]string{
+		"description": UserFieldSource,
+		"coverUrl":    "tmdb",
+		"boxOffice":   "tmdb",
+		// runtime has no source: it was set before sources were recorded
+	}
+	enrichment := &Enrichment{
+		Source: "tmdb",
+		Fields: map[string]any{
+			"description": "From TMDB",
+			"coverUrl":    "https://example.com/new.jpg",
+			"runtime":     int32(136),
+			"budget":      int32(63000000),
+			"boxOffice":   int32(400000000),
+			"releaseDate": "1999-03-31",
+			// Not a movie field, so it is ignored
+			"pages": int32(100),
+		},
+	}
+
+	set, written := enrichedFields(props, []string{"Media", "Movie"}, sources, enrichment)
+
+	slices.Sort(written)
+	if want := []string{"coverUrl", "releaseDate"}; !slices.Equal(written, want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+	if set["coverUrl"] != "https://example.com/new.jpg" || set["releaseDate"] != "1999-03-31" {
+		t.Errorf("set %v", set)
+	}
+	for _, field := range []string{"description", "runtime", "budget", "boxOffice", "pages"} {
+		if _, ok := set[field]; ok {
+			t.Errorf("overwrote %s", field)
+		}
+	}
+
+	want := map[string]string{
+		"description": UserFieldSource,
+		"coverUrl":    "tmdb",
+		"boxOffice":   "tmdb",
+		"releaseDate": "tmdb",
+	}
+	if len(sources) != len(want) {
+		t.Errorf("got sources %v, want %v", sources, want)
+	}
+	for field, source := range want {
+		if sources[field] != source {
+			t.Errorf("got source %q for %s, want %q", sources[field], field, source)
+		}
+	}
+}
+
+func TestEnrichedFieldsRefreshesOtherEnrichersFields(t *testing.T) {
+	props := map[string]any{"description": "From Open Library", "pages": int64(500)}
+	sources := map[string]string{"description": "openlibrary", "pages": "openlibrary"}
+	enrichment := &Enrichment{
+		Source: "googlebooks",
+		Fields: map[string]any{"description": "", "pages": int32(531), "publisher": "HarperCollins"},
+	}
+
+	set, _ := enrichedFields(props, []string{"Media", "Book"}, sources, enrichment)
+
+	if _, ok := set["description"]; ok {
+		t.Errorf("an empty value cleared the description")
+	}
+	if set["pages"] != int32(531) || set["publisher"] != "HarperCollins" {
+		t.Errorf("set %v, want pages refreshed and publisher filled in", set)
+	}
+	if sources["pages"] != "googlebooks" || sources["description"] != "openlibrary" {
+		t.Errorf("got sources %v", sources)
+	}
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Error kinds returned by the repository. Check for them with errors.Is.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrValidation    = errors.New("validation failed")
+	ErrConflict      = errors.New("conflict")
+	ErrUnavailable   = errors.New("database unavailable")
+)
+
+// Error is a repository error tagged with one of the error kinds above
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+	// Current holds the latest stored state for conflict errors, so
+	// clients can merge their changes and retry
+	Current any
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Kind.Error()
+}
+
+// Unwrap exposes both the kind and the underlying cause to errors.Is and errors.As
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// NotFoundError reports that the named entity does not exist
+func NotFoundError(entity string) error {
+	return &Error{Kind: ErrNotFound, Message: entity + " not found"}
+}
+
+// AlreadyExistsError reports that a write would duplicate an existing entity
+func AlreadyExistsError(format string, args ...any) error {
+	return &Error{Kind: ErrAlreadyExists, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationFailedError reports input the repository refused to store
+func ValidationFailedError(format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+// ConflictError reports a write that lost a race with another writer
+func ConflictError(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// VersionConflictError reports an update made against a stale version of
+// the named entity, carrying the entity's current state
+func VersionConflictError(entity string, current any) error {
+	return &Error{
+		Kind:    ErrConflict,
+		Message: entity + " was modified by someone else; merge with the current version and retry",
+		Current: current,
+	}
+}
+
+// UnavailableError reports that the database could not serve the request
+func UnavailableError(err error) error {
+	return &Error{Kind: ErrUnavailable, Message: "database unavailable, try again later", Err: err}
+}
+
+// constraintMessagePattern pulls the label and property out of a uniqueness
+// violation, e.g. "Node(12) already exists with label `User` and property `email` = 'a@b.c'"
+var constraintMessagePattern = regexp.MustCompile("label `(\\w+)` and propert(?:y|ies) `?([\\w, `]+?)`? =")
+
+// translateError maps driver errors onto the repository error kinds.
+// Errors that are already typed, or that have no better kind, pass through.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var repoErr *Error
+	if errors.As(err, &repoErr) {
+		return err
+	}
+
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) {
+		switch {
+		case neo4jErr.Code == "Neo.ClientError.Schema.ConstraintValidationFailed":
+			return &Error{Kind: ErrAlreadyExists, Message: constraintMessage(neo4jErr.Msg), Err: err}
+		case strings.HasPrefix(neo4jErr.Code, "Neo.TransientError."),
+			strings.HasPrefix(neo4jErr.Code, "Neo.ClientError.Cluster."),
+			neo4jErr.Code == "Neo.ClientError.General.ForbiddenReadOnlyDatabase",
+			neo4jErr.Code == "Neo.ClientError.Database.DatabaseUnavailable":
+			return UnavailableError(err)
+		}
+		return err
+	}
+
+	var connectivityErr *neo4j.ConnectivityError
+	var limitErr *neo4j.TransactionExecutionLimit
+	if errors.As(err, &connectivityErr) || errors.As(err, &limitErr) {
+		return UnavailableError(err)
+	}
+
+	return err
+}
+
+// constraintMessage turns a constraint violation into a message safe to show clients
+func constraintMessage(msg string) string {
+	match := constraintMessagePattern.FindStringSubmatch(msg)
+	if match == nil {
+		return "a record with the same unique fields already exists"
+	}
+
+	property := strings.Trim(strings.ReplaceAll(match[2], "`", ""), " ")
+	return fmt.Sprintf("%s with this %s already exists", strings.ToLower(match[1]), property)
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// FollowImport is a creator a user follows in another service, such as a
+// Twitch channel. Creators are matched on their external ID, linked to the
+// game they stream when it has been imported with GameSource and GameID, and
+// credited on the media imported with MediaSource and MediaIDs.
+type FollowImport struct {
+	Source     string
+	ExternalID string
+	Name       string
+	// Role links the creator to a CreatorRole, such as Streamer, whether the
+	// import creates the creator or finds it
+	Role        string
+	FollowedAt  *string
+	GameSource  string
+	GameID      string
+	MediaSource string
+	MediaIDs    []string
+}
+
+// ImportFollows creates or updates followed creators and the user's follows
+// of them, and returns how many follows were written
+func (r *Neo4jRepository) ImportFollows(ctx context.Context, userID uuid.UUID, follows []*FollowImport) (int, error) {
+	rows := make([]map[string]any, 0, len(follows))
+	for _, follow := range follows {
+		if follow.Role != "" && !IsCreatorRole(follow.Role) {
+			return 0, ValidationFailedError("unknown creator role %q", follow.Role)
+		}
+		rows = append(rows, map[string]any{
+			"source":      normalizeSource(follow.Source),
+			"externalId":  follow.ExternalID,
+			"name":        follow.Name,
+			"role":        follow.Role,
+			"followedAt":  follow.FollowedAt,
+			"gameSource":  normalizeSource(follow.GameSource),
+			"gameId":      follow.GameID,
+			"mediaSource": normalizeSource(follow.MediaSource),
+			"mediaIds":    follow.MediaIDs,
+		})
+	}
+
+	query := `
+		MATCH (u:User {id: $userID})
+		UNWIND $rows AS row
+		MERGE (x:ExternalID {source: row.source, value: row.externalId})
+		MERGE (c:Creator)-[:IDENTIFIED_BY]->(x)
+		ON CREATE SET c.id = randomUUID()
+		SET c.name = row.name
+		WITH u, c, row
+		OPTIONAL MATCH (r:CreatorRole {name: row.role})
+		FOREACH (role IN CASE WHEN r IS NULL THEN [] ELSE [r] END | MERGE (c)-[:HAS_ROLE]->(role))
+		MERGE (u)-[f:FOLLOWS]->(c)
+		SET f.followedAt = coalesce(datetime(row.followedAt), f.followedAt)
+		WITH c, f, row
+		OPTIONAL MATCH (g:Game)-[:IDENTIFIED_BY]->(:ExternalID {source: row.gameSource, value: row.gameId})
+		FOREACH (game IN CASE WHEN g IS NULL THEN [] ELSE [g] END | MERGE (c)-[:STREAMS]->(game))
+		WITH c, f, row
+		UNWIND CASE WHEN size(coalesce(row.mediaIds, [])) = 0 THEN [null] ELSE row.mediaIds END AS mediaId
+		OPTIONAL MATCH (m:Media)-[:IDENTIFIED_BY]->(:ExternalID {source: row.mediaSource, value: mediaId})
+		FOREACH (media IN CASE WHEN m IS NULL THEN [] ELSE [m] END | MERGE (c)-[:CREATED {role: row.role}]->(media))
+		RETURN count(DISTINCT f) as count
+	`
+
+	return r.importRows(ctx, query, userID, rows, nil)
+}
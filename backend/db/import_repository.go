@@ -0,0 +1,919 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"nq/graph/model"
+	"nq/isbn"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// maxMatchCandidates caps the media sharing a creator with an import item
+// that are scored against it, for prolific creators
+const maxMatchCandidates = 200
+
+// DefaultImportBatchSize is the number of items written per transaction when
+// the caller doesn't choose a batch size
+const DefaultImportBatchSize = 500
+
+// ImportOptions controls how ImportMedia writes its items
+type ImportOptions struct {
+	// BatchSize is the number of items written per UNWIND transaction
+	BatchSize int
+	// OnExisting decides what happens to items that match existing media
+	OnExisting model.ImportConflictPolicy
+}
+
+// mediaLabels maps each media type onto its node label
+var mediaLabels = map[model.MediaType]string{
+	model.MediaTypeMovie:      "Movie",
+	model.MediaTypeTvShow:     "TVShow",
+	model.MediaTypeBook:       "Book",
+	model.MediaTypeGame:       "Game",
+	model.MediaTypeMusicAlbum: "MusicAlbum",
+	model.MediaTypeVideo:      "Video",
+	model.MediaTypeArticle:    "Article",
+	model.MediaTypeStream:     "Stream",
+}
+
+// mediaLabel returns the media type label among a node's labels
+func mediaLabel(labels []string) string {
+	for _, label := range labels {
+		for _, known := range mediaLabels {
+			if label == known {
+				return label
+			}
+		}
+	}
+	return ""
+}
+
+// importItem is an import input that passed validation, with the node
+// properties it will write
+type importItem struct {
+	index int
+	input *model.MediaImportInput
+	props map[string]any
+}
+
+// ImportMedia upserts media in batches, one transaction per batch. Items are
+// matched against existing media by ISBN, then external ID, then by a
+// confidence score over normalized title, release year and creators; items
+// that may be duplicates but fall short of MatchThreshold are created and
+// queued for review. Invalid items, and items in a batch whose transaction
+// failed, are reported as errors without stopping the rest of the import.
+func (r *Neo4jRepository) ImportMedia(ctx context.Context, items []*model.MediaImportInput, opts ImportOptions) ([]*model.MediaImportResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+	if opts.OnExisting == "" {
+		opts.OnExisting = model.ImportConflictPolicyUpdate
+	}
+
+	results := make([]*model.MediaImportResult, len(items))
+	byType := make(map[model.MediaType][]*importItem)
+	for i, input := range items {
+		props, err := importProperties(input)
+		if err != nil {
+			results[i] = importErrorResult(i, err)
+			continue
+		}
+		byType[input.Type] = append(byType[input.Type], &importItem{index: i, input: input, props: props})
+	}
+
+	// Each batch holds a single media type so its queries can use one label
+	for _, mediaType := range model.AllMediaType {
+		pending := byType[mediaType]
+		for start := 0; start < len(pending); start += opts.BatchSize {
+			batch := pending[start:min(start+opts.BatchSize, len(pending))]
+
+			batchResults, err := r.importBatch(ctx, mediaLabels[mediaType], batch, opts.OnExisting)
+			if err != nil {
+				// The transaction rolled back, so nothing in the batch was written
+				for _, item := range batch {
+					results[item.index] = importErrorResult(item.index, err)
+				}
+				continue
+			}
+
+			for _, result := range batchResults {
+				results[result.Index] = result
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// importBatch writes a batch of items that share a label in one transaction
+func (r *Neo4jRepository) importBatch(ctx context.Context, label string, batch []*importItem, onExisting model.ImportConflictPolicy) ([]*model.MediaImportResult, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		matches, possibleDuplicates, err := resolveImportBatch(ctx, tx, label, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make(map[int]string, len(batch))
+		statuses := make(map[int]model.ImportStatus, len(batch))
+		confidences := make(map[int]float64, len(batch))
+		reviewIDs := make(map[int]uuid.UUID)
+		// Natural keys claimed by earlier items in this batch, so duplicates
+		// within one request update the same node instead of creating two
+		claimed := newBatchClaims()
+
+		var nodes, editions, externalIDs, tags, platforms, reviews []map[string]any
+		creators := newCreatorCredits(label)
+		for _, item := range batch {
+			keys := naturalKeys(item.input)
+
+			var id string
+			match, existing := matches[item.index]
+			if existing {
+				id = match.id
+				confidences[item.index] = match.confidence
+			} else {
+				id, existing = claimed.find(keys, releaseYear(item.input))
+			}
+			if !existing {
+				id = uuid.New().String()
+				if duplicate, ok := possibleDuplicates[item.index]; ok {
+					reviewIDs[item.index] = uuid.New()
+					confidences[item.index] = duplicate.confidence
+					reviews = append(reviews, map[string]any{
+						"id":          reviewIDs[item.index].String(),
+						"mediaId":     id,
+						"candidateId": duplicate.id,
+						"confidence":  duplicate.confidence,
+						"reasons":     duplicate.reasons,
+					})
+				}
+			}
+			claimed.claim(id, keys, releaseYear(item.input))
+
+			ids[item.index] = id
+			switch {
+			case !existing:
+				statuses[item.index] = model.ImportStatusCreated
+			case onExisting == model.ImportConflictPolicySkip:
+				statuses[item.index] = model.ImportStatusSkipped
+				continue
+			default:
+				statuses[item.index] = model.ImportStatusUpdated
+			}
+
+			props := item.props
+			if _, ok := props["isbn"]; ok {
+				// The item describes one edition of the book. Editions of
+				// books already stored are added next to theirs rather than
+				// replacing the book's ISBN, publisher and page count.
+				editions = append(editions, editionRow(id, props, props["releaseDate"]))
+				if existing {
+					props = maps.Clone(props)
+					for _, field := range editionFields {
+						delete(props, field)
+					}
+				}
+			}
+			nodes = append(nodes, map[string]any{"id": id, "props": props})
+			for _, externalID := range item.input.ExternalIds {
+				externalIDs = append(externalIDs, map[string]any{
+					"mediaId": id,
+					"source":  normalizeSource(externalID.Source),
+					"value":   strings.TrimSpace(externalID.Value),
+				})
+			}
+			for _, creator := range item.input.Creators {
+				creators.add(id, creator)
+			}
+			for _, tag := range item.input.Tags {
+				tags = append(tags, map[string]any{
+					"mediaId": id,
+					"name":    strings.TrimSpace(tag.Name),
+					"type":    strings.TrimSpace(tag.Type),
+				})
+			}
+			for _, platform := range item.input.Platforms {
+				platforms = append(platforms, map[string]any{
+					"mediaId": id,
+					"name":    strings.TrimSpace(platform.Name),
+					"baseUrl": platform.BaseURL,
+				})
+			}
+		}
+
+		// The label comes from mediaLabels, never from user input
+		writes := []struct {
+			query string
+			rows  []map[string]any
+		}{
+			{`
+				UNWIND $rows AS row
+				MERGE (m:Media:` + label + ` {id: row.id})
+				ON CREATE SET m.createdAt = datetime()
+				SET m += row.props, m.updatedAt = datetime()
+			`, nodes},
+			{linkEditionQuery, editions},
+			{`
+				UNWIND $rows AS row
+				MATCH (m:Media {id: row.mediaId})
+				MERGE (x:ExternalID {source: row.source, value: row.value})
+				MERGE (m)-[:IDENTIFIED_BY]->(x)
+			`, externalIDs},
+			{linkCreatorsQuery, creators.rows},
+			{`
+				UNWIND $rows AS row
+				MATCH (m:Media {id: row.mediaId})
+				MERGE (t:Tag {name: row.name, type: row.type})
+				ON CREATE SET t.id = randomUUID()
+				MERGE (m)-[:TAGGED_WITH]->(t)
+			`, tags},
+			{`
+				UNWIND $rows AS row
+				MATCH (m:Media {id: row.mediaId})
+				MERGE (p:Platform {name: row.name})
+				ON CREATE SET p.id = randomUUID(), p.baseUrl = row.baseUrl
+				MERGE (p)-[:HOSTS]->(m)
+			`, platforms},
+			{`
+				UNWIND $rows AS row
+				MATCH (m:Media {id: row.mediaId}), (c:Media {id: row.candidateId})
+				CREATE (r:MatchReview {
+					id: row.id,
+					mediaId: row.mediaId,
+					candidateId: row.candidateId,
+					confidence: row.confidence,
+					reasons: row.reasons,
+					status: 'PENDING',
+					createdAt: datetime()
+				})
+				CREATE (r)-[:REVIEWS]->(m)
+				CREATE (r)-[:CANDIDATE]->(c)
+			`, reviews},
+		}
+
+		for _, write := range writes {
+			if len(write.rows) == 0 {
+				continue
+			}
+			result, err := tx.Run(ctx, write.query, map[string]any{"rows": write.rows})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := result.Consume(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		// Candidates of reviews are loaded too, under negative indexes
+		fetch := maps.Clone(ids)
+		for index := range reviewIDs {
+			fetch[-1-index] = possibleDuplicates[index].id
+		}
+		media, err := fetchMediaByIDs(ctx, tx, fetch)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]*model.MediaImportResult, 0, len(batch))
+		for _, item := range batch {
+			result := &model.MediaImportResult{
+				Index:  int32(item.index),
+				Status: statuses[item.index],
+				Media:  media[ids[item.index]],
+			}
+			if confidence, ok := confidences[item.index]; ok {
+				result.Confidence = &confidence
+			}
+			if reviewID, ok := reviewIDs[item.index]; ok {
+				duplicate := possibleDuplicates[item.index]
+				result.Review = &model.MatchReview{
+					ID:         reviewID,
+					Media:      result.Media,
+					Candidate:  media[duplicate.id],
+					Confidence: duplicate.confidence,
+					Reasons:    duplicate.reasons,
+					Status:     model.MatchReviewStatusPending,
+					CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+				}
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.MediaImportResult), nil
+}
+
+// ActivityTagType is the type of the tags users put on their own activities,
+// as opposed to the catalog's tags on media
+const ActivityTagType = "user"
+
+// ActivityImport is an activity read from another service's export.
+// ImportKey identifies it within that service, so importing the same export
+// again updates the activity instead of adding a second one. Activities
+// folded into another by a media merge are skipped.
+type ActivityImport struct {
+	ImportKey  string
+	MediaID    uuid.UUID
+	StatusID   int32
+	Rating     *float64
+	Review     *string
+	StartedAt  *string
+	FinishedAt *string
+	Rewatch    *bool
+	// Count is how many times the media was consumed, if the service tracks it
+	Count *int32
+	// Progress is how far along the user is, measured in ProgressUnit
+	Progress     *float64
+	ProgressUnit *string
+	// KeepStatus only applies StatusID to new and still Planned activities,
+	// so a repeated sync never undoes a status the user chose
+	KeepStatus bool
+	// Tags are the user's own labels for the activity, such as shelves
+	Tags []string
+}
+
+// RatingImport is a rating read from another service's export, already
+// converted onto our scale with ScaleScore
+type RatingImport struct {
+	MediaID uuid.UUID
+	Score   float64
+	RatedAt *string
+}
+
+// ImportActivities upserts a user's imported activities by import key and
+// returns how many were written. Unset fields never clear stored values.
+func (r *Neo4jRepository) ImportActivities(ctx context.Context, userID uuid.UUID, activities []*ActivityImport) (int, error) {
+	rows := make([]map[string]any, 0, len(activities))
+	for _, activity := range activities {
+		props := map[string]any{}
+		if activity.Rating != nil {
+			props["rating"] = *activity.Rating
+		}
+		if activity.Review != nil {
+			props["review"] = *activity.Review
+		}
+		if activity.StartedAt != nil {
+			props["startedAt"] = *activity.StartedAt
+		}
+		if activity.FinishedAt != nil {
+			props["finishedAt"] = *activity.FinishedAt
+		}
+		if activity.Rewatch != nil {
+			props["rewatch"] = *activity.Rewatch
+		}
+		if activity.Count != nil {
+			props["count"] = *activity.Count
+		}
+		if activity.Progress != nil {
+			props["progress"] = *activity.Progress
+		}
+		if activity.ProgressUnit != nil {
+			props["progressUnit"] = *activity.ProgressUnit
+		}
+
+		tags := make([]string, 0, len(activity.Tags))
+		for _, tag := range activity.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+
+		rows = append(rows, map[string]any{
+			"id":         uuid.New().String(),
+			"importKey":  activity.ImportKey,
+			"mediaId":    activity.MediaID.String(),
+			"statusId":   activity.StatusID,
+			"keepStatus": activity.KeepStatus,
+			"props":      props,
+			"tags":       tags,
+		})
+	}
+
+	query := `
+		MATCH (u:User {id: $userID})
+		UNWIND $rows AS row
+		MATCH (m:Media {id: row.mediaId})
+		WHERE NOT EXISTS {
+			MATCH (u)-[:HAS_ACTIVITY]->(folded:UserActivity)
+			WHERE row.importKey IN folded.mergedImportKeys
+		}
+		MERGE (u)-[:HAS_ACTIVITY]->(a:UserActivity {importKey: row.importKey})
+		ON CREATE SET a.id = row.id, a.createdAt = datetime(), a.version = 0
+		SET a += row.props, a.updatedAt = datetime(), a.version = a.version + 1,
+		    a.statusId = CASE
+		        WHEN row.keepStatus AND a.statusId IS NOT NULL AND a.statusId <> $planned THEN a.statusId
+		        ELSE row.statusId
+		    END
+		MERGE (a)-[:ACTIVITY_FOR]->(m)
+		FOREACH (name IN row.tags |
+			MERGE (t:Tag {name: name, type: $tagType})
+			ON CREATE SET t.id = randomUUID()
+			MERGE (a)-[:TAGGED_WITH]->(t)
+		)
+		RETURN count(a) as count
+	`
+
+	return r.importRows(ctx, query, userID, rows, map[string]any{
+		"tagType": ActivityTagType,
+		"planned": StatusPlanned,
+	})
+}
+
+// ImportRatings upserts a user's imported ratings and returns how many were
+// written. A rating keeps its original ratedAt when the import has none.
+func (r *Neo4jRepository) ImportRatings(ctx context.Context, userID uuid.UUID, ratings []*RatingImport) (int, error) {
+	rows := make([]map[string]any, 0, len(ratings))
+	for _, rating := range ratings {
+		rows = append(rows, map[string]any{
+			"mediaId": rating.MediaID.String(),
+			"score":   rating.Score,
+			"ratedAt": rating.RatedAt,
+		})
+	}
+
+	query := `
+		MATCH (u:User {id: $userID})
+		UNWIND $rows AS row
+		MATCH (m:Media {id: row.mediaId})
+		MERGE (r:Rating {userId: $userID, mediaId: row.mediaId})
+		SET r.score = row.score,
+		    r.ratedAt = coalesce(datetime(row.ratedAt), r.ratedAt, datetime()),
+		    r.version = coalesce(r.version, 0) + 1
+		MERGE (u)-[:RATED]->(r)
+		MERGE (r)-[:RATING_FOR]->(m)
+		RETURN count(r) as count
+	`
+
+	return r.importRows(ctx, query, userID, rows, nil)
+}
+
+// ImportFavorites adds media to a user's favorites and returns how many
+// favorites were written
+func (r *Neo4jRepository) ImportFavorites(ctx context.Context, userID uuid.UUID, mediaIDs []uuid.UUID) (int, error) {
+	rows := make([]map[string]any, 0, len(mediaIDs))
+	for _, mediaID := range mediaIDs {
+		rows = append(rows, map[string]any{"mediaId": mediaID.String()})
+	}
+
+	query := `
+		MATCH (u:User {id: $userID})
+		UNWIND $rows AS row
+		MATCH (m:Media {id: row.mediaId})
+		MERGE (u)-[f:FAVORITES]->(m)
+		ON CREATE SET f.createdAt = datetime()
+		RETURN count(f) as count
+	`
+
+	return r.importRows(ctx, query, userID, rows, nil)
+}
+
+// importRows runs a per-user UNWIND query over rows in batches, one
+// transaction per batch, and sums the counts it returns
+func (r *Neo4jRepository) importRows(ctx context.Context, query string, userID uuid.UUID, rows []map[string]any, extra map[string]any) (int, error) {
+	total := 0
+	for start := 0; start < len(rows); start += DefaultImportBatchSize {
+		batch := rows[start:min(start+DefaultImportBatchSize, len(rows))]
+
+		result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			params := map[string]any{
+				"userID": userID.String(),
+				"rows":   batch,
+			}
+			for key, value := range extra {
+				params[key] = value
+			}
+
+			result, err := tx.Run(ctx, query, params)
+			if err != nil {
+				return nil, err
+			}
+
+			if result.Next(ctx) {
+				return int(getInt32FromRecord(result.Record(), "count")), nil
+			}
+			return 0, result.Err()
+		})
+
+		if err != nil {
+			return total, err
+		}
+		total += result.(int)
+	}
+
+	return total, nil
+}
+
+// resolveImportBatch finds existing media for the items in a batch, keyed by
+// item index. ISBNs and then external IDs match outright. Other items are
+// scored against media sharing their normalized title or a creator (see
+// scoreMatch) and matched when the best candidate reaches MatchThreshold.
+// Best candidates scoring between ReviewThreshold and MatchThreshold are
+// returned as possible duplicates to review.
+func resolveImportBatch(ctx context.Context, tx neo4j.ManagedTransaction, label string, batch []*importItem) (map[int]*importMatch, map[int]*importMatch, error) {
+	var isbns, externalIDs []map[string]any
+	for _, item := range batch {
+		if value, ok := item.props["isbn"]; ok {
+			isbns = append(isbns, map[string]any{"index": item.index, "isbn": value})
+		}
+		for _, externalID := range item.input.ExternalIds {
+			externalIDs = append(externalIDs, map[string]any{
+				"index":  item.index,
+				"source": normalizeSource(externalID.Source),
+				"value":  strings.TrimSpace(externalID.Value),
+			})
+		}
+	}
+
+	lookups := []struct {
+		query string
+		keys  []map[string]any
+	}{
+		{`
+			UNWIND $keys AS key
+			MATCH (:Edition {isbn13: key.isbn})-[:EDITION_OF]->(m:` + label + `)
+			RETURN key.index AS index, m.id AS id, 'same ISBN' AS reason
+		`, isbns},
+		{`
+			UNWIND $keys AS key
+			MATCH (m:` + label + ` {isbn: key.isbn})
+			RETURN key.index AS index, m.id AS id, 'same ISBN' AS reason
+		`, isbns},
+		{`
+			UNWIND $keys AS key
+			MATCH (m:` + label + `)-[:IDENTIFIED_BY]->(:ExternalID {source: key.source, value: key.value})
+			RETURN key.index AS index, m.id AS id, 'same ' + key.source + ' ID' AS reason
+		`, externalIDs},
+	}
+
+	matches := make(map[int]*importMatch)
+	for _, lookup := range lookups {
+		if len(lookup.keys) == 0 {
+			continue
+		}
+
+		result, err := tx.Run(ctx, lookup.query, map[string]any{"keys": lookup.keys})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for result.Next(ctx) {
+			record := result.Record()
+			index := int(getInt32FromRecord(record, "index"))
+			if _, ok := matches[index]; !ok {
+				matches[index] = &importMatch{
+					id:         record.AsMap()["id"].(string),
+					confidence: 1,
+					reasons:    []string{getString(record.AsMap()["reason"])},
+				}
+			}
+		}
+		if err := result.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	items := make(map[int]*importItem, len(batch))
+	var keys []map[string]any
+	for _, item := range batch {
+		if _, ok := matches[item.index]; ok {
+			continue
+		}
+		items[item.index] = item
+		creators := make([]string, 0, len(item.input.Creators))
+		for _, creator := range item.input.Creators {
+			creators = append(creators, strings.TrimSpace(creator.Name))
+		}
+		keys = append(keys, map[string]any{
+			"index":    item.index,
+			"title":    item.props["normalizedTitle"],
+			"creators": creators,
+		})
+	}
+
+	reviews := make(map[int]*importMatch)
+	if len(keys) == 0 {
+		return matches, reviews, nil
+	}
+
+	query := `
+		UNWIND $keys AS key
+		CALL {
+			WITH key
+			MATCH (m:Media:` + label + ` {normalizedTitle: key.title})
+			RETURN m
+			UNION
+			WITH key
+			UNWIND key.creators AS creator
+			MATCH (:Creator {name: creator})-[:CREATED]->(m:` + label + `)
+			RETURN m
+			LIMIT $limit
+		}
+		RETURN key.index AS index, m.id AS id, m.title AS title,
+		       coalesce(left(m.releaseDate, 4), toString(m.releaseYear)) AS year,
+		       [(c:Creator)-[:CREATED]->(m) | c.name] AS creators,
+		       [(m)-[:IDENTIFIED_BY]->(x:ExternalID) | x.source + ':' + x.value] AS externalIds
+	`
+
+	result, err := tx.Run(ctx, query, map[string]any{"keys": keys, "limit": maxMatchCandidates})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for result.Next(ctx) {
+		record := result.Record()
+		index := int(getInt32FromRecord(record, "index"))
+		candidate := &matchCandidate{
+			id:          getString(record.AsMap()["id"]),
+			title:       getString(record.AsMap()["title"]),
+			year:        getString(record.AsMap()["year"]),
+			creators:    getStringSlice(record.AsMap()["creators"]),
+			externalIDs: getStringSlice(record.AsMap()["externalIds"]),
+		}
+
+		confidence, reasons := scoreMatch(items[index], candidate)
+		best := matches[index]
+		if best == nil {
+			best = reviews[index]
+		}
+		if confidence < ReviewThreshold || (best != nil && best.confidence >= confidence) {
+			continue
+		}
+
+		match := &importMatch{id: candidate.id, confidence: confidence, reasons: reasons}
+		if confidence >= MatchThreshold {
+			matches[index] = match
+			delete(reviews, index)
+		} else {
+			reviews[index] = match
+		}
+	}
+
+	return matches, reviews, result.Err()
+}
+
+// fetchMediaByIDs loads the given media nodes, keyed by ID
+func fetchMediaByIDs(ctx context.Context, tx neo4j.ManagedTransaction, ids map[int]string) (map[string]model.Media, error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	query := `
+		UNWIND $ids AS id
+		MATCH (m:Media {id: id})
+		RETURN labels(m) as labels, properties(m) as props
+	`
+
+	result, err := tx.Run(ctx, query, map[string]any{"ids": unique})
+	if err != nil {
+		return nil, err
+	}
+
+	media := make(map[string]model.Media, len(unique))
+	for result.Next(ctx) {
+		record := result.Record()
+		props := record.AsMap()["props"].(map[string]any)
+		if item := mediaFromNode(getStringSlice(record.AsMap()["labels"]), props); item != nil {
+			media[props["id"].(string)] = item
+		}
+	}
+
+	return media, result.Err()
+}
+
+// importProperties validates an import item and returns the node
+// properties it sets. Unset fields are left out so that updates never
+// clear values already stored.
+func importProperties(input *model.MediaImportInput) (map[string]any, error) {
+	if _, ok := mediaLabels[input.Type]; !ok {
+		return nil, ValidationFailedError("unknown media type %s", input.Type)
+	}
+
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return nil, ValidationFailedError("title must not be empty")
+	}
+
+	props := map[string]any{"title": title, "normalizedTitle": NormalizeTitle(title)}
+
+	if input.ReleaseDate != nil {
+		if _, err := time.Parse(time.DateOnly, *input.ReleaseDate); err != nil {
+			return nil, ValidationFailedError("releaseDate must be a date in YYYY-MM-DD format")
+		}
+		props["releaseDate"] = *input.ReleaseDate
+	}
+	if input.ReleaseYear != nil {
+		if *input.ReleaseYear < 1000 || *input.ReleaseYear > 9999 {
+			return nil, ValidationFailedError("releaseYear must be a four-digit year")
+		}
+		if input.ReleaseDate != nil && releaseYear(input) != strconv.Itoa(int(*input.ReleaseYear)) {
+			return nil, ValidationFailedError("releaseYear doesn't match releaseDate")
+		}
+		props["releaseYear"] = *input.ReleaseYear
+	}
+	if input.Description != nil {
+		props["description"] = *input.Description
+	}
+	if input.CoverURL != nil {
+		props["coverUrl"] = *input.CoverURL
+	}
+
+	for _, externalID := range input.ExternalIds {
+		if strings.TrimSpace(externalID.Source) == "" || strings.TrimSpace(externalID.Value) == "" {
+			return nil, ValidationFailedError("external IDs need both a source and a value")
+		}
+	}
+	for _, creator := range input.Creators {
+		if strings.TrimSpace(creator.Name) == "" || strings.TrimSpace(creator.Role) == "" {
+			return nil, ValidationFailedError("creators need both a name and a role")
+		}
+	}
+	for _, tag := range input.Tags {
+		if strings.TrimSpace(tag.Name) == "" || strings.TrimSpace(tag.Type) == "" {
+			return nil, ValidationFailedError("tags need both a name and a type")
+		}
+	}
+	for _, platform := range input.Platforms {
+		if strings.TrimSpace(platform.Name) == "" {
+			return nil, ValidationFailedError("platforms need a name")
+		}
+	}
+
+	// Type-specific fields, each only allowed on its own media types
+	movie := []model.MediaType{model.MediaTypeMovie}
+	tvShow := []model.MediaType{model.MediaTypeTvShow}
+	book := []model.MediaType{model.MediaTypeBook}
+	game := []model.MediaType{model.MediaTypeGame}
+	musicAlbum := []model.MediaType{model.MediaTypeMusicAlbum}
+	video := []model.MediaType{model.MediaTypeVideo}
+	article := []model.MediaType{model.MediaTypeArticle}
+	stream := []model.MediaType{model.MediaTypeStream}
+
+	fields := []struct {
+		name       string
+		mediaTypes []model.MediaType
+		set        bool
+		value      func() any
+	}{
+		{"runtime", movie, input.Runtime != nil, func() any { return *input.Runtime }},
+		{"budget", movie, input.Budget != nil, func() any { return *input.Budget }},
+		{"boxOffice", movie, input.BoxOffice != nil, func() any { return *input.BoxOffice }},
+		{"seasons", tvShow, input.Seasons != nil, func() any { return *input.Seasons }},
+		{"episodes", tvShow, input.Episodes != nil, func() any { return *input.Episodes }},
+		{"status", tvShow, input.Status != nil, func() any { return *input.Status }},
+		{"pages", book, input.Pages != nil, func() any { return *input.Pages }},
+		{"isbn", book, input.Isbn != nil, func() any { isbn13, _ := isbn.To13(*input.Isbn); return isbn13 }},
+		{"publisher", book, input.Publisher != nil, func() any { return *input.Publisher }},
+		{"genre", game, input.Genre != nil, func() any { return input.Genre }},
+		{"esrbRating", game, input.EsrbRating != nil, func() any { return *input.EsrbRating }},
+		{"multiplayer", game, input.Multiplayer != nil, func() any { return *input.Multiplayer }},
+		{"trackCount", musicAlbum, input.TrackCount != nil, func() any { return *input.TrackCount }},
+		{"duration", slices.Concat(musicAlbum, video, stream), input.Duration != nil, func() any { return *input.Duration }},
+		{"label", musicAlbum, input.Label != nil, func() any { return *input.Label }},
+		{"url", slices.Concat(video, article, stream), input.URL != nil, func() any { return *input.URL }},
+		{"site", article, input.Site != nil, func() any { return *input.Site }},
+		{"author", article, input.Author != nil, func() any { return *input.Author }},
+		{"wordCount", article, input.WordCount != nil, func() any { return *input.WordCount }},
+		{"readTime", article, input.ReadTime != nil, func() any { return *input.ReadTime }},
+	}
+
+	for _, field := range fields {
+		if !field.set {
+			continue
+		}
+		if !slices.Contains(field.mediaTypes, input.Type) {
+			return nil, ValidationFailedError("%s can't be set on %s items", field.name, input.Type)
+		}
+		props[field.name] = field.value()
+	}
+
+	if input.Isbn != nil && !isbn.Valid(*input.Isbn) {
+		return nil, ValidationFailedError("isbn %q is not a valid ISBN-10 or ISBN-13", *input.Isbn)
+	}
+	if input.Isbn != nil {
+		if isbn10, ok := isbn.To10(*input.Isbn); ok {
+			props["isbn10"] = isbn10
+		}
+	}
+
+	if input.Type == model.MediaTypeArticle {
+		if input.URL != nil && input.Site == nil {
+			if site := SiteName(*input.URL); site != "" {
+				props["site"] = site
+			}
+		}
+		if input.WordCount != nil && input.ReadTime == nil {
+			props["readTime"] = ReadTime(*input.WordCount)
+		}
+	}
+
+	return props, nil
+}
+
+// naturalKeys lists the keys that identify the same work across import items
+func naturalKeys(input *model.MediaImportInput) []string {
+	var keys []string
+	if input.Isbn != nil {
+		isbn13, _ := isbn.To13(*input.Isbn)
+		keys = append(keys, "isbn:"+isbn13)
+	}
+	for _, externalID := range input.ExternalIds {
+		keys = append(keys, "external:"+normalizeSource(externalID.Source)+":"+strings.TrimSpace(externalID.Value))
+	}
+	for _, creator := range input.Creators {
+		keys = append(keys, "creator:"+NormalizeTitle(input.Title)+":"+normalizeName(creator.Name))
+	}
+	if year := releaseYear(input); year != "" {
+		keys = append(keys, "title:"+NormalizeTitle(input.Title)+":"+year)
+	}
+	return keys
+}
+
+// batchClaims records which node earlier items in an import batch went to,
+// by natural key, along with the release year each node was given
+type batchClaims struct {
+	ids   map[string]string
+	years map[string]string
+}
+
+func newBatchClaims() *batchClaims {
+	return &batchClaims{ids: map[string]string{}, years: map[string]string{}}
+}
+
+// find returns the node an earlier item with one of keys went to. A title by
+// the same creator released in another year is a remake, re-recording or
+// reissue, so creator keys only match when the years agree or one is unknown.
+func (c *batchClaims) find(keys []string, year string) (string, bool) {
+	for _, key := range keys {
+		id, ok := c.ids[key]
+		if !ok {
+			continue
+		}
+		if claimedYear := c.years[id]; strings.HasPrefix(key, "creator:") && year != "" && claimedYear != "" && year != claimedYear {
+			continue
+		}
+		return id, true
+	}
+	return "", false
+}
+
+// claim records that an item with keys and year went to the node id
+func (c *batchClaims) claim(id string, keys []string, year string) {
+	for _, key := range keys {
+		c.ids[key] = id
+	}
+	if _, ok := c.years[id]; !ok && year != "" {
+		c.years[id] = year
+	}
+}
+
+// normalizeSource lower-cases an external ID source so "IMDb" and "imdb" match
+func normalizeSource(source string) string {
+	return strings.ToLower(strings.TrimSpace(source))
+}
+
+// releaseYear returns the year an item was released, taken from its release
+// date when known, or "" if neither the date nor the year is set
+func releaseYear(input *model.MediaImportInput) string {
+	if input.ReleaseDate != nil && len(*input.ReleaseDate) >= 4 {
+		return (*input.ReleaseDate)[:4]
+	}
+	if input.ReleaseYear != nil {
+		return strconv.Itoa(int(*input.ReleaseYear))
+	}
+	return ""
+}
+
+// importErrorResult reports a failed item. Only typed repository errors are
+// shown verbatim; anything else is logged and summarized.
+func importErrorResult(index int, err error) *model.MediaImportResult {
+	message := err.Error()
+	var repoErr *Error
+	if !errors.As(err, &repoErr) {
+		log.Printf("Import of item %d failed: %v", index, err)
+		message = "import failed"
+	}
+
+	return &model.MediaImportResult{
+		Index:  int32(index),
+		Status: model.ImportStatusError,
+		Error:  &message,
+	}
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"nq/graph/model"
+	"testing"
+)
+
+func TestBatchClaimsKeepsRemakesApart(t *testing.T) {
+	year := func(y int32) *int32 { return &y }
+	creators := []*model.CreatorInput{{Name: "Taylor Swift", Role: "Artist"}}
+	original := &model.MediaImportInput{Type: model.MediaTypeMusicAlbum, Title: "Fearless", ReleaseYear: year(2008), Creators: creators}
+	rerecording := &model.MediaImportInput{Type: model.MediaTypeMusicAlbum, Title: "Fearless", ReleaseYear: year(2021), Creators: creators}
+	undated := &model.MediaImportInput{Type: model.MediaTypeMusicAlbum, Title: "Fearless", Creators: creators}
+
+	claims := newBatchClaims()
+	claims.claim("original", naturalKeys(original), releaseYear(original))
+
+	if id, ok := claims.find(naturalKeys(rerecording), releaseYear(rerecording)); ok {
+		t.Errorf("matched %s, want a re-recording from another year kept apart", id)
+	}
+	if id, ok := claims.find(naturalKeys(undated), releaseYear(undated)); !ok || id != "original" {
+		t.Errorf("got %q, want an undated row matched on title and creator", id)
+	}
+	if id, ok := claims.find(naturalKeys(original), releaseYear(original)); !ok || id != "original" {
+		t.Errorf("got %q, want the same album matched", id)
+	}
+}
@@ -0,0 +1,510 @@
+package db
+
+import (
+	"context"
+	"nq/graph/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// DefaultJobMaxAttempts is how many times a job runs before it fails for good
+const DefaultJobMaxAttempts = 5
+
+// Job is a unit of background work, stored so it survives restarts and can
+// be picked up by any server instance
+type Job struct {
+	ID   uuid.UUID
+	Kind string
+	// Payload is the job's input, JSON encoded
+	Payload string
+	// Key deduplicates jobs: while a job with a key is queued or running,
+	// enqueueing another with the same key returns the existing one
+	Key string
+	// Schedule names the schedule that enqueued the job, if any
+	Schedule    string
+	State       model.JobState
+	Attempts    int
+	MaxAttempts int
+	RunAfter    time.Time
+	// LeaseOwner is the instance running the job. If it doesn't extend the
+	// lease before LeaseExpiresAt, another instance may claim the job.
+	LeaseOwner     string
+	LeaseExpiresAt *time.Time
+	LastError      string
+	CreatedAt      time.Time
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+}
+
+// JobInput describes a job to enqueue
+type JobInput struct {
+	Kind     string
+	Payload  string
+	Key      string
+	Schedule string
+	// RunAfter delays the job; the zero time runs it as soon as possible
+	RunAfter time.Time
+	// MaxAttempts defaults to DefaultJobMaxAttempts
+	MaxAttempts int
+}
+
+// JobFilter narrows GetJobs. Zero fields match every job.
+type JobFilter struct {
+	State *model.JobState
+	Kind  string
+	Limit int
+}
+
+// EnqueueJob stores a queued job. If the input has a key and a job with that
+// key is still queued or running, that job is returned instead.
+func (r *Neo4jRepository) EnqueueJob(ctx context.Context, input *JobInput) (*Job, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Only unfinished jobs carry activeKey, and its unique constraint
+		// makes the MERGE safe between instances
+		query := `
+			CREATE (j:Job)
+			SET j += $props
+			RETURN j
+		`
+		if input.Key != "" {
+			query = `
+				MERGE (j:Job {activeKey: $key})
+				ON CREATE SET j += $props
+				RETURN j
+			`
+		}
+
+		params := map[string]any{
+			"key":   input.Key,
+			"props": jobProps(input),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return jobFromRecord(result.Record()), nil
+		}
+
+		return nil, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Job), nil
+}
+
+// SaveJobSchedule registers a recurring schedule, first due at nextRunAt. A
+// schedule that already exists keeps its next run unless its spec changed.
+func (r *Neo4jRepository) SaveJobSchedule(ctx context.Context, name, spec string, nextRunAt time.Time) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MERGE (s:JobSchedule {name: $name})
+			ON CREATE SET s.createdAt = datetime()
+			WITH s, s.spec IS NULL OR s.spec <> $spec as changed
+			SET s.spec = $spec,
+			    s.nextRunAt = CASE WHEN changed THEN datetime($nextRunAt) ELSE s.nextRunAt END
+			RETURN s.name as name
+		`
+
+		params := map[string]any{
+			"name":      name,
+			"spec":      spec,
+			"nextRunAt": nextRunAt.UTC().Format(time.RFC3339),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+		return result.Consume(ctx)
+	})
+
+	return err
+}
+
+// EnqueueScheduledJob enqueues a schedule's job if the schedule is due at
+// now, and moves the schedule on to next. It returns nil when the schedule
+// isn't due, e.g. because another instance enqueued the run already.
+func (r *Neo4jRepository) EnqueueScheduledJob(ctx context.Context, name string, now, next time.Time, input *JobInput) (*Job, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Writing the schedule takes its lock before the due check is made
+		// again, so two instances can't both run it
+		query := `
+			MATCH (s:JobSchedule {name: $name})
+			WHERE s.nextRunAt <= datetime($now)
+			SET s.claimLock = true
+			REMOVE s.claimLock
+			WITH s
+			WHERE s.nextRunAt <= datetime($now)
+			SET s.nextRunAt = datetime($next), s.lastRunAt = datetime($now)
+			MERGE (j:Job {activeKey: $key})
+			ON CREATE SET j += $props
+			RETURN j
+		`
+
+		params := map[string]any{
+			"name":  name,
+			"now":   now.UTC().Format(time.RFC3339),
+			"next":  next.UTC().Format(time.RFC3339),
+			"key":   input.Key,
+			"props": jobProps(input),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return jobFromRecord(result.Record()), nil
+		}
+
+		return (*Job)(nil), result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Job), nil
+}
+
+// ClaimJobs leases up to limit due jobs of the given kinds to owner and
+// counts an attempt for each. Jobs whose lease expired, because the instance
+// running them stopped, are claimed again, or failed once they are out of
+// attempts.
+func (r *Neo4jRepository) ClaimJobs(ctx context.Context, owner string, kinds []string, limit int, lease time.Duration) ([]*Job, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		abandoned := `
+			MATCH (j:Job {state: 'RUNNING'})
+			WHERE j.leaseExpiresAt <= datetime() AND j.attempts >= j.maxAttempts
+			SET j.state = 'FAILED',
+			    j.lastError = 'lease expired while running on ' + j.leaseOwner,
+			    j.finishedAt = datetime()
+			REMOVE j.activeKey, j.leaseOwner, j.leaseExpiresAt
+		`
+
+		result, err := tx.Run(ctx, abandoned, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return nil, err
+		}
+
+		// The due check is repeated once the write lock is held, so a job
+		// another instance claimed in the meantime is skipped
+		query := `
+			MATCH (j:Job)
+			WHERE j.kind IN $kinds
+			  AND ((j.state = 'QUEUED' AND j.runAfter <= datetime())
+			    OR (j.state = 'RUNNING' AND j.leaseExpiresAt <= datetime()))
+			WITH j
+			ORDER BY j.runAfter
+			LIMIT $limit
+			SET j.claimLock = true
+			REMOVE j.claimLock
+			WITH j
+			WHERE (j.state = 'QUEUED' AND j.runAfter <= datetime())
+			   OR (j.state = 'RUNNING' AND j.leaseExpiresAt <= datetime())
+			SET j.state = 'RUNNING',
+			    j.attempts = j.attempts + 1,
+			    j.leaseOwner = $owner,
+			    j.leaseExpiresAt = datetime() + duration({milliseconds: $lease}),
+			    j.startedAt = datetime(),
+			    j.updatedAt = datetime()
+			RETURN j
+		`
+
+		params := map[string]any{
+			"owner": owner,
+			"kinds": kinds,
+			"limit": limit,
+			"lease": lease.Milliseconds(),
+		}
+
+		result, err = tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs := []*Job{}
+		for result.Next(ctx) {
+			jobs = append(jobs, jobFromRecord(result.Record()))
+		}
+
+		return jobs, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*Job), nil
+}
+
+// ExtendJobLease keeps a running job leased to owner for another lease. It
+// returns a not found error when owner no longer holds the lease.
+func (r *Neo4jRepository) ExtendJobLease(ctx context.Context, id uuid.UUID, owner string, lease time.Duration) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (j:Job {id: $id, state: 'RUNNING', leaseOwner: $owner})
+			SET j.leaseExpiresAt = datetime() + duration({milliseconds: $lease})
+			RETURN j.id as id
+		`
+
+		params := map[string]any{
+			"id":    id.String(),
+			"owner": owner,
+			"lease": lease.Milliseconds(),
+		}
+
+		return singleJobWrite(ctx, tx, query, params)
+	})
+
+	return err
+}
+
+// CompleteJob marks a job owner is running as succeeded
+func (r *Neo4jRepository) CompleteJob(ctx context.Context, id uuid.UUID, owner string) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (j:Job {id: $id, state: 'RUNNING', leaseOwner: $owner})
+			SET j.state = 'SUCCEEDED', j.finishedAt = datetime(), j.updatedAt = datetime()
+			REMOVE j.activeKey, j.leaseOwner, j.leaseExpiresAt
+			RETURN j.id as id
+		`
+
+		params := map[string]any{
+			"id":    id.String(),
+			"owner": owner,
+		}
+
+		return singleJobWrite(ctx, tx, query, params)
+	})
+
+	return err
+}
+
+// FailJob records a failed attempt of a job owner is running. With retryAt
+// the job is queued again for then; without it the job fails for good.
+func (r *Neo4jRepository) FailJob(ctx context.Context, id uuid.UUID, owner, message string, retryAt *time.Time) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (j:Job {id: $id, state: 'RUNNING', leaseOwner: $owner})
+			SET j.state = 'FAILED', j.lastError = $message, j.finishedAt = datetime(), j.updatedAt = datetime()
+			REMOVE j.activeKey, j.leaseOwner, j.leaseExpiresAt
+			RETURN j.id as id
+		`
+		params := map[string]any{
+			"id":      id.String(),
+			"owner":   owner,
+			"message": message,
+		}
+
+		if retryAt != nil {
+			query = `
+				MATCH (j:Job {id: $id, state: 'RUNNING', leaseOwner: $owner})
+				SET j.state = 'QUEUED', j.lastError = $message, j.runAfter = datetime($retryAt), j.updatedAt = datetime()
+				REMOVE j.leaseOwner, j.leaseExpiresAt
+				RETURN j.id as id
+			`
+			params["retryAt"] = retryAt.UTC().Format(time.RFC3339)
+		}
+
+		return singleJobWrite(ctx, tx, query, params)
+	})
+
+	return err
+}
+
+// GetJob returns a job by ID
+func (r *Neo4jRepository) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (j:Job {id: $id})
+			RETURN j
+		`
+
+		params := map[string]any{"id": id.String()}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return jobFromRecord(result.Record()), nil
+		}
+
+		return nil, NotFoundError("job")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Job), nil
+}
+
+// GetJobs returns the most recently created jobs matching a filter
+func (r *Neo4jRepository) GetJobs(ctx context.Context, filter JobFilter) ([]*Job, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (j:Job)
+			WHERE ($state IS NULL OR j.state = $state)
+			  AND ($kind = "" OR j.kind = $kind)
+			RETURN j
+			ORDER BY j.createdAt DESC
+			LIMIT $limit
+		`
+
+		params := map[string]any{
+			"state": nil,
+			"kind":  filter.Kind,
+			"limit": filter.Limit,
+		}
+		if filter.State != nil {
+			params["state"] = filter.State.String()
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs := []*Job{}
+		for result.Next(ctx) {
+			jobs = append(jobs, jobFromRecord(result.Record()))
+		}
+
+		return jobs, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*Job), nil
+}
+
+// PurgeJobs deletes jobs that finished before a time and returns how many
+func (r *Neo4jRepository) PurgeJobs(ctx context.Context, finishedBefore time.Time) (int, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (j:Job)
+			WHERE j.state IN ['SUCCEEDED', 'FAILED'] AND j.finishedAt < datetime($before)
+			DETACH DELETE j
+			RETURN count(*) as count
+		`
+
+		params := map[string]any{"before": finishedBefore.UTC().Format(time.RFC3339)}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return int(getInt32FromRecord(result.Record(), "count")), nil
+		}
+
+		return 0, result.Err()
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.(int), nil
+}
+
+// singleJobWrite runs a write that matches one job leased to the caller,
+// reporting a not found error when the lease was lost
+func singleJobWrite(ctx context.Context, tx neo4j.ManagedTransaction, query string, params map[string]any) (any, error) {
+	result, err := tx.Run(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Next(ctx) {
+		return nil, nil
+	}
+
+	return nil, NotFoundError("job lease")
+}
+
+// jobProps are the properties of a new job
+func jobProps(input *JobInput) map[string]any {
+	maxAttempts := input.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultJobMaxAttempts
+	}
+	now := time.Now().UTC()
+	runAfter := input.RunAfter.UTC()
+	if input.RunAfter.IsZero() {
+		runAfter = now
+	}
+
+	props := map[string]any{
+		"id":          uuid.New().String(),
+		"kind":        input.Kind,
+		"payload":     input.Payload,
+		"key":         input.Key,
+		"schedule":    input.Schedule,
+		"state":       model.JobStateQueued.String(),
+		"attempts":    0,
+		"maxAttempts": maxAttempts,
+		"runAfter":    runAfter,
+		"createdAt":   now,
+		"updatedAt":   now,
+	}
+	if input.Key != "" {
+		props["activeKey"] = input.Key
+	}
+	return props
+}
+
+// jobFromRecord builds a job from a record returning it as j
+func jobFromRecord(record *neo4j.Record) *Job {
+	node, _ := record.AsMap()["j"].(neo4j.Node)
+	job := &Job{
+		Kind:       getString(node.Props["kind"]),
+		Payload:    getString(node.Props["payload"]),
+		Key:        getString(node.Props["key"]),
+		Schedule:   getString(node.Props["schedule"]),
+		State:      model.JobState(getString(node.Props["state"])),
+		LeaseOwner: getString(node.Props["leaseOwner"]),
+		LastError:  getString(node.Props["lastError"]),
+	}
+	if attempts, ok := node.Props["attempts"].(int64); ok {
+		job.Attempts = int(attempts)
+	}
+	if maxAttempts, ok := node.Props["maxAttempts"].(int64); ok {
+		job.MaxAttempts = int(maxAttempts)
+	}
+	job.ID, _ = uuid.Parse(getString(node.Props["id"]))
+	if runAfter, ok := node.Props["runAfter"].(time.Time); ok {
+		job.RunAfter = runAfter
+	}
+	if createdAt, ok := node.Props["createdAt"].(time.Time); ok {
+		job.CreatedAt = createdAt
+	}
+	if leaseExpiresAt, ok := node.Props["leaseExpiresAt"].(time.Time); ok {
+		job.LeaseExpiresAt = &leaseExpiresAt
+	}
+	if startedAt, ok := node.Props["startedAt"].(time.Time); ok {
+		job.StartedAt = &startedAt
+	}
+	if finishedAt, ok := node.Props["finishedAt"].(time.Time); ok {
+		job.FinishedAt = &finishedAt
+	}
+	return job
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"nq/graph/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// ListenImport is one play of a track from an imported listening history.
+// Tracks are matched on their album and title, and listens on ImportKey, so
+// importing the same history twice doesn't double the plays.
+type ListenImport struct {
+	ImportKey string
+	// MediaID is the album the track belongs to
+	MediaID  uuid.UUID
+	Track    string
+	PlayedAt string
+}
+
+// ImportListens writes listening events for a user, creating the tracks they
+// refer to under their albums, and returns how many were written
+func (r *Neo4jRepository) ImportListens(ctx context.Context, userID uuid.UUID, listens []*ListenImport) (int, error) {
+	rows := make([]map[string]any, 0, len(listens))
+	for _, listen := range listens {
+		rows = append(rows, map[string]any{
+			"importKey": listen.ImportKey,
+			"mediaId":   listen.MediaID.String(),
+			"track":     listen.Track,
+			"playedAt":  listen.PlayedAt,
+		})
+	}
+
+	query := `
+		MATCH (u:User {id: $userID})
+		UNWIND $rows AS row
+		MATCH (m:MusicAlbum {id: row.mediaId})
+		MERGE (t:Track {albumId: row.mediaId, title: row.track})
+		ON CREATE SET t.id = randomUUID()
+		MERGE (t)-[:TRACK_OF]->(m)
+		MERGE (u)-[:LISTENED]->(l:Listen {importKey: row.importKey})
+		ON CREATE SET l.id = randomUUID()
+		SET l.playedAt = datetime(row.playedAt)
+		MERGE (l)-[:LISTEN_OF]->(t)
+		RETURN count(l) as count
+	`
+
+	return r.importRows(ctx, query, userID, rows, nil)
+}
+
+// GetTopAlbums returns the albums a user has played most, counting the plays
+// of all their tracks
+func (r *Neo4jRepository) GetTopAlbums(ctx context.Context, userID uuid.UUID, limit int) ([]*model.AlbumPlays, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (:User {id: $userID})-[:LISTENED]->(l:Listen)-[:LISTEN_OF]->(:Track)-[:TRACK_OF]->(m:MusicAlbum)
+			WITH m, count(l) as plays, max(l.playedAt) as lastPlayedAt
+			ORDER BY plays DESC, lastPlayedAt DESC
+			LIMIT $limit
+			RETURN m as album, plays, lastPlayedAt
+		`
+
+		params := map[string]any{
+			"userID": userID.String(),
+			"limit":  limit,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		albums := []*model.AlbumPlays{}
+		for result.Next(ctx) {
+			record := result.Record()
+			node, ok := record.AsMap()["album"].(neo4j.Node)
+			if !ok {
+				continue
+			}
+			album, ok := mediaFromNode(node.Labels, node.Props).(*model.MusicAlbum)
+			if !ok {
+				continue
+			}
+
+			plays := &model.AlbumPlays{
+				Album: album,
+				Plays: getInt32FromRecord(record, "plays"),
+			}
+			if lastPlayedAt, ok := record.AsMap()["lastPlayedAt"].(time.Time); ok {
+				formatted := lastPlayedAt.UTC().Format(time.RFC3339)
+				plays.LastPlayedAt = &formatted
+			}
+			albums = append(albums, plays)
+		}
+
+		return albums, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.AlbumPlays), nil
+}
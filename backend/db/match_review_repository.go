@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"nq/graph/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// matchReviewReturn returns a review as r with its media and candidate
+const matchReviewReturn = `
+	MATCH (r)-[:REVIEWS]->(m:Media)
+	MATCH (r)-[:CANDIDATE]->(c:Media)
+	RETURN r, labels(m) as mediaLabels, properties(m) as media,
+	       labels(c) as candidateLabels, properties(c) as candidate
+`
+
+// GetMatchReviews returns possible duplicates queued by imports, most likely
+// duplicates first. A nil status returns reviews in every status.
+func (r *Neo4jRepository) GetMatchReviews(ctx context.Context, status *model.MatchReviewStatus, limit int) ([]*model.MatchReview, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (r:MatchReview)
+			WHERE $status IS NULL OR r.status = $status
+			WITH r
+		` + matchReviewReturn + `
+			ORDER BY r.confidence DESC, r.createdAt
+			LIMIT $limit
+		`
+
+		params := map[string]any{
+			"status": nil,
+			"limit":  limit,
+		}
+		if status != nil {
+			params["status"] = status.String()
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews := []*model.MatchReview{}
+		for result.Next(ctx) {
+			reviews = append(reviews, matchReviewFromRecord(result.Record()))
+		}
+
+		return reviews, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.MatchReview), nil
+}
+
+// ResolveMatchReview records whether a pending review's media and candidate
+// are the same work. Confirming merges the media into the candidate, keeping
+// the most recent rating of users who rated both; the review returned shows
+// the media as it was before the merge.
+func (r *Neo4jRepository) ResolveMatchReview(ctx context.Context, id uuid.UUID, sameWork bool) (*model.MatchReview, error) {
+	status := model.MatchReviewStatusRejected
+	if sameWork {
+		status = model.MatchReviewStatusConfirmed
+	}
+
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (r:MatchReview {id: $id})
+			WITH r, r.status as previous
+			SET r.status = CASE WHEN previous = 'PENDING' THEN $status ELSE previous END,
+			    r.resolvedAt = CASE WHEN previous = 'PENDING' THEN datetime() ELSE r.resolvedAt END
+			WITH r, previous
+		` + matchReviewReturn + `, previous
+		`
+
+		params := map[string]any{
+			"id":     id.String(),
+			"status": status.String(),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			return nil, NotFoundError("match review")
+		}
+
+		record := result.Record()
+		review := matchReviewFromRecord(record)
+		if previous := getString(record.AsMap()["previous"]); previous != model.MatchReviewStatusPending.String() {
+			return nil, ConflictError("match review was already resolved as %s", previous)
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return nil, err
+		}
+
+		if sameWork && review.Media != nil && review.Candidate != nil {
+			mediaID, candidateID := review.Media.GetID().String(), review.Candidate.GetID().String()
+			if mediaID != candidateID {
+				if _, err := mergeMedia(ctx, tx, candidateID, []string{mediaID}, model.RatingMergePolicyLatest); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return review, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.MatchReview), nil
+}
+
+// matchReviewFromRecord builds a review from a record returned by
+// matchReviewReturn
+func matchReviewFromRecord(record *neo4j.Record) *model.MatchReview {
+	values := record.AsMap()
+	node, _ := values["r"].(neo4j.Node)
+	mediaProps, _ := values["media"].(map[string]any)
+	candidateProps, _ := values["candidate"].(map[string]any)
+
+	review := &model.MatchReview{
+		Media:     mediaFromNode(getStringSlice(values["mediaLabels"]), mediaProps),
+		Candidate: mediaFromNode(getStringSlice(values["candidateLabels"]), candidateProps),
+		Reasons:   getStringSlice(node.Props["reasons"]),
+		Status:    model.MatchReviewStatus(getString(node.Props["status"])),
+	}
+	review.ID, _ = uuid.Parse(getString(node.Props["id"]))
+	review.Confidence, _ = node.Props["confidence"].(float64)
+	if createdAt, ok := node.Props["createdAt"].(time.Time); ok {
+		review.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	}
+	if resolvedAt, ok := node.Props["resolvedAt"].(time.Time); ok {
+		formatted := resolvedAt.UTC().Format(time.RFC3339)
+		review.ResolvedAt = &formatted
+	}
+	return review
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Confidence thresholds of the import matcher. Items whose best candidate
+// scores at least MatchThreshold are merged into it; those scoring at least
+// ReviewThreshold are imported as new media and queued for review as a
+// possible duplicate.
+const (
+	MatchThreshold  = 0.85
+	ReviewThreshold = 0.65
+)
+
+// Weights of the evidence a fuzzy match is scored on. A field that is
+// unknown on either side scores half its weight.
+const (
+	titleWeight   = 0.6
+	yearWeight    = 0.2
+	creatorWeight = 0.2
+)
+
+// minTitleSimilarity drops candidates, such as other works by the same
+// creator, whose titles have little in common with the item's
+const minTitleSimilarity = 0.5
+
+// leadingArticles are dropped from the start of normalized titles, so
+// "The Matrix" and "Matrix" match
+var leadingArticles = []string{"the ", "a ", "an "}
+
+// matchCandidate is existing media an import item may be a copy of
+type matchCandidate struct {
+	id       string
+	title    string
+	year     string
+	creators []string
+	// externalIDs holds the candidate's identifiers as "source:value"
+	externalIDs []string
+}
+
+// importMatch is the existing media an import item resolved to
+type importMatch struct {
+	id         string
+	confidence float64
+	reasons    []string
+}
+
+// NormalizeTitle reduces a title to a key that ignores case, accents,
+// punctuation, a leading article and spacing
+func NormalizeTitle(title string) string {
+	normalized := normalizeName(strings.ReplaceAll(title, "&", " and "))
+	for _, article := range leadingArticles {
+		if rest, ok := strings.CutPrefix(normalized, article); ok && rest != "" {
+			return rest
+		}
+	}
+	return normalized
+}
+
+// normalizeName reduces a creator name to a key that ignores case, accents,
+// punctuation and spacing
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, removeAccents(strings.ToLower(name)))), " ")
+}
+
+// removeAccents strips combining accents from s
+func removeAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(s))
+}
+
+// scoreMatch scores how likely an import item and a candidate are the same
+// work, from 0 to 1, with the evidence behind the score. Different
+// identifiers from the same source rule a candidate out.
+func scoreMatch(item *importItem, candidate *matchCandidate) (float64, []string) {
+	for _, externalID := range item.input.ExternalIds {
+		source := normalizeSource(externalID.Source)
+		value := strings.TrimSpace(externalID.Value)
+		for _, other := range candidate.externalIDs {
+			otherSource, otherValue, _ := strings.Cut(other, ":")
+			if otherSource == source && otherValue != value {
+				return 0, []string{fmt.Sprintf("different %s IDs", source)}
+			}
+		}
+	}
+
+	var reasons []string
+
+	title := titleSimilarity(NormalizeTitle(item.input.Title), NormalizeTitle(candidate.title))
+	if title < minTitleSimilarity {
+		return 0, nil
+	}
+	if title == 1 {
+		reasons = append(reasons, "same title")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("similar title (%.2f)", title))
+	}
+	score := titleWeight * title
+
+	year := releaseYear(item.input)
+	switch {
+	case year == "" || candidate.year == "":
+		score += yearWeight / 2
+		reasons = append(reasons, "release year unknown")
+	case year == candidate.year:
+		score += yearWeight
+		reasons = append(reasons, "same release year")
+	case yearsApart(year, candidate.year) == 1:
+		// Release dates often differ by region or between festival and
+		// theatrical releases
+		score += yearWeight * 0.6
+		reasons = append(reasons, fmt.Sprintf("release years %s and %s", year, candidate.year))
+	default:
+		reasons = append(reasons, fmt.Sprintf("different release years %s and %s", year, candidate.year))
+	}
+
+	names := make([]string, 0, len(item.input.Creators))
+	for _, creator := range item.input.Creators {
+		names = append(names, normalizeName(creator.Name))
+	}
+	var shared string
+	for _, creator := range candidate.creators {
+		if slices.Contains(names, normalizeName(creator)) {
+			shared = creator
+			break
+		}
+	}
+	switch {
+	case shared != "":
+		score += creatorWeight
+		reasons = append(reasons, "shared creator "+shared)
+	case len(names) == 0 || len(candidate.creators) == 0:
+		score += creatorWeight / 2
+		reasons = append(reasons, "creators unknown")
+	default:
+		reasons = append(reasons, "no creator in common")
+	}
+
+	return score, reasons
+}
+
+// titleSimilarity compares two normalized titles by edit distance and by the
+// words they share, whichever is higher, so reordered titles still match
+func titleSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	edit := 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	shared := 0
+	for _, word := range wordsA {
+		if slices.Contains(wordsB, word) {
+			shared++
+		}
+	}
+	words := float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+
+	// Below 1, since the titles differ
+	return min(max(edit, words), 0.99)
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// yearsApart returns how many years apart two four-digit years are
+func yearsApart(a, b string) int {
+	yearA, errA := strconv.Atoi(a)
+	yearB, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return -1
+	}
+	if yearA > yearB {
+		return yearA - yearB
+	}
+	return yearB - yearA
+}
+
+// BackfillNormalizedTitles sets normalizedTitle on media stored before it
+// existed, so the import matcher can find them
+func (db *Database) BackfillNormalizedTitles(ctx context.Context) error {
+	for {
+		updated, err := db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			result, err := tx.Run(ctx, `
+				MATCH (m:Media)
+				WHERE m.normalizedTitle IS NULL AND m.title IS NOT NULL AND m.id IS NOT NULL
+				RETURN m.id AS id, m.title AS title
+				LIMIT $limit
+			`, map[string]any{"limit": DefaultImportBatchSize})
+			if err != nil {
+				return nil, err
+			}
+
+			var rows []map[string]any
+			for result.Next(ctx) {
+				record := result.Record()
+				rows = append(rows, map[string]any{
+					"id":    record.AsMap()["id"],
+					"title": NormalizeTitle(getString(record.AsMap()["title"])),
+				})
+			}
+			if err := result.Err(); err != nil || len(rows) == 0 {
+				return 0, err
+			}
+
+			result, err = tx.Run(ctx, `
+				UNWIND $rows AS row
+				MATCH (m:Media {id: row.id})
+				SET m.normalizedTitle = row.title
+			`, map[string]any{"rows": rows})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := result.Consume(ctx); err != nil {
+				return nil, err
+			}
+			return len(rows), nil
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to backfill normalized titles: %w", err)
+		}
+		if updated.(int) == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"math"
+	"nq/graph/model"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"The Matrix", "Matrix"},
+		{"Blade Runner: 2049", "blade runner 2049"},
+		{"Amélie", "Amelie"},
+		{"Fast & Furious", "Fast and Furious"},
+		{"  A   Quiet Place ", "Quiet Place"},
+	}
+	for _, tt := range tests {
+		if NormalizeTitle(tt.a) != NormalizeTitle(tt.b) {
+			t.Errorf("%q normalizes to %q and %q to %q, want them equal", tt.a, NormalizeTitle(tt.a), tt.b, NormalizeTitle(tt.b))
+		}
+	}
+	// A title that is only an article keeps it
+	if got := NormalizeTitle("The"); got != "the" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"The Matrix", "Matrix", 1},
+		{"Star Wars: Episode IV - A New Hope", "Star Wars Episode IV: New Hope", 1 - 2.0/31},
+		{"Harry Potter and the Philosopher's Stone", "Harry Potter and the Sorcerer's Stone", 0.8},
+		{"Alien", "Aliens", 1 - 1.0/6},
+		// Reordered words share every word but aren't the same title
+		{"Mission Impossible", "Impossible Mission", 0.99},
+		{"The Godfather", "The Godfather Part II", 9.0 / 17},
+		{"Dune", "Dune: Part One", 1.0 / 3},
+		{"Dune", "", 0},
+	}
+	for _, tt := range tests {
+		got := titleSimilarity(NormalizeTitle(tt.a), NormalizeTitle(tt.b))
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("titleSimilarity(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScoreMatchThresholds(t *testing.T) {
+	const (
+		match  = "match"
+		review = "review"
+		create = "create"
+	)
+	year := func(y int32) *int32 { return &y }
+	item := func(title string, released int32, creators ...string) *importItem {
+		input := &model.MediaImportInput{Type: model.MediaTypeMovie, Title: title}
+		if released != 0 {
+			input.ReleaseYear = year(released)
+		}
+		for _, name := range creators {
+			input.Creators = append(input.Creators, &model.CreatorInput{Name: name, Role: "Director"})
+		}
+		return &importItem{input: input}
+	}
+
+	tests := []struct {
+		name      string
+		item      *importItem
+		candidate *matchCandidate
+		score     float64
+		outcome   string
+		reason    string
+	}{
+		{
+			"same title, year and director",
+			item("The Matrix", 1999, "Lana Wachowski"),
+			&matchCandidate{title: "Matrix", year: "1999", creators: []string{"Lilly Wachowski", "Lana Wachowski"}},
+			1, match, "shared creator Lana Wachowski",
+		},
+		{
+			"accents and punctuation",
+			item("Amelie", 2001, "jean-pierre jeunet"),
+			&matchCandidate{title: "Amélie", year: "2001", creators: []string{"Jean-Pierre Jeunet"}},
+			1, match, "same title",
+		},
+		{
+			"regional title",
+			item("Harry Potter and the Sorcerer's Stone", 2001, "Chris Columbus"),
+			&matchCandidate{title: "Harry Potter and the Philosopher's Stone", year: "2001", creators: []string{"Chris Columbus"}},
+			0.6*0.8 + 0.2 + 0.2, match, "similar title (0.80)",
+		},
+		{
+			"release years a year apart",
+			item("Parasite", 2020, "Bong Joon-ho"),
+			&matchCandidate{title: "Parasite", year: "2019", creators: []string{"Bong Joon Ho"}},
+			0.6 + 0.2*0.6 + 0.2, match, "release years 2020 and 2019",
+		},
+		{
+			"nothing known but the title",
+			item("Heat", 0),
+			&matchCandidate{title: "Heat", year: "1995", creators: []string{"Michael Mann"}},
+			0.6 + 0.1 + 0.1, review, "release year unknown",
+		},
+		{
+			"same title and director years apart",
+			item("Funny Games", 2007, "Michael Haneke"),
+			&matchCandidate{title: "Funny Games", year: "1997", creators: []string{"Michael Haneke"}},
+			0.6 + 0.2, review, "different release years 2007 and 1997",
+		},
+		{
+			"same title by someone else",
+			item("Crash", 2004, "Paul Haggis"),
+			&matchCandidate{title: "Crash", year: "1996", creators: []string{"David Cronenberg"}},
+			0.6, create, "no creator in common",
+		},
+		{
+			"sequel",
+			item("Aliens", 1986, "James Cameron"),
+			&matchCandidate{title: "Alien", year: "1979", creators: []string{"Ridley Scott"}},
+			0.6 * (1 - 1.0/6), create, "similar title (0.83)",
+		},
+		{
+			"sequel by the same director",
+			item("The Godfather Part II", 1974, "Francis Ford Coppola"),
+			&matchCandidate{title: "The Godfather", year: "1972", creators: []string{"Francis Ford Coppola"}},
+			0.6*9/17 + 0.2, create, "shared creator Francis Ford Coppola",
+		},
+		{
+			"titles too far apart",
+			item("Dune: Part One", 2021, "Denis Villeneuve"),
+			&matchCandidate{title: "Dune", year: "2021", creators: []string{"Denis Villeneuve"}},
+			0, create, "",
+		},
+	}
+	for _, tt := range tests {
+		score, reasons := scoreMatch(tt.item, tt.candidate)
+
+		outcome := create
+		switch {
+		case score >= MatchThreshold:
+			outcome = match
+		case score >= ReviewThreshold:
+			outcome = review
+		}
+		if math.Abs(score-tt.score) > 1e-9 || outcome != tt.outcome {
+			t.Errorf("%s: scored %g (%s), want %g (%s)", tt.name, score, outcome, tt.score, tt.outcome)
+		}
+		if tt.reason != "" && !slices.Contains(reasons, tt.reason) {
+			t.Errorf("%s: got reasons %q, want %q among them", tt.name, reasons, tt.reason)
+		}
+	}
+}
+
+func TestScoreMatchRulesOutDifferentIDs(t *testing.T) {
+	item := &importItem{input: &model.MediaImportInput{
+		Type:        model.MediaTypeMovie,
+		Title:       "Heat",
+		ExternalIds: []*model.ExternalIDInput{{Source: "IMDb", Value: "tt0113277"}},
+	}}
+
+	score, reasons := scoreMatch(item, &matchCandidate{title: "Heat", externalIDs: []string{"imdb:tt0113278"}})
+	if score != 0 || !slices.Equal(reasons, []string{"different imdb IDs"}) {
+		t.Errorf("got %g %q, want the candidate ruled out", score, reasons)
+	}
+
+	// An ID from another source says nothing
+	score, _ = scoreMatch(item, &matchCandidate{title: "Heat", externalIDs: []string{"tmdb:949"}})
+	if math.Abs(score-0.8) > 1e-9 {
+		t.Errorf("got %g, want the title alone scored", score)
+	}
+}
@@ -2,9 +2,12 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"nq/graph/model"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
@@ -19,12 +22,14 @@ func (r *Neo4jRepository) CreateMovie(ctx context.Context, input model.CreateMov
 			CREATE (m:Movie:Media {
 				id: $id,
 				title: $title,
+				normalizedTitle: $normalizedTitle,
 				releaseDate: $releaseDate,
 				description: $description,
 				coverUrl: $coverUrl,
 				runtime: $runtime,
 				budget: $budget,
 				boxOffice: $boxOffice,
+				fieldSources: $fieldSources,
 				createdAt: datetime(),
 				updatedAt: datetime()
 			})
@@ -34,16 +39,19 @@ func (r *Neo4jRepository) CreateMovie(ctx context.Context, input model.CreateMov
 		`
 
 		params := map[string]any{
-			"id":          movieID.String(),
-			"title":       input.Title,
-			"releaseDate": input.ReleaseDate,
-			"description": input.Description,
-			"coverUrl":    input.CoverURL,
-			"runtime":     input.Runtime,
-			"budget":      input.Budget,
-			"boxOffice":   input.BoxOffice,
+			"id":              movieID.String(),
+			"title":           input.Title,
+			"normalizedTitle": NormalizeTitle(input.Title),
+			"releaseDate":     input.ReleaseDate,
+			"description":     input.Description,
+			"coverUrl":        input.CoverURL,
+			"runtime":         input.Runtime,
+			"budget":          input.Budget,
+			"boxOffice":       input.BoxOffice,
 		}
 
+		params["fieldSources"] = userFieldSources(params, "releaseDate", "description", "coverUrl", "runtime", "budget", "boxOffice")
+
 		result, err := tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
@@ -116,7 +124,7 @@ func (r *Neo4jRepository) GetMovieByID(ctx context.Context, id uuid.UUID) (*mode
 			return movie, nil
 		}
 
-		return nil, fmt.Errorf("movie not found")
+		return nil, NotFoundError("movie")
 	})
 
 	if err != nil {
@@ -187,12 +195,14 @@ func (r *Neo4jRepository) CreateTVShow(ctx context.Context, input model.CreateTV
 			CREATE (t:TVShow:Media {
 				id: $id,
 				title: $title,
+				normalizedTitle: $normalizedTitle,
 				releaseDate: $releaseDate,
 				description: $description,
 				coverUrl: $coverUrl,
 				seasons: $seasons,
 				episodes: $episodes,
 				status: $status,
+				fieldSources: $fieldSources,
 				createdAt: datetime(),
 				updatedAt: datetime()
 			})
@@ -202,16 +212,19 @@ func (r *Neo4jRepository) CreateTVShow(ctx context.Context, input model.CreateTV
 		`
 
 		params := map[string]any{
-			"id":          tvShowID.String(),
-			"title":       input.Title,
-			"releaseDate": input.ReleaseDate,
-			"description": input.Description,
-			"coverUrl":    input.CoverURL,
-			"seasons":     input.Seasons,
-			"episodes":    input.Episodes,
-			"status":      input.Status,
+			"id":              tvShowID.String(),
+			"title":           input.Title,
+			"normalizedTitle": NormalizeTitle(input.Title),
+			"releaseDate":     input.ReleaseDate,
+			"description":     input.Description,
+			"coverUrl":        input.CoverURL,
+			"seasons":         input.Seasons,
+			"episodes":        input.Episodes,
+			"status":          input.Status,
 		}
 
+		params["fieldSources"] = userFieldSources(params, "releaseDate", "description", "coverUrl", "seasons", "episodes", "status")
+
 		result, err := tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
@@ -284,7 +297,7 @@ func (r *Neo4jRepository) GetTVShowByID(ctx context.Context, id uuid.UUID) (*mod
 			return tvShow, nil
 		}
 
-		return nil, fmt.Errorf("TV show not found")
+		return nil, NotFoundError("TV show")
 	})
 
 	if err != nil {
@@ -349,63 +362,71 @@ func (r *Neo4jRepository) GetAllTVShows(ctx context.Context) ([]*model.TVShow, e
 // Similar implementations for Book, Game, and MusicAlbum would follow the same pattern
 // For brevity, I'll implement a few key ones and provide stubs for the rest
 
-// CreateBook creates a new book in the database
+// CreateBook creates a new book in the database. A book with an ISBN gets
+// its first edition, which no other book may share.
 func (r *Neo4jRepository) CreateBook(ctx context.Context, input model.CreateBookInput) (*model.Book, error) {
 	bookID := uuid.New()
 
+	isbn13, isbn10, err := canonicalISBN(input.Isbn)
+	if err != nil {
+		return nil, err
+	}
+
 	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
 			CREATE (b:Book:Media {
 				id: $id,
 				title: $title,
+				normalizedTitle: $normalizedTitle,
 				releaseDate: $releaseDate,
 				description: $description,
 				coverUrl: $coverUrl,
 				pages: $pages,
 				isbn: $isbn,
+				isbn10: $isbn10,
 				publisher: $publisher,
+				fieldSources: $fieldSources,
 				createdAt: datetime(),
 				updatedAt: datetime()
 			})
+			FOREACH (_ IN CASE WHEN $isbn IS NULL THEN [] ELSE [1] END |
+				CREATE (:Edition {
+					id: $editionId,
+					isbn13: $isbn,
+					isbn10: $isbn10,
+					publisher: $publisher,
+					pages: $pages,
+					releaseDate: $releaseDate,
+					createdAt: datetime()
+				})-[:EDITION_OF]->(b))
 			RETURN b.id as id, b.title as title, b.releaseDate as releaseDate,
 			       b.description as description, b.coverUrl as coverUrl,
-			       b.pages as pages, b.isbn as isbn, b.publisher as publisher
+			       b.pages as pages, b.isbn as isbn, b.isbn10 as isbn10, b.publisher as publisher
 		`
 
 		params := map[string]any{
-			"id":          bookID.String(),
-			"title":       input.Title,
-			"releaseDate": input.ReleaseDate,
-			"description": input.Description,
-			"coverUrl":    input.CoverURL,
-			"pages":       input.Pages,
-			"isbn":        input.Isbn,
-			"publisher":   input.Publisher,
+			"id":              bookID.String(),
+			"editionId":       uuid.New().String(),
+			"title":           input.Title,
+			"normalizedTitle": NormalizeTitle(input.Title),
+			"releaseDate":     input.ReleaseDate,
+			"description":     input.Description,
+			"coverUrl":        input.CoverURL,
+			"pages":           input.Pages,
+			"isbn":            isbn13,
+			"isbn10":          isbn10,
+			"publisher":       input.Publisher,
 		}
 
+		params["fieldSources"] = userFieldSources(params, "releaseDate", "description", "coverUrl", "pages", "isbn", "isbn10", "publisher")
+
 		result, err := tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
 		}
 
 		if result.Next(ctx) {
-			record := result.Record()
-			book := &model.Book{
-				ID:            bookID,
-				Title:         record.AsMap()["title"].(string),
-				ReleaseDate:   getStringPointer(record.AsMap()["releaseDate"]),
-				Description:   getStringPointer(record.AsMap()["description"]),
-				CoverURL:      getStringPointer(record.AsMap()["coverUrl"]),
-				Pages:         getInt32Pointer(record.AsMap()["pages"]),
-				Isbn:          getStringPointer(record.AsMap()["isbn"]),
-				Publisher:     getStringPointer(record.AsMap()["publisher"]),
-				Creators:      []*model.Creator{},
-				Platforms:     []*model.Platform{},
-				Tags:          []*model.Tag{},
-				Ratings:       []*model.Rating{},
-				AverageRating: nil,
-			}
-			return book, nil
+			return bookFromRecord(bookID, result.Record()), nil
 		}
 
 		return nil, fmt.Errorf("failed to create book")
@@ -420,14 +441,89 @@ func (r *Neo4jRepository) CreateBook(ctx context.Context, input model.CreateBook
 
 // GetBookByID retrieves a book by its ID
 func (r *Neo4jRepository) GetBookByID(ctx context.Context, id uuid.UUID) (*model.Book, error) {
-	// Implementation similar to GetMovieByID but for Book
-	return nil, fmt.Errorf("not implemented")
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (b:Book {id: $id})
+			RETURN b.id as id, b.title as title, b.releaseDate as releaseDate,
+			       b.description as description, b.coverUrl as coverUrl,
+			       b.pages as pages, b.isbn as isbn, b.isbn10 as isbn10, b.publisher as publisher
+		`
+
+		params := map[string]any{"id": id.String()}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return bookFromRecord(id, result.Record()), nil
+		}
+
+		return nil, NotFoundError("book")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.Book), nil
 }
 
 // GetAllBooks retrieves all books
 func (r *Neo4jRepository) GetAllBooks(ctx context.Context) ([]*model.Book, error) {
-	// Implementation similar to GetAllMovies but for Book
-	return nil, fmt.Errorf("not implemented")
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (b:Book)
+			RETURN b.id as id, b.title as title, b.releaseDate as releaseDate,
+			       b.description as description, b.coverUrl as coverUrl,
+			       b.pages as pages, b.isbn as isbn, b.isbn10 as isbn10, b.publisher as publisher
+			ORDER BY b.title
+		`
+
+		result, err := tx.Run(ctx, query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		books := []*model.Book{}
+		for result.Next(ctx) {
+			record := result.Record()
+			bookID, err := uuid.Parse(record.AsMap()["id"].(string))
+			if err != nil {
+				return nil, err
+			}
+			books = append(books, bookFromRecord(bookID, record))
+		}
+
+		return books, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.Book), nil
+}
+
+// bookFromRecord builds a book from a record returning the book fields
+func bookFromRecord(id uuid.UUID, record *neo4j.Record) *model.Book {
+	return &model.Book{
+		ID:            id,
+		Title:         record.AsMap()["title"].(string),
+		ReleaseDate:   getStringPointer(record.AsMap()["releaseDate"]),
+		Description:   getStringPointer(record.AsMap()["description"]),
+		CoverURL:      getStringPointer(record.AsMap()["coverUrl"]),
+		Pages:         getInt32Pointer(record.AsMap()["pages"]),
+		Isbn:          getStringPointer(record.AsMap()["isbn"]),
+		Isbn10:        getStringPointer(record.AsMap()["isbn10"]),
+		Publisher:     getStringPointer(record.AsMap()["publisher"]),
+		Creators:      []*model.Creator{},
+		Platforms:     []*model.Platform{},
+		Tags:          []*model.Tag{},
+		Ratings:       []*model.Rating{},
+		AverageRating: nil,
+	}
 }
 
 // CreateGame creates a new game in the database
@@ -462,18 +558,417 @@ func (r *Neo4jRepository) GetAllMusicAlbums(ctx context.Context) ([]*model.Music
 	return nil, fmt.Errorf("not implemented")
 }
 
-// GetMediaByID retrieves any media by its ID
+// GetVideoByID retrieves a video by its ID
+func (r *Neo4jRepository) GetVideoByID(ctx context.Context, id uuid.UUID) (*model.Video, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (v:Video {id: $id})
+			RETURN v.id as id, v.title as title, v.releaseDate as releaseDate,
+			       v.description as description, v.coverUrl as coverUrl,
+			       v.url as url, v.duration as duration
+		`
+
+		params := map[string]any{"id": id.String()}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return videoFromRecord(id, result.Record()), nil
+		}
+
+		return nil, NotFoundError("video")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.Video), nil
+}
+
+// GetAllVideos retrieves all videos
+func (r *Neo4jRepository) GetAllVideos(ctx context.Context) ([]*model.Video, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (v:Video)
+			RETURN v.id as id, v.title as title, v.releaseDate as releaseDate,
+			       v.description as description, v.coverUrl as coverUrl,
+			       v.url as url, v.duration as duration
+			ORDER BY v.title
+		`
+
+		result, err := tx.Run(ctx, query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		videos := []*model.Video{}
+		for result.Next(ctx) {
+			record := result.Record()
+			videoID, err := uuid.Parse(record.AsMap()["id"].(string))
+			if err != nil {
+				return nil, err
+			}
+			videos = append(videos, videoFromRecord(videoID, record))
+		}
+
+		return videos, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.Video), nil
+}
+
+// videoFromRecord builds a video from a record returning the video fields
+func videoFromRecord(id uuid.UUID, record *neo4j.Record) *model.Video {
+	return &model.Video{
+		ID:          id,
+		Title:       getString(record.AsMap()["title"]),
+		ReleaseDate: getStringPointer(record.AsMap()["releaseDate"]),
+		Description: getStringPointer(record.AsMap()["description"]),
+		CoverURL:    getStringPointer(record.AsMap()["coverUrl"]),
+		URL:         getStringPointer(record.AsMap()["url"]),
+		Duration:    getInt32Pointer(record.AsMap()["duration"]),
+		Creators:    []*model.Creator{},
+		Platforms:   []*model.Platform{},
+		Tags:        []*model.Tag{},
+		Ratings:     []*model.Rating{},
+	}
+}
+
+// GetStreamByID retrieves a stream by its ID
+func (r *Neo4jRepository) GetStreamByID(ctx context.Context, id uuid.UUID) (*model.Stream, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (s:Stream {id: $id})
+			RETURN s.id as id, s.title as title, s.releaseDate as releaseDate,
+			       s.description as description, s.coverUrl as coverUrl,
+			       s.url as url, s.duration as duration
+		`
+
+		params := map[string]any{"id": id.String()}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return streamFromRecord(id, result.Record()), nil
+		}
+
+		return nil, NotFoundError("stream")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.Stream), nil
+}
+
+// GetAllStreams retrieves all streams
+func (r *Neo4jRepository) GetAllStreams(ctx context.Context) ([]*model.Stream, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (s:Stream)
+			RETURN s.id as id, s.title as title, s.releaseDate as releaseDate,
+			       s.description as description, s.coverUrl as coverUrl,
+			       s.url as url, s.duration as duration
+			ORDER BY s.title
+		`
+
+		result, err := tx.Run(ctx, query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		streams := []*model.Stream{}
+		for result.Next(ctx) {
+			record := result.Record()
+			streamID, err := uuid.Parse(record.AsMap()["id"].(string))
+			if err != nil {
+				return nil, err
+			}
+			streams = append(streams, streamFromRecord(streamID, record))
+		}
+
+		return streams, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.Stream), nil
+}
+
+// streamFromRecord builds a stream from a record returning the stream fields
+func streamFromRecord(id uuid.UUID, record *neo4j.Record) *model.Stream {
+	return &model.Stream{
+		ID:          id,
+		Title:       getString(record.AsMap()["title"]),
+		ReleaseDate: getStringPointer(record.AsMap()["releaseDate"]),
+		Description: getStringPointer(record.AsMap()["description"]),
+		CoverURL:    getStringPointer(record.AsMap()["coverUrl"]),
+		URL:         getStringPointer(record.AsMap()["url"]),
+		Duration:    getInt32Pointer(record.AsMap()["duration"]),
+		Creators:    []*model.Creator{},
+		Platforms:   []*model.Platform{},
+		Tags:        []*model.Tag{},
+		Ratings:     []*model.Rating{},
+	}
+}
+
+// WordsPerMinute is the reading speed used to estimate an article's read time
+const WordsPerMinute = 238
+
+// ReadTime estimates the minutes it takes to read wordCount words, rounded up
+func ReadTime(wordCount int32) int32 {
+	if wordCount <= 0 {
+		return 0
+	}
+	return (wordCount + WordsPerMinute - 1) / WordsPerMinute
+}
+
+// SiteName returns the host of an article URL without a leading "www.", or ""
+// if the URL has no host
+func SiteName(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// GetArticleByID retrieves an article by its ID
+func (r *Neo4jRepository) GetArticleByID(ctx context.Context, id uuid.UUID) (*model.Article, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (a:Article {id: $id})
+			RETURN a.id as id, a.title as title, a.releaseDate as releaseDate,
+			       a.description as description, a.coverUrl as coverUrl,
+			       a.url as url, a.site as site, a.author as author,
+			       a.wordCount as wordCount, a.readTime as readTime
+		`
+
+		params := map[string]any{"id": id.String()}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return articleFromRecord(id, result.Record()), nil
+		}
+
+		return nil, NotFoundError("article")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*model.Article), nil
+}
+
+// GetAllArticles retrieves all articles
+func (r *Neo4jRepository) GetAllArticles(ctx context.Context) ([]*model.Article, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (a:Article)
+			RETURN a.id as id, a.title as title, a.releaseDate as releaseDate,
+			       a.description as description, a.coverUrl as coverUrl,
+			       a.url as url, a.site as site, a.author as author,
+			       a.wordCount as wordCount, a.readTime as readTime
+			ORDER BY a.title
+		`
+
+		result, err := tx.Run(ctx, query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		articles := []*model.Article{}
+		for result.Next(ctx) {
+			record := result.Record()
+			articleID, err := uuid.Parse(record.AsMap()["id"].(string))
+			if err != nil {
+				return nil, err
+			}
+			articles = append(articles, articleFromRecord(articleID, record))
+		}
+
+		return articles, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*model.Article), nil
+}
+
+// articleFromRecord builds an article from a record returning the article
+// fields
+func articleFromRecord(id uuid.UUID, record *neo4j.Record) *model.Article {
+	return &model.Article{
+		ID:          id,
+		Title:       getString(record.AsMap()["title"]),
+		ReleaseDate: getStringPointer(record.AsMap()["releaseDate"]),
+		Description: getStringPointer(record.AsMap()["description"]),
+		CoverURL:    getStringPointer(record.AsMap()["coverUrl"]),
+		URL:         getStringPointer(record.AsMap()["url"]),
+		Site:        getStringPointer(record.AsMap()["site"]),
+		Author:      getStringPointer(record.AsMap()["author"]),
+		WordCount:   getInt32Pointer(record.AsMap()["wordCount"]),
+		ReadTime:    getInt32Pointer(record.AsMap()["readTime"]),
+		Creators:    []*model.Creator{},
+		Platforms:   []*model.Platform{},
+		Tags:        []*model.Tag{},
+		Ratings:     []*model.Rating{},
+	}
+}
+
+// GetMediaByID retrieves any media by its ID, following the redirects left by
+// merges
 func (r *Neo4jRepository) GetMediaByID(ctx context.Context, id uuid.UUID) (model.Media, error) {
-	// Try each media type
-	if movie, err := r.GetMovieByID(ctx, id); err == nil {
+	// Try each media type, stopping on anything other than a miss
+	movie, err := r.GetMovieByID(ctx, id)
+	if err == nil {
 		return movie, nil
 	}
-	if tvShow, err := r.GetTVShowByID(ctx, id); err == nil {
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	tvShow, err := r.GetTVShowByID(ctx, id)
+	if err == nil {
 		return tvShow, nil
 	}
-	// Add other media types...
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	video, err := r.GetVideoByID(ctx, id)
+	if err == nil {
+		return video, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	article, err := r.GetArticleByID(ctx, id)
+	if err == nil {
+		return article, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	stream, err := r.GetStreamByID(ctx, id)
+	if err == nil {
+		return stream, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	// Other media types are built straight from their nodes
+	media, err := r.getMediaNode(ctx, id)
+	if err == nil {
+		return media, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
 
-	return nil, fmt.Errorf("media not found")
+	// Media merged into other media resolve to the media they were merged into
+	target, err := r.mediaRedirect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if target != uuid.Nil {
+		return r.GetMediaByID(ctx, target)
+	}
+
+	return nil, NotFoundError("media")
+}
+
+// getMediaNode retrieves any media by its ID, building the model from the
+// node's labels and properties
+func (r *Neo4jRepository) getMediaNode(ctx context.Context, id uuid.UUID) (model.Media, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (m:Media {id: $id})
+			RETURN labels(m) as labels, properties(m) as props
+		`
+
+		result, err := tx.Run(ctx, query, map[string]any{"id": id.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			record := result.Record()
+			props, _ := record.AsMap()["props"].(map[string]any)
+			if media := mediaFromNode(getStringSlice(record.AsMap()["labels"]), props); media != nil {
+				return media, nil
+			}
+		}
+
+		return nil, NotFoundError("media")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(model.Media), nil
+}
+
+// GetMediaByExternalID returns the media identified by an ID in another
+// service
+func (r *Neo4jRepository) GetMediaByExternalID(ctx context.Context, source, value string) (model.Media, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (m:Media)-[:IDENTIFIED_BY]->(:ExternalID {source: $source, value: $value})
+			RETURN labels(m) as labels, properties(m) as props
+			LIMIT 1
+		`
+
+		params := map[string]any{
+			"source": normalizeSource(source),
+			"value":  strings.TrimSpace(value),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			record := result.Record()
+			props, _ := record.AsMap()["props"].(map[string]any)
+			if media := mediaFromNode(getStringSlice(record.AsMap()["labels"]), props); media != nil {
+				return media, nil
+			}
+		}
+
+		return nil, NotFoundError("media")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(model.Media), nil
 }
 
 // GetAllMedia retrieves all media items
@@ -482,6 +977,174 @@ func (r *Neo4jRepository) GetAllMedia(ctx context.Context) ([]model.Media, error
 	return nil, fmt.Errorf("not implemented")
 }
 
+// mediaFromNode builds the media model matching a node's labels from its
+// properties. It returns nil for nodes that aren't a known media type.
+func mediaFromNode(labels []string, props map[string]any) model.Media {
+	id, err := uuid.Parse(getString(props["id"]))
+	if err != nil {
+		return nil
+	}
+
+	title := getString(props["title"])
+	releaseDate := getStringPointer(props["releaseDate"])
+	description := getStringPointer(props["description"])
+	coverURL := getStringPointer(props["coverUrl"])
+
+	for _, label := range labels {
+		switch label {
+		case "Movie":
+			return &model.Movie{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				Runtime:     getInt32Pointer(props["runtime"]),
+				Budget:      getInt32Pointer(props["budget"]),
+				BoxOffice:   getInt32Pointer(props["boxOffice"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "TVShow":
+			return &model.TVShow{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				Seasons:     getInt32Pointer(props["seasons"]),
+				Episodes:    getInt32Pointer(props["episodes"]),
+				Status:      getStringPointer(props["status"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "Book":
+			return &model.Book{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				Pages:       getInt32Pointer(props["pages"]),
+				Isbn:        getStringPointer(props["isbn"]),
+				Isbn10:      getStringPointer(props["isbn10"]),
+				Publisher:   getStringPointer(props["publisher"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "Game":
+			return &model.Game{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				Genre:       getStringSlice(props["genre"]),
+				EsrbRating:  getStringPointer(props["esrbRating"]),
+				Multiplayer: getBoolPointer(props["multiplayer"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "MusicAlbum":
+			return &model.MusicAlbum{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				TrackCount:  getInt32Pointer(props["trackCount"]),
+				Duration:    getInt32Pointer(props["duration"]),
+				Label:       getStringPointer(props["label"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "Video":
+			return &model.Video{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				URL:         getStringPointer(props["url"]),
+				Duration:    getInt32Pointer(props["duration"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "Stream":
+			return &model.Stream{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				URL:         getStringPointer(props["url"]),
+				Duration:    getInt32Pointer(props["duration"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		case "Article":
+			return &model.Article{
+				ID:          id,
+				Title:       title,
+				ReleaseDate: releaseDate,
+				Description: description,
+				CoverURL:    coverURL,
+				URL:         getStringPointer(props["url"]),
+				Site:        getStringPointer(props["site"]),
+				Author:      getStringPointer(props["author"]),
+				WordCount:   getInt32Pointer(props["wordCount"]),
+				ReadTime:    getInt32Pointer(props["readTime"]),
+				Creators:    []*model.Creator{},
+				Platforms:   []*model.Platform{},
+				Tags:        []*model.Tag{},
+				Ratings:     []*model.Rating{},
+			}
+		}
+	}
+
+	return nil
+}
+
+// Helper function to safely get a string from interface{}
+func getString(value any) string {
+	str, _ := value.(string)
+	return str
+}
+
+// Helper function to safely get a string slice from a Neo4j list
+func getStringSlice(value any) []string {
+	list, _ := value.([]any)
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if str, ok := item.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+// Helper function to safely get bool pointer from interface{}
+func getBoolPointer(value any) *bool {
+	if b, ok := value.(bool); ok {
+		return &b
+	}
+	return nil
+}
+
 // Helper function to safely get int32 pointer from interface{}
 func getInt32Pointer(value interface{}) *int32 {
 	if value == nil {
@@ -0,0 +1,655 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"nq/graph/model"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// MaxMergeMedia bounds how many media one merge may fold into another
+const MaxMergeMedia = 100
+
+// mergedRelationships are moved from merged media onto the kept media as
+// they are. Ratings, creators and tracks are moved separately, since they
+// have to be deduplicated, and a user's activities are folded together
+// before theirs are moved.
+var mergedRelationships = []struct {
+	kind     string
+	outgoing bool
+	// idProperty names the property in which nodes also store the media's
+	// ID, if they do
+	idProperty string
+}{
+	{kind: "ACTIVITY_FOR", idProperty: "mediaId"},
+	{kind: "RECOMMENDS", idProperty: "mediaId"},
+	{kind: "FAVORITES"},
+	{kind: "HOSTS"},
+	{kind: "STREAMS"},
+	{kind: "REVIEWS", idProperty: "mediaId"},
+	{kind: "CANDIDATE", idProperty: "candidateId"},
+	{kind: "EDITION_OF"},
+	{kind: "TAGGED_WITH", outgoing: true},
+	{kind: "IDENTIFIED_BY", outgoing: true},
+}
+
+// mergedRating is one user's rating of one of the media being merged
+type mergedRating struct {
+	userID  string
+	mediaID string
+	score   float64
+	// ratedAt is in epoch milliseconds, 0 when unknown
+	ratedAt int64
+}
+
+// MergeMedia folds duplicates of the same work into keepID in one
+// transaction and leaves a redirect for each merged ID
+func (r *Neo4jRepository) MergeMedia(ctx context.Context, keepID uuid.UUID, mergeIDs []uuid.UUID, policy model.RatingMergePolicy) (*model.MediaMergeResult, error) {
+	if len(mergeIDs) == 0 {
+		return nil, ValidationFailedError("mergeIds must name at least one media")
+	}
+	if len(mergeIDs) > MaxMergeMedia {
+		return nil, ValidationFailedError("at most %d media can be merged at once", MaxMergeMedia)
+	}
+	if !policy.IsValid() {
+		return nil, ValidationFailedError("unknown rating policy %q", policy)
+	}
+
+	merged := make([]string, 0, len(mergeIDs))
+	ids := make([]uuid.UUID, 0, len(mergeIDs))
+	for _, id := range mergeIDs {
+		if id == keepID {
+			return nil, ValidationFailedError("media %s can't be merged into itself", id)
+		}
+		if !slices.Contains(merged, id.String()) {
+			merged = append(merged, id.String())
+			ids = append(ids, id)
+		}
+	}
+
+	conflicts, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		return mergeMedia(ctx, tx, keepID.String(), merged, policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := r.GetMediaByID(ctx, keepID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.MediaMergeResult{
+		Media:           media,
+		MergedIds:       ids,
+		RatingConflicts: int32(conflicts.(int)),
+	}, nil
+}
+
+// mergeMedia merges media into keepID within tx and returns how many users
+// had rated more than one of them
+func mergeMedia(ctx context.Context, tx neo4j.ManagedTransaction, keepID string, mergeIDs []string, policy model.RatingMergePolicy) (int, error) {
+	fill, err := lockMergedMedia(ctx, tx, keepID, mergeIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	conflicts, err := mergeRatings(ctx, tx, keepID, mergeIDs, policy)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := mergeActivities(ctx, tx, keepID, mergeIDs); err != nil {
+		return 0, err
+	}
+
+	params := map[string]any{
+		"keepId":   keepID,
+		"mergeIds": mergeIDs,
+		"fill":     fill,
+	}
+
+	type write struct {
+		query  string
+		params map[string]any
+	}
+
+	writes := []write{{`
+		MATCH (c:Creator)-[rel:CREATED]->(m:Media)
+		WHERE m.id IN $mergeIds
+		MATCH (k:Media {id: $keepId})
+		WITH c, k, rel, rel.role AS role
+		DELETE rel
+		WITH DISTINCT c, k, role
+		WHERE NOT EXISTS {
+			MATCH (c)-[existing:CREATED]->(k)
+			WHERE existing.role = role OR (existing.role IS NULL AND role IS NULL)
+		}
+		CREATE (c)-[:CREATED {role: role}]->(k)
+	`, params}}
+
+	// Tracks are unique per album and title, so each album's tracks are moved
+	// in turn, folding them into the kept album's track of the same title
+	for _, mergeID := range mergeIDs {
+		trackParams := maps.Clone(params)
+		trackParams["mergeId"] = mergeID
+		writes = append(writes, write{`
+			MATCH (t:Track {albumId: $mergeId})
+			MATCH (same:Track {albumId: $keepId, title: t.title})
+			OPTIONAL MATCH (l:Listen)-[rel:LISTEN_OF]->(t)
+			FOREACH (listen IN CASE WHEN l IS NULL THEN [] ELSE [l] END | MERGE (listen)-[:LISTEN_OF]->(same))
+			DELETE rel
+			WITH DISTINCT t
+			DETACH DELETE t
+		`, trackParams}, write{`
+			MATCH (t:Track {albumId: $mergeId})-[rel:TRACK_OF]->()
+			MATCH (k:Media {id: $keepId})
+			SET t.albumId = $keepId
+			CREATE (t)-[:TRACK_OF]->(k)
+			DELETE rel
+		`, trackParams})
+	}
+
+	// The relationship types come from mergedRelationships, never from user
+	// input
+	for _, relationship := range mergedRelationships {
+		match := "(x)-[rel:" + relationship.kind + "]->(m:Media)"
+		merge := "(x)-[moved:" + relationship.kind + "]->(k)"
+		if relationship.outgoing {
+			match = "(m:Media)-[rel:" + relationship.kind + "]->(x)"
+			merge = "(k)-[moved:" + relationship.kind + "]->(x)"
+		}
+		query := `
+			MATCH ` + match + `
+			WHERE m.id IN $mergeIds
+			MATCH (k:Media {id: $keepId})
+			MERGE ` + merge + `
+			ON CREATE SET moved = properties(rel)
+			DELETE rel
+		`
+		if property := relationship.idProperty; property != "" {
+			query += `SET x.` + property + ` = CASE WHEN x.` + property + ` IS NULL THEN null ELSE $keepId END`
+		}
+		writes = append(writes, write{query, params})
+	}
+
+	writes = append(writes, write{`
+		MATCH (r:MatchReview)-[:REVIEWS]->(:Media {id: $keepId})<-[:CANDIDATE]-(r)
+		WHERE r.status = 'PENDING'
+		SET r.status = 'CONFIRMED', r.resolvedAt = datetime()
+	`, params}, write{`
+		MATCH (k:Media {id: $keepId})
+		SET k += $fill, k.updatedAt = datetime()
+	`, params}, write{`
+		MATCH (m:Media)
+		WHERE m.id IN $mergeIds
+		DETACH DELETE m
+	`, params}, write{`
+		MATCH (d:MediaRedirect)
+		WHERE d.toId IN $mergeIds
+		SET d.toId = $keepId
+	`, params}, write{`
+		UNWIND $mergeIds AS id
+		MERGE (d:MediaRedirect {fromId: id})
+		SET d.toId = $keepId, d.mergedAt = datetime()
+	`, params})
+
+	for _, write := range writes {
+		result, err := tx.Run(ctx, write.query, write.params)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// lockMergedMedia takes the write lock on the kept and merged media, checks
+// they exist and are the same type of media, and returns the properties the
+// kept media lacks, taken from the merged media in the order given
+func lockMergedMedia(ctx context.Context, tx neo4j.ManagedTransaction, keepID string, mergeIDs []string) (map[string]any, error) {
+	query := `
+		UNWIND $ids AS id
+		OPTIONAL MATCH (m:Media {id: id})
+		SET m.mergeLock = true
+		REMOVE m.mergeLock
+		RETURN id, [label IN labels(m) WHERE label <> 'Media'] AS labels, properties(m) AS props
+	`
+
+	result, err := tx.Run(ctx, query, map[string]any{"ids": append([]string{keepID}, mergeIDs...)})
+	if err != nil {
+		return nil, err
+	}
+
+	var keepType string
+	var keep map[string]any
+	fill := map[string]any{}
+	for result.Next(ctx) {
+		record := result.Record().AsMap()
+		id := getString(record["id"])
+		props, ok := record["props"].(map[string]any)
+		if !ok {
+			return nil, NotFoundError("media " + id)
+		}
+
+		labels := getStringSlice(record["labels"])
+		slices.Sort(labels)
+		mediaType := strings.Join(labels, ":")
+		if keep == nil {
+			keepType, keep = mediaType, props
+			continue
+		}
+		if mediaType != keepType {
+			return nil, ValidationFailedError("media %s is a %s, not a %s like %s", id, mediaType, keepType, keepID)
+		}
+
+		for key, value := range props {
+			if _, ok := keep[key]; !ok && value != nil {
+				if _, ok := fill[key]; !ok {
+					fill[key] = value
+				}
+			}
+		}
+	}
+
+	return fill, result.Err()
+}
+
+// mergeRatings settles users who rated more than one of the media with the
+// policy, leaving one rating per user, and moves the ratings onto the kept
+// media. It returns how many users had conflicting ratings.
+func mergeRatings(ctx context.Context, tx neo4j.ManagedTransaction, keepID string, mergeIDs []string, policy model.RatingMergePolicy) (int, error) {
+	result, err := tx.Run(ctx, `
+		MATCH (r:Rating)-[:RATING_FOR]->(m:Media)
+		WHERE m.id IN $ids
+		RETURN r.userId AS userId, r.mediaId AS mediaId, r.score AS score,
+		       coalesce(r.ratedAt.epochMillis, 0) AS ratedAt
+	`, map[string]any{"ids": append([]string{keepID}, mergeIDs...)})
+	if err != nil {
+		return 0, err
+	}
+
+	byUser := map[string][]*mergedRating{}
+	var users []string
+	for result.Next(ctx) {
+		record := result.Record().AsMap()
+		rating := &mergedRating{
+			userID:  getString(record["userId"]),
+			mediaID: getString(record["mediaId"]),
+		}
+		if score := getFloat64Pointer(record["score"]); score != nil {
+			rating.score = *score
+		}
+		rating.ratedAt, _ = record["ratedAt"].(int64)
+		if _, ok := byUser[rating.userID]; !ok {
+			users = append(users, rating.userID)
+		}
+		byUser[rating.userID] = append(byUser[rating.userID], rating)
+	}
+	if err := result.Err(); err != nil {
+		return 0, err
+	}
+
+	var survivors, losers []map[string]any
+	for _, user := range users {
+		ratings := byUser[user]
+		if len(ratings) < 2 {
+			continue
+		}
+		survivor, score, ratedAt := settleRatings(ratings, keepID, policy)
+		survivors = append(survivors, map[string]any{
+			"userId":  survivor.userID,
+			"mediaId": survivor.mediaID,
+			"score":   score,
+			"ratedAt": ratedAt,
+		})
+		for _, rating := range ratings {
+			if rating != survivor {
+				losers = append(losers, map[string]any{"userId": rating.userID, "mediaId": rating.mediaID})
+			}
+		}
+	}
+
+	writes := []struct {
+		query  string
+		params map[string]any
+	}{
+		{`
+			UNWIND $rows AS row
+			MATCH (r:Rating {userId: row.userId, mediaId: row.mediaId})
+			DETACH DELETE r
+		`, map[string]any{"rows": losers}},
+		{`
+			UNWIND $rows AS row
+			MATCH (r:Rating {userId: row.userId, mediaId: row.mediaId})
+			SET r.score = row.score,
+			    r.ratedAt = CASE WHEN row.ratedAt = 0 THEN r.ratedAt ELSE datetime({epochMillis: row.ratedAt}) END,
+			    r.version = coalesce(r.version, 0) + 1
+		`, map[string]any{"rows": survivors}},
+		{`
+			MATCH (r:Rating)-[rel:RATING_FOR]->(m:Media)
+			WHERE m.id IN $mergeIds
+			MATCH (k:Media {id: $keepId})
+			DELETE rel
+			SET r.mediaId = $keepId
+			CREATE (r)-[:RATING_FOR]->(k)
+		`, map[string]any{"keepId": keepID, "mergeIds": mergeIDs}},
+	}
+
+	for _, write := range writes {
+		result, err := tx.Run(ctx, write.query, write.params)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(survivors), nil
+}
+
+// settleRatings picks the rating node a user keeps, the kept media's if they
+// rated it, and the score and date it ends up with under the policy
+func settleRatings(ratings []*mergedRating, keepID string, policy model.RatingMergePolicy) (*mergedRating, float64, int64) {
+	var kept *mergedRating
+	latest, highest, lowest := ratings[0], ratings[0], ratings[0]
+	total := 0.0
+	for _, rating := range ratings {
+		if rating.mediaID == keepID {
+			kept = rating
+		}
+		if rating.ratedAt > latest.ratedAt {
+			latest = rating
+		}
+		if rating.score > highest.score {
+			highest = rating
+		}
+		if rating.score < lowest.score {
+			lowest = rating
+		}
+		total += rating.score
+	}
+
+	survivor := kept
+	if survivor == nil {
+		survivor = latest
+	}
+
+	switch policy {
+	case model.RatingMergePolicyHighest:
+		return survivor, highest.score, highest.ratedAt
+	case model.RatingMergePolicyLowest:
+		return survivor, lowest.score, lowest.ratedAt
+	case model.RatingMergePolicyAverage:
+		return survivor, total / float64(len(ratings)), latest.ratedAt
+	case model.RatingMergePolicyKeep:
+		if kept != nil {
+			return survivor, kept.score, kept.ratedAt
+		}
+	}
+	return survivor, latest.score, latest.ratedAt
+}
+
+// mergedActivity is one user's activity on one of the media being merged
+type mergedActivity struct {
+	id           string
+	userID       string
+	mediaID      string
+	statusID     int32
+	rating       *float64
+	review       *string
+	startedAt    *string
+	finishedAt   *string
+	count        *int32
+	progress     *float64
+	progressUnit *string
+	importKey    string
+	// mergedImportKeys are the import keys of activities folded into it
+	mergedImportKeys []string
+	// updatedAt is in epoch milliseconds, 0 when unknown
+	updatedAt int64
+}
+
+// statusProgress orders activity statuses by how far along they are, so the
+// furthest one is kept when activities are folded together
+var statusProgress = map[int32]int{
+	StatusPlanned:    1,
+	StatusOnHold:     2,
+	StatusInProgress: 3,
+	StatusDropped:    4,
+	StatusCompleted:  5,
+}
+
+// mergeActivities folds the activities of users who tracked more than one
+// of the media into one activity each, before they are moved onto the kept
+// media. Rewatches are separate viewings and are moved as they are.
+func mergeActivities(ctx context.Context, tx neo4j.ManagedTransaction, keepID string, mergeIDs []string) error {
+	result, err := tx.Run(ctx, `
+		MATCH (u:User)-[:HAS_ACTIVITY]->(a:UserActivity)-[:ACTIVITY_FOR]->(m:Media)
+		WHERE m.id IN $ids AND coalesce(a.rewatch, false) = false
+		RETURN a.id AS id, u.id AS userId, m.id AS mediaId, a.statusId AS statusId,
+		       a.rating AS rating, a.review AS review,
+		       a.startedAt AS startedAt, a.finishedAt AS finishedAt,
+		       a.count AS count, a.progress AS progress, a.progressUnit AS progressUnit,
+		       a.importKey AS importKey, a.mergedImportKeys AS mergedImportKeys,
+		       coalesce(a.updatedAt.epochMillis, 0) AS updatedAt
+	`, map[string]any{"ids": append([]string{keepID}, mergeIDs...)})
+	if err != nil {
+		return err
+	}
+
+	byUser := map[string][]*mergedActivity{}
+	var users []string
+	for result.Next(ctx) {
+		record := result.Record().AsMap()
+		activity := &mergedActivity{
+			id:           getString(record["id"]),
+			userID:       getString(record["userId"]),
+			mediaID:      getString(record["mediaId"]),
+			rating:       getFloat64Pointer(record["rating"]),
+			review:       getStringPointer(record["review"]),
+			startedAt:    getStringPointer(record["startedAt"]),
+			finishedAt:   getStringPointer(record["finishedAt"]),
+			count:        getInt32Pointer(record["count"]),
+			progress:     getFloat64Pointer(record["progress"]),
+			progressUnit: getStringPointer(record["progressUnit"]),
+			importKey:    getString(record["importKey"]),
+		}
+		activity.mergedImportKeys = getStringSlice(record["mergedImportKeys"])
+		if statusID := getInt32Pointer(record["statusId"]); statusID != nil {
+			activity.statusID = *statusID
+		}
+		activity.updatedAt, _ = record["updatedAt"].(int64)
+		if _, ok := byUser[activity.userID]; !ok {
+			users = append(users, activity.userID)
+		}
+		byUser[activity.userID] = append(byUser[activity.userID], activity)
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	var survivors []map[string]any
+	var folded []string
+	for _, user := range users {
+		activities := byUser[user]
+		if len(activities) < 2 {
+			continue
+		}
+		survivor, props := settleActivities(activities, keepID)
+		survivors = append(survivors, map[string]any{"id": survivor.id, "props": props})
+		for _, activity := range activities {
+			if activity != survivor {
+				folded = append(folded, activity.id)
+			}
+		}
+	}
+	if len(survivors) == 0 {
+		return nil
+	}
+
+	writes := []struct {
+		query  string
+		params map[string]any
+	}{
+		{`
+			UNWIND $rows AS row
+			MATCH (a:UserActivity {id: row.id})
+			SET a += row.props, a.updatedAt = datetime(), a.version = coalesce(a.version, 0) + 1
+		`, map[string]any{"rows": survivors}},
+		{`
+			MATCH (u:User)-[:HAS_ACTIVITY]->(a:UserActivity)-[:TAGGED_WITH]->(t:Tag)
+			WHERE a.id IN $folded
+			MATCH (u)-[:HAS_ACTIVITY]->(survivor:UserActivity)
+			WHERE survivor.id IN $survivors
+			MERGE (survivor)-[:TAGGED_WITH]->(t)
+		`, map[string]any{"folded": folded, "survivors": activityIDs(survivors)}},
+		{`
+			MATCH (a:UserActivity)
+			WHERE a.id IN $folded
+			DETACH DELETE a
+		`, map[string]any{"folded": folded}},
+	}
+
+	for _, write := range writes {
+		result, err := tx.Run(ctx, write.query, write.params)
+		if err != nil {
+			return err
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// activityIDs returns the IDs of the activities in rows
+func activityIDs(rows []map[string]any) []string {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row["id"].(string))
+	}
+	return ids
+}
+
+// settleActivities picks the activity a user keeps, the one on the kept
+// media if they tracked it or else the latest updated, and the properties it
+// ends up with: the furthest status, the summed count, the furthest
+// progress, the earliest start and latest finish, and its own rating and
+// review, or the latest others gave. The import keys of the folded
+// activities are kept in mergedImportKeys, so re-importing them doesn't
+// bring them back.
+func settleActivities(activities []*mergedActivity, keepID string) (*mergedActivity, map[string]any) {
+	ordered := slices.Clone(activities)
+	slices.SortStableFunc(ordered, func(a, b *mergedActivity) int {
+		if (a.mediaID == keepID) != (b.mediaID == keepID) {
+			if a.mediaID == keepID {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.updatedAt, a.updatedAt)
+	})
+	survivor := ordered[0]
+
+	statusID := survivor.statusID
+	rating, review := survivor.rating, survivor.review
+	startedAt, finishedAt := survivor.startedAt, survivor.finishedAt
+	progress, progressUnit := survivor.progress, survivor.progressUnit
+	var count *int32
+	var importKeys []string
+	for _, activity := range ordered {
+		if statusProgress[activity.statusID] > statusProgress[statusID] {
+			statusID = activity.statusID
+		}
+		if rating == nil {
+			rating = activity.rating
+		}
+		if review == nil {
+			review = activity.review
+		}
+		if activity.startedAt != nil && (startedAt == nil || *activity.startedAt < *startedAt) {
+			startedAt = activity.startedAt
+		}
+		if activity.finishedAt != nil && (finishedAt == nil || *activity.finishedAt > *finishedAt) {
+			finishedAt = activity.finishedAt
+		}
+		if activity.progress != nil && (progress == nil || *activity.progress > *progress) {
+			progress, progressUnit = activity.progress, activity.progressUnit
+		}
+		if activity.count != nil {
+			total := *activity.count
+			if count != nil {
+				total += *count
+			}
+			count = &total
+		}
+		keys := activity.mergedImportKeys
+		if activity != survivor && activity.importKey != "" {
+			keys = append([]string{activity.importKey}, keys...)
+		}
+		for _, key := range keys {
+			if !slices.Contains(importKeys, key) {
+				importKeys = append(importKeys, key)
+			}
+		}
+	}
+
+	props := map[string]any{
+		"statusId":     statusID,
+		"rating":       rating,
+		"review":       review,
+		"startedAt":    startedAt,
+		"finishedAt":   finishedAt,
+		"count":        count,
+		"progress":     progress,
+		"progressUnit": progressUnit,
+	}
+	if len(importKeys) > 0 {
+		props["mergedImportKeys"] = importKeys
+	}
+	return survivor, props
+}
+
+// mediaRedirect returns the media an ID was merged into, or uuid.Nil if it
+// wasn't merged
+func (r *Neo4jRepository) mediaRedirect(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, `
+			MATCH (d:MediaRedirect {fromId: $id})
+			RETURN d.toId AS toId
+		`, map[string]any{"id": id.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return getString(result.Record().AsMap()["toId"]), nil
+		}
+		return "", result.Err()
+	})
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if result.(string) == "" {
+		return uuid.Nil, nil
+	}
+
+	target, err := uuid.Parse(result.(string))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid redirect for media %s: %w", id, err)
+	}
+	return target, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSettleActivitiesFoldsIntoKeptMedia(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	count := func(n int32) *int32 { return &n }
+	progress := func(p float64) *float64 { return &p }
+	rating := 8.0
+
+	kept := &mergedActivity{
+		id:        "kept",
+		mediaID:   "keep",
+		statusID:  StatusPlanned,
+		importKey: "goodreads:1",
+		startedAt: ptr("2024-03-01"),
+		count:     count(1),
+		updatedAt: 1,
+	}
+	merged := &mergedActivity{
+		id:               "merged",
+		mediaID:          "other",
+		statusID:         StatusCompleted,
+		rating:           &rating,
+		importKey:        "storygraph:1",
+		mergedImportKeys: []string{"storygraph:0"},
+		startedAt:        ptr("2023-01-01"),
+		finishedAt:       ptr("2023-02-01"),
+		count:            count(2),
+		progress:         progress(300),
+		progressUnit:     ptr("pages"),
+		updatedAt:        2,
+	}
+
+	survivor, props := settleActivities([]*mergedActivity{merged, kept}, "keep")
+
+	if survivor != kept {
+		t.Errorf("kept %s, want the activity on the kept media", survivor.id)
+	}
+	if props["statusId"] != StatusCompleted {
+		t.Errorf("got status %v, want the furthest, Completed", props["statusId"])
+	}
+	if got := props["count"].(*int32); got == nil || *got != 3 {
+		t.Errorf("got count %v, want the counts summed", got)
+	}
+	if got := props["startedAt"].(*string); *got != "2023-01-01" {
+		t.Errorf("got startedAt %s, want the earliest", *got)
+	}
+	if got := props["finishedAt"].(*string); *got != "2023-02-01" {
+		t.Errorf("got finishedAt %s", *got)
+	}
+	if got := props["progress"].(*float64); *got != 300 || *props["progressUnit"].(*string) != "pages" {
+		t.Errorf("got progress %v", *got)
+	}
+	if got := props["rating"].(*float64); got == nil || *got != rating {
+		t.Errorf("got rating %v, want the merged activity's when the kept one has none", got)
+	}
+	if got := props["mergedImportKeys"].([]string); !slices.Equal(got, []string{"storygraph:1", "storygraph:0"}) {
+		t.Errorf("got merged import keys %v", got)
+	}
+}
+
+func TestSettleActivitiesKeepsLatestWithoutKeptMedia(t *testing.T) {
+	older := &mergedActivity{id: "older", mediaID: "a", statusID: StatusInProgress, updatedAt: 1}
+	newer := &mergedActivity{id: "newer", mediaID: "b", statusID: StatusOnHold, updatedAt: 2}
+
+	survivor, props := settleActivities([]*mergedActivity{older, newer}, "keep")
+
+	if survivor != newer {
+		t.Errorf("kept %s, want the latest updated", survivor.id)
+	}
+	if props["statusId"] != StatusInProgress {
+		t.Errorf("got status %v, want In Progress over On Hold", props["statusId"])
+	}
+	if props["count"].(*int32) != nil {
+		t.Errorf("got a count when neither activity had one")
+	}
+	if _, ok := props["mergedImportKeys"]; ok {
+		t.Errorf("recorded import keys for activities that had none")
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,7 +13,8 @@ import (
 
 // Database wraps the Neo4j driver and provides database operations
 type Database struct {
-	driver neo4j.DriverWithContext
+	driver       neo4j.DriverWithContext
+	databaseName string
 }
 
 // NewDatabase creates a new database connection
@@ -23,6 +25,7 @@ func NewDatabase() (*Database, error) {
 	dbURI := os.Getenv("NEO4J_URI")
 	dbUser := os.Getenv("NEO4J_USERNAME")
 	dbPassword := os.Getenv("NEO4J_PASSWORD")
+	dbName := os.Getenv("NEO4J_DATABASE")
 
 	// Create driver connection (Aura handles optimization automatically)
 	driver, err := neo4j.NewDriverWithContext(
@@ -41,7 +44,7 @@ func NewDatabase() (*Database, error) {
 
 	log.Println("Successfully connected to Neo4j Aura")
 
-	return &Database{driver: driver}, nil
+	return &Database{driver: driver, databaseName: dbName}, nil
 }
 
 // Close closes the database connection
@@ -55,22 +58,65 @@ func (db *Database) GetDriver() neo4j.DriverWithContext {
 	return db.driver
 }
 
-// ExecuteRead executes a read transaction
+// ExecuteRead executes a read transaction. Driver errors are translated into
+// the repository error kinds. Reads are routed to followers
+// or read replicas and wait on any bookmarks carried by the context.
 func (db *Database) ExecuteRead(ctx context.Context, work func(neo4j.ManagedTransaction) (any, error)) (any, error) {
-	session := db.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	result, err := session.ExecuteRead(ctx, work)
-	return result, err
+	return db.execute(ctx, neo4j.AccessModeRead, work)
 }
 
-// ExecuteWrite executes a write transaction
+// ExecuteWrite executes a write transaction. The resulting bookmark is
+// recorded on the context so later reads observe the write.
 func (db *Database) ExecuteWrite(ctx context.Context, work func(neo4j.ManagedTransaction) (any, error)) (any, error) {
-	session := db.driver.NewSession(ctx, neo4j.SessionConfig{})
+	return db.execute(ctx, neo4j.AccessModeWrite, work)
+}
+
+// execute runs a transaction in a new session. When the database rejects
+// the bookmarks the context was seeded with, e.g. ones a client made up or
+// got from another database, they are dropped and the transaction is tried
+// again without them; it failed before any work ran.
+func (db *Database) execute(ctx context.Context, mode neo4j.AccessMode, work func(neo4j.ManagedTransaction) (any, error)) (any, error) {
+	result, err := db.executeOnce(ctx, mode, work)
+	if invalidBookmark(err) {
+		if manager := bookmarkManagerFromContext(ctx); manager != nil && manager.dropSeeded() {
+			log.Printf("Warning: Dropped bookmarks the database rejected: %v", err)
+			result, err = db.executeOnce(ctx, mode, work)
+		}
+	}
+	return result, translateError(err)
+}
+
+// executeOnce runs a transaction in a new session
+func (db *Database) executeOnce(ctx context.Context, mode neo4j.AccessMode, work func(neo4j.ManagedTransaction) (any, error)) (any, error) {
+	session := db.driver.NewSession(ctx, db.sessionConfig(ctx, mode))
 	defer session.Close(ctx)
 
-	result, err := session.ExecuteWrite(ctx, work)
-	return result, err
+	if mode == neo4j.AccessModeRead {
+		return session.ExecuteRead(ctx, work)
+	}
+	return session.ExecuteWrite(ctx, work)
+}
+
+// invalidBookmark reports whether the database refused a transaction's
+// bookmarks
+func invalidBookmark(err error) bool {
+	var neo4jErr *neo4j.Neo4jError
+	return errors.As(err, &neo4jErr) &&
+		(neo4jErr.Code == "Neo.ClientError.Transaction.InvalidBookmark" ||
+			neo4jErr.Code == "Neo.ClientError.Transaction.InvalidBookmarkMixture")
+}
+
+// sessionConfig builds the session configuration for the given access mode,
+// attaching the context's bookmark manager when one is present
+func (db *Database) sessionConfig(ctx context.Context, mode neo4j.AccessMode) neo4j.SessionConfig {
+	config := neo4j.SessionConfig{
+		AccessMode:   mode,
+		DatabaseName: db.databaseName,
+	}
+	if manager := bookmarkManagerFromContext(ctx); manager != nil {
+		config.BookmarkManager = manager
+	}
+	return config
 }
 
 // validateAuraURI checks if the URI is properly formatted for Neo4j Aura
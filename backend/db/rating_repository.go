@@ -2,13 +2,22 @@ package db
 
 import (
 	"context"
-	"fmt"
 	"nq/graph/model"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
+// MaxScore is the top of the rating scale; scores run from 0 to MaxScore
+const MaxScore = 10.0
+
+// ScaleScore converts a score on a 0 to scaleMax scale, such as five stars,
+// onto our rating scale
+func ScaleScore(score, scaleMax float64) float64 {
+	return score / scaleMax * MaxScore
+}
+
 // CreateRating creates a new rating in the database
 func (r *Neo4jRepository) CreateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64) (*model.Rating, error) {
 	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
@@ -19,11 +28,13 @@ func (r *Neo4jRepository) CreateRating(ctx context.Context, userID, mediaID uuid
 				userId: $userID,
 				mediaId: $mediaID,
 				score: $score,
-				ratedAt: datetime()
+				ratedAt: datetime(),
+				version: 1
 			})
 			CREATE (u)-[:RATED]->(r)
 			CREATE (r)-[:RATING_FOR]->(m)
-			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt
+			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt,
+			       coalesce(r.version, 0) as version
 		`
 
 		params := map[string]any{
@@ -39,15 +50,11 @@ func (r *Neo4jRepository) CreateRating(ctx context.Context, userID, mediaID uuid
 
 		if result.Next(ctx) {
 			record := result.Record()
-			rating := &model.Rating{
-				Score:   score,
-				RatedAt: record.AsMap()["ratedAt"].(string),
-				// TODO: Populate User and Media from IDs
-			}
+			rating := ratingFromRecord(record)
 			return rating, nil
 		}
 
-		return nil, fmt.Errorf("failed to create rating")
+		return nil, NotFoundError("user or media")
 	})
 
 	if err != nil {
@@ -62,7 +69,8 @@ func (r *Neo4jRepository) GetRating(ctx context.Context, userID, mediaID uuid.UU
 	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
 			MATCH (r:Rating {userId: $userID, mediaId: $mediaID})
-			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt
+			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt,
+			       coalesce(r.version, 0) as version
 		`
 
 		params := map[string]any{
@@ -77,15 +85,11 @@ func (r *Neo4jRepository) GetRating(ctx context.Context, userID, mediaID uuid.UU
 
 		if result.Next(ctx) {
 			record := result.Record()
-			rating := &model.Rating{
-				Score:   getFloat64FromRecord(record, "score"),
-				RatedAt: record.AsMap()["ratedAt"].(string),
-				// TODO: Populate User and Media from IDs
-			}
+			rating := ratingFromRecord(record)
 			return rating, nil
 		}
 
-		return nil, fmt.Errorf("rating not found")
+		return nil, NotFoundError("rating")
 	})
 
 	if err != nil {
@@ -101,7 +105,8 @@ func (r *Neo4jRepository) GetUserRatings(ctx context.Context, userID uuid.UUID)
 		query := `
 			MATCH (r:Rating {userId: $userID})
 			OPTIONAL MATCH (r)-[:RATING_FOR]->(m:Media)
-			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt
+			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt,
+			       coalesce(r.version, 0) as version
 			ORDER BY r.ratedAt DESC
 		`
 
@@ -115,11 +120,7 @@ func (r *Neo4jRepository) GetUserRatings(ctx context.Context, userID uuid.UUID)
 		var ratings []*model.Rating
 		for result.Next(ctx) {
 			record := result.Record()
-			rating := &model.Rating{
-				Score:   getFloat64FromRecord(record, "score"),
-				RatedAt: record.AsMap()["ratedAt"].(string),
-				// TODO: Populate User and Media from IDs
-			}
+			rating := ratingFromRecord(record)
 			ratings = append(ratings, rating)
 		}
 
@@ -139,7 +140,8 @@ func (r *Neo4jRepository) GetMediaRatings(ctx context.Context, mediaID uuid.UUID
 		query := `
 			MATCH (r:Rating {mediaId: $mediaID})
 			OPTIONAL MATCH (u:User)-[:RATED]->(r)
-			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt
+			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt,
+			       coalesce(r.version, 0) as version
 			ORDER BY r.ratedAt DESC
 		`
 
@@ -153,11 +155,7 @@ func (r *Neo4jRepository) GetMediaRatings(ctx context.Context, mediaID uuid.UUID
 		var ratings []*model.Rating
 		for result.Next(ctx) {
 			record := result.Record()
-			rating := &model.Rating{
-				Score:   getFloat64FromRecord(record, "score"),
-				RatedAt: record.AsMap()["ratedAt"].(string),
-				// TODO: Populate User and Media from IDs
-			}
+			rating := ratingFromRecord(record)
 			ratings = append(ratings, rating)
 		}
 
@@ -171,13 +169,19 @@ func (r *Neo4jRepository) GetMediaRatings(ctx context.Context, mediaID uuid.UUID
 	return result.([]*model.Rating), nil
 }
 
-// UpdateRating updates an existing rating
-func (r *Neo4jRepository) UpdateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64) (*model.Rating, error) {
+// UpdateRating changes the score of an existing rating. When
+// expectedVersion is given and no longer matches the rating's version,
+// nothing is written and a conflict error carrying the current rating is
+// returned.
+func (r *Neo4jRepository) UpdateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64, expectedVersion *int32) (*model.Rating, error) {
 	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Lock the rating before comparing versions so a concurrent update
+		// can't slip in between the check and the write
 		query := `
 			MATCH (r:Rating {userId: $userID, mediaId: $mediaID})
-			SET r.score = $score, r.ratedAt = datetime()
-			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt
+			SET r.version = coalesce(r.version, 0)
+			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt,
+			       r.version as version
 		`
 
 		params := map[string]any{
@@ -191,17 +195,32 @@ func (r *Neo4jRepository) UpdateRating(ctx context.Context, userID, mediaID uuid
 			return nil, err
 		}
 
+		if !result.Next(ctx) {
+			return nil, NotFoundError("rating")
+		}
+
+		current := ratingFromRecord(result.Record())
+		if expectedVersion != nil && current.Version != *expectedVersion {
+			return nil, VersionConflictError("rating", current)
+		}
+
+		query = `
+			MATCH (r:Rating {userId: $userID, mediaId: $mediaID})
+			SET r.score = $score, r.ratedAt = datetime(), r.version = r.version + 1
+			RETURN r.userId as userId, r.mediaId as mediaId, r.score as score, r.ratedAt as ratedAt,
+			       r.version as version
+		`
+
+		result, err = tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
 		if result.Next(ctx) {
-			record := result.Record()
-			rating := &model.Rating{
-				Score:   score,
-				RatedAt: record.AsMap()["ratedAt"].(string),
-				// TODO: Populate User and Media from IDs
-			}
-			return rating, nil
+			return ratingFromRecord(result.Record()), nil
 		}
 
-		return nil, fmt.Errorf("rating not found")
+		return nil, NotFoundError("rating")
 	})
 
 	if err != nil {
@@ -272,6 +291,19 @@ func (r *Neo4jRepository) GetAverageRating(ctx context.Context, mediaID uuid.UUI
 	return result.(*float64), nil
 }
 
+// ratingFromRecord reads a rating's score, date and version. Its user and
+// media aren't loaded.
+func ratingFromRecord(record *neo4j.Record) *model.Rating {
+	rating := &model.Rating{
+		Score:   getFloat64FromRecord(record, "score"),
+		Version: getInt32FromRecord(record, "version"),
+	}
+	if ratedAt, ok := getTime(record.AsMap()["ratedAt"]); ok {
+		rating.RatedAt = ratedAt.Format(time.RFC3339)
+	}
+	return rating
+}
+
 // Helper function to safely get float64 from record
 func getFloat64FromRecord(record *neo4j.Record, key string) float64 {
 	value := record.AsMap()[key]
@@ -2,8 +2,9 @@ package db
 
 import (
 	"context"
-	"fmt"
 	"nq/graph/model"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
@@ -69,7 +70,7 @@ func (r *Neo4jRepository) CreateRecommendation(ctx context.Context, userID, medi
 			return recommendation, nil
 		}
 
-		return nil, fmt.Errorf("failed to create recommendation")
+		return nil, NotFoundError("user or media")
 	})
 
 	if err != nil {
@@ -79,15 +80,15 @@ func (r *Neo4jRepository) CreateRecommendation(ctx context.Context, userID, medi
 	return result.(*model.Recommendation), nil
 }
 
-// GetRecommendations retrieves all recommendations for a user
+// GetRecommendations retrieves all recommendations for a user, best first
 func (r *Neo4jRepository) GetRecommendations(ctx context.Context, userID uuid.UUID) ([]*model.Recommendation, error) {
 	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
-			MATCH (rec:Recommendation {userId: $userID})
-			OPTIONAL MATCH (rec)-[:RECOMMENDS]->(m:Media)
+			MATCH (rec:Recommendation {userId: $userID})-[:RECOMMENDS]->(m:Media)
 			OPTIONAL MATCH (rec)-[:RECOMMENDED_BY]->(r:User)
 			RETURN rec.id as id, rec.userId as userId, rec.mediaId as mediaId,
-			       rec.recommenderId as recommenderId, rec.source as source, rec.score as score
+			       rec.recommenderId as recommenderId, rec.source as source, rec.score as score,
+			       labels(m) as labels, properties(m) as props
 			ORDER BY rec.score DESC, rec.createdAt DESC
 		`
 
@@ -106,16 +107,18 @@ func (r *Neo4jRepository) GetRecommendations(ctx context.Context, userID uuid.UU
 				return nil, err
 			}
 
+			props, _ := record.AsMap()["props"].(map[string]any)
 			recommendation := &model.Recommendation{
 				ID:     recommendationID,
+				Media:  mediaFromNode(getStringSlice(record.AsMap()["labels"]), props),
 				Source: getStringPointer(record.AsMap()["source"]),
 				Score:  getFloat64Pointer(record.AsMap()["score"]),
-				// TODO: Populate User, Media, and Recommender from IDs
+				// TODO: Populate User and Recommender from IDs
 			}
 			recommendations = append(recommendations, recommendation)
 		}
 
-		return recommendations, nil
+		return recommendations, result.Err()
 	})
 
 	if err != nil {
@@ -154,7 +157,7 @@ func (r *Neo4jRepository) GetRecommendationByID(ctx context.Context, id uuid.UUI
 			return recommendation, nil
 		}
 
-		return nil, fmt.Errorf("recommendation not found")
+		return nil, NotFoundError("recommendation")
 	})
 
 	if err != nil {
@@ -184,3 +187,276 @@ func (r *Neo4jRepository) DeleteRecommendation(ctx context.Context, id uuid.UUID
 
 	return err
 }
+
+// ScoredMedia is a media item a recommender suggests, with its score
+type ScoredMedia struct {
+	MediaID uuid.UUID
+	Score   float64
+}
+
+// ReplaceRecommendations swaps a user's recommendations from a source, such
+// as a recommender, for scored in one transaction, so the user never sees
+// the old ones gone and the new ones missing. Media deleted since it was
+// scored is skipped. It returns how many recommendations were written.
+// They are the nodes CreateRecommendation writes, without a recommender;
+// recommenders replace theirs here rather than one CreateRecommendation at a
+// time, so a failure part way through can't leave a user with half a list.
+func (r *Neo4jRepository) ReplaceRecommendations(ctx context.Context, userID uuid.UUID, source string, scored []ScoredMedia) (int, error) {
+	rows := make([]map[string]any, 0, len(scored))
+	for _, item := range scored {
+		rows = append(rows, map[string]any{
+			"id":      uuid.New().String(),
+			"mediaId": item.MediaID.String(),
+			"score":   item.Score,
+		})
+	}
+
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		params := map[string]any{"userID": userID.String(), "source": source, "rows": rows}
+
+		result, err := tx.Run(ctx, `
+			MATCH (rec:Recommendation {userId: $userID, source: $source})
+			DETACH DELETE rec
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(ctx, `
+			MATCH (u:User {id: $userID})
+			UNWIND $rows AS row
+			MATCH (m:Media {id: row.mediaId})
+			CREATE (rec:Recommendation {
+				id: row.id,
+				userId: $userID,
+				mediaId: row.mediaId,
+				source: $source,
+				score: row.score,
+				createdAt: datetime()
+			})
+			CREATE (u)-[:RECEIVED_RECOMMENDATION]->(rec)
+			CREATE (rec)-[:RECOMMENDS]->(m)
+			RETURN count(rec) as written
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := result.Single(ctx)
+		if err != nil {
+			return nil, err
+		}
+		written, _ := record.AsMap()["written"].(int64)
+		return int(written), nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.(int), nil
+}
+
+// Interaction is what a user has done with a media item: rated it, tracked
+// it with activities or favorited it
+type Interaction struct {
+	UserID  uuid.UUID
+	MediaID uuid.UUID
+	// Rating is the user's rating of the media or, without one, the best
+	// rating on their activities for it
+	Rating *float64
+	// Statuses are the statuses of the user's activities for the media
+	Statuses []int32
+	Favorite bool
+	// At is the last time the user rated, tracked or favorited the media,
+	// when known
+	At *time.Time
+}
+
+// GetInteractions returns every user's interactions with media, one per user
+// and media item
+func (r *Neo4jRepository) GetInteractions(ctx context.Context) ([]*Interaction, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		type key struct{ userID, mediaID string }
+		byKey := map[key]*Interaction{}
+		var interactions []*Interaction
+		// rated marks media with a Rating, which wins over activity ratings
+		rated := map[key]bool{}
+
+		queries := []string{`
+			MATCH (u:User)-[:RATED]->(r:Rating)-[:RATING_FOR]->(m:Media)
+			RETURN u.id as userId, m.id as mediaId, r.score as rating, null as statusId,
+			       false as favorite, r.ratedAt as at
+		`, `
+			MATCH (u:User)-[:HAS_ACTIVITY]->(a:UserActivity)-[:ACTIVITY_FOR]->(m:Media)
+			RETURN u.id as userId, m.id as mediaId, a.rating as rating, a.statusId as statusId,
+			       false as favorite, coalesce(a.finishedAt, a.startedAt, a.createdAt) as at
+		`, `
+			MATCH (u:User)-[f:FAVORITES]->(m:Media)
+			RETURN u.id as userId, m.id as mediaId, null as rating, null as statusId,
+			       true as favorite, f.createdAt as at
+		`}
+
+		for i, query := range queries {
+			result, err := tx.Run(ctx, query, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			for result.Next(ctx) {
+				values := result.Record().AsMap()
+				k := key{getString(values["userId"]), getString(values["mediaId"])}
+				interaction, ok := byKey[k]
+				if !ok {
+					userID, err := uuid.Parse(k.userID)
+					if err != nil {
+						continue
+					}
+					mediaID, err := uuid.Parse(k.mediaID)
+					if err != nil {
+						continue
+					}
+					interaction = &Interaction{UserID: userID, MediaID: mediaID}
+					byKey[k] = interaction
+					interactions = append(interactions, interaction)
+				}
+
+				// The first query reads Rating nodes
+				if rating := getFloat64Pointer(values["rating"]); rating != nil {
+					switch {
+					case i == 0:
+						interaction.Rating = rating
+						rated[k] = true
+					case !rated[k] && (interaction.Rating == nil || *rating > *interaction.Rating):
+						interaction.Rating = rating
+					}
+				}
+				if status, ok := values["statusId"].(int64); ok && !slices.Contains(interaction.Statuses, int32(status)) {
+					interaction.Statuses = append(interaction.Statuses, int32(status))
+				}
+				if favorite, _ := values["favorite"].(bool); favorite {
+					interaction.Favorite = true
+				}
+				if at, ok := getTime(values["at"]); ok && (interaction.At == nil || at.After(*interaction.At)) {
+					interaction.At = &at
+				}
+			}
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		return interactions, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*Interaction), nil
+}
+
+// ContentLink is a creator, tag or platform a media item shares with
+// another
+type ContentLink struct {
+	FromID uuid.UUID
+	ToID   uuid.UUID
+	// Via is the relationship to what they share: CREATED, TAGGED_WITH or
+	// HOSTS
+	Via string
+	// Degree is how many media share it
+	Degree int
+}
+
+// GetContentLinks returns the media sharing a creator, tag or platform with
+// each of the given media. Creators, tags and platforms shared by more than
+// maxDegree media are skipped.
+func (r *Neo4jRepository) GetContentLinks(ctx context.Context, mediaIDs []uuid.UUID, maxDegree int) ([]*ContentLink, error) {
+	ids := make([]string, len(mediaIDs))
+	for i, id := range mediaIDs {
+		ids[i] = id.String()
+	}
+
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Media sharing several creators or tags get a link for each
+		query := `
+			UNWIND $ids AS id
+			MATCH (seed:Media {id: id})
+			CALL {
+				WITH seed
+				MATCH (seed)<-[:CREATED]-(n:Creator)
+				RETURN n, 'CREATED' AS via
+				UNION
+				WITH seed
+				MATCH (seed)-[:TAGGED_WITH]->(n:Tag)
+				RETURN n, 'TAGGED_WITH' AS via
+				UNION
+				WITH seed
+				MATCH (seed)<-[:HOSTS]-(n:Platform)
+				RETURN n, 'HOSTS' AS via
+			}
+			CALL {
+				WITH n
+				MATCH (n)-[:CREATED|TAGGED_WITH|HOSTS]-(m:Media)
+				RETURN count(DISTINCT m) AS degree
+			}
+			WITH seed, n, via, degree
+			WHERE degree <= $maxDegree
+			MATCH (n)-[:CREATED|TAGGED_WITH|HOSTS]-(other:Media)
+			WHERE other <> seed
+			RETURN DISTINCT seed.id as fromId, other.id as toId, via, n.id as viaId, degree
+		`
+
+		params := map[string]any{"ids": ids, "maxDegree": maxDegree}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		var links []*ContentLink
+		for result.Next(ctx) {
+			values := result.Record().AsMap()
+			fromID, err := uuid.Parse(getString(values["fromId"]))
+			if err != nil {
+				continue
+			}
+			toID, err := uuid.Parse(getString(values["toId"]))
+			if err != nil {
+				continue
+			}
+			degree, _ := values["degree"].(int64)
+			links = append(links, &ContentLink{FromID: fromID, ToID: toID, Via: getString(values["via"]), Degree: int(degree)})
+		}
+
+		return links, result.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*ContentLink), nil
+}
+
+// getTime reads a stored date or datetime, or one stored as a string, such as an imported activity's finishedAt
+func getTime(value any) (time.Time, bool) {
+	switch value := value.(type) {
+	case time.Time:
+		return value, true
+	case neo4j.Date:
+		return value.Time(), true
+	case neo4j.LocalDateTime:
+		return value.Time(), true
+	case string:
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"nq/graph/model"
+	"nq/vault"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +16,16 @@ type Repository interface {
 	ActivityRepository
 	RatingRepository
 	RecommendationRepository
+	ImportRepository
+	MatchReviewRepository
+	EnrichmentRepository
+	EditionRepository
+	ListenRepository
+	FollowRepository
+	TokenRepository
+	CredentialRepository
+	ConnectionRepository
+	JobRepository
 }
 
 // UserRepository defines operations for user management
@@ -22,7 +34,7 @@ type UserRepository interface {
 	GetUserByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	GetAllUsers(ctx context.Context) ([]*model.User, error)
-	UpdateUser(ctx context.Context, id uuid.UUID, input model.UpdateUserInput) (*model.User, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, input model.UpdateUserInput, expectedVersion *int32) (*model.User, error)
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 }
 
@@ -53,9 +65,23 @@ type MediaRepository interface {
 	GetMusicAlbumByID(ctx context.Context, id uuid.UUID) (*model.MusicAlbum, error)
 	GetAllMusicAlbums(ctx context.Context) ([]*model.MusicAlbum, error)
 
+	// Video operations
+	GetVideoByID(ctx context.Context, id uuid.UUID) (*model.Video, error)
+	GetAllVideos(ctx context.Context) ([]*model.Video, error)
+
+	// Article operations
+	GetArticleByID(ctx context.Context, id uuid.UUID) (*model.Article, error)
+	GetAllArticles(ctx context.Context) ([]*model.Article, error)
+
+	// Stream operations
+	GetStreamByID(ctx context.Context, id uuid.UUID) (*model.Stream, error)
+	GetAllStreams(ctx context.Context) ([]*model.Stream, error)
+
 	// Generic media operations
 	GetMediaByID(ctx context.Context, id uuid.UUID) (model.Media, error)
 	GetAllMedia(ctx context.Context) ([]model.Media, error)
+	GetMediaByExternalID(ctx context.Context, source, value string) (model.Media, error)
+	MergeMedia(ctx context.Context, keepID uuid.UUID, mergeIDs []uuid.UUID, policy model.RatingMergePolicy) (*model.MediaMergeResult, error)
 }
 
 // ActivityRepository defines operations for user activities
@@ -64,7 +90,7 @@ type ActivityRepository interface {
 	GetActivityByID(ctx context.Context, id uuid.UUID) (*model.UserActivity, error)
 	GetUserActivities(ctx context.Context, userID uuid.UUID) ([]*model.UserActivity, error)
 	GetMediaActivities(ctx context.Context, mediaID uuid.UUID) ([]*model.UserActivity, error)
-	UpdateActivity(ctx context.Context, id uuid.UUID, statusID *int32, rating *float64, review *string, finishedAt *string) (*model.UserActivity, error)
+	UpdateActivity(ctx context.Context, id uuid.UUID, input model.UpdateActivityInput, expectedVersion *int32) (*model.UserActivity, error)
 	DeleteActivity(ctx context.Context, id uuid.UUID) error
 }
 
@@ -74,7 +100,7 @@ type RatingRepository interface {
 	GetRating(ctx context.Context, userID, mediaID uuid.UUID) (*model.Rating, error)
 	GetUserRatings(ctx context.Context, userID uuid.UUID) ([]*model.Rating, error)
 	GetMediaRatings(ctx context.Context, mediaID uuid.UUID) ([]*model.Rating, error)
-	UpdateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64) (*model.Rating, error)
+	UpdateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64, expectedVersion *int32) (*model.Rating, error)
 	DeleteRating(ctx context.Context, userID, mediaID uuid.UUID) error
 	GetAverageRating(ctx context.Context, mediaID uuid.UUID) (*float64, error)
 }
@@ -85,16 +111,105 @@ type RecommendationRepository interface {
 	GetRecommendations(ctx context.Context, userID uuid.UUID) ([]*model.Recommendation, error)
 	GetRecommendationByID(ctx context.Context, id uuid.UUID) (*model.Recommendation, error)
 	DeleteRecommendation(ctx context.Context, id uuid.UUID) error
+	ReplaceRecommendations(ctx context.Context, userID uuid.UUID, source string, scored []ScoredMedia) (int, error)
+	GetInteractions(ctx context.Context) ([]*Interaction, error)
+	GetContentLinks(ctx context.Context, mediaIDs []uuid.UUID, maxDegree int) ([]*ContentLink, error)
+}
+
+// ImportRepository defines bulk import operations
+type ImportRepository interface {
+	ImportMedia(ctx context.Context, items []*model.MediaImportInput, opts ImportOptions) ([]*model.MediaImportResult, error)
+	ImportActivities(ctx context.Context, userID uuid.UUID, activities []*ActivityImport) (int, error)
+	ImportRatings(ctx context.Context, userID uuid.UUID, ratings []*RatingImport) (int, error)
+	ImportFavorites(ctx context.Context, userID uuid.UUID, mediaIDs []uuid.UUID) (int, error)
+}
+
+// MatchReviewRepository defines the queue of possible duplicates found by
+// imports
+type MatchReviewRepository interface {
+	GetMatchReviews(ctx context.Context, status *model.MatchReviewStatus, limit int) ([]*model.MatchReview, error)
+	ResolveMatchReview(ctx context.Context, id uuid.UUID, sameWork bool) (*model.MatchReview, error)
+}
+
+// EnrichmentRepository defines operations for filling in media metadata
+// from metadata services
+type EnrichmentRepository interface {
+	GetEnrichmentTarget(ctx context.Context, id uuid.UUID) (*EnrichmentTarget, error)
+	ApplyEnrichment(ctx context.Context, id uuid.UUID, enrichment *Enrichment) ([]string, error)
+	GetFieldSources(ctx context.Context, id uuid.UUID) ([]*model.FieldSource, error)
+}
+
+// EditionRepository defines operations for the editions of books
+type EditionRepository interface {
+	GetBookByISBN(ctx context.Context, isbn string) (*model.Book, error)
+	AddEdition(ctx context.Context, input model.AddEditionInput) (*model.Edition, error)
+	GetEditions(ctx context.Context, bookID uuid.UUID) ([]*model.Edition, error)
+}
+
+// ListenRepository defines operations for listening history
+type ListenRepository interface {
+	ImportListens(ctx context.Context, userID uuid.UUID, listens []*ListenImport) (int, error)
+	GetTopAlbums(ctx context.Context, userID uuid.UUID, limit int) ([]*model.AlbumPlays, error)
+}
+
+// FollowRepository defines operations for the creators users follow
+type FollowRepository interface {
+	ImportFollows(ctx context.Context, userID uuid.UUID, follows []*FollowImport) (int, error)
+}
+
+// TokenRepository defines storage for integration OAuth tokens
+type TokenRepository interface {
+	SaveOAuthState(ctx context.Context, state string, pending *OAuthState) error
+	TakeOAuthState(ctx context.Context, provider, state string) (*OAuthState, error)
+	PurgeOAuthStates(ctx context.Context) (int, error)
+	SaveOAuthToken(ctx context.Context, userID uuid.UUID, token *OAuthToken) error
+	GetOAuthToken(ctx context.Context, userID uuid.UUID, provider string) (*OAuthToken, error)
+	DeleteOAuthToken(ctx context.Context, userID uuid.UUID, provider string) error
+}
+
+// CredentialRepository defines encrypted storage for users' integration
+// secrets
+type CredentialRepository interface {
+	SaveCredential(ctx context.Context, userID uuid.UUID, provider, name, secret string) error
+	GetCredential(ctx context.Context, userID uuid.UUID, provider, name string) (string, error)
+	RotateSecrets(ctx context.Context, reencrypt bool) (int, error)
+}
+
+// ConnectionRepository defines storage for users' integration connections
+type ConnectionRepository interface {
+	SaveConnection(ctx context.Context, conn *Connection) (*Connection, error)
+	GetConnection(ctx context.Context, userID uuid.UUID, provider string) (*Connection, error)
+	GetConnections(ctx context.Context, userID uuid.UUID) ([]*Connection, error)
+	GetAllConnections(ctx context.Context, provider string) ([]*Connection, error)
+	SaveSyncCursor(ctx context.Context, userID uuid.UUID, provider, cursor string) error
+	DeleteConnection(ctx context.Context, userID uuid.UUID, provider string) error
+}
+
+// JobRepository defines storage for background jobs and their schedules
+type JobRepository interface {
+	EnqueueJob(ctx context.Context, input *JobInput) (*Job, error)
+	SaveJobSchedule(ctx context.Context, name, spec string, nextRunAt time.Time) error
+	EnqueueScheduledJob(ctx context.Context, name string, now, next time.Time, input *JobInput) (*Job, error)
+	ClaimJobs(ctx context.Context, owner string, kinds []string, limit int, lease time.Duration) ([]*Job, error)
+	ExtendJobLease(ctx context.Context, id uuid.UUID, owner string, lease time.Duration) error
+	CompleteJob(ctx context.Context, id uuid.UUID, owner string) error
+	FailJob(ctx context.Context, id uuid.UUID, owner, message string, retryAt *time.Time) error
+	GetJob(ctx context.Context, id uuid.UUID) (*Job, error)
+	GetJobs(ctx context.Context, filter JobFilter) ([]*Job, error)
+	PurgeJobs(ctx context.Context, finishedBefore time.Time) (int, error)
 }
 
 // Neo4jRepository implements the Repository interface using Neo4j
 type Neo4jRepository struct {
 	db *Database
+	// vault seals tokens and credentials; without it they can't be stored
+	vault *vault.Keyring
 }
 
-// NewNeo4jRepository creates a new Neo4j repository
-func NewNeo4jRepository(db *Database) *Neo4jRepository {
-	return &Neo4jRepository{db: db}
+// NewNeo4jRepository creates a new Neo4j repository. The keyring may be nil
+// when no master key is configured.
+func NewNeo4jRepository(db *Database, keyring *vault.Keyring) *Neo4jRepository {
+	return &Neo4jRepository{db: db, vault: keyring}
 }
 
 // Helper method to get the database instance
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// OAuthStateTTL is how long an authorization started with SaveOAuthState can
+// be completed
+const OAuthStateTTL = 10 * time.Minute
+
+// OAuthToken is a user's token set for an integration. Tokens are sealed
+// with the repository's vault at rest and never returned through the API.
+type OAuthToken struct {
+	Provider     string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	Scope        string
+}
+
+// OAuthState is a pending authorization, created when the user is sent to
+// the provider and consumed when the provider redirects back
+type OAuthState struct {
+	UserID       uuid.UUID
+	Provider     string
+	CodeVerifier string
+}
+
+// SaveOAuthState stores a pending authorization under its state parameter
+func (r *Neo4jRepository) SaveOAuthState(ctx context.Context, state string, pending *OAuthState) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (u:User {id: $userID})
+			CREATE (s:OAuthState {
+				state: $state,
+				provider: $provider,
+				codeVerifier: $codeVerifier,
+				createdAt: datetime()
+			})
+			CREATE (u)-[:STARTED_AUTHORIZATION]->(s)
+			RETURN s.state as state
+		`
+
+		params := map[string]any{
+			"userID":       pending.UserID.String(),
+			"state":        state,
+			"provider":     pending.Provider,
+			"codeVerifier": pending.CodeVerifier,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return nil, nil
+		}
+
+		return nil, NotFoundError("user")
+	})
+
+	return err
+}
+
+// TakeOAuthState returns and deletes the pending authorization for a state
+// parameter. States are single use and expire after OAuthStateTTL.
+func (r *Neo4jRepository) TakeOAuthState(ctx context.Context, provider, state string) (*OAuthState, error) {
+	// Expired states are still deleted, so the check happens after commit
+	expired := false
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (u:User)-[:STARTED_AUTHORIZATION]->(s:OAuthState {state: $state, provider: $provider})
+			WITH u, s, s.createdAt > datetime() - duration({seconds: $ttl}) as fresh, s.codeVerifier as codeVerifier
+			DETACH DELETE s
+			RETURN u.id as userId, codeVerifier, fresh
+		`
+
+		params := map[string]any{
+			"state":    state,
+			"provider": provider,
+			"ttl":      int64(OAuthStateTTL / time.Second),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			return nil, NotFoundError("authorization")
+		}
+
+		record := result.Record()
+		fresh, _ := record.AsMap()["fresh"].(bool)
+		expired = !fresh
+
+		userID, err := uuid.Parse(record.AsMap()["userId"].(string))
+		if err != nil {
+			return nil, err
+		}
+
+		return &OAuthState{
+			UserID:       userID,
+			Provider:     provider,
+			CodeVerifier: getString(record.AsMap()["codeVerifier"]),
+		}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ValidationFailedError("authorization expired, start again")
+	}
+
+	return result.(*OAuthState), nil
+}
+
+// PurgeOAuthStates deletes pending authorizations that expired without being
+// completed and returns how many
+func (r *Neo4jRepository) PurgeOAuthStates(ctx context.Context) (int, error) {
+	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (s:OAuthState)
+			WHERE s.createdAt <= datetime() - duration({seconds: $ttl})
+			DETACH DELETE s
+			RETURN count(*) as count
+		`
+
+		params := map[string]any{"ttl": int64(OAuthStateTTL / time.Second)}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return int(getInt32FromRecord(result.Record(), "count")), nil
+		}
+
+		return 0, result.Err()
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.(int), nil
+}
+
+// SaveOAuthToken stores a user's token set for token.Provider, replacing any
+// previous one
+func (r *Neo4jRepository) SaveOAuthToken(ctx context.Context, userID uuid.UUID, token *OAuthToken) error {
+	accessToken, err := r.seal(token.AccessToken, "OAuthToken", userID.String(), token.Provider, "", "accessToken")
+	if err != nil {
+		return err
+	}
+	refreshToken, err := r.seal(token.RefreshToken, "OAuthToken", userID.String(), token.Provider, "", "refreshToken")
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (u:User {id: $userID})
+			MERGE (t:OAuthToken {userId: $userID, provider: $provider})
+			ON CREATE SET t.createdAt = datetime()
+			MERGE (u)-[:HAS_TOKEN]->(t)
+			SET t.accessToken = $accessToken,
+			    t.refreshToken = $refreshToken,
+			    t.expiresAt = datetime($expiresAt),
+			    t.scope = $scope,
+			    t.keyId = $keyId,
+			    t.sealedAt = datetime(),
+			    t.updatedAt = datetime()
+			RETURN t.provider as provider
+		`
+
+		params := map[string]any{
+			"userID":       userID.String(),
+			"provider":     token.Provider,
+			"accessToken":  accessToken,
+			"refreshToken": refreshToken,
+			"expiresAt":    token.ExpiresAt.UTC().Format(time.RFC3339),
+			"scope":        token.Scope,
+			"keyId":        r.vault.CurrentKeyID(),
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			return nil, nil
+		}
+
+		return nil, NotFoundError("user")
+	})
+
+	return err
+}
+
+// GetOAuthToken returns a user's token set for a provider
+func (r *Neo4jRepository) GetOAuthToken(ctx context.Context, userID uuid.UUID, provider string) (*OAuthToken, error) {
+	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (t:OAuthToken {userId: $userID, provider: $provider})
+			RETURN t.accessToken as accessToken, t.refreshToken as refreshToken,
+			       t.expiresAt as expiresAt, t.scope as scope
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next(ctx) {
+			record := result.Record()
+			token := &OAuthToken{
+				Provider:     provider,
+				AccessToken:  getString(record.AsMap()["accessToken"]),
+				RefreshToken: getString(record.AsMap()["refreshToken"]),
+				Scope:        getString(record.AsMap()["scope"]),
+			}
+			if expiresAt, ok := record.AsMap()["expiresAt"].(time.Time); ok {
+				token.ExpiresAt = expiresAt
+			}
+			return token, nil
+		}
+
+		return nil, NotFoundError(provider + " connection")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := result.(*OAuthToken)
+	if token.AccessToken, err = r.open(token.AccessToken, "OAuthToken", userID.String(), provider, "", "accessToken"); err != nil {
+		return nil, err
+	}
+	if token.RefreshToken, err = r.open(token.RefreshToken, "OAuthToken", userID.String(), provider, "", "refreshToken"); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// DeleteOAuthToken removes a user's token set for a provider
+func (r *Neo4jRepository) DeleteOAuthToken(ctx context.Context, userID uuid.UUID, provider string) error {
+	_, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (t:OAuthToken {userId: $userID, provider: $provider})
+			DETACH DELETE t
+		`
+
+		params := map[string]any{
+			"userID":   userID.String(),
+			"provider": provider,
+		}
+
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Consume(ctx)
+	})
+
+	return err
+}
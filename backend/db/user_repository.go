@@ -20,10 +20,12 @@ func (r *Neo4jRepository) CreateUser(ctx context.Context, input model.CreateUser
 				name: $name,
 				email: $email,
 				authProvider: $authProvider,
+				version: 1,
 				createdAt: datetime(),
 				updatedAt: datetime()
 			})
-			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider
+			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider,
+			       u.version as version
 		`
 
 		params := map[string]any{
@@ -45,6 +47,7 @@ func (r *Neo4jRepository) CreateUser(ctx context.Context, input model.CreateUser
 				Name:            record.AsMap()["name"].(string),
 				Email:           record.AsMap()["email"].(string),
 				AuthProvider:    getStringPointer(record.AsMap()["authProvider"]),
+				Version:         getInt32FromRecord(record, "version"),
 				Activities:      []*model.UserActivity{},
 				Ratings:         []*model.Rating{},
 				Favorites:       []model.Media{},
@@ -73,6 +76,7 @@ func (r *Neo4jRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*model
 			OPTIONAL MATCH (u)-[:FAVORITES]->(f:Media)
 			OPTIONAL MATCH (u)-[:RECEIVED_RECOMMENDATION]->(rec:Recommendation)
 			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider,
+			       coalesce(u.version, 0) as version,
 			       collect(DISTINCT a) as activities,
 			       collect(DISTINCT r) as ratings,
 			       collect(DISTINCT f) as favorites,
@@ -93,6 +97,7 @@ func (r *Neo4jRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*model
 				Name:            record.AsMap()["name"].(string),
 				Email:           record.AsMap()["email"].(string),
 				AuthProvider:    getStringPointer(record.AsMap()["authProvider"]),
+				Version:         getInt32FromRecord(record, "version"),
 				Activities:      []*model.UserActivity{},   // TODO: Parse activities
 				Ratings:         []*model.Rating{},         // TODO: Parse ratings
 				Favorites:       []model.Media{},           // TODO: Parse favorites
@@ -101,7 +106,7 @@ func (r *Neo4jRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*model
 			return user, nil
 		}
 
-		return nil, fmt.Errorf("user not found")
+		return nil, NotFoundError("user")
 	})
 
 	if err != nil {
@@ -116,7 +121,8 @@ func (r *Neo4jRepository) GetUserByEmail(ctx context.Context, email string) (*mo
 	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
 			MATCH (u:User {email: $email})
-			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider
+			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider,
+			       coalesce(u.version, 0) as version
 		`
 
 		params := map[string]any{"email": email}
@@ -138,6 +144,7 @@ func (r *Neo4jRepository) GetUserByEmail(ctx context.Context, email string) (*mo
 				Name:            record.AsMap()["name"].(string),
 				Email:           record.AsMap()["email"].(string),
 				AuthProvider:    getStringPointer(record.AsMap()["authProvider"]),
+				Version:         getInt32FromRecord(record, "version"),
 				Activities:      []*model.UserActivity{},
 				Ratings:         []*model.Rating{},
 				Favorites:       []model.Media{},
@@ -146,7 +153,7 @@ func (r *Neo4jRepository) GetUserByEmail(ctx context.Context, email string) (*mo
 			return user, nil
 		}
 
-		return nil, fmt.Errorf("user not found")
+		return nil, NotFoundError("user")
 	})
 
 	if err != nil {
@@ -161,7 +168,8 @@ func (r *Neo4jRepository) GetAllUsers(ctx context.Context) ([]*model.User, error
 	result, err := r.db.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
 			MATCH (u:User)
-			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider
+			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider,
+			       coalesce(u.version, 0) as version
 			ORDER BY u.name
 		`
 
@@ -183,6 +191,7 @@ func (r *Neo4jRepository) GetAllUsers(ctx context.Context) ([]*model.User, error
 				Name:            record.AsMap()["name"].(string),
 				Email:           record.AsMap()["email"].(string),
 				AuthProvider:    getStringPointer(record.AsMap()["authProvider"]),
+				Version:         getInt32FromRecord(record, "version"),
 				Activities:      []*model.UserActivity{},
 				Ratings:         []*model.Rating{},
 				Favorites:       []model.Media{},
@@ -201,16 +210,41 @@ func (r *Neo4jRepository) GetAllUsers(ctx context.Context) ([]*model.User, error
 	return result.([]*model.User), nil
 }
 
-// UpdateUser updates an existing user
-func (r *Neo4jRepository) UpdateUser(ctx context.Context, id uuid.UUID, input model.UpdateUserInput) (*model.User, error) {
+// UpdateUser updates an existing user. When expectedVersion is set and the
+// stored version differs, nothing is written and a conflict error carrying
+// the current user is returned.
+func (r *Neo4jRepository) UpdateUser(ctx context.Context, id uuid.UUID, input model.UpdateUserInput, expectedVersion *int32) (*model.User, error) {
 	result, err := r.db.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Lock the user before comparing versions so a concurrent update
+		// can't slip in between the check and the write
 		query := `
 			MATCH (u:User {id: $id})
-			SET u.updatedAt = datetime()
+			SET u.version = coalesce(u.version, 0)
+			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider,
+			       u.version as version
 		`
 
 		params := map[string]any{"id": id.String()}
 
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Next(ctx) {
+			return nil, NotFoundError("user")
+		}
+
+		current := userFromRecord(id, result.Record())
+		if expectedVersion != nil && current.Version != *expectedVersion {
+			return nil, VersionConflictError("user", current)
+		}
+
+		query = `
+			MATCH (u:User {id: $id})
+			SET u.updatedAt = datetime(), u.version = u.version + 1
+		`
+
 		// Add optional fields to SET clause
 		if input.Name != nil {
 			query += ", u.name = $name"
@@ -223,30 +257,20 @@ func (r *Neo4jRepository) UpdateUser(ctx context.Context, id uuid.UUID, input mo
 		}
 
 		query += `
-			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider
+			RETURN u.id as id, u.name as name, u.email as email, u.authProvider as authProvider,
+			       u.version as version
 		`
 
-		result, err := tx.Run(ctx, query, params)
+		result, err = tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
 		}
 
 		if result.Next(ctx) {
-			record := result.Record()
-			user := &model.User{
-				ID:              id,
-				Name:            record.AsMap()["name"].(string),
-				Email:           record.AsMap()["email"].(string),
-				AuthProvider:    getStringPointer(record.AsMap()["authProvider"]),
-				Activities:      []*model.UserActivity{},
-				Ratings:         []*model.Rating{},
-				Favorites:       []model.Media{},
-				Recommendations: []*model.Recommendation{},
-			}
-			return user, nil
+			return userFromRecord(id, result.Record()), nil
 		}
 
-		return nil, fmt.Errorf("user not found")
+		return nil, NotFoundError("user")
 	})
 
 	if err != nil {
@@ -277,6 +301,21 @@ func (r *Neo4jRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+// userFromRecord builds a user from a record returning the core user fields
+func userFromRecord(id uuid.UUID, record *neo4j.Record) *model.User {
+	return &model.User{
+		ID:              id,
+		Name:            record.AsMap()["name"].(string),
+		Email:           record.AsMap()["email"].(string),
+		AuthProvider:    getStringPointer(record.AsMap()["authProvider"]),
+		Version:         getInt32FromRecord(record, "version"),
+		Activities:      []*model.UserActivity{},
+		Ratings:         []*model.Rating{},
+		Favorites:       []model.Media{},
+		Recommendations: []*model.Recommendation{},
+	}
+}
+
 // Helper function to safely get string pointer from interface{}
 func getStringPointer(value interface{}) *string {
 	if value == nil {
@@ -0,0 +1,249 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"nq/db"
+	"nq/graph/model"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultEnrichers are the enrichers run when ENRICHERS is not set
+const DefaultEnrichers = "tmdb,openlibrary,musicbrainz,igdb"
+
+// DefaultUserAgent identifies our requests to metadata services, some of
+// which reject anonymous clients
+const DefaultUserAgent = "nq/1.0"
+
+// Enricher looks media up in a metadata service
+type Enricher interface {
+	// Name is the source recorded on the fields the enricher fills in
+	Name() string
+	// Supports reports whether the enricher knows media of a type
+	Supports(mediaType model.MediaType) bool
+	// Enrich returns the metadata found for a media item, or nil when the
+	// service has no confident match for it
+	Enrich(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error)
+}
+
+// Pipeline runs enrichers in order until one finds a media item
+type Pipeline struct {
+	enrichers []Enricher
+}
+
+// NewPipeline creates a pipeline of enrichers, tried in the order given
+func NewPipeline(enrichers ...Enricher) *Pipeline {
+	return &Pipeline{enrichers: enrichers}
+}
+
+// NewPipelineFromEnv creates a pipeline of the enrichers listed in
+// ENRICHERS, comma separated. Enrichers without their settings, such as
+// API keys, are left out.
+func NewPipelineFromEnv() *Pipeline {
+	names := os.Getenv("ENRICHERS")
+	if names == "" {
+		names = DefaultEnrichers
+	}
+
+	var enrichers []Enricher
+	for _, name := range strings.Split(names, ",") {
+		var enricher Enricher
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "", "off":
+			continue
+		case tmdbSource:
+			// Checked before going into the interface, where a nil
+			// pointer would not be nil
+			if tmdb := newTMDBEnricher(); tmdb != nil {
+				enricher = tmdb
+			}
+		case openLibrarySource:
+			enricher = newOpenLibraryEnricher()
+		case musicBrainzSource:
+			enricher = newMusicBrainzEnricher()
+		case igdbSource:
+			if igdb := newIGDBEnricher(); igdb != nil {
+				enricher = igdb
+			}
+		default:
+			log.Printf("Warning: Unknown enricher %q in ENRICHERS", name)
+			continue
+		}
+		if enricher != nil {
+			enrichers = append(enrichers, enricher)
+		}
+	}
+	return NewPipeline(enrichers...)
+}
+
+// Enrichers lists the pipeline's enrichers
+func (p *Pipeline) Enrichers() []Enricher {
+	return slices.Clone(p.enrichers)
+}
+
+// Supports reports whether any enricher knows media of a type
+func (p *Pipeline) Supports(mediaType model.MediaType) bool {
+	for _, enricher := range p.enrichers {
+		if enricher.Supports(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enrich looks a media item up with the first enricher that finds it and
+// fills in the fields it doesn't have yet. Media no enricher finds is
+// returned unchanged, with no source.
+func (p *Pipeline) Enrich(ctx context.Context, repo db.Repository, id uuid.UUID) (*model.MediaEnrichment, error) {
+	target, err := repo.GetEnrichmentTarget(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Supports(target.Type) {
+		return nil, db.ValidationFailedError("no enricher on this server supports %s media", strings.ToLower(target.Type.String()))
+	}
+
+	result := &model.MediaEnrichment{Fields: []string{}}
+	for _, enricher := range p.enrichers {
+		if !enricher.Supports(target.Type) {
+			continue
+		}
+
+		enrichment, err := enricher.Enrich(ctx, target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", enricher.Name(), err)
+		}
+		if enrichment == nil {
+			continue
+		}
+
+		enrichment.Source = enricher.Name()
+		fields, err := repo.ApplyEnrichment(ctx, target.ID, enrichment)
+		if err != nil {
+			return nil, err
+		}
+		source := enricher.Name()
+		result.Source = &source
+		if fields != nil {
+			result.Fields = fields
+		}
+		break
+	}
+
+	result.Media, err = repo.GetMediaByID(ctx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// requestHeader returns the headers sent with every enrichment request
+func requestHeader() http.Header {
+	userAgent := os.Getenv("ENRICHMENT_USER_AGENT")
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", userAgent)
+	header.Set("Accept", "application/json")
+	return header
+}
+
+// baseURLFromEnv returns the base URL set in an environment variable, or
+// fallback, without a trailing slash
+func baseURLFromEnv(name, fallback string) string {
+	baseURL := os.Getenv(name)
+	if baseURL == "" {
+		baseURL = fallback
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// sameTitle reports whether two titles name the same work, ignoring case,
+// accents, punctuation and a leading article
+func sameTitle(a, b string) bool {
+	return db.NormalizeTitle(a) != "" && db.NormalizeTitle(a) == db.NormalizeTitle(b)
+}
+
+// yearOf returns the year a date such as "1999-03-31" starts with, or ""
+func yearOf(date string) string {
+	if len(date) < 4 {
+		return ""
+	}
+	return date[:4]
+}
+
+// releaseDate normalizes a date from a metadata service to YYYY-MM-DD,
+// returning "" for dates that don't name a day, which are kept as a year
+// instead
+func releaseDate(date string) string {
+	for _, layout := range []string{time.DateOnly, "January 2, 2006", "Jan 2, 2006", "2 January 2006"} {
+		if parsed, err := time.Parse(layout, strings.TrimSpace(date)); err == nil {
+			return parsed.Format(time.DateOnly)
+		}
+	}
+	return ""
+}
+
+// dateFields sets releaseDate, or releaseYear when a date only names its
+// year or month, from a date from a metadata service
+func dateFields(fields map[string]any, date string) {
+	if day := releaseDate(date); day != "" {
+		fields["releaseDate"] = day
+		return
+	}
+	if year := yearOf(strings.TrimSpace(date)); year != "" {
+		var value int32
+		if _, err := fmt.Sscan(year, &value); err == nil && value > 0 {
+			fields["releaseYear"] = value
+		}
+	}
+}
+
+// pickMatch returns the first candidate whose title matches the target and,
+// when both years are known, whose year is the target's or one off, since
+// release dates often differ by region
+func pickMatch[T any](target *db.EnrichmentTarget, candidates []T, title func(T) string, year func(T) string) (T, bool) {
+	for _, candidate := range candidates {
+		if !sameTitle(title(candidate), target.Title) {
+			continue
+		}
+		if candidateYear := year(candidate); target.Year != "" && candidateYear != "" && !nearYear(target.Year, candidateYear) {
+			continue
+		}
+		return candidate, true
+	}
+	var zero T
+	return zero, false
+}
+
+// nearYear reports whether two years are at most one apart
+func nearYear(a, b string) bool {
+	var yearA, yearB int
+	if _, err := fmt.Sscan(a, &yearA); err != nil {
+		return false
+	}
+	if _, err := fmt.Sscan(b, &yearB); err != nil {
+		return false
+	}
+	return yearA-yearB <= 1 && yearB-yearA <= 1
+}
+
+// quote puts a phrase in double quotes for a search query, as both the
+// MusicBrainz and IGDB query languages take them
+func quote(phrase string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(phrase) + `"`
+}
+
+// capInt32 converts a count to int32, capping it rather than overflowing
+func capInt32(n int64) int32 {
+	return int32(min(max(n, 0), 1<<31-1))
+}
@@ -0,0 +1,213 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"nq/db"
+	"nq/graph/model"
+	"nq/integrations"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fixturesDir holds the recorded provider responses, relative to this
+// package
+const fixturesDir = "../testdata/fixtures"
+
+// countingTransport counts requests by host
+type countingTransport struct {
+	mu     sync.Mutex
+	counts map[string]int
+	next   http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.counts[req.URL.Host]++
+	t.mu.Unlock()
+	return t.next.RoundTrip(req)
+}
+
+// replayClient creates an HTTP client for a provider that answers from the
+// recorded fixtures without waiting or retrying
+func replayClient(provider string) (*integrations.HTTPClient, *countingTransport) {
+	transport := &countingTransport{
+		counts: map[string]int{},
+		next:   &integrations.FixtureTransport{Dir: fixturesDir},
+	}
+	client := integrations.NewHTTPClient(provider)
+	client.Client.Transport = transport
+	client.Limiter = nil
+	client.MaxRetries = 0
+	return client, transport
+}
+
+// enrichmentRepository serves one media item and records the enrichment
+// applied to it. Other repository methods aren't used by Pipeline.Enrich
+// and panic.
+type enrichmentRepository struct {
+	db.Repository
+	target  *db.EnrichmentTarget
+	applied *db.Enrichment
+}
+
+func (r *enrichmentRepository) GetEnrichmentTarget(ctx context.Context, id uuid.UUID) (*db.EnrichmentTarget, error) {
+	if id != r.target.ID {
+		return nil, db.NotFoundError("media")
+	}
+	return r.target, nil
+}
+
+func (r *enrichmentRepository) ApplyEnrichment(ctx context.Context, id uuid.UUID, enrichment *db.Enrichment) ([]string, error) {
+	r.applied = enrichment
+	fields := make([]string, 0, len(enrichment.Fields))
+	for field := range enrichment.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields, nil
+}
+
+func (r *enrichmentRepository) GetMediaByID(ctx context.Context, id uuid.UUID) (model.Media, error) {
+	return &model.Movie{ID: id, Title: r.target.Title}, nil
+}
+
+// stubEnricher finds nothing for the media types it supports
+type stubEnricher struct {
+	types []model.MediaType
+	calls int
+}
+
+func (e *stubEnricher) Name() string { return "stub" }
+
+func (e *stubEnricher) Supports(mediaType model.MediaType) bool {
+	return slices.Contains(e.types, mediaType)
+}
+
+func (e *stubEnricher) Enrich(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error) {
+	e.calls++
+	return nil, nil
+}
+
+// hasCreator reports whether an enrichment credits a creator in a role
+func hasCreator(enrichment *db.Enrichment, name, role string) bool {
+	return slices.ContainsFunc(enrichment.Creators, func(c *model.CreatorInput) bool {
+		return c.Name == name && c.Role == role
+	})
+}
+
+// creatorID returns the ID in a source of a creator an enrichment credits,
+// or ""
+func creatorID(enrichment *db.Enrichment, name, source string) string {
+	for _, creator := range enrichment.Creators {
+		if creator.Name != name {
+			continue
+		}
+		for _, id := range creator.ExternalIds {
+			if id.Source == source {
+				return id.Value
+			}
+		}
+	}
+	return ""
+}
+
+// externalID returns an enrichment's ID in a source, or ""
+func externalID(enrichment *db.Enrichment, source string) string {
+	for _, id := range enrichment.ExternalIDs {
+		if id.Source == source {
+			return id.Value
+		}
+	}
+	return ""
+}
+
+func TestPipelineEnrichUsesFirstEnricherWithAMatch(t *testing.T) {
+	client, _ := replayClient(tmdbSource)
+	tmdb := &TMDBEnricher{BaseURL: DefaultTMDBBaseURL, ImageBaseURL: DefaultTMDBImageBaseURL, Token: "token", HTTPClient: client}
+	// Supports movies but finds nothing, so TMDB is tried next
+	miss := &stubEnricher{types: []model.MediaType{model.MediaTypeMovie}}
+	// Doesn't support movies, so it is skipped
+	books := &stubEnricher{types: []model.MediaType{model.MediaTypeBook}}
+
+	repo := &enrichmentRepository{target: &db.EnrichmentTarget{
+		ID:    uuid.New(),
+		Type:  model.MediaTypeMovie,
+		Title: "The Matrix",
+		Year:  "1999",
+	}}
+	result, err := NewPipeline(books, miss, tmdb).Enrich(context.Background(), repo, repo.target.ID)
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+
+	if miss.calls != 1 || books.calls != 0 {
+		t.Errorf("stub enrichers called %d and %d times, want 1 and 0", miss.calls, books.calls)
+	}
+	if result.Source == nil || *result.Source != tmdbSource {
+		t.Errorf("got source %v, want tmdb", result.Source)
+	}
+	if repo.applied == nil || repo.applied.Source != tmdbSource {
+		t.Fatalf("applied %+v, want TMDB's enrichment", repo.applied)
+	}
+	if !slices.Contains(result.Fields, "runtime") || result.Media == nil {
+		t.Errorf("got %+v", result)
+	}
+}
+
+func TestPipelineEnrichWithoutMatch(t *testing.T) {
+	miss := &stubEnricher{types: []model.MediaType{model.MediaTypeMovie}}
+	repo := &enrichmentRepository{target: &db.EnrichmentTarget{ID: uuid.New(), Type: model.MediaTypeMovie, Title: "Unknown"}}
+
+	result, err := NewPipeline(miss).Enrich(context.Background(), repo, repo.target.ID)
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if result.Source != nil || len(result.Fields) != 0 || repo.applied != nil {
+		t.Errorf("got %+v, want the media unchanged", result)
+	}
+}
+
+func TestPipelineEnrichUnsupportedType(t *testing.T) {
+	miss := &stubEnricher{types: []model.MediaType{model.MediaTypeMovie}}
+	repo := &enrichmentRepository{target: &db.EnrichmentTarget{ID: uuid.New(), Type: model.MediaTypeGame, Title: "Portal"}}
+
+	_, err := NewPipeline(miss).Enrich(context.Background(), repo, repo.target.ID)
+	if !errors.Is(err, db.ErrValidation) {
+		t.Errorf("got %v, want a validation error", err)
+	}
+}
+
+func TestPickMatchComparesTitlesAndYears(t *testing.T) {
+	type candidate struct{ title, year string }
+	candidates := []candidate{
+		{"The Matrix Reloaded", "2003"},
+		{"The Matrix", "2021"},
+		{"The Matrix", "1999"},
+		{"the matrix", "2000"},
+	}
+	title := func(c candidate) string { return c.title }
+	year := func(c candidate) string { return c.year }
+
+	tests := []struct {
+		year string
+		want string
+		ok   bool
+	}{
+		{"1999", "1999", true},
+		// Release dates differ by region, so a year off still matches
+		{"2001", "2000", true},
+		{"2010", "", false},
+		{"", "2021", true},
+	}
+	for _, tt := range tests {
+		match, ok := pickMatch(&db.EnrichmentTarget{Title: "The Matrix", Year: tt.year}, candidates, title, year)
+		if ok != tt.ok || match.year != tt.want {
+			t.Errorf("year %q: got %+v, %v, want %q, %v", tt.year, match, ok, tt.want, tt.ok)
+		}
+	}
+}
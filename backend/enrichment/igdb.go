@@ -0,0 +1,263 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"nq/integrations"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for the IGDB enricher
+const (
+	DefaultIGDBBaseURL      = "https://api.igdb.com/v4"
+	DefaultIGDBAuthBaseURL  = "https://id.twitch.tv"
+	DefaultIGDBImageBaseURL = "https://images.igdb.com/igdb/image/upload"
+)
+
+// igdbSource is the external ID source for IGDB games
+const igdbSource = "igdb"
+
+// igdbSteamCategory is the external game category of Steam app IDs
+const igdbSteamCategory = 1
+
+// igdbESRBCategory is the age rating category of ESRB ratings
+const igdbESRBCategory = 1
+
+// igdbESRBRatings maps IGDB's ESRB rating values onto the ratings
+var igdbESRBRatings = map[int]string{
+	6:  "RP",
+	7:  "EC",
+	8:  "E",
+	9:  "E10+",
+	10: "T",
+	11: "M",
+	12: "AO",
+}
+
+// igdbGameFields are the fields requested for every game
+const igdbGameFields = "name,summary,first_release_date,cover.image_id,genres.name,game_modes.name," +
+	"involved_companies.company.name,involved_companies.developer,age_ratings.category,age_ratings.rating"
+
+// IGDBEnricher looks games up in IGDB, which authenticates with Twitch
+// application credentials
+type IGDBEnricher struct {
+	BaseURL      string
+	AuthBaseURL  string
+	ImageBaseURL string
+	ClientID     string
+	ClientSecret string
+	HTTPClient   *integrations.HTTPClient
+
+	// mu guards the cached application token
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// igdbGame is a game in IGDB
+type igdbGame struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Summary string `json:"summary"`
+	// FirstReleaseDate is a Unix timestamp
+	FirstReleaseDate int64 `json:"first_release_date"`
+	Cover            *struct {
+		ImageID string `json:"image_id"`
+	} `json:"cover"`
+	Genres []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+	GameModes []struct {
+		Name string `json:"name"`
+	} `json:"game_modes"`
+	InvolvedCompanies []struct {
+		Company struct {
+			Name string `json:"name"`
+		} `json:"company"`
+		Developer bool `json:"developer"`
+	} `json:"involved_companies"`
+	AgeRatings []struct {
+		Category int `json:"category"`
+		Rating   int `json:"rating"`
+	} `json:"age_ratings"`
+}
+
+// newIGDBEnricher creates an IGDB enricher from IGDB_CLIENT_ID and
+// IGDB_CLIENT_SECRET, or the Twitch integration's TWITCH_CLIENT_ID and
+// TWITCH_CLIENT_SECRET. IGDB_BASE_URL, IGDB_AUTH_BASE_URL and
+// IGDB_IMAGE_BASE_URL point it at other servers. It returns nil when no
+// credentials are configured.
+func newIGDBEnricher() *IGDBEnricher {
+	clientID, clientSecret := os.Getenv("IGDB_CLIENT_ID"), os.Getenv("IGDB_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		clientID, clientSecret = os.Getenv("TWITCH_CLIENT_ID"), os.Getenv("TWITCH_CLIENT_SECRET")
+	}
+	if clientID == "" || clientSecret == "" {
+		return nil
+	}
+
+	return &IGDBEnricher{
+		BaseURL:      baseURLFromEnv("IGDB_BASE_URL", DefaultIGDBBaseURL),
+		AuthBaseURL:  baseURLFromEnv("IGDB_AUTH_BASE_URL", DefaultIGDBAuthBaseURL),
+		ImageBaseURL: baseURLFromEnv("IGDB_IMAGE_BASE_URL", DefaultIGDBImageBaseURL),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		HTTPClient:   integrations.NewHTTPClient(igdbSource),
+	}
+}
+
+// Name is the source of fields filled in from IGDB
+func (e *IGDBEnricher) Name() string {
+	return igdbSource
+}
+
+// Supports reports whether the media is a game
+func (e *IGDBEnricher) Supports(mediaType model.MediaType) bool {
+	return mediaType == model.MediaTypeGame
+}
+
+// Enrich finds a game by its IGDB ID or Steam app ID, or else by title and
+// year
+func (e *IGDBEnricher) Enrich(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error) {
+	var where []string
+	if id, err := strconv.ParseInt(target.ExternalIDs[igdbSource], 10, 64); err == nil {
+		where = append(where, fmt.Sprintf("where id = %d;", id))
+	}
+	if appID, err := strconv.ParseInt(target.ExternalIDs["steam"], 10, 64); err == nil {
+		where = append(where, fmt.Sprintf(`where external_games.category = %d & external_games.uid = "%d";`, igdbSteamCategory, appID))
+	}
+	for _, clause := range where {
+		var games []igdbGame
+		if err := e.query(ctx, "fields "+igdbGameFields+"; "+clause+" limit 1;", &games); err != nil {
+			return nil, err
+		}
+		if len(games) > 0 {
+			return e.enrichment(&games[0]), nil
+		}
+	}
+
+	var games []igdbGame
+	if err := e.query(ctx, "search "+quote(target.Title)+"; fields "+igdbGameFields+"; limit 10;", &games); err != nil {
+		return nil, err
+	}
+	match, ok := pickMatch(target, games,
+		func(g igdbGame) string { return g.Name },
+		func(g igdbGame) string { return releaseYearOf(g.FirstReleaseDate) })
+	if !ok {
+		return nil, nil
+	}
+	return e.enrichment(&match), nil
+}
+
+// enrichment returns the metadata of an IGDB game
+func (e *IGDBEnricher) enrichment(game *igdbGame) *db.Enrichment {
+	enrichment := &db.Enrichment{
+		Fields: map[string]any{"description": game.Summary},
+		ExternalIDs: []*model.ExternalIDInput{
+			{Source: igdbSource, Value: strconv.FormatInt(game.ID, 10)},
+		},
+	}
+	if game.FirstReleaseDate > 0 {
+		enrichment.Fields["releaseDate"] = time.Unix(game.FirstReleaseDate, 0).UTC().Format(time.DateOnly)
+	}
+	if game.Cover != nil && game.Cover.ImageID != "" {
+		enrichment.Fields["coverUrl"] = e.ImageBaseURL + "/t_cover_big/" + game.Cover.ImageID + ".jpg"
+	}
+
+	genres := make([]string, 0, len(game.Genres))
+	for _, genre := range game.Genres {
+		genres = append(genres, genre.Name)
+	}
+	enrichment.Fields["genre"] = genres
+
+	if len(game.GameModes) > 0 {
+		multiplayer := false
+		for _, mode := range game.GameModes {
+			multiplayer = multiplayer || mode.Name != "Single player"
+		}
+		enrichment.Fields["multiplayer"] = multiplayer
+	}
+
+	for _, rating := range game.AgeRatings {
+		if esrb, ok := igdbESRBRatings[rating.Rating]; ok && rating.Category == igdbESRBCategory {
+			enrichment.Fields["esrbRating"] = esrb
+			break
+		}
+	}
+
+	for _, company := range game.InvolvedCompanies {
+		if company.Developer {
+			enrichment.Creators = append(enrichment.Creators, &model.CreatorInput{Name: company.Company.Name, Role: "Developer"})
+		}
+	}
+	return enrichment
+}
+
+// query sends an Apicalypse query to the games endpoint
+func (e *IGDBEnricher) query(ctx context.Context, body string, out any) error {
+	token, err := e.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	header := requestHeader()
+	header.Set("Client-ID", e.ClientID)
+	header.Set("Authorization", "Bearer "+token)
+	err = e.HTTPClient.Post(ctx, e.BaseURL+"/games", header, "text/plain", body, out)
+	if errors.Is(err, integrations.ErrUnauthorized) {
+		// Drop the token, in case it was revoked, so the next query gets
+		// another
+		e.mu.Lock()
+		e.token = ""
+		e.mu.Unlock()
+	}
+	return err
+}
+
+// accessToken returns an application token, requesting one with the client
+// credentials when the cached one is missing or about to expire
+func (e *IGDBEnricher) accessToken(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token != "" && time.Now().Before(e.expiresAt) {
+		return e.token, nil
+	}
+
+	form := url.Values{
+		"client_id":     {e.ClientID},
+		"client_secret": {e.ClientSecret},
+		"grant_type":    {"client_credentials"},
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err := e.HTTPClient.PostForm(ctx, e.AuthBaseURL+"/oauth2/token", requestHeader(), form, &body)
+	if errors.Is(err, integrations.ErrBadRequest) || errors.Is(err, integrations.ErrForbidden) {
+		return "", fmt.Errorf("IGDB client credentials were rejected: %w", err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	e.token = body.AccessToken
+	// Renew a minute early, so tokens don't expire mid-request
+	e.expiresAt = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return e.token, nil
+}
+
+// releaseYearOf returns the year of a Unix timestamp, or "" when it is unset
+func releaseYearOf(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return strconv.Itoa(time.Unix(timestamp, 0).UTC().Year())
+}
@@ -0,0 +1,55 @@
+package enrichment
+
+import (
+	"context"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+)
+
+func TestIGDBEnrichGame(t *testing.T) {
+	client, transport := replayClient(igdbSource)
+	enricher := &IGDBEnricher{
+		BaseURL:      DefaultIGDBBaseURL,
+		AuthBaseURL:  DefaultIGDBAuthBaseURL,
+		ImageBaseURL: DefaultIGDBImageBaseURL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		HTTPClient:   client,
+	}
+	target := &db.EnrichmentTarget{Type: model.MediaTypeGame, Title: "Portal"}
+
+	enrichment, err := enricher.Enrich(context.Background(), target)
+	if err != nil || enrichment == nil {
+		t.Fatalf("got %v, %v", enrichment, err)
+	}
+
+	want := map[string]any{
+		"releaseDate": "2007-10-09",
+		"esrbRating":  "T",
+		"multiplayer": false,
+		"coverUrl":    DefaultIGDBImageBaseURL + "/t_cover_big/co1x7d.jpg",
+	}
+	for field, value := range want {
+		if enrichment.Fields[field] != value {
+			t.Errorf("got %s %v, want %v", field, enrichment.Fields[field], value)
+		}
+	}
+	if genres, _ := enrichment.Fields["genre"].([]string); len(genres) != 2 || genres[0] != "Shooter" || genres[1] != "Puzzle" {
+		t.Errorf("got genres %v", enrichment.Fields["genre"])
+	}
+	if !hasCreator(enrichment, "Valve Corporation", "Developer") {
+		t.Errorf("got creators %v", enrichment.Creators)
+	}
+	if externalID(enrichment, igdbSource) != "71" {
+		t.Errorf("got external IDs %v", enrichment.ExternalIDs)
+	}
+
+	// The application token is cached until it expires
+	if _, err := enricher.Enrich(context.Background(), target); err != nil {
+		t.Fatalf("second Enrich: %v", err)
+	}
+	if transport.counts["id.twitch.tv"] != 1 || transport.counts["api.igdb.com"] != 2 {
+		t.Errorf("got requests %v, want one token for two queries", transport.counts)
+	}
+}
@@ -0,0 +1,231 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"nq/integrations"
+	"strings"
+)
+
+// Defaults for the MusicBrainz enricher
+const (
+	DefaultMusicBrainzBaseURL = "https://musicbrainz.org/ws/2"
+	DefaultCoverArtBaseURL    = "https://coverartarchive.org"
+)
+
+// musicBrainzSource is the external ID source for MusicBrainz release
+// groups, which group the releases of an album
+const musicBrainzSource = "musicbrainz"
+
+// musicBrainzArtistSource is the external ID source for MusicBrainz artists
+const musicBrainzArtistSource = "musicbrainz-artist"
+
+// MusicBrainzEnricher looks albums up in MusicBrainz, with covers from the
+// Cover Art Archive
+type MusicBrainzEnricher struct {
+	BaseURL         string
+	CoverArtBaseURL string
+	HTTPClient      *integrations.HTTPClient
+}
+
+// musicBrainzReleaseGroup is an album in MusicBrainz
+type musicBrainzReleaseGroup struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	FirstReleaseDate string `json:"first-release-date"`
+	ArtistCredit     []struct {
+		Name   string `json:"name"`
+		Artist struct {
+			ID string `json:"id"`
+		} `json:"artist"`
+	} `json:"artist-credit"`
+}
+
+// newMusicBrainzEnricher creates a MusicBrainz enricher, pointed at
+// MUSICBRAINZ_BASE_URL and COVERART_BASE_URL when they are set. MusicBrainz
+// needs no API key, but identifies clients by ENRICHMENT_USER_AGENT.
+func newMusicBrainzEnricher() *MusicBrainzEnricher {
+	return &MusicBrainzEnricher{
+		BaseURL:         baseURLFromEnv("MUSICBRAINZ_BASE_URL", DefaultMusicBrainzBaseURL),
+		CoverArtBaseURL: baseURLFromEnv("COVERART_BASE_URL", DefaultCoverArtBaseURL),
+		HTTPClient:      integrations.NewHTTPClient(musicBrainzSource),
+	}
+}
+
+// Name is the source of fields filled in from MusicBrainz
+func (e *MusicBrainzEnricher) Name() string {
+	return musicBrainzSource
+}
+
+// Supports reports whether the media is an album
+func (e *MusicBrainzEnricher) Supports(mediaType model.MediaType) bool {
+	return mediaType == model.MediaTypeMusicAlbum
+}
+
+// Enrich finds an album by its MusicBrainz ID, or else by title, artist and
+// year, and reads its tracks and label from its first official release
+func (e *MusicBrainzEnricher) Enrich(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error) {
+	id := target.ExternalIDs[musicBrainzSource]
+	if id == "" {
+		var err error
+		if id, err = e.search(ctx, target); err != nil || id == "" {
+			return nil, err
+		}
+	}
+
+	var group musicBrainzReleaseGroup
+	err := e.get(ctx, "/release-group/"+url.PathEscape(id), url.Values{"inc": {"artist-credits"}}, &group)
+	if errors.Is(err, integrations.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	enrichment := &db.Enrichment{
+		Fields: map[string]any{},
+		ExternalIDs: []*model.ExternalIDInput{
+			{Source: musicBrainzSource, Value: group.ID},
+		},
+	}
+	dateFields(enrichment.Fields, group.FirstReleaseDate)
+	for _, credit := range group.ArtistCredit {
+		creator := &model.CreatorInput{Name: credit.Name, Role: "Artist"}
+		if credit.Artist.ID != "" {
+			creator.ExternalIds = []*model.ExternalIDInput{{Source: musicBrainzArtistSource, Value: credit.Artist.ID}}
+		}
+		enrichment.Creators = append(enrichment.Creators, creator)
+	}
+
+	if err := e.addRelease(ctx, enrichment, group.ID); err != nil {
+		return nil, err
+	}
+	if cover, err := e.cover(ctx, group.ID); err != nil {
+		return nil, err
+	} else if cover != "" {
+		enrichment.Fields["coverUrl"] = cover
+	}
+	return enrichment, nil
+}
+
+// search returns the ID of the release group with the target's title and,
+// when it has creators, its first artist, or ""
+func (e *MusicBrainzEnricher) search(ctx context.Context, target *db.EnrichmentTarget) (string, error) {
+	terms := []string{"releasegroup:" + quote(target.Title)}
+	if len(target.Creators) > 0 {
+		terms = append(terms, "artist:"+quote(target.Creators[0]))
+	}
+
+	var body struct {
+		ReleaseGroups []musicBrainzReleaseGroup `json:"release-groups"`
+	}
+	query := url.Values{"query": {strings.Join(terms, " AND ")}, "limit": {"10"}}
+	if err := e.get(ctx, "/release-group", query, &body); err != nil {
+		return "", err
+	}
+
+	match, ok := pickMatch(target, body.ReleaseGroups,
+		func(g musicBrainzReleaseGroup) string { return g.Title },
+		func(g musicBrainzReleaseGroup) string { return yearOf(g.FirstReleaseDate) })
+	if !ok {
+		return "", nil
+	}
+	return match.ID, nil
+}
+
+// addRelease adds the track count, duration and label of the first official
+// release of a release group to an enrichment
+func (e *MusicBrainzEnricher) addRelease(ctx context.Context, enrichment *db.Enrichment, groupID string) error {
+	var body struct {
+		Releases []struct {
+			Status    string `json:"status"`
+			LabelInfo []struct {
+				Label *struct {
+					Name string `json:"name"`
+				} `json:"label"`
+			} `json:"label-info"`
+			Media []struct {
+				TrackCount int64 `json:"track-count"`
+				Tracks     []struct {
+					// Length is in milliseconds
+					Length int64 `json:"length"`
+				} `json:"tracks"`
+			} `json:"media"`
+		} `json:"releases"`
+	}
+	query := url.Values{"release-group": {groupID}, "inc": {"labels recordings media"}, "limit": {"25"}}
+	if err := e.get(ctx, "/release", query, &body); err != nil {
+		return err
+	}
+	if len(body.Releases) == 0 {
+		return nil
+	}
+
+	release := body.Releases[0]
+	for _, candidate := range body.Releases {
+		if candidate.Status == "Official" {
+			release = candidate
+			break
+		}
+	}
+
+	var tracks, length int64
+	for _, medium := range release.Media {
+		tracks += medium.TrackCount
+		for _, track := range medium.Tracks {
+			length += track.Length
+		}
+	}
+	if tracks > 0 {
+		enrichment.Fields["trackCount"] = capInt32(tracks)
+	}
+	if length > 0 {
+		enrichment.Fields["duration"] = capInt32((length + 500) / 1000)
+	}
+	for _, info := range release.LabelInfo {
+		if info.Label != nil && info.Label.Name != "" {
+			enrichment.Fields["label"] = info.Label.Name
+			break
+		}
+	}
+	return nil
+}
+
+// cover returns the URL of a release group's front cover, or "" when the
+// Cover Art Archive has none
+func (e *MusicBrainzEnricher) cover(ctx context.Context, groupID string) (string, error) {
+	var body struct {
+		Images []struct {
+			Front      bool              `json:"front"`
+			Image      string            `json:"image"`
+			Thumbnails map[string]string `json:"thumbnails"`
+		} `json:"images"`
+	}
+	err := e.HTTPClient.GetJSON(ctx, e.CoverArtBaseURL+"/release-group/"+url.PathEscape(groupID), requestHeader(), &body)
+	if errors.Is(err, integrations.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, image := range body.Images {
+		if !image.Front {
+			continue
+		}
+		if large := image.Thumbnails["large"]; large != "" {
+			return large, nil
+		}
+		return image.Image, nil
+	}
+	return "", nil
+}
+
+// get sends a GET request to the MusicBrainz API for JSON
+func (e *MusicBrainzEnricher) get(ctx context.Context, path string, query url.Values, out any) error {
+	query.Set("fmt", "json")
+	return e.HTTPClient.GetJSON(ctx, e.BaseURL+path+"?"+query.Encode(), requestHeader(), out)
+}
@@ -0,0 +1,43 @@
+package enrichment
+
+import (
+	"context"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+)
+
+func TestMusicBrainzEnrichAlbum(t *testing.T) {
+	client, _ := replayClient(musicBrainzSource)
+	enricher := &MusicBrainzEnricher{BaseURL: DefaultMusicBrainzBaseURL, CoverArtBaseURL: DefaultCoverArtBaseURL, HTTPClient: client}
+
+	enrichment, err := enricher.Enrich(context.Background(), &db.EnrichmentTarget{
+		Type:     model.MediaTypeMusicAlbum,
+		Title:    "OK Computer",
+		Creators: []string{"Radiohead"},
+	})
+	if err != nil || enrichment == nil {
+		t.Fatalf("got %v, %v", enrichment, err)
+	}
+
+	want := map[string]any{
+		"releaseDate": "1997-05-21",
+		"label":       "Parlophone",
+		"trackCount":  int32(12),
+		"duration":    int32(3201),
+	}
+	for field, value := range want {
+		if enrichment.Fields[field] != value {
+			t.Errorf("got %s %v (%T), want %v", field, enrichment.Fields[field], enrichment.Fields[field], value)
+		}
+	}
+	if coverURL, _ := enrichment.Fields["coverUrl"].(string); coverURL == "" {
+		t.Errorf("no cover from the Cover Art Archive")
+	}
+	if !hasCreator(enrichment, "Radiohead", "Artist") || creatorID(enrichment, "Radiohead", musicBrainzArtistSource) != "a74b1b7f-71a5-4011-9441-d0b5e4122711" {
+		t.Errorf("got creators %v", enrichment.Creators)
+	}
+	if externalID(enrichment, musicBrainzSource) != "b1392450-e666-3926-a536-22c65f834433" {
+		t.Errorf("got external IDs %v", enrichment.ExternalIDs)
+	}
+}
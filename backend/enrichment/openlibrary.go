@@ -0,0 +1,271 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"nq/integrations"
+	"nq/isbn"
+	"strings"
+)
+
+// Defaults for the Open Library enricher
+const (
+	DefaultOpenLibraryBaseURL      = "https://openlibrary.org"
+	DefaultOpenLibraryCoverBaseURL = "https://covers.openlibrary.org"
+)
+
+// openLibrarySource is the external ID source for Open Library works
+const openLibrarySource = "openlibrary"
+
+// openLibraryAuthorSource is the external ID source for Open Library authors
+const openLibraryAuthorSource = "openlibrary-author"
+
+// maxOpenLibraryAuthors caps the authors looked up for a work
+const maxOpenLibraryAuthors = 5
+
+// OpenLibraryEnricher looks books up in Open Library, by ISBN when they have
+// one
+type OpenLibraryEnricher struct {
+	BaseURL      string
+	CoverBaseURL string
+	HTTPClient   *integrations.HTTPClient
+}
+
+// openLibraryWork is a work, which groups the editions of a book
+type openLibraryWork struct {
+	Title       string          `json:"title"`
+	Description json.RawMessage `json:"description"`
+	Covers      []int64         `json:"covers"`
+	Authors     []struct {
+		Author struct {
+			Key string `json:"key"`
+		} `json:"author"`
+	} `json:"authors"`
+}
+
+// newOpenLibraryEnricher creates an Open Library enricher, pointed at
+// OPENLIBRARY_BASE_URL and OPENLIBRARY_COVER_BASE_URL when they are set.
+// Open Library needs no API key.
+func newOpenLibraryEnricher() *OpenLibraryEnricher {
+	return &OpenLibraryEnricher{
+		BaseURL:      baseURLFromEnv("OPENLIBRARY_BASE_URL", DefaultOpenLibraryBaseURL),
+		CoverBaseURL: baseURLFromEnv("OPENLIBRARY_COVER_BASE_URL", DefaultOpenLibraryCoverBaseURL),
+		HTTPClient:   integrations.NewHTTPClient(openLibrarySource),
+	}
+}
+
+// Name is the source of fields filled in from Open Library
+func (e *OpenLibraryEnricher) Name() string {
+	return openLibrarySource
+}
+
+// Supports reports whether the media is a book
+func (e *OpenLibraryEnricher) Supports(mediaType model.MediaType) bool {
+	return mediaType == model.MediaTypeBook
+}
+
+// Enrich finds the edition of a book with its ISBN, or else its work by
+// title and author
+func (e *OpenLibraryEnricher) Enrich(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error) {
+	if isbn.Valid(target.ISBN) {
+		enrichment, err := e.edition(ctx, isbn.Clean(target.ISBN))
+		if enrichment != nil || err != nil {
+			return enrichment, err
+		}
+	}
+	return e.search(ctx, target)
+}
+
+// edition returns the metadata of the edition with an ISBN, or nil when Open
+// Library doesn't have it
+func (e *OpenLibraryEnricher) edition(ctx context.Context, number string) (*db.Enrichment, error) {
+	var body struct {
+		Publishers    []string `json:"publishers"`
+		NumberOfPages int64    `json:"number_of_pages"`
+		PublishDate   string   `json:"publish_date"`
+		Covers        []int64  `json:"covers"`
+		Works         []struct {
+			Key string `json:"key"`
+		} `json:"works"`
+	}
+	err := e.get(ctx, "/isbn/"+url.PathEscape(number)+".json", nil, &body)
+	if errors.Is(err, integrations.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	enrichment := &db.Enrichment{Fields: map[string]any{}}
+	if len(body.Publishers) > 0 {
+		enrichment.Fields["publisher"] = body.Publishers[0]
+	}
+	if body.NumberOfPages > 0 {
+		enrichment.Fields["pages"] = capInt32(body.NumberOfPages)
+	}
+	dateFields(enrichment.Fields, body.PublishDate)
+	if len(body.Covers) > 0 {
+		enrichment.Fields["coverUrl"] = e.coverURL(body.Covers[0])
+	}
+
+	if len(body.Works) > 0 {
+		if err := e.addWork(ctx, enrichment, body.Works[0].Key); err != nil {
+			return nil, err
+		}
+	}
+	return enrichment, nil
+}
+
+// search returns the metadata of the work with the target's title and, when
+// it has creators, one of its authors, or nil when there is none
+func (e *OpenLibraryEnricher) search(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error) {
+	query := url.Values{
+		"title":  {target.Title},
+		"fields": {"key,title,author_name,first_publish_year,cover_i,number_of_pages_median"},
+		"limit":  {"10"},
+	}
+	if len(target.Creators) > 0 {
+		query.Set("author", target.Creators[0])
+	}
+
+	type doc struct {
+		Key              string   `json:"key"`
+		Title            string   `json:"title"`
+		AuthorName       []string `json:"author_name"`
+		FirstPublishYear int      `json:"first_publish_year"`
+		CoverID          int64    `json:"cover_i"`
+		Pages            int64    `json:"number_of_pages_median"`
+	}
+	var body struct {
+		Docs []doc `json:"docs"`
+	}
+	if err := e.get(ctx, "/search.json", query, &body); err != nil {
+		return nil, err
+	}
+
+	// Works are dated by their first edition, which the target's year, of the
+	// edition read, can be long after, so only titles and authors are compared
+	var match *doc
+	for i, candidate := range body.Docs {
+		if sameTitle(candidate.Title, target.Title) && sharesCreator(target.Creators, candidate.AuthorName) {
+			match = &body.Docs[i]
+			break
+		}
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	enrichment := &db.Enrichment{Fields: map[string]any{}}
+	if match.Pages > 0 {
+		enrichment.Fields["pages"] = capInt32(match.Pages)
+	}
+	if match.CoverID > 0 {
+		enrichment.Fields["coverUrl"] = e.coverURL(match.CoverID)
+	}
+	if match.FirstPublishYear > 0 {
+		enrichment.Fields["releaseYear"] = int32(match.FirstPublishYear)
+	}
+	if err := e.addWork(ctx, enrichment, match.Key); err != nil {
+		return nil, err
+	}
+	return enrichment, nil
+}
+
+// addWork adds the description, authors and ID of a work to an enrichment
+func (e *OpenLibraryEnricher) addWork(ctx context.Context, enrichment *db.Enrichment, key string) error {
+	if !strings.HasPrefix(key, "/works/") {
+		return nil
+	}
+
+	var work openLibraryWork
+	err := e.get(ctx, key+".json", nil, &work)
+	if errors.Is(err, integrations.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if description := workDescription(work.Description); description != "" {
+		enrichment.Fields["description"] = description
+	}
+	if _, ok := enrichment.Fields["coverUrl"]; !ok && len(work.Covers) > 0 {
+		enrichment.Fields["coverUrl"] = e.coverURL(work.Covers[0])
+	}
+	for i, author := range work.Authors {
+		if i == maxOpenLibraryAuthors {
+			break
+		}
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := e.get(ctx, author.Author.Key+".json", nil, &body); err != nil {
+			return err
+		}
+		enrichment.Creators = append(enrichment.Creators, &model.CreatorInput{
+			Name: body.Name,
+			Role: "Author",
+			ExternalIds: []*model.ExternalIDInput{{
+				Source: openLibraryAuthorSource,
+				Value:  strings.TrimPrefix(author.Author.Key, "/authors/"),
+			}},
+		})
+	}
+	enrichment.ExternalIDs = append(enrichment.ExternalIDs, &model.ExternalIDInput{
+		Source: openLibrarySource,
+		Value:  strings.TrimPrefix(key, "/works/"),
+	})
+	return nil
+}
+
+// coverURL returns the URL of the large size of a cover
+func (e *OpenLibraryEnricher) coverURL(id int64) string {
+	return fmt.Sprintf("%s/b/id/%d-L.jpg", e.CoverBaseURL, id)
+}
+
+// get sends a GET request to Open Library
+func (e *OpenLibraryEnricher) get(ctx context.Context, path string, query url.Values, out any) error {
+	rawURL := e.BaseURL + path
+	if len(query) > 0 {
+		rawURL += "?" + query.Encode()
+	}
+	return e.HTTPClient.GetJSON(ctx, rawURL, requestHeader(), out)
+}
+
+// workDescription reads a work's description, which is either a string or
+// a typed text object
+func workDescription(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+	var typed struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &typed); err == nil {
+		return strings.TrimSpace(typed.Value)
+	}
+	return ""
+}
+
+// sharesCreator reports whether two lists of names have one in common, or
+// the first is empty
+func sharesCreator(known, found []string) bool {
+	if len(known) == 0 {
+		return true
+	}
+	for _, a := range known {
+		for _, b := range found {
+			if sameTitle(a, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package enrichment
+
+import (
+	"context"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+)
+
+func TestOpenLibraryEnrichByISBN(t *testing.T) {
+	client, _ := replayClient(openLibrarySource)
+	enricher := &OpenLibraryEnricher{BaseURL: DefaultOpenLibraryBaseURL, CoverBaseURL: DefaultOpenLibraryCoverBaseURL, HTTPClient: client}
+
+	enrichment, err := enricher.Enrich(context.Background(), &db.EnrichmentTarget{
+		Type:  model.MediaTypeBook,
+		Title: "The Fellowship of the Ring",
+		ISBN:  "978-0-261-10357-3",
+	})
+	if err != nil || enrichment == nil {
+		t.Fatalf("got %v, %v", enrichment, err)
+	}
+
+	want := map[string]any{
+		"publisher": "HarperCollins",
+		"pages":     int32(531),
+		// The edition only gives its year
+		"releaseYear": int32(2007),
+		"coverUrl":    DefaultOpenLibraryCoverBaseURL + "/b/id/8406786-L.jpg",
+	}
+	for field, value := range want {
+		if enrichment.Fields[field] != value {
+			t.Errorf("got %s %v, want %v", field, enrichment.Fields[field], value)
+		}
+	}
+	if _, ok := enrichment.Fields["releaseDate"]; ok {
+		t.Errorf("got a release date from a year")
+	}
+	// The description comes from the work, given as a typed text object
+	if description, _ := enrichment.Fields["description"].(string); description == "" {
+		t.Errorf("no description from the work")
+	}
+	if !hasCreator(enrichment, "J.R.R. Tolkien", "Author") || creatorID(enrichment, "J.R.R. Tolkien", openLibraryAuthorSource) != "OL26320A" {
+		t.Errorf("got creators %v", enrichment.Creators)
+	}
+	if externalID(enrichment, openLibrarySource) != "OL14933414W" {
+		t.Errorf("got external IDs %v", enrichment.ExternalIDs)
+	}
+}
@@ -0,0 +1,299 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"nq/integrations"
+	"os"
+	"strconv"
+)
+
+// Defaults for the TMDB enricher
+const (
+	DefaultTMDBBaseURL      = "https://api.themoviedb.org/3"
+	DefaultTMDBImageBaseURL = "https://image.tmdb.org/t/p/original"
+)
+
+// External ID sources of TMDB movies and TV shows, which are numbered
+// separately
+const (
+	tmdbSource   = "tmdb"
+	tmdbTVSource = "tmdb-tv"
+	imdbSource   = "imdb"
+	// tmdbPersonSource identifies the people credited on them
+	tmdbPersonSource = "tmdb-person"
+)
+
+// tmdbStatuses maps TMDB's TV statuses onto ours
+var tmdbStatuses = map[string]string{
+	"Returning Series": "Ongoing",
+	"In Production":    "Ongoing",
+	"Planned":          "Ongoing",
+	"Pilot":            "Ongoing",
+	"Ended":            "Ended",
+	"Canceled":         "Cancelled",
+}
+
+// TMDBEnricher looks movies and TV shows up in The Movie Database
+type TMDBEnricher struct {
+	BaseURL      string
+	ImageBaseURL string
+	// Token is a TMDB API read access token
+	Token      string
+	HTTPClient *integrations.HTTPClient
+}
+
+// tmdbResult is a movie or TV show in TMDB search results
+type tmdbResult struct {
+	ID           int64  `json:"id"`
+	Title        string `json:"title"`
+	Name         string `json:"name"`
+	ReleaseDate  string `json:"release_date"`
+	FirstAirDate string `json:"first_air_date"`
+}
+
+// newTMDBEnricher creates a TMDB enricher from TMDB_API_TOKEN and, to point
+// it at other servers, TMDB_BASE_URL and TMDB_IMAGE_BASE_URL. It returns nil
+// when no token is configured.
+func newTMDBEnricher() *TMDBEnricher {
+	token := os.Getenv("TMDB_API_TOKEN")
+	if token == "" {
+		return nil
+	}
+
+	return &TMDBEnricher{
+		BaseURL:      baseURLFromEnv("TMDB_BASE_URL", DefaultTMDBBaseURL),
+		ImageBaseURL: baseURLFromEnv("TMDB_IMAGE_BASE_URL", DefaultTMDBImageBaseURL),
+		Token:        token,
+		HTTPClient:   integrations.NewHTTPClient(tmdbSource),
+	}
+}
+
+// Name is the source of fields filled in from TMDB
+func (e *TMDBEnricher) Name() string {
+	return tmdbSource
+}
+
+// Supports reports whether the media is a movie or TV show
+func (e *TMDBEnricher) Supports(mediaType model.MediaType) bool {
+	return mediaType == model.MediaTypeMovie || mediaType == model.MediaTypeTvShow
+}
+
+// Enrich finds a movie or TV show by its TMDB or IMDb ID, or else by title
+// and year
+func (e *TMDBEnricher) Enrich(ctx context.Context, target *db.EnrichmentTarget) (*db.Enrichment, error) {
+	tv := target.Type == model.MediaTypeTvShow
+	source := tmdbSource
+	if tv {
+		source = tmdbTVSource
+	}
+
+	id := target.ExternalIDs[source]
+	if id == "" && target.ExternalIDs[imdbSource] != "" {
+		var err error
+		if id, err = e.find(ctx, target.ExternalIDs[imdbSource], tv); err != nil {
+			return nil, err
+		}
+	}
+	if id == "" {
+		var err error
+		if id, err = e.search(ctx, target, tv); err != nil || id == "" {
+			return nil, err
+		}
+	}
+
+	if tv {
+		return e.tvShow(ctx, id)
+	}
+	return e.movie(ctx, id)
+}
+
+// find returns the TMDB ID of the movie or TV show with an IMDb ID, or ""
+func (e *TMDBEnricher) find(ctx context.Context, imdbID string, tv bool) (string, error) {
+	var body struct {
+		MovieResults []tmdbResult `json:"movie_results"`
+		TVResults    []tmdbResult `json:"tv_results"`
+	}
+	if err := e.get(ctx, "/find/"+url.PathEscape(imdbID), url.Values{"external_source": {"imdb_id"}}, &body); err != nil {
+		return "", err
+	}
+
+	results := body.MovieResults
+	if tv {
+		results = body.TVResults
+	}
+	if len(results) == 0 {
+		return "", nil
+	}
+	return strconv.FormatInt(results[0].ID, 10), nil
+}
+
+// search returns the TMDB ID of the movie or TV show with the target's title
+// and year, or ""
+func (e *TMDBEnricher) search(ctx context.Context, target *db.EnrichmentTarget, tv bool) (string, error) {
+	path, yearParam := "/search/movie", "primary_release_year"
+	if tv {
+		path, yearParam = "/search/tv", "first_air_date_year"
+	}
+	query := url.Values{"query": {target.Title}}
+	if target.Year != "" {
+		query.Set(yearParam, target.Year)
+	}
+
+	var body struct {
+		Results []tmdbResult `json:"results"`
+	}
+	if err := e.get(ctx, path, query, &body); err != nil {
+		return "", err
+	}
+
+	match, ok := pickMatch(target, body.Results,
+		func(r tmdbResult) string { return r.Title + r.Name },
+		func(r tmdbResult) string { return yearOf(r.ReleaseDate + r.FirstAirDate) })
+	if !ok {
+		return "", nil
+	}
+	return strconv.FormatInt(match.ID, 10), nil
+}
+
+// movie returns the metadata of a TMDB movie
+func (e *TMDBEnricher) movie(ctx context.Context, id string) (*db.Enrichment, error) {
+	var body struct {
+		Overview    string `json:"overview"`
+		PosterPath  string `json:"poster_path"`
+		ReleaseDate string `json:"release_date"`
+		Runtime     int64  `json:"runtime"`
+		Budget      int64  `json:"budget"`
+		Revenue     int64  `json:"revenue"`
+		IMDbID      string `json:"imdb_id"`
+		Credits     struct {
+			Crew []struct {
+				ID   int64  `json:"id"`
+				Name string `json:"name"`
+				Job  string `json:"job"`
+			} `json:"crew"`
+		} `json:"credits"`
+	}
+	err := e.get(ctx, "/movie/"+url.PathEscape(id), url.Values{"append_to_response": {"credits"}}, &body)
+	if errors.Is(err, integrations.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	enrichment := &db.Enrichment{
+		Fields: e.commonFields(body.Overview, body.PosterPath, body.ReleaseDate),
+		ExternalIDs: []*model.ExternalIDInput{
+			{Source: tmdbSource, Value: id},
+		},
+	}
+	for name, value := range map[string]int64{"runtime": body.Runtime, "budget": body.Budget, "boxOffice": body.Revenue} {
+		// TMDB reports unknown amounts as 0
+		if value > 0 {
+			enrichment.Fields[name] = capInt32(value)
+		}
+	}
+	for _, member := range body.Credits.Crew {
+		if member.Job == "Director" {
+			enrichment.Creators = append(enrichment.Creators, &model.CreatorInput{
+				Name:        member.Name,
+				Role:        "Director",
+				ExternalIds: tmdbPersonIDs(member.ID),
+			})
+		}
+	}
+	if body.IMDbID != "" {
+		enrichment.ExternalIDs = append(enrichment.ExternalIDs, &model.ExternalIDInput{Source: imdbSource, Value: body.IMDbID})
+	}
+	return enrichment, nil
+}
+
+// tvShow returns the metadata of a TMDB TV show
+func (e *TMDBEnricher) tvShow(ctx context.Context, id string) (*db.Enrichment, error) {
+	var body struct {
+		Overview         string `json:"overview"`
+		PosterPath       string `json:"poster_path"`
+		FirstAirDate     string `json:"first_air_date"`
+		NumberOfSeasons  int64  `json:"number_of_seasons"`
+		NumberOfEpisodes int64  `json:"number_of_episodes"`
+		Status           string `json:"status"`
+		CreatedBy        []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"created_by"`
+		ExternalIDs struct {
+			IMDbID string `json:"imdb_id"`
+		} `json:"external_ids"`
+	}
+	err := e.get(ctx, "/tv/"+url.PathEscape(id), url.Values{"append_to_response": {"external_ids"}}, &body)
+	if errors.Is(err, integrations.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	enrichment := &db.Enrichment{
+		Fields: e.commonFields(body.Overview, body.PosterPath, body.FirstAirDate),
+		ExternalIDs: []*model.ExternalIDInput{
+			{Source: tmdbTVSource, Value: id},
+		},
+	}
+	if body.NumberOfSeasons > 0 {
+		enrichment.Fields["seasons"] = capInt32(body.NumberOfSeasons)
+	}
+	if body.NumberOfEpisodes > 0 {
+		enrichment.Fields["episodes"] = capInt32(body.NumberOfEpisodes)
+	}
+	if status, ok := tmdbStatuses[body.Status]; ok {
+		enrichment.Fields["status"] = status
+	}
+	for _, creator := range body.CreatedBy {
+		enrichment.Creators = append(enrichment.Creators, &model.CreatorInput{
+			Name:        creator.Name,
+			Role:        "Creator",
+			ExternalIds: tmdbPersonIDs(creator.ID),
+		})
+	}
+	if body.ExternalIDs.IMDbID != "" {
+		enrichment.ExternalIDs = append(enrichment.ExternalIDs, &model.ExternalIDInput{Source: imdbSource, Value: body.ExternalIDs.IMDbID})
+	}
+	return enrichment, nil
+}
+
+// commonFields returns the description, cover and release date of a movie or
+// TV show
+func (e *TMDBEnricher) commonFields(overview, posterPath, date string) map[string]any {
+	fields := map[string]any{"description": overview}
+	if posterPath != "" {
+		fields["coverUrl"] = e.ImageBaseURL + posterPath
+	}
+	dateFields(fields, date)
+	return fields
+}
+
+// get sends a GET request to the TMDB API
+func (e *TMDBEnricher) get(ctx context.Context, path string, query url.Values, out any) error {
+	header := requestHeader()
+	header.Set("Authorization", "Bearer "+e.Token)
+
+	err := e.HTTPClient.GetJSON(ctx, e.BaseURL+path+"?"+query.Encode(), header, out)
+	if errors.Is(err, integrations.ErrUnauthorized) {
+		return fmt.Errorf("TMDB API token was rejected: %w", err)
+	}
+	return err
+}
+
+// tmdbPersonIDs returns the external IDs of a person TMDB credits, which
+// has none when TMDB doesn't number them
+func tmdbPersonIDs(id int64) []*model.ExternalIDInput {
+	if id == 0 {
+		return nil
+	}
+	return []*model.ExternalIDInput{{Source: tmdbPersonSource, Value: strconv.FormatInt(id, 10)}}
+}
@@ -0,0 +1,76 @@
+package enrichment
+
+import (
+	"context"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+)
+
+// newReplayTMDBEnricher creates a TMDB enricher answered from fixtures
+func newReplayTMDBEnricher() *TMDBEnricher {
+	client, _ := replayClient(tmdbSource)
+	return &TMDBEnricher{BaseURL: DefaultTMDBBaseURL, ImageBaseURL: DefaultTMDBImageBaseURL, Token: "token", HTTPClient: client}
+}
+
+func TestTMDBEnrichMovie(t *testing.T) {
+	enrichment, err := newReplayTMDBEnricher().Enrich(context.Background(), &db.EnrichmentTarget{
+		Type:  model.MediaTypeMovie,
+		Title: "The Matrix",
+		Year:  "1999",
+	})
+	if err != nil || enrichment == nil {
+		t.Fatalf("got %v, %v", enrichment, err)
+	}
+
+	want := map[string]any{
+		"releaseDate": "1999-03-31",
+		"runtime":     int32(136),
+		"budget":      int32(63000000),
+		"boxOffice":   int32(463517383),
+		"coverUrl":    DefaultTMDBImageBaseURL + "/f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg",
+	}
+	for field, value := range want {
+		if enrichment.Fields[field] != value {
+			t.Errorf("got %s %v, want %v", field, enrichment.Fields[field], value)
+		}
+	}
+	if enrichment.Fields["description"] == "" {
+		t.Errorf("no description")
+	}
+	if !hasCreator(enrichment, "Lana Wachowski", "Director") || !hasCreator(enrichment, "Lilly Wachowski", "Director") {
+		t.Errorf("got creators %v", enrichment.Creators)
+	}
+	if externalID(enrichment, tmdbSource) != "603" || externalID(enrichment, imdbSource) != "tt0133093" {
+		t.Errorf("got external IDs %v", enrichment.ExternalIDs)
+	}
+}
+
+func TestTMDBEnrichTVShow(t *testing.T) {
+	enrichment, err := newReplayTMDBEnricher().Enrich(context.Background(), &db.EnrichmentTarget{
+		Type:  model.MediaTypeTvShow,
+		Title: "Breaking Bad",
+		Year:  "2008",
+	})
+	if err != nil || enrichment == nil {
+		t.Fatalf("got %v, %v", enrichment, err)
+	}
+
+	want := map[string]any{
+		"releaseDate": "2008-01-20",
+		"seasons":     int32(5),
+		"episodes":    int32(62),
+		"status":      "Ended",
+	}
+	for field, value := range want {
+		if enrichment.Fields[field] != value {
+			t.Errorf("got %s %v, want %v", field, enrichment.Fields[field], value)
+		}
+	}
+	if !hasCreator(enrichment, "Vince Gilligan", "Creator") || creatorID(enrichment, "Vince Gilligan", tmdbPersonSource) != "66633" {
+		t.Errorf("got creators %v", enrichment.Creators)
+	}
+	if externalID(enrichment, tmdbTVSource) != "1396" || externalID(enrichment, imdbSource) != "tt0903747" {
+		t.Errorf("got external IDs %v", enrichment.ExternalIDs)
+	}
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminMiddlewareRequiresToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		admin  bool
+	}{
+		{"matching token", "secret", "secret", true},
+		{"wrong token", "secret", "guess", false},
+		{"no token sent", "secret", "", false},
+		{"no token configured", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var admin bool
+			handler := AdminMiddleware(tt.token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				admin = isAdmin(r.Context())
+			}))
+			request := httptest.NewRequest(http.MethodPost, "/query", nil)
+			if tt.header != "" {
+				request.Header.Set(AdminTokenHeader, tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+			if admin != tt.admin {
+				t.Errorf("got admin %v, want %v", admin, tt.admin)
+			}
+		})
+	}
+}
+
+func TestAdminDirectiveRefusesOthers(t *testing.T) {
+	called := false
+	next := func(ctx context.Context) (any, error) {
+		called = true
+		return "merged", nil
+	}
+
+	if _, err := Admin(context.Background(), nil, next); !errors.Is(err, ErrForbidden) || called {
+		t.Errorf("got %v with the resolver called %v, want ErrForbidden before it runs", err, called)
+	}
+
+	ctx := context.WithValue(context.Background(), adminKey{}, true)
+	if result, err := Admin(ctx, nil, next); err != nil || result != "merged" {
+		t.Errorf("got %v, %v for an admin", result, err)
+	}
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"context"
+	"nq/validation"
+	"slices"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Directives returns the implementations of the schema's directives
+func Directives() DirectiveRoot {
+	return DirectiveRoot{
+		Admin:      Admin,
+		Constraint: Constraint,
+	}
+}
+
+// Constraint implements @constraint. Violations are recorded on the root
+// field's validation.Errors instead of failing straight away, so that
+// ValidateArguments can report every invalid field together.
+func Constraint(ctx context.Context, obj any, next graphql.Resolver, min *float64, max *float64, minLength *int32, maxLength *int32, format *string) (any, error) {
+	value, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := validation.Constraint{
+		Min:       min,
+		Max:       max,
+		MinLength: minLength,
+		MaxLength: maxLength,
+		Format:    format,
+	}.Check(value)
+	if len(messages) == 0 {
+		return value, nil
+	}
+
+	path := pathNames(graphql.GetPath(ctx))
+	errs := validation.FromContext(ctx)
+	if errs == nil {
+		// Not under CollectValidationErrors; fail this field on its own
+		return nil, validation.InvalidFields([]validation.FieldError{{Path: path, Message: messages[0]}})
+	}
+	for _, message := range messages {
+		errs.Add(path, message)
+	}
+	return value, nil
+}
+
+// CollectValidationErrors gives each root field a collector for @constraint
+// violations. Arguments are parsed after this runs, so the directive can find it.
+func CollectValidationErrors(ctx context.Context, next graphql.RootResolver) graphql.Marshaler {
+	return next(validation.WithErrors(ctx))
+}
+
+// ValidateArguments runs the Go validators for a root field's arguments and
+// then fails the field, before its resolver touches the repository, if
+// either they or @constraint found a problem
+func ValidateArguments(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || (fc.Object != "Query" && fc.Object != "Mutation") {
+		return next(ctx)
+	}
+
+	errs := validation.FromContext(ctx)
+	if errs == nil {
+		return next(ctx)
+	}
+
+	names := make([]string, 0, len(fc.Args))
+	for name := range fc.Args {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	fieldPath := pathNames(fc.Path())
+	for _, name := range names {
+		validation.Input(errs, append(fieldPath, name), fc.Args[name])
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return next(ctx)
+}
+
+// pathNames converts a GraphQL path into the string form used in field errors
+func pathNames(path ast.Path) []string {
+	names := make([]string, len(path))
+	for i, element := range path {
+		switch e := element.(type) {
+		case ast.PathName:
+			names[i] = string(e)
+		case ast.PathIndex:
+			names[i] = strconv.Itoa(int(e))
+		}
+	}
+	return names
+}
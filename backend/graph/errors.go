@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log"
+	"nq/db"
+	"nq/integrations"
+	"nq/validation"
+	"runtime/debug"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes reported to clients in extensions.code
+const (
+	CodeNotFound      = "NOT_FOUND"
+	CodeAlreadyExists = "ALREADY_EXISTS"
+	CodeValidation    = "VALIDATION_FAILED"
+	CodeConflict      = "CONFLICT"
+	CodeForbidden     = "FORBIDDEN"
+	CodeUnavailable   = "UNAVAILABLE"
+	CodeBadUserInput  = "BAD_USER_INPUT"
+	CodeInternal      = "INTERNAL_SERVER_ERROR"
+)
+
+// errorCodes maps repository and integration error kinds onto the codes
+// above. A provider that is down, rate limits us or doesn't answer is
+// unavailable rather than broken.
+var errorCodes = []struct {
+	kind error
+	code string
+}{
+	{db.ErrNotFound, CodeNotFound},
+	{db.ErrAlreadyExists, CodeAlreadyExists},
+	{db.ErrValidation, CodeValidation},
+	{db.ErrConflict, CodeConflict},
+	{db.ErrUnavailable, CodeUnavailable},
+	{ErrForbidden, CodeForbidden},
+	{integrations.ErrNotFound, CodeNotFound},
+	{integrations.ErrRateLimited, CodeUnavailable},
+	{integrations.ErrServer, CodeUnavailable},
+	{integrations.ErrUnavailable, CodeUnavailable},
+}
+
+// ErrorPresenter attaches an extensions.code to every error sent to clients.
+// Errors without a known kind are logged and replaced by a generic message
+// so driver internals never reach the wire.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+	if _, ok := presented.Extensions["code"]; ok {
+		return presented
+	}
+
+	for _, mapping := range errorCodes {
+		if errors.Is(err, mapping.kind) {
+			setCode(presented, mapping.code)
+			addDetails(presented, err)
+			return presented
+		}
+	}
+
+	if isRequestError(err) {
+		setCode(presented, CodeBadUserInput)
+		return presented
+	}
+
+	log.Printf("Unhandled error at %s: %v", presented.Path, err)
+	presented.Message = "internal server error"
+	setCode(presented, CodeInternal)
+	return presented
+}
+
+// isRequestError reports whether gqlgen raised err about the request itself,
+// such as an argument that fails to parse. gqlgen also wraps resolver errors
+// in a gqlerror to give them a path, so only a gqlerror that wraps nothing
+// but other gqlerrors counts.
+func isRequestError(err error) bool {
+	gqlErr, ok := err.(*gqlerror.Error)
+	for ok && gqlErr.Err != nil {
+		gqlErr, ok = gqlErr.Err.(*gqlerror.Error)
+	}
+	return ok
+}
+
+// Recover turns a resolver panic into an internal error instead of letting
+// the panic message reach the client
+func Recover(ctx context.Context, p any) error {
+	log.Printf("Recovered from panic: %v\n%s", p, debug.Stack())
+
+	err := gqlerror.Errorf("internal server error")
+	setCode(err, CodeInternal)
+	return err
+}
+
+// addDetails copies structured details from validation and repository
+// errors into extensions
+func addDetails(presented *gqlerror.Error, err error) {
+	var invalid *validation.Error
+	if errors.As(err, &invalid) && len(invalid.Fields) > 0 {
+		presented.Extensions["fields"] = invalid.Fields
+	}
+	var repoErr *db.Error
+	if errors.As(err, &repoErr) && repoErr.Current != nil {
+		presented.Extensions["current"] = repoErr.Current
+	}
+}
+
+func setCode(err *gqlerror.Error, code string) {
+	if err.Extensions == nil {
+		err.Extensions = map[string]any{}
+	}
+	err.Extensions["code"] = code
+}
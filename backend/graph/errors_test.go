@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"nq/db"
+	"nq/validation"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestErrorPresenterReportsInvalidFields(t *testing.T) {
+	fields := []validation.FieldError{{Path: []string{"createUser", "input", "email"}, Message: "must be an email address"}}
+
+	presented := ErrorPresenter(context.Background(), validation.InvalidFields(fields))
+
+	if presented.Extensions["code"] != CodeValidation {
+		t.Errorf("got code %v, want %s", presented.Extensions["code"], CodeValidation)
+	}
+	if got, ok := presented.Extensions["fields"].([]validation.FieldError); !ok || len(got) != 1 {
+		t.Errorf("got fields %v", presented.Extensions["fields"])
+	}
+	if presented.Message != "invalid input: createUser.input.email: must be an email address" {
+		t.Errorf("got message %q", presented.Message)
+	}
+}
+
+func TestErrorPresenterReportsCurrentVersion(t *testing.T) {
+	current := map[string]any{"version": 3}
+
+	presented := ErrorPresenter(context.Background(), db.VersionConflictError("rating", current))
+
+	if presented.Extensions["code"] != CodeConflict {
+		t.Errorf("got code %v, want %s", presented.Extensions["code"], CodeConflict)
+	}
+	if _, ok := presented.Extensions["current"]; !ok {
+		t.Errorf("conflict doesn't carry the current rating")
+	}
+	if _, ok := presented.Extensions["fields"]; ok {
+		t.Errorf("conflict reports fields")
+	}
+}
+
+func TestErrorPresenterHidesResolverErrors(t *testing.T) {
+	// gqlgen wraps every resolver error in a gqlerror to give it a path
+	err := graphql.ErrorOnPath(context.Background(), errors.New("neo4j: connection refused at 10.0.0.3:7687"))
+
+	presented := ErrorPresenter(context.Background(), err)
+
+	if presented.Extensions["code"] != CodeInternal {
+		t.Errorf("got code %v, want %s", presented.Extensions["code"], CodeInternal)
+	}
+	if presented.Message != "internal server error" {
+		t.Errorf("got message %q, want the generic one", presented.Message)
+	}
+}
+
+func TestErrorPresenterReportsRequestErrors(t *testing.T) {
+	err := graphql.ErrorOnPath(context.Background(), gqlerror.Errorf("cannot parse %q as an Int", "ten"))
+
+	presented := ErrorPresenter(context.Background(), err)
+
+	if presented.Extensions["code"] != CodeBadUserInput {
+		t.Errorf("got code %v, want %s", presented.Extensions["code"], CodeBadUserInput)
+	}
+	if presented.Message != `cannot parse "ten" as an Int` {
+		t.Errorf("got message %q", presented.Message)
+	}
+}
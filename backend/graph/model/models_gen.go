@@ -3,6 +3,11 @@
 package model
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
 	"github.com/google/uuid"
 )
 
@@ -25,6 +30,88 @@ type ActivityStatus struct {
 	Name string `json:"name"`
 }
 
+type AddEditionInput struct {
+	BookID      uuid.UUID `json:"bookId"`
+	Isbn        string    `json:"isbn"`
+	Title       *string   `json:"title,omitempty"`
+	Publisher   *string   `json:"publisher,omitempty"`
+	Pages       *int32    `json:"pages,omitempty"`
+	ReleaseDate *string   `json:"releaseDate,omitempty"`
+	Format      *string   `json:"format,omitempty"`
+}
+
+type AlbumPlays struct {
+	Album        *MusicAlbum `json:"album"`
+	Plays        int32       `json:"plays"`
+	LastPlayedAt *string     `json:"lastPlayedAt,omitempty"`
+}
+
+type Article struct {
+	ID            uuid.UUID   `json:"id"`
+	Title         string      `json:"title"`
+	ReleaseDate   *string     `json:"releaseDate,omitempty"`
+	Description   *string     `json:"description,omitempty"`
+	CoverURL      *string     `json:"coverUrl,omitempty"`
+	Creators      []*Creator  `json:"creators"`
+	Platforms     []*Platform `json:"platforms"`
+	Tags          []*Tag      `json:"tags"`
+	Ratings       []*Rating   `json:"ratings"`
+	AverageRating *float64    `json:"averageRating,omitempty"`
+	URL           *string     `json:"url,omitempty"`
+	Site          *string     `json:"site,omitempty"`
+	Author        *string     `json:"author,omitempty"`
+	WordCount     *int32      `json:"wordCount,omitempty"`
+	ReadTime      *int32      `json:"readTime,omitempty"`
+}
+
+func (Article) IsMedia()                     {}
+func (this Article) GetID() uuid.UUID        { return this.ID }
+func (this Article) GetTitle() string        { return this.Title }
+func (this Article) GetReleaseDate() *string { return this.ReleaseDate }
+func (this Article) GetDescription() *string { return this.Description }
+func (this Article) GetCoverURL() *string    { return this.CoverURL }
+func (this Article) GetCreators() []*Creator {
+	if this.Creators == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Creator, 0, len(this.Creators))
+	for _, concrete := range this.Creators {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Article) GetPlatforms() []*Platform {
+	if this.Platforms == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Platform, 0, len(this.Platforms))
+	for _, concrete := range this.Platforms {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Article) GetTags() []*Tag {
+	if this.Tags == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Tag, 0, len(this.Tags))
+	for _, concrete := range this.Tags {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Article) GetRatings() []*Rating {
+	if this.Ratings == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Rating, 0, len(this.Ratings))
+	for _, concrete := range this.Ratings {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Article) GetAverageRating() *float64 { return this.AverageRating }
+
 type Book struct {
 	ID            uuid.UUID   `json:"id"`
 	Title         string      `json:"title"`
@@ -38,7 +125,9 @@ type Book struct {
 	AverageRating *float64    `json:"averageRating,omitempty"`
 	Pages         *int32      `json:"pages,omitempty"`
 	Isbn          *string     `json:"isbn,omitempty"`
+	Isbn10        *string     `json:"isbn10,omitempty"`
 	Publisher     *string     `json:"publisher,omitempty"`
+	Editions      []*Edition  `json:"editions"`
 }
 
 func (Book) IsMedia()                     {}
@@ -97,6 +186,7 @@ type CreateActivityInput struct {
 	Review     *string   `json:"review,omitempty"`
 	StartedAt  *string   `json:"startedAt,omitempty"`
 	FinishedAt *string   `json:"finishedAt,omitempty"`
+	Rewatch    *bool     `json:"rewatch,omitempty"`
 }
 
 type CreateBookInput struct {
@@ -162,11 +252,39 @@ type Creator struct {
 	MediaItems []Media      `json:"mediaItems"`
 }
 
+type CreatorInput struct {
+	Name        string             `json:"name"`
+	Role        string             `json:"role"`
+	ExternalIds []*ExternalIDInput `json:"externalIds,omitempty"`
+}
+
 type CreatorRole struct {
 	ID   int32  `json:"id"`
 	Name string `json:"name"`
 }
 
+type Edition struct {
+	ID          uuid.UUID `json:"id"`
+	BookID      uuid.UUID `json:"bookId"`
+	Title       *string   `json:"title,omitempty"`
+	Isbn13      string    `json:"isbn13"`
+	Isbn10      *string   `json:"isbn10,omitempty"`
+	Publisher   *string   `json:"publisher,omitempty"`
+	Pages       *int32    `json:"pages,omitempty"`
+	ReleaseDate *string   `json:"releaseDate,omitempty"`
+	Format      *string   `json:"format,omitempty"`
+}
+
+type ExternalIDInput struct {
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+type FieldSource struct {
+	Field  string `json:"field"`
+	Source string `json:"source"`
+}
+
 type Game struct {
 	ID            uuid.UUID   `json:"id"`
 	Title         string      `json:"title"`
@@ -231,6 +349,115 @@ func (this Game) GetRatings() []*Rating {
 }
 func (this Game) GetAverageRating() *float64 { return this.AverageRating }
 
+type ImportReport struct {
+	Source             ImportSource    `json:"source"`
+	MediaCreated       int32           `json:"mediaCreated"`
+	MediaMatched       int32           `json:"mediaMatched"`
+	ActivitiesImported int32           `json:"activitiesImported"`
+	RatingsImported    int32           `json:"ratingsImported"`
+	ListensImported    int32           `json:"listensImported"`
+	FavoritesImported  int32           `json:"favoritesImported"`
+	CreatorsFollowed   int32           `json:"creatorsFollowed"`
+	MatchesToReview    int32           `json:"matchesToReview"`
+	Unmatched          []*UnmatchedRow `json:"unmatched"`
+}
+
+type IntegrationConnectResult struct {
+	Connection   *IntegrationConnection `json:"connection,omitempty"`
+	AuthorizeURL *string                `json:"authorizeUrl,omitempty"`
+}
+
+type IntegrationConnection struct {
+	Provider     string  `json:"provider"`
+	Account      *string `json:"account,omitempty"`
+	ConnectedAt  string  `json:"connectedAt"`
+	LastSyncedAt *string `json:"lastSyncedAt,omitempty"`
+}
+
+type IntegrationProvider struct {
+	Name     string              `json:"name"`
+	AuthKind IntegrationAuthKind `json:"authKind"`
+}
+
+type Job struct {
+	ID          uuid.UUID `json:"id"`
+	Kind        string    `json:"kind"`
+	State       JobState  `json:"state"`
+	Attempts    int32     `json:"attempts"`
+	MaxAttempts int32     `json:"maxAttempts"`
+	RunAfter    string    `json:"runAfter"`
+	Schedule    *string   `json:"schedule,omitempty"`
+	LastError   *string   `json:"lastError,omitempty"`
+	CreatedAt   string    `json:"createdAt"`
+	StartedAt   *string   `json:"startedAt,omitempty"`
+	FinishedAt  *string   `json:"finishedAt,omitempty"`
+}
+
+type MatchReview struct {
+	ID         uuid.UUID         `json:"id"`
+	Media      Media             `json:"media"`
+	Candidate  Media             `json:"candidate"`
+	Confidence float64           `json:"confidence"`
+	Reasons    []string          `json:"reasons"`
+	Status     MatchReviewStatus `json:"status"`
+	CreatedAt  string            `json:"createdAt"`
+	ResolvedAt *string           `json:"resolvedAt,omitempty"`
+}
+
+type MediaEnrichment struct {
+	Media  Media    `json:"media"`
+	Source *string  `json:"source,omitempty"`
+	Fields []string `json:"fields"`
+}
+
+type MediaImportInput struct {
+	Type        MediaType          `json:"type"`
+	Title       string             `json:"title"`
+	ReleaseDate *string            `json:"releaseDate,omitempty"`
+	ReleaseYear *int32             `json:"releaseYear,omitempty"`
+	Description *string            `json:"description,omitempty"`
+	CoverURL    *string            `json:"coverUrl,omitempty"`
+	ExternalIds []*ExternalIDInput `json:"externalIds,omitempty"`
+	Creators    []*CreatorInput    `json:"creators,omitempty"`
+	Tags        []*TagInput        `json:"tags,omitempty"`
+	Platforms   []*PlatformInput   `json:"platforms,omitempty"`
+	Runtime     *int32             `json:"runtime,omitempty"`
+	Budget      *int32             `json:"budget,omitempty"`
+	BoxOffice   *int32             `json:"boxOffice,omitempty"`
+	Seasons     *int32             `json:"seasons,omitempty"`
+	Episodes    *int32             `json:"episodes,omitempty"`
+	Status      *string            `json:"status,omitempty"`
+	Pages       *int32             `json:"pages,omitempty"`
+	Isbn        *string            `json:"isbn,omitempty"`
+	Publisher   *string            `json:"publisher,omitempty"`
+	Genre       []string           `json:"genre,omitempty"`
+	EsrbRating  *string            `json:"esrbRating,omitempty"`
+	Multiplayer *bool              `json:"multiplayer,omitempty"`
+	TrackCount  *int32             `json:"trackCount,omitempty"`
+	Duration    *int32             `json:"duration,omitempty"`
+	Label       *string            `json:"label,omitempty"`
+	URL         *string            `json:"url,omitempty"`
+	Site        *string            `json:"site,omitempty"`
+	Author      *string            `json:"author,omitempty"`
+	WordCount   *int32             `json:"wordCount,omitempty"`
+	ReadTime    *int32             `json:"readTime,omitempty"`
+}
+
+type MediaImportResult struct {
+	Index      int32        `json:"index"`
+	Status     ImportStatus `json:"status"`
+	Media      Media        `json:"media,omitempty"`
+	Confidence *float64     `json:"confidence,omitempty"`
+	Review     *MatchReview `json:"review,omitempty"`
+	Error      *string      `json:"error,omitempty"`
+}
+
+type MediaMergeResult struct {
+	Media           Media       `json:"media"`
+	MergedIds       []uuid.UUID `json:"mergedIds"`
+	RatingConflicts int32       `json:"ratingConflicts"`
+}
+
 type Movie struct {
 	ID            uuid.UUID   `json:"id"`
 	Title         string      `json:"title"`
@@ -369,6 +596,11 @@ type Platform struct {
 	MediaItems []Media   `json:"mediaItems"`
 }
 
+type PlatformInput struct {
+	Name    string  `json:"name"`
+	BaseURL *string `json:"baseUrl,omitempty"`
+}
+
 type Query struct {
 }
 
@@ -377,6 +609,7 @@ type Rating struct {
 	Media   Media   `json:"media"`
 	Score   float64 `json:"score"`
 	RatedAt string  `json:"ratedAt"`
+	Version int32   `json:"version"`
 }
 
 type Recommendation struct {
@@ -388,6 +621,69 @@ type Recommendation struct {
 	Score       *float64  `json:"score,omitempty"`
 }
 
+type Stream struct {
+	ID            uuid.UUID   `json:"id"`
+	Title         string      `json:"title"`
+	ReleaseDate   *string     `json:"releaseDate,omitempty"`
+	Description   *string     `json:"description,omitempty"`
+	CoverURL      *string     `json:"coverUrl,omitempty"`
+	Creators      []*Creator  `json:"creators"`
+	Platforms     []*Platform `json:"platforms"`
+	Tags          []*Tag      `json:"tags"`
+	Ratings       []*Rating   `json:"ratings"`
+	AverageRating *float64    `json:"averageRating,omitempty"`
+	URL           *string     `json:"url,omitempty"`
+	Duration      *int32      `json:"duration,omitempty"`
+}
+
+func (Stream) IsMedia()                     {}
+func (this Stream) GetID() uuid.UUID        { return this.ID }
+func (this Stream) GetTitle() string        { return this.Title }
+func (this Stream) GetReleaseDate() *string { return this.ReleaseDate }
+func (this Stream) GetDescription() *string { return this.Description }
+func (this Stream) GetCoverURL() *string    { return this.CoverURL }
+func (this Stream) GetCreators() []*Creator {
+	if this.Creators == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Creator, 0, len(this.Creators))
+	for _, concrete := range this.Creators {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Stream) GetPlatforms() []*Platform {
+	if this.Platforms == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Platform, 0, len(this.Platforms))
+	for _, concrete := range this.Platforms {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Stream) GetTags() []*Tag {
+	if this.Tags == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Tag, 0, len(this.Tags))
+	for _, concrete := range this.Tags {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Stream) GetRatings() []*Rating {
+	if this.Ratings == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Rating, 0, len(this.Ratings))
+	for _, concrete := range this.Ratings {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Stream) GetAverageRating() *float64 { return this.AverageRating }
+
 type TVShow struct {
 	ID            uuid.UUID   `json:"id"`
 	Title         string      `json:"title"`
@@ -458,20 +754,42 @@ type Tag struct {
 	Type string    `json:"type"`
 }
 
+type TagInput struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type UnmatchedRow struct {
+	File   string  `json:"file"`
+	Line   int32   `json:"line"`
+	Title  *string `json:"title,omitempty"`
+	Reason string  `json:"reason"`
+}
+
+type UpdateActivityInput struct {
+	StatusID   *int32   `json:"statusId,omitempty"`
+	Rating     *float64 `json:"rating,omitempty"`
+	Review     *string  `json:"review,omitempty"`
+	FinishedAt *string  `json:"finishedAt,omitempty"`
+}
+
 type UpdateUserInput struct {
 	Name  *string `json:"name,omitempty"`
 	Email *string `json:"email,omitempty"`
 }
 
 type User struct {
-	ID              uuid.UUID         `json:"id"`
-	Name            string            `json:"name"`
-	Email           string            `json:"email"`
-	AuthProvider    *string           `json:"authProvider,omitempty"`
-	Activities      []*UserActivity   `json:"activities"`
-	Ratings         []*Rating         `json:"ratings"`
-	Favorites       []Media           `json:"favorites"`
-	Recommendations []*Recommendation `json:"recommendations"`
+	ID              uuid.UUID                `json:"id"`
+	Name            string                   `json:"name"`
+	Email           string                   `json:"email"`
+	AuthProvider    *string                  `json:"authProvider,omitempty"`
+	Version         int32                    `json:"version"`
+	Activities      []*UserActivity          `json:"activities"`
+	Ratings         []*Rating                `json:"ratings"`
+	Favorites       []Media                  `json:"favorites"`
+	Recommendations []*Recommendation        `json:"recommendations"`
+	Connections     []*IntegrationConnection `json:"connections"`
+	TopAlbums       []*AlbumPlays            `json:"topAlbums"`
 }
 
 type UserActivity struct {
@@ -484,4 +802,556 @@ type UserActivity struct {
 	StartedAt      *string         `json:"startedAt,omitempty"`
 	FinishedAt     *string         `json:"finishedAt,omitempty"`
 	SourcePlatform *Platform       `json:"sourcePlatform,omitempty"`
+	Rewatch        *bool           `json:"rewatch,omitempty"`
+	Count          *int32          `json:"count,omitempty"`
+	Progress       *float64        `json:"progress,omitempty"`
+	ProgressUnit   *string         `json:"progressUnit,omitempty"`
+	Version        int32           `json:"version"`
+}
+
+type Video struct {
+	ID            uuid.UUID   `json:"id"`
+	Title         string      `json:"title"`
+	ReleaseDate   *string     `json:"releaseDate,omitempty"`
+	Description   *string     `json:"description,omitempty"`
+	CoverURL      *string     `json:"coverUrl,omitempty"`
+	Creators      []*Creator  `json:"creators"`
+	Platforms     []*Platform `json:"platforms"`
+	Tags          []*Tag      `json:"tags"`
+	Ratings       []*Rating   `json:"ratings"`
+	AverageRating *float64    `json:"averageRating,omitempty"`
+	URL           *string     `json:"url,omitempty"`
+	Duration      *int32      `json:"duration,omitempty"`
+}
+
+func (Video) IsMedia()                     {}
+func (this Video) GetID() uuid.UUID        { return this.ID }
+func (this Video) GetTitle() string        { return this.Title }
+func (this Video) GetReleaseDate() *string { return this.ReleaseDate }
+func (this Video) GetDescription() *string { return this.Description }
+func (this Video) GetCoverURL() *string    { return this.CoverURL }
+func (this Video) GetCreators() []*Creator {
+	if this.Creators == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Creator, 0, len(this.Creators))
+	for _, concrete := range this.Creators {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Video) GetPlatforms() []*Platform {
+	if this.Platforms == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Platform, 0, len(this.Platforms))
+	for _, concrete := range this.Platforms {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Video) GetTags() []*Tag {
+	if this.Tags == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Tag, 0, len(this.Tags))
+	for _, concrete := range this.Tags {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Video) GetRatings() []*Rating {
+	if this.Ratings == nil {
+		return nil
+	}
+	interfaceSlice := make([]*Rating, 0, len(this.Ratings))
+	for _, concrete := range this.Ratings {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+func (this Video) GetAverageRating() *float64 { return this.AverageRating }
+
+type ImportConflictPolicy string
+
+const (
+	ImportConflictPolicyUpdate ImportConflictPolicy = "UPDATE"
+	ImportConflictPolicySkip   ImportConflictPolicy = "SKIP"
+)
+
+var AllImportConflictPolicy = []ImportConflictPolicy{
+	ImportConflictPolicyUpdate,
+	ImportConflictPolicySkip,
+}
+
+func (e ImportConflictPolicy) IsValid() bool {
+	switch e {
+	case ImportConflictPolicyUpdate, ImportConflictPolicySkip:
+		return true
+	}
+	return false
+}
+
+func (e ImportConflictPolicy) String() string {
+	return string(e)
+}
+
+func (e *ImportConflictPolicy) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportConflictPolicy(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportConflictPolicy", str)
+	}
+	return nil
+}
+
+func (e ImportConflictPolicy) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImportConflictPolicy) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImportConflictPolicy) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ImportSource string
+
+const (
+	ImportSourceLetterboxd   ImportSource = "LETTERBOXD"
+	ImportSourceGoodreads    ImportSource = "GOODREADS"
+	ImportSourceImdb         ImportSource = "IMDB"
+	ImportSourceSteam        ImportSource = "STEAM"
+	ImportSourceSpotify      ImportSource = "SPOTIFY"
+	ImportSourceYoutube      ImportSource = "YOUTUBE"
+	ImportSourceYoutubeMusic ImportSource = "YOUTUBE_MUSIC"
+	ImportSourceAppleMusic   ImportSource = "APPLE_MUSIC"
+	ImportSourceInstapaper   ImportSource = "INSTAPAPER"
+	ImportSourceTwitch       ImportSource = "TWITCH"
+)
+
+var AllImportSource = []ImportSource{
+	ImportSourceLetterboxd,
+	ImportSourceGoodreads,
+	ImportSourceImdb,
+	ImportSourceSteam,
+	ImportSourceSpotify,
+	ImportSourceYoutube,
+	ImportSourceYoutubeMusic,
+	ImportSourceAppleMusic,
+	ImportSourceInstapaper,
+	ImportSourceTwitch,
+}
+
+func (e ImportSource) IsValid() bool {
+	switch e {
+	case ImportSourceLetterboxd, ImportSourceGoodreads, ImportSourceImdb, ImportSourceSteam, ImportSourceSpotify, ImportSourceYoutube, ImportSourceYoutubeMusic, ImportSourceAppleMusic, ImportSourceInstapaper, ImportSourceTwitch:
+		return true
+	}
+	return false
+}
+
+func (e ImportSource) String() string {
+	return string(e)
+}
+
+func (e *ImportSource) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportSource(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportSource", str)
+	}
+	return nil
+}
+
+func (e ImportSource) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImportSource) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImportSource) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ImportStatus string
+
+const (
+	ImportStatusCreated ImportStatus = "CREATED"
+	ImportStatusUpdated ImportStatus = "UPDATED"
+	ImportStatusSkipped ImportStatus = "SKIPPED"
+	ImportStatusError   ImportStatus = "ERROR"
+)
+
+var AllImportStatus = []ImportStatus{
+	ImportStatusCreated,
+	ImportStatusUpdated,
+	ImportStatusSkipped,
+	ImportStatusError,
+}
+
+func (e ImportStatus) IsValid() bool {
+	switch e {
+	case ImportStatusCreated, ImportStatusUpdated, ImportStatusSkipped, ImportStatusError:
+		return true
+	}
+	return false
+}
+
+func (e ImportStatus) String() string {
+	return string(e)
+}
+
+func (e *ImportStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportStatus", str)
+	}
+	return nil
+}
+
+func (e ImportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImportStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImportStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type IntegrationAuthKind string
+
+const (
+	IntegrationAuthKindOauth     IntegrationAuthKind = "OAUTH"
+	IntegrationAuthKindAccountID IntegrationAuthKind = "ACCOUNT_ID"
+)
+
+var AllIntegrationAuthKind = []IntegrationAuthKind{
+	IntegrationAuthKindOauth,
+	IntegrationAuthKindAccountID,
+}
+
+func (e IntegrationAuthKind) IsValid() bool {
+	switch e {
+	case IntegrationAuthKindOauth, IntegrationAuthKindAccountID:
+		return true
+	}
+	return false
+}
+
+func (e IntegrationAuthKind) String() string {
+	return string(e)
+}
+
+func (e *IntegrationAuthKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = IntegrationAuthKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid IntegrationAuthKind", str)
+	}
+	return nil
+}
+
+func (e IntegrationAuthKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *IntegrationAuthKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e IntegrationAuthKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type JobState string
+
+const (
+	JobStateQueued    JobState = "QUEUED"
+	JobStateRunning   JobState = "RUNNING"
+	JobStateSucceeded JobState = "SUCCEEDED"
+	JobStateFailed    JobState = "FAILED"
+)
+
+var AllJobState = []JobState{
+	JobStateQueued,
+	JobStateRunning,
+	JobStateSucceeded,
+	JobStateFailed,
+}
+
+func (e JobState) IsValid() bool {
+	switch e {
+	case JobStateQueued, JobStateRunning, JobStateSucceeded, JobStateFailed:
+		return true
+	}
+	return false
+}
+
+func (e JobState) String() string {
+	return string(e)
+}
+
+func (e *JobState) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = JobState(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid JobState", str)
+	}
+	return nil
+}
+
+func (e JobState) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *JobState) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e JobState) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MatchReviewStatus string
+
+const (
+	MatchReviewStatusPending   MatchReviewStatus = "PENDING"
+	MatchReviewStatusConfirmed MatchReviewStatus = "CONFIRMED"
+	MatchReviewStatusRejected  MatchReviewStatus = "REJECTED"
+)
+
+var AllMatchReviewStatus = []MatchReviewStatus{
+	MatchReviewStatusPending,
+	MatchReviewStatusConfirmed,
+	MatchReviewStatusRejected,
+}
+
+func (e MatchReviewStatus) IsValid() bool {
+	switch e {
+	case MatchReviewStatusPending, MatchReviewStatusConfirmed, MatchReviewStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e MatchReviewStatus) String() string {
+	return string(e)
+}
+
+func (e *MatchReviewStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MatchReviewStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MatchReviewStatus", str)
+	}
+	return nil
+}
+
+func (e MatchReviewStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MatchReviewStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MatchReviewStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MediaType string
+
+const (
+	MediaTypeMovie      MediaType = "MOVIE"
+	MediaTypeTvShow     MediaType = "TV_SHOW"
+	MediaTypeBook       MediaType = "BOOK"
+	MediaTypeGame       MediaType = "GAME"
+	MediaTypeMusicAlbum MediaType = "MUSIC_ALBUM"
+	MediaTypeVideo      MediaType = "VIDEO"
+	MediaTypeArticle    MediaType = "ARTICLE"
+	MediaTypeStream     MediaType = "STREAM"
+)
+
+var AllMediaType = []MediaType{
+	MediaTypeMovie,
+	MediaTypeTvShow,
+	MediaTypeBook,
+	MediaTypeGame,
+	MediaTypeMusicAlbum,
+	MediaTypeVideo,
+	MediaTypeArticle,
+	MediaTypeStream,
+}
+
+func (e MediaType) IsValid() bool {
+	switch e {
+	case MediaTypeMovie, MediaTypeTvShow, MediaTypeBook, MediaTypeGame, MediaTypeMusicAlbum, MediaTypeVideo, MediaTypeArticle, MediaTypeStream:
+		return true
+	}
+	return false
+}
+
+func (e MediaType) String() string {
+	return string(e)
+}
+
+func (e *MediaType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MediaType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MediaType", str)
+	}
+	return nil
+}
+
+func (e MediaType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MediaType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MediaType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RatingMergePolicy string
+
+const (
+	RatingMergePolicyLatest  RatingMergePolicy = "LATEST"
+	RatingMergePolicyHighest RatingMergePolicy = "HIGHEST"
+	RatingMergePolicyLowest  RatingMergePolicy = "LOWEST"
+	RatingMergePolicyAverage RatingMergePolicy = "AVERAGE"
+	RatingMergePolicyKeep    RatingMergePolicy = "KEEP"
+)
+
+var AllRatingMergePolicy = []RatingMergePolicy{
+	RatingMergePolicyLatest,
+	RatingMergePolicyHighest,
+	RatingMergePolicyLowest,
+	RatingMergePolicyAverage,
+	RatingMergePolicyKeep,
+}
+
+func (e RatingMergePolicy) IsValid() bool {
+	switch e {
+	case RatingMergePolicyLatest, RatingMergePolicyHighest, RatingMergePolicyLowest, RatingMergePolicyAverage, RatingMergePolicyKeep:
+		return true
+	}
+	return false
+}
+
+func (e RatingMergePolicy) String() string {
+	return string(e)
+}
+
+func (e *RatingMergePolicy) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RatingMergePolicy(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RatingMergePolicy", str)
+	}
+	return nil
+}
+
+func (e RatingMergePolicy) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RatingMergePolicy) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RatingMergePolicy) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
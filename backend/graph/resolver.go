@@ -1,7 +1,15 @@
 package graph
 
 import (
+	"context"
+	"log"
 	"nq/db"
+	"nq/enrichment"
+	"nq/graph/model"
+	"nq/integrations"
+	"nq/jobs"
+
+	"github.com/google/uuid"
 )
 
 // This file will not be regenerated automatically.
@@ -10,11 +18,33 @@ import (
 
 type Resolver struct {
 	Repo db.Repository
+	// Integrations holds the integration providers configured on this server
+	Integrations *integrations.Registry
+	// Enrichment holds the metadata enrichers configured on this server
+	Enrichment *enrichment.Pipeline
+	// Jobs queues background work, such as enriching created media
+	Jobs *jobs.Scheduler
 }
 
-// NewResolver creates a new resolver with database repository
-func NewResolver(repo db.Repository) *Resolver {
+// NewResolver creates a new resolver with database repository, integration
+// providers, metadata enrichers and the job scheduler
+func NewResolver(repo db.Repository, registry *integrations.Registry, pipeline *enrichment.Pipeline, scheduler *jobs.Scheduler) *Resolver {
 	return &Resolver{
-		Repo: repo,
+		Repo:         repo,
+		Integrations: registry,
+		Enrichment:   pipeline,
+		Jobs:         scheduler,
+	}
+}
+
+// enqueueEnrichment queues enrichment of media just created, when an
+// enricher supports its type. Failing to queue it doesn't fail the mutation,
+// since the media can still be enriched with enrichMedia.
+func (r *Resolver) enqueueEnrichment(ctx context.Context, id uuid.UUID, mediaType model.MediaType) {
+	if r.Jobs == nil || r.Enrichment == nil || !r.Enrichment.Supports(mediaType) {
+		return
+	}
+	if _, err := jobs.EnqueueEnrichMedia(ctx, r.Jobs, id); err != nil {
+		log.Printf("Warning: Failed to queue enrichment of media %s: %v", id, err)
 	}
 }
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// ratingRepository stores at most one rating, enough for RateMedia
+type ratingRepository struct {
+	db.Repository
+	rating  *model.Rating
+	created bool
+}
+
+func (r *ratingRepository) UpdateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64, expectedVersion *int32) (*model.Rating, error) {
+	if r.rating == nil {
+		return nil, db.NotFoundError("rating")
+	}
+	if expectedVersion != nil && *expectedVersion != r.rating.Version {
+		return nil, db.VersionConflictError("rating", r.rating)
+	}
+	r.rating.Score = score
+	r.rating.Version++
+	return r.rating, nil
+}
+
+func (r *ratingRepository) CreateRating(ctx context.Context, userID, mediaID uuid.UUID, score float64) (*model.Rating, error) {
+	r.rating = &model.Rating{Score: score, Version: 1}
+	r.created = true
+	return r.rating, nil
+}
+
+func (r *ratingRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	return &model.User{ID: id}, nil
+}
+
+func (r *ratingRepository) GetMediaByID(ctx context.Context, id uuid.UUID) (model.Media, error) {
+	return &model.Movie{ID: id}, nil
+}
+
+func TestRateMediaExpectedVersion(t *testing.T) {
+	version := func(v int32) *int32 { return &v }
+	tests := []struct {
+		name            string
+		existing        *model.Rating
+		expectedVersion *int32
+		conflict        bool
+	}{
+		{"new rating", nil, nil, false},
+		{"new rating expected not to exist", nil, version(0), false},
+		{"rating removed since it was read", nil, version(2), true},
+		{"current version", &model.Rating{Score: 5, Version: 2}, version(2), false},
+		{"stale version", &model.Rating{Score: 5, Version: 3}, version(2), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &ratingRepository{rating: tt.existing}
+			mutation := (&Resolver{Repo: repo}).Mutation()
+
+			rating, err := mutation.RateMedia(context.Background(), uuid.New(), uuid.New(), 8, tt.expectedVersion)
+			if tt.conflict {
+				if !errors.Is(err, db.ErrConflict) || repo.created {
+					t.Errorf("got %v with a rating created %v, want a conflict", err, repo.created)
+				}
+				return
+			}
+			if err != nil || rating.Score != 8 || rating.User == nil || rating.Media == nil {
+				t.Errorf("got %+v, %v", rating, err)
+			}
+		})
+	}
+}
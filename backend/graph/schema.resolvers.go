@@ -6,20 +6,30 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"nq/db"
 	"nq/graph/model"
+	"nq/integrations"
+	"nq/jobs"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/google/uuid"
 )
 
+// Editions is the resolver for the editions field.
+func (r *bookResolver) Editions(ctx context.Context, obj *model.Book) ([]*model.Edition, error) {
+	return r.Resolver.Repo.GetEditions(ctx, obj.ID)
+}
+
 // CreateUser is the resolver for the createUser field.
 func (r *mutationResolver) CreateUser(ctx context.Context, input model.CreateUserInput) (*model.User, error) {
 	return r.Resolver.Repo.CreateUser(ctx, input)
 }
 
 // UpdateUser is the resolver for the updateUser field.
-func (r *mutationResolver) UpdateUser(ctx context.Context, id uuid.UUID, input model.UpdateUserInput) (*model.User, error) {
-	return r.Resolver.Repo.UpdateUser(ctx, id, input)
+func (r *mutationResolver) UpdateUser(ctx context.Context, id uuid.UUID, input model.UpdateUserInput, expectedVersion *int32) (*model.User, error) {
+	return r.Resolver.Repo.UpdateUser(ctx, id, input, expectedVersion)
 }
 
 // DeleteUser is the resolver for the deleteUser field.
@@ -30,17 +40,37 @@ func (r *mutationResolver) DeleteUser(ctx context.Context, id uuid.UUID) (bool,
 
 // CreateMovie is the resolver for the createMovie field.
 func (r *mutationResolver) CreateMovie(ctx context.Context, input model.CreateMovieInput) (*model.Movie, error) {
-	return r.Resolver.Repo.CreateMovie(ctx, input)
+	movie, err := r.Resolver.Repo.CreateMovie(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	r.Resolver.enqueueEnrichment(ctx, movie.ID, model.MediaTypeMovie)
+	return movie, nil
 }
 
 // CreateTVShow is the resolver for the createTVShow field.
 func (r *mutationResolver) CreateTVShow(ctx context.Context, input model.CreateTVShowInput) (*model.TVShow, error) {
-	return r.Resolver.Repo.CreateTVShow(ctx, input)
+	show, err := r.Resolver.Repo.CreateTVShow(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	r.Resolver.enqueueEnrichment(ctx, show.ID, model.MediaTypeTvShow)
+	return show, nil
 }
 
 // CreateBook is the resolver for the createBook field.
 func (r *mutationResolver) CreateBook(ctx context.Context, input model.CreateBookInput) (*model.Book, error) {
-	panic(fmt.Errorf("not implemented: CreateBook - createBook"))
+	book, err := r.Resolver.Repo.CreateBook(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	r.Resolver.enqueueEnrichment(ctx, book.ID, model.MediaTypeBook)
+	return book, nil
+}
+
+// AddEdition is the resolver for the addEdition field.
+func (r *mutationResolver) AddEdition(ctx context.Context, input model.AddEditionInput) (*model.Edition, error) {
+	return r.Resolver.Repo.AddEdition(ctx, input)
 }
 
 // CreateGame is the resolver for the createGame field.
@@ -54,8 +84,31 @@ func (r *mutationResolver) CreateMusicAlbum(ctx context.Context, input model.Cre
 }
 
 // RateMedia is the resolver for the rateMedia field.
-func (r *mutationResolver) RateMedia(ctx context.Context, userID uuid.UUID, mediaID uuid.UUID, score float64) (*model.Rating, error) {
-	panic(fmt.Errorf("not implemented: RateMedia - rateMedia"))
+func (r *mutationResolver) RateMedia(ctx context.Context, userID uuid.UUID, mediaID uuid.UUID, score float64, expectedVersion *int32) (*model.Rating, error) {
+	rating, err := r.Resolver.Repo.UpdateRating(ctx, userID, mediaID, score, expectedVersion)
+	if errors.Is(err, db.ErrNotFound) {
+		// The user hasn't rated the media yet, so a client expecting a
+		// version has a stale view of a rating that was since removed
+		if expectedVersion != nil && *expectedVersion != 0 {
+			return nil, db.VersionConflictError("rating", nil)
+		}
+		rating, err = r.Resolver.Repo.CreateRating(ctx, userID, mediaID, score)
+		if errors.Is(err, db.ErrAlreadyExists) {
+			// A concurrent request rated it first
+			rating, err = r.Resolver.Repo.UpdateRating(ctx, userID, mediaID, score, expectedVersion)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rating.User, err = r.Resolver.Repo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if rating.Media, err = r.Resolver.Repo.GetMediaByID(ctx, mediaID); err != nil {
+		return nil, err
+	}
+	return rating, nil
 }
 
 // AddToFavorites is the resolver for the addToFavorites field.
@@ -68,6 +121,88 @@ func (r *mutationResolver) CreateActivity(ctx context.Context, input model.Creat
 	panic(fmt.Errorf("not implemented: CreateActivity - createActivity"))
 }
 
+// UpdateActivity is the resolver for the updateActivity field.
+func (r *mutationResolver) UpdateActivity(ctx context.Context, id uuid.UUID, input model.UpdateActivityInput, expectedVersion *int32) (*model.UserActivity, error) {
+	return r.Resolver.Repo.UpdateActivity(ctx, id, input, expectedVersion)
+}
+
+// ImportMedia is the resolver for the importMedia field.
+func (r *mutationResolver) ImportMedia(ctx context.Context, items []*model.MediaImportInput, onExisting *model.ImportConflictPolicy, batchSize *int32) ([]*model.MediaImportResult, error) {
+	opts := db.ImportOptions{}
+	if onExisting != nil {
+		opts.OnExisting = *onExisting
+	}
+	if batchSize != nil {
+		opts.BatchSize = int(*batchSize)
+	}
+	return r.Resolver.Repo.ImportMedia(ctx, items, opts)
+}
+
+// ImportLibrary is the resolver for the importLibrary field.
+func (r *mutationResolver) ImportLibrary(ctx context.Context, userID uuid.UUID, source model.ImportSource, file graphql.Upload) (*model.ImportReport, error) {
+	return integrations.ImportExport(ctx, r.Resolver.Repo, userID, source, file.File)
+}
+
+// ConnectIntegration is the resolver for the connectIntegration field.
+func (r *mutationResolver) ConnectIntegration(ctx context.Context, userID uuid.UUID, provider string, account *string, apiKey *string) (*model.IntegrationConnectResult, error) {
+	var input integrations.ConnectInput
+	if account != nil {
+		input.Account = *account
+	}
+	if apiKey != nil {
+		input.APIKey = *apiKey
+	}
+	conn, authorizeURL, err := r.Resolver.Integrations.Connect(ctx, r.Resolver.Repo, userID, provider, input)
+	if err != nil {
+		return nil, err
+	}
+	if conn == nil {
+		return &model.IntegrationConnectResult{AuthorizeURL: &authorizeURL}, nil
+	}
+	return &model.IntegrationConnectResult{Connection: integrations.ConnectionModel(conn)}, nil
+}
+
+// CompleteIntegrationConnection is the resolver for the completeIntegrationConnection field.
+func (r *mutationResolver) CompleteIntegrationConnection(ctx context.Context, provider string, state string, code string) (*model.IntegrationConnection, error) {
+	conn, err := r.Resolver.Integrations.Complete(ctx, r.Resolver.Repo, provider, state, code)
+	if err != nil {
+		return nil, err
+	}
+	return integrations.ConnectionModel(conn), nil
+}
+
+// SyncIntegration is the resolver for the syncIntegration field.
+func (r *mutationResolver) SyncIntegration(ctx context.Context, userID uuid.UUID, provider string, full *bool) (*model.ImportReport, error) {
+	return r.Resolver.Integrations.Sync(ctx, r.Resolver.Repo, userID, provider, full != nil && *full)
+}
+
+// DisconnectIntegration is the resolver for the disconnectIntegration field.
+func (r *mutationResolver) DisconnectIntegration(ctx context.Context, userID uuid.UUID, provider string) (bool, error) {
+	if err := r.Resolver.Integrations.Disconnect(ctx, r.Resolver.Repo, userID, provider); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResolveMatchReview is the resolver for the resolveMatchReview field.
+func (r *mutationResolver) ResolveMatchReview(ctx context.Context, id uuid.UUID, sameWork bool) (*model.MatchReview, error) {
+	return r.Resolver.Repo.ResolveMatchReview(ctx, id, sameWork)
+}
+
+// EnrichMedia is the resolver for the enrichMedia field.
+func (r *mutationResolver) EnrichMedia(ctx context.Context, id uuid.UUID) (*model.MediaEnrichment, error) {
+	return r.Resolver.Enrichment.Enrich(ctx, r.Resolver.Repo, id)
+}
+
+// MergeMedia is the resolver for the mergeMedia field.
+func (r *mutationResolver) MergeMedia(ctx context.Context, keepID uuid.UUID, mergeIds []uuid.UUID, ratingPolicy *model.RatingMergePolicy) (*model.MediaMergeResult, error) {
+	policy := model.RatingMergePolicyLatest
+	if ratingPolicy != nil {
+		policy = *ratingPolicy
+	}
+	return r.Resolver.Repo.MergeMedia(ctx, keepID, mergeIds, policy)
+}
+
 // User is the resolver for the user field.
 func (r *queryResolver) User(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	return r.Resolver.Repo.GetUserByID(ctx, id)
@@ -100,7 +235,7 @@ func (r *queryResolver) TvShows(ctx context.Context) ([]*model.TVShow, error) {
 
 // Books is the resolver for the books field.
 func (r *queryResolver) Books(ctx context.Context) ([]*model.Book, error) {
-	panic(fmt.Errorf("not implemented: Books - books"))
+	return r.Resolver.Repo.GetAllBooks(ctx)
 }
 
 // Games is the resolver for the games field.
@@ -113,14 +248,135 @@ func (r *queryResolver) MusicAlbums(ctx context.Context) ([]*model.MusicAlbum, e
 	panic(fmt.Errorf("not implemented: MusicAlbums - musicAlbums"))
 }
 
+// Videos is the resolver for the videos field.
+func (r *queryResolver) Videos(ctx context.Context) ([]*model.Video, error) {
+	return r.Resolver.Repo.GetAllVideos(ctx)
+}
+
+// Articles is the resolver for the articles field.
+func (r *queryResolver) Articles(ctx context.Context) ([]*model.Article, error) {
+	return r.Resolver.Repo.GetAllArticles(ctx)
+}
+
+// Streams is the resolver for the streams field.
+func (r *queryResolver) Streams(ctx context.Context) ([]*model.Stream, error) {
+	return r.Resolver.Repo.GetAllStreams(ctx)
+}
+
+// MediaByExternalID is the resolver for the mediaByExternalId field.
+func (r *queryResolver) MediaByExternalID(ctx context.Context, source string, id string) (model.Media, error) {
+	return r.Resolver.Repo.GetMediaByExternalID(ctx, source, id)
+}
+
+// BookByIsbn is the resolver for the bookByIsbn field.
+func (r *queryResolver) BookByIsbn(ctx context.Context, isbn string) (*model.Book, error) {
+	return r.Resolver.Repo.GetBookByISBN(ctx, isbn)
+}
+
+// MediaFieldSources is the resolver for the mediaFieldSources field.
+func (r *queryResolver) MediaFieldSources(ctx context.Context, id uuid.UUID) ([]*model.FieldSource, error) {
+	return r.Resolver.Repo.GetFieldSources(ctx, id)
+}
+
+// MatchReviews is the resolver for the matchReviews field.
+func (r *queryResolver) MatchReviews(ctx context.Context, status *model.MatchReviewStatus, limit *int32) ([]*model.MatchReview, error) {
+	n := 50
+	if limit != nil {
+		n = int(*limit)
+	}
+	return r.Resolver.Repo.GetMatchReviews(ctx, status, n)
+}
+
+// IntegrationProviders is the resolver for the integrationProviders field.
+func (r *queryResolver) IntegrationProviders(ctx context.Context) ([]*model.IntegrationProvider, error) {
+	providers := r.Resolver.Integrations.Providers()
+	result := make([]*model.IntegrationProvider, len(providers))
+	for i, provider := range providers {
+		result[i] = &model.IntegrationProvider{Name: provider.Name(), AuthKind: provider.AuthKind()}
+	}
+	return result, nil
+}
+
+// Job is the resolver for the job field.
+func (r *queryResolver) Job(ctx context.Context, id uuid.UUID) (*model.Job, error) {
+	job, err := r.Resolver.Repo.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return jobs.JobModel(job), nil
+}
+
+// Jobs is the resolver for the jobs field.
+func (r *queryResolver) Jobs(ctx context.Context, state *model.JobState, kind *string, limit *int32) ([]*model.Job, error) {
+	filter := db.JobFilter{State: state, Limit: 50}
+	if kind != nil {
+		filter.Kind = *kind
+	}
+	if limit != nil {
+		filter.Limit = int(*limit)
+	}
+
+	stored, err := r.Resolver.Repo.GetJobs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Job, len(stored))
+	for i, job := range stored {
+		result[i] = jobs.JobModel(job)
+	}
+	return result, nil
+}
+
+// Recommendations is the resolver for the recommendations field.
+func (r *userResolver) Recommendations(ctx context.Context, obj *model.User) ([]*model.Recommendation, error) {
+	recommendations, err := r.Resolver.Repo.GetRecommendations(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, recommendation := range recommendations {
+		recommendation.User = obj
+	}
+	return recommendations, nil
+}
+
+// Connections is the resolver for the connections field.
+func (r *userResolver) Connections(ctx context.Context, obj *model.User) ([]*model.IntegrationConnection, error) {
+	connections, err := r.Resolver.Repo.GetConnections(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.IntegrationConnection, len(connections))
+	for i, conn := range connections {
+		result[i] = integrations.ConnectionModel(conn)
+	}
+	return result, nil
+}
+
+// TopAlbums is the resolver for the topAlbums field.
+func (r *userResolver) TopAlbums(ctx context.Context, obj *model.User, limit *int32) ([]*model.AlbumPlays, error) {
+	count := 10
+	if limit != nil {
+		count = int(*limit)
+	}
+	return r.Resolver.Repo.GetTopAlbums(ctx, obj.ID, count)
+}
+
+// Book returns BookResolver implementation.
+func (r *Resolver) Book() BookResolver { return &bookResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type bookResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
 
 // !!! WARNING !!!
 // The code below was going to be deleted when updating resolvers. It has been copied here so you have
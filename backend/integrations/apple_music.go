@@ -1 +1,227 @@
 package integrations
+
+import (
+	"bytes"
+	"nq/db"
+	"nq/graph/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appleMusicMaxRating is the top of the library's rating scale; one star is 20
+const appleMusicMaxRating = 100.0
+
+// appleMusicSource prefixes the import keys of Apple Music albums
+const appleMusicSource = "applemusic"
+
+// appleMusicFile is the name of the library file in unmatched rows
+const appleMusicFile = "Library.xml"
+
+// appleMusicNonMusic are the track flags of library items that aren't music
+var appleMusicNonMusic = []string{"Podcast", "Movie", "TV Show", "Music Video", "Has Video", "iTunesU", "Audiobook"}
+
+// appleMusicAlbum collects the tracks of one album
+type appleMusicAlbum struct {
+	title  string
+	artist string
+	tracks []*plistDict
+}
+
+// ParseAppleMusic reads a Library.xml exported from Music or iTunes into one
+// library entry per album. Tracks are grouped by album and album artist, and
+// each album gets its track count and total duration from its tracks, an
+// activity with their total play count, started when the first track was
+// added, and the user's star rating.
+func ParseAppleMusic(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	root, err := readPlist(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("can't read %s: %v", appleMusicFile, err)
+	}
+	library, ok := root.(*plistDict)
+	if !ok || library.dict("Tracks") == nil {
+		return nil, nil, db.ValidationFailedError("file has no Tracks; is it a Library.xml exported from Music or iTunes?")
+	}
+
+	// Tracks are keyed by track ID; sort them so albums come out in file order
+	var tracks []*plistDict
+	for _, value := range library.dict("Tracks").values {
+		if track, ok := value.(*plistDict); ok {
+			tracks = append(tracks, track)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].line < tracks[j].line })
+
+	var order []string
+	albums := make(map[string]*appleMusicAlbum)
+	var unmatched []*model.UnmatchedRow
+	for _, track := range tracks {
+		if !isAppleMusicTrack(track) {
+			continue
+		}
+
+		title := track.string("Album")
+		artist := appleMusicAlbumArtist(track)
+		if title == "" || artist == "" {
+			reason := "track has no album"
+			if title != "" {
+				reason = "track has no artist"
+			}
+			unmatched = append(unmatched, &model.UnmatchedRow{
+				File:   appleMusicFile,
+				Line:   int32(track.line),
+				Title:  optionalString(track.string("Name")),
+				Reason: reason,
+			})
+			continue
+		}
+
+		key := strings.ToLower(title) + ":" + strings.ToLower(artist)
+		album, ok := albums[key]
+		if !ok {
+			album = &appleMusicAlbum{title: title, artist: artist}
+			albums[key] = album
+			order = append(order, key)
+		}
+		album.tracks = append(album.tracks, track)
+	}
+
+	entries := make([]*LibraryEntry, 0, len(order))
+	for _, key := range order {
+		entries = append(entries, appleMusicAlbumEntry(key, albums[key]))
+	}
+
+	return entries, unmatched, nil
+}
+
+// isAppleMusicTrack reports whether a library item is a song rather than a
+// podcast, video or audiobook
+func isAppleMusicTrack(track *plistDict) bool {
+	for _, flag := range appleMusicNonMusic {
+		if track.bool(flag) {
+			return false
+		}
+	}
+	return !strings.Contains(strings.ToLower(track.string("Kind")), "audiobook")
+}
+
+// appleMusicAlbumArtist returns the artist an album is filed under
+func appleMusicAlbumArtist(track *plistDict) string {
+	if artist := track.string("Album Artist"); artist != "" {
+		return artist
+	}
+	if track.bool("Compilation") {
+		return "Various Artists"
+	}
+	return track.string("Artist")
+}
+
+// appleMusicAlbumEntry builds the library entry for an album from its tracks
+func appleMusicAlbumEntry(key string, album *appleMusicAlbum) *LibraryEntry {
+	siteURL := "https://music.apple.com"
+	media := &model.MediaImportInput{
+		Type:      model.MediaTypeMusicAlbum,
+		Title:     album.title,
+		Creators:  []*model.CreatorInput{{Name: album.artist, Role: "Artist"}},
+		Platforms: []*model.PlatformInput{{Name: "Apple Music", BaseURL: &siteURL}},
+	}
+
+	// The same song can be in the library twice, e.g. as a file and from the
+	// catalog, so count tracks by disc, number and name
+	seen := make(map[string]bool)
+	genres := make(map[string]bool)
+	var trackCount, duration int32
+	var plays int64
+	played := 0
+	var addedAt, lastPlayedAt time.Time
+	var trackRatings []float64
+	var albumRating *float64
+	for _, track := range album.tracks {
+		id := strconv.FormatInt(track.int("Disc Number"), 10) + ":" + strconv.FormatInt(track.int("Track Number"), 10) + ":" + strings.ToLower(track.string("Name"))
+		if !seen[id] {
+			seen[id] = true
+			trackCount++
+			duration += int32(track.int("Total Time") / 1000)
+		}
+
+		if year := int32(track.int("Year")); year >= 1000 && year <= 9999 && media.ReleaseYear == nil {
+			media.ReleaseYear = &year
+		}
+		if genre := track.string("Genre"); genre != "" && !genres[genre] {
+			genres[genre] = true
+			media.Tags = append(media.Tags, &model.TagInput{Name: genre, Type: "genre"})
+		}
+
+		if count := track.int("Play Count"); count > 0 {
+			plays += count
+			played++
+		}
+		if at := track.date("Date Added"); !at.IsZero() && (addedAt.IsZero() || at.Before(addedAt)) {
+			addedAt = at
+		}
+		if at := track.date("Play Date UTC"); at.After(lastPlayedAt) {
+			lastPlayedAt = at
+		}
+
+		// Computed ratings are derived by the app from the album or its
+		// tracks, so only the user's own ratings count
+		if rating := track.int("Album Rating"); rating > 0 && !track.bool("Album Rating Computed") && albumRating == nil {
+			score := db.ScaleScore(float64(rating), appleMusicMaxRating)
+			albumRating = &score
+		}
+		if rating := track.int("Rating"); rating > 0 && !track.bool("Rating Computed") {
+			trackRatings = append(trackRatings, float64(rating))
+		}
+	}
+	media.TrackCount = &trackCount
+	if duration > 0 {
+		media.Duration = &duration
+	}
+
+	activity := &db.ActivityImport{
+		ImportKey:  appleMusicSource + ":album:" + key,
+		StatusID:   db.StatusPlanned,
+		KeepStatus: true,
+	}
+	switch {
+	case played == len(album.tracks):
+		activity.StatusID = db.StatusCompleted
+		if !lastPlayedAt.IsZero() {
+			finishedAt := lastPlayedAt.UTC().Format(time.RFC3339)
+			activity.FinishedAt = &finishedAt
+		}
+	case played > 0:
+		activity.StatusID = db.StatusInProgress
+	}
+	if plays > 0 {
+		count := int32(plays)
+		activity.Count = &count
+	}
+	if !addedAt.IsZero() {
+		startedAt := addedAt.UTC().Format(time.RFC3339)
+		activity.StartedAt = &startedAt
+	}
+
+	entry := &LibraryEntry{
+		File:     appleMusicFile,
+		Line:     album.tracks[0].line,
+		Media:    media,
+		Activity: activity,
+	}
+
+	// Without an album rating, the album gets the average of its rated tracks
+	if albumRating == nil && len(trackRatings) > 0 {
+		total := 0.0
+		for _, rating := range trackRatings {
+			total += rating
+		}
+		score := db.ScaleScore(total/float64(len(trackRatings)), appleMusicMaxRating)
+		albumRating = &score
+	}
+	if albumRating != nil {
+		entry.Rating = &db.RatingImport{Score: *albumRating}
+	}
+
+	return entry
+}
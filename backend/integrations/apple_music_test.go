@@ -0,0 +1,166 @@
+package integrations
+
+import (
+	"errors"
+	"fmt"
+	"nq/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+// appleMusicLibrary wraps track dictionaries in a Library.xml
+func appleMusicLibrary(tracks ...string) string {
+	var body strings.Builder
+	for i, track := range tracks {
+		fmt.Fprintf(&body, "\t\t<key>%d</key>\n\t\t<dict>\n\t\t\t<key>Track ID</key><integer>%d</integer>\n%s\t\t</dict>\n", 100+i, 100+i, track)
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple Computer//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Major Version</key><integer>1</integer>
+	<key>Tracks</key>
+	<dict>
+` + body.String() + `	</dict>
+	<key>Playlists</key>
+	<array/>
+</dict>
+</plist>
+`
+}
+
+// appleMusicTrack writes the keys of a track; values are typed by their Go
+// type
+func appleMusicTrack(keys ...any) string {
+	var track strings.Builder
+	for i := 0; i < len(keys); i += 2 {
+		fmt.Fprintf(&track, "\t\t\t<key>%s</key>", keys[i])
+		switch value := keys[i+1].(type) {
+		case int:
+			fmt.Fprintf(&track, "<integer>%d</integer>\n", value)
+		case bool:
+			fmt.Fprintf(&track, "<%t/>\n", value)
+		case time.Time:
+			fmt.Fprintf(&track, "<date>%s</date>\n", value.Format(time.RFC3339))
+		default:
+			fmt.Fprintf(&track, "<string>%s</string>\n", value)
+		}
+	}
+	return track.String()
+}
+
+func TestParseAppleMusic(t *testing.T) {
+	day := func(month, day int) time.Time { return time.Date(2024, time.Month(month), day, 10, 0, 0, 0, time.UTC) }
+	library := appleMusicLibrary(
+		appleMusicTrack("Name", "So What", "Artist", "Miles Davis", "Album", "Kind of Blue", "Track Number", 1, "Total Time", 562000,
+			"Year", 1959, "Genre", "Jazz", "Play Count", 3, "Date Added", day(1, 2), "Play Date UTC", day(3, 1), "Rating", 80),
+		appleMusicTrack("Name", "Come Together", "Artist", "The Beatles", "Album Artist", "The Beatles", "Album", "Abbey Road",
+			"Track Number", 1, "Total Time", 259000, "Play Count", 2, "Album Rating", 100),
+		appleMusicTrack("Name", "Freddie Freeloader", "Artist", "Miles Davis", "Album", "Kind of Blue", "Track Number", 2, "Total Time", 586000,
+			"Genre", "Jazz", "Play Count", 1, "Date Added", day(1, 1), "Play Date UTC", day(4, 1), "Rating", 60, "Album Rating", 60, "Album Rating Computed", true),
+		// The same song again from the catalog, never played
+		appleMusicTrack("Name", "Come Together", "Artist", "The Beatles", "Album Artist", "The Beatles", "Album", "abbey road",
+			"Track Number", 1, "Total Time", 259000),
+		appleMusicTrack("Name", "Hit", "Artist", "Someone", "Album", "Now 1", "Compilation", true),
+		appleMusicTrack("Name", "Episode 1", "Artist", "A Podcast", "Album", "A Podcast", "Podcast", true),
+		appleMusicTrack("Name", "Voice Memo", "Artist", "Me"),
+		appleMusicTrack("Name", "Untitled", "Album", "Demos"),
+	)
+
+	entries, unmatched, err := ParseAppleMusic([]byte(library))
+	if err != nil {
+		t.Fatalf("ParseAppleMusic: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d albums, want 3", len(entries))
+	}
+
+	blue := entries[0]
+	if blue.Media.Title != "Kind of Blue" || blue.Media.Creators[0].Name != "Miles Davis" || *blue.Media.TrackCount != 2 ||
+		*blue.Media.Duration != 562+586 || *blue.Media.ReleaseYear != 1959 || len(blue.Media.Tags) != 1 {
+		t.Errorf("got %+v", blue.Media)
+	}
+	activity := blue.Activity
+	if activity.StatusID != db.StatusCompleted || *activity.Count != 4 ||
+		*activity.StartedAt != "2024-01-01T10:00:00Z" || *activity.FinishedAt != "2024-04-01T10:00:00Z" {
+		t.Errorf("got activity %+v, want every track played, from the first added to the last played", activity)
+	}
+	// The computed album rating is ignored for the tracks' average
+	if blue.Rating == nil || blue.Rating.Score != 7 {
+		t.Errorf("got rating %+v, want the average of 4 and 3 stars", blue.Rating)
+	}
+
+	abbey := entries[1]
+	if *abbey.Media.TrackCount != 1 || *abbey.Media.Duration != 259 || abbey.Activity.StatusID != db.StatusInProgress || *abbey.Activity.Count != 2 {
+		t.Errorf("got %+v and %+v, want the duplicate counted once and the album in progress", abbey.Media, abbey.Activity)
+	}
+	if abbey.Rating == nil || abbey.Rating.Score != 10 {
+		t.Errorf("got rating %+v, want the album rating", abbey.Rating)
+	}
+
+	compilation := entries[2]
+	if compilation.Media.Creators[0].Name != "Various Artists" || compilation.Activity.StatusID != db.StatusPlanned ||
+		compilation.Activity.Count != nil || compilation.Media.Duration != nil || compilation.Rating != nil {
+		t.Errorf("got %+v and %+v, want an unplayed compilation", compilation.Media, compilation.Activity)
+	}
+
+	if len(unmatched) != 2 || unmatched[0].Reason != "track has no album" || unmatched[1].Reason != "track has no artist" || *unmatched[1].Title != "Untitled" {
+		t.Errorf("got unmatched %+v", unmatched)
+	}
+	if int(unmatched[0].Line) <= blue.Line {
+		t.Errorf("got line %d for a later track than one on line %d", unmatched[0].Line, blue.Line)
+	}
+}
+
+func TestParseAppleMusicMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not XML", "Name,Artist\nSo What,Miles Davis\n"},
+		{"other XML", `<?xml version="1.0"?><rss><channel/></rss>`},
+		{"no tracks", `<plist version="1.0"><dict><key>Major Version</key><integer>1</integer></dict></plist>`},
+		{"truncated", appleMusicLibrary(appleMusicTrack("Name", "So What"))[:200]},
+		{"bad integer", appleMusicLibrary(appleMusicTrack("Name", "So What", "Album", "Kind of Blue") + "<key>Play Count</key><integer>many</integer>\n")},
+		{"value without a key", `<plist version="1.0"><dict><string>orphan</string></dict></plist>`},
+	}
+	for _, tt := range tests {
+		if _, _, err := ParseAppleMusic([]byte(tt.data)); !errors.Is(err, db.ErrValidation) {
+			t.Errorf("%s: got %v, want a validation error", tt.name, err)
+		}
+	}
+}
+
+func TestReadPlistValues(t *testing.T) {
+	root, err := readPlist(strings.NewReader(`<plist version="1.0"><dict>
+		<key>string</key><string> text </string>
+		<key>integer</key><integer>-42</integer>
+		<key>real</key><real>1.5</real>
+		<key>yes</key><true/>
+		<key>date</key><date>2024-01-02T03:04:05Z</date>
+		<key>data</key><data>
+			aGVs
+			bG8=
+		</data>
+		<key>array</key><array><integer>1</integer><string>two</string><dict/></array>
+		<key>nested</key><dict><key>inner</key><string>value</string></dict>
+	</dict></plist>`))
+	if err != nil {
+		t.Fatalf("readPlist: %v", err)
+	}
+
+	dict := root.(*plistDict)
+	if dict.string("string") != "text" || dict.int("integer") != -42 || dict.values["real"] != 1.5 || !dict.bool("yes") || dict.bool("missing") {
+		t.Errorf("got %v", dict.values)
+	}
+	if !dict.date("date").Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || string(dict.values["data"].([]byte)) != "hello" {
+		t.Errorf("got date %v and data %v", dict.values["date"], dict.values["data"])
+	}
+	if array := dict.values["array"].([]any); len(array) != 3 || array[1] != "two" {
+		t.Errorf("got array %v", array)
+	}
+	if dict.dict("nested").string("inner") != "value" || dict.dict("string") != nil || dict.int("string") != 0 {
+		t.Errorf("got nested %v", dict.values["nested"])
+	}
+}
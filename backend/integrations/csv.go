@@ -0,0 +1,92 @@
+package integrations
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// csvRow is a CSV record with its columns looked up by header name
+type csvRow struct {
+	line    int
+	columns map[string]int
+	fields  []string
+}
+
+// get returns the trimmed value of a column, or "" if the row doesn't have it
+func (r csvRow) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+// has reports whether the file has the column
+func (r csvRow) has(column string) bool {
+	_, ok := r.columns[column]
+	return ok
+}
+
+// readCSV reads a CSV file with a header row. Each row records the line it
+// starts on, since quoted fields such as reviews can span several lines.
+func readCSV(r io.Reader) ([]csvRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Excel-saved exports start with a byte order mark
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+
+	var rows []csvRow
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, csvRow{line: line, columns: columns, fields: fields})
+	}
+
+	return rows, nil
+}
+
+// optionalString returns nil for an empty string
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// dateLayouts are the date formats found in export files
+var dateLayouts = []string{time.DateOnly, "2006/01/02"}
+
+// optionalDate turns a YYYY-MM-DD or YYYY/MM/DD date into a midnight UTC
+// date-time, or nil if the value isn't a date
+func optionalDate(value string) *string {
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			formatted := date.Format(time.RFC3339)
+			return &formatted
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package integrations
+
+import (
+	"bytes"
+	"nq/db"
+	"nq/graph/model"
+	"nq/isbn"
+	"strconv"
+	"strings"
+)
+
+// goodreadsMaxRating is the top of Goodreads' five-star rating scale
+const goodreadsMaxRating = 5.0
+
+// goodreadsSource is the external ID source for Goodreads book IDs
+const goodreadsSource = "goodreads"
+
+// goodreadsFile is the name of the CSV in unmatched rows
+const goodreadsFile = "goodreads_library_export.csv"
+
+// goodreadsShelfStatuses maps exclusive shelves onto activity statuses. The
+// first three are Goodreads' own; the rest are common custom shelves.
+var goodreadsShelfStatuses = map[string]int32{
+	"read":              db.StatusCompleted,
+	"currently-reading": db.StatusInProgress,
+	"to-read":           db.StatusPlanned,
+	"did-not-finish":    db.StatusDropped,
+	"dnf":               db.StatusDropped,
+	"abandoned":         db.StatusDropped,
+	"on-hold":           db.StatusOnHold,
+	"paused":            db.StatusOnHold,
+}
+
+// goodreadsDefaultShelves are the shelves every Goodreads user has. They only
+// set the status; any other shelf is also kept as a tag.
+var goodreadsDefaultShelves = map[string]bool{
+	"read":              true,
+	"currently-reading": true,
+	"to-read":           true,
+}
+
+// goodreadsReviewBreaks turns the HTML line breaks in reviews into newlines
+var goodreadsReviewBreaks = strings.NewReplacer("<br/>", "\n", "<br />", "\n", "<br>", "\n")
+
+// ParseGoodreads reads a Goodreads library export CSV into library entries.
+// Every row becomes a book with its authors and an activity; rated books also
+// get a rating.
+func ParseGoodreads(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	rows, err := readCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("can't read Goodreads export: %v", err)
+	}
+	if len(rows) > 0 && !rows[0].has("Book Id") {
+		return nil, nil, db.ValidationFailedError("file has no Book Id column; is it a Goodreads library export?")
+	}
+
+	var entries []*LibraryEntry
+	var unmatched []*model.UnmatchedRow
+	for _, row := range rows {
+		title := row.get("Title")
+		if title == "" {
+			unmatched = append(unmatched, &model.UnmatchedRow{
+				File:   goodreadsFile,
+				Line:   int32(row.line),
+				Reason: "row has no title",
+			})
+			continue
+		}
+
+		media := &model.MediaImportInput{
+			Type:      model.MediaTypeBook,
+			Title:     title,
+			Publisher: optionalString(row.get("Publisher")),
+			Isbn:      goodreadsISBN(row.get("ISBN13"), row.get("ISBN")),
+		}
+		if bookID := row.get("Book Id"); bookID != "" {
+			media.ExternalIds = []*model.ExternalIDInput{{Source: goodreadsSource, Value: bookID}}
+		}
+		for _, author := range goodreadsAuthors(row) {
+			media.Creators = append(media.Creators, &model.CreatorInput{Name: author, Role: "Author"})
+		}
+		if pages, err := strconv.Atoi(row.get("Number of Pages")); err == nil && pages > 0 {
+			pageCount := int32(pages)
+			media.Pages = &pageCount
+		}
+		// The original year matches the work rather than this edition
+		for _, column := range []string{"Original Publication Year", "Year Published"} {
+			if year, err := strconv.Atoi(row.get(column)); err == nil && year >= 1000 && year <= 9999 {
+				releaseYear := int32(year)
+				media.ReleaseYear = &releaseYear
+				break
+			}
+		}
+
+		shelf := row.get("Exclusive Shelf")
+		status, ok := goodreadsShelfStatuses[shelf]
+		if !ok {
+			status = db.StatusPlanned
+		}
+
+		var tags []string
+		if shelf != "" && !goodreadsDefaultShelves[shelf] {
+			tags = append(tags, shelf)
+		}
+		for _, name := range strings.Split(row.get("Bookshelves"), ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && name != shelf && !goodreadsDefaultShelves[name] {
+				tags = append(tags, name)
+			}
+		}
+
+		// Goodreads writes 0 for books that weren't rated
+		rating := scaledRating(row.get("My Rating"), goodreadsMaxRating)
+		dateRead := optionalDate(row.get("Date Read"))
+
+		bookKey := row.get("Book Id")
+		if bookKey == "" {
+			bookKey = title
+		}
+
+		activity := &db.ActivityImport{
+			ImportKey:  goodreadsSource + ":book:" + bookKey,
+			StatusID:   status,
+			Rating:     rating,
+			Review:     optionalString(strings.TrimSpace(goodreadsReviewBreaks.Replace(row.get("My Review")))),
+			FinishedAt: dateRead,
+			Tags:       tags,
+		}
+		if count, err := strconv.Atoi(row.get("Read Count")); err == nil && count > 0 {
+			readCount := int32(count)
+			activity.Count = &readCount
+		}
+
+		entry := &LibraryEntry{
+			File:     goodreadsFile,
+			Line:     row.line,
+			Media:    media,
+			Activity: activity,
+		}
+		if rating != nil {
+			entry.Rating = &db.RatingImport{Score: *rating, RatedAt: dateRead}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, unmatched, nil
+}
+
+// goodreadsISBN returns the first valid ISBN of the row. Goodreads writes
+// them as spreadsheet formulas, e.g. ="0439023483", and leaves ="" when unknown.
+func goodreadsISBN(values ...string) *string {
+	for _, value := range values {
+		value = strings.Trim(value, `="`)
+		if value != "" && isbn.Valid(value) {
+			return &value
+		}
+	}
+	return nil
+}
+
+// goodreadsAuthors lists the main author followed by any additional authors
+func goodreadsAuthors(row csvRow) []string {
+	var authors []string
+	if author := row.get("Author"); author != "" {
+		authors = append(authors, author)
+	}
+	for _, author := range strings.Split(row.get("Additional Authors"), ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
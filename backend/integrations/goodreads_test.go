@@ -0,0 +1,92 @@
+package integrations
+
+import (
+	"errors"
+	"nq/db"
+	"slices"
+	"testing"
+)
+
+// goodreadsExport is a Goodreads library export with a full row, a row
+// without a title, an abandoned book and a row cut short. Exports saved by
+// Excel start with a byte order mark.
+const goodreadsExport = "\ufeffBook Id,Title,Author,Additional Authors,ISBN,ISBN13,My Rating,Publisher,Number of Pages,Year Published,Original Publication Year,Date Read,Bookshelves,Exclusive Shelf,My Review,Read Count\n" +
+	`2767052,The Hunger Games,Suzanne Collins,,="0439023483",="9780439023481",4,Scholastic Press,374,2008,2008,2024/01/05,"favorites, read",read,"Gripping.<br/><br/>Would reread.",2` + "\n" +
+	`1,,Nobody,,="",="",0,,,,,,,to-read,,0` + "\n" +
+	`41865,Twilight,Stephenie Meyer,"Someone Else, Another",="",="123",0,,abc,2005,,,dnf,dnf,,0` + "\n" +
+	`99,Cut Short,Ann Author` + "\n"
+
+func TestParseGoodreads(t *testing.T) {
+	entries, unmatched, err := ParseGoodreads([]byte(goodreadsExport))
+	if err != nil {
+		t.Fatalf("ParseGoodreads: %v", err)
+	}
+	if len(unmatched) != 1 || unmatched[0].Line != 3 || unmatched[0].File != goodreadsFile {
+		t.Errorf("got unmatched %+v, want the row without a title on line 3", unmatched)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	read := entries[0]
+	if read.Media.Title != "The Hunger Games" || read.Media.Isbn == nil || *read.Media.Isbn != "9780439023481" {
+		t.Errorf("got %+v, want the book with its ISBN-13 unquoted", read.Media)
+	}
+	if *read.Media.Pages != 374 || *read.Media.ReleaseYear != 2008 || *read.Media.Publisher != "Scholastic Press" {
+		t.Errorf("got pages, year or publisher wrong: %+v", read.Media)
+	}
+	if read.Media.ExternalIds[0].Source != goodreadsSource || read.Media.ExternalIds[0].Value != "2767052" {
+		t.Errorf("got external IDs %+v", read.Media.ExternalIds)
+	}
+	activity := read.Activity
+	if activity.StatusID != db.StatusCompleted || *activity.Count != 2 || *activity.FinishedAt != "2024-01-05T00:00:00Z" {
+		t.Errorf("got activity %+v", activity)
+	}
+	if *activity.Review != "Gripping.\n\nWould reread." {
+		t.Errorf("got review %q, want the line breaks kept", *activity.Review)
+	}
+	if !slices.Equal(activity.Tags, []string{"favorites"}) {
+		t.Errorf("got tags %v, want the custom shelf only", activity.Tags)
+	}
+	if read.Rating == nil || read.Rating.Score != 8 || *read.Rating.RatedAt != "2024-01-05T00:00:00Z" {
+		t.Errorf("got rating %+v, want 4 stars as 8 when read", read.Rating)
+	}
+
+	dropped := entries[1]
+	if dropped.Activity.StatusID != db.StatusDropped || !slices.Equal(dropped.Activity.Tags, []string{"dnf"}) {
+		t.Errorf("got %+v, want a dropped book tagged dnf", dropped.Activity)
+	}
+	var authors []string
+	for _, creator := range dropped.Media.Creators {
+		authors = append(authors, creator.Name)
+	}
+	if !slices.Equal(authors, []string{"Stephenie Meyer", "Someone Else", "Another"}) {
+		t.Errorf("got authors %v", authors)
+	}
+	// An invalid ISBN, unreadable page count and a rating of 0 are left out
+	if dropped.Media.Isbn != nil || dropped.Media.Pages != nil || dropped.Rating != nil || dropped.Activity.Count != nil {
+		t.Errorf("got %+v, want the unknown fields left unset", dropped.Media)
+	}
+
+	short := entries[2]
+	if short.Media.Title != "Cut Short" || short.Activity.StatusID != db.StatusPlanned || short.Activity.ImportKey != "goodreads:book:99" {
+		t.Errorf("got %+v, want a planned book from the columns present", short.Activity)
+	}
+}
+
+func TestParseGoodreadsMalformedFiles(t *testing.T) {
+	_, _, err := ParseGoodreads([]byte("Title,Year\nHeat,1995\n"))
+	if !errors.Is(err, db.ErrValidation) {
+		t.Errorf("got %v for a file without Book Id, want a validation error", err)
+	}
+
+	_, _, err = ParseGoodreads([]byte("Book Id,Title\n1,\"unterminated\n2,x\"y\"\n"))
+	if err != nil {
+		t.Errorf("got %v, want stray quotes tolerated", err)
+	}
+
+	entries, unmatched, err := ParseGoodreads(nil)
+	if err != nil || len(entries) != 0 || len(unmatched) != 0 {
+		t.Errorf("got %d entries, %d unmatched and %v for an empty file", len(entries), len(unmatched), err)
+	}
+}
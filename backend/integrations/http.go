@@ -0,0 +1,299 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for HTTPClient
+const (
+	DefaultHTTPTimeout = 30 * time.Second
+	DefaultMaxRetries  = 3
+	DefaultRetryDelay  = 500 * time.Millisecond
+	DefaultMaxDelay    = 30 * time.Second
+)
+
+// maxErrorBody is how much of an error response is kept in a StatusError
+const maxErrorBody = 512
+
+// Error kinds of StatusError, by response status. Check for them with
+// errors.Is.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrBadRequest   = errors.New("bad request")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrUnavailable matches requests that failed without a response, such as
+// network failures and timeouts
+var ErrUnavailable = errors.New("provider unavailable")
+
+// StatusError is a response from a provider with an unexpected status
+type StatusError struct {
+	Provider   string
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	// Body is the start of the response body, for the provider's error
+	// message
+	Body string
+	// RetryAfter is how long the provider asked us to wait, if it did
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s %s returned %s", e.Provider, e.Method, e.Path, e.Status)
+}
+
+// Unwrap exposes the error kind of the status to errors.Is
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServer
+	case e.StatusCode >= 400:
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// HTTPClient sends an integration's requests. It waits for the provider's
+// rate limit, retries rate limited requests and, for idempotent requests,
+// server errors and network failures with exponential backoff, and turns
+// responses that aren't 2xx into StatusErrors.
+type HTTPClient struct {
+	// Provider names the integration in errors and picks its rate limit
+	Provider string
+	Client   *http.Client
+	Limiter  *RateLimiter
+	// MaxRetries is how many times a request is retried after the first try
+	MaxRetries int
+	// RetryDelay is the first backoff delay, doubled on every retry
+	RetryDelay time.Duration
+	// MaxDelay caps backoff delays. Requests whose Retry-After asks for
+	// longer fail with ErrRateLimited instead of waiting.
+	MaxDelay time.Duration
+}
+
+// NewHTTPClient creates a client for a provider, sharing the provider's rate
+// limit with its other clients. Its transport records or replays fixtures as
+// set by INTEGRATIONS_HTTP_MODE.
+func NewHTTPClient(provider string) *HTTPClient {
+	return &HTTPClient{
+		Provider:   provider,
+		Client:     &http.Client{Timeout: DefaultHTTPTimeout, Transport: fixtureTransportFromEnv(http.DefaultTransport)},
+		Limiter:    sharedRateLimiter(provider),
+		MaxRetries: DefaultMaxRetries,
+		RetryDelay: DefaultRetryDelay,
+		MaxDelay:   DefaultMaxDelay,
+	}
+}
+
+// Do sends a request and returns its response when the status is 2xx. The
+// caller closes the body.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := c.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("%s: request to %s can't be retried", c.Provider, req.URL.Path)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("%s: request to %s failed: %w", c.Provider, req.URL.Path, redactURLError(err))
+			}
+			if !idempotent(req.Method) || attempt >= c.MaxRetries {
+				return nil, fmt.Errorf("%s: request to %s failed: %w: %w", c.Provider, req.URL.Path, ErrUnavailable, redactURLError(err))
+			}
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		statusErr := c.statusError(req, resp)
+		resp.Body.Close()
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode >= 500 && idempotent(req.Method))
+		if !retryable || attempt >= c.MaxRetries {
+			return nil, statusErr
+		}
+
+		delay := c.backoff(attempt)
+		if statusErr.RetryAfter > 0 {
+			if statusErr.RetryAfter > c.MaxDelay {
+				return nil, statusErr
+			}
+			delay = statusErr.RetryAfter
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// GetJSON sends a GET request with headers and decodes the JSON response
+// into out
+func (c *HTTPClient) GetJSON(ctx context.Context, rawURL string, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	return c.doJSON(req, out)
+}
+
+// PostForm sends a form and decodes the JSON response into out, which may be
+// nil to ignore the response
+func (c *HTTPClient) PostForm(ctx context.Context, rawURL string, header http.Header, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.doJSON(req, out)
+}
+
+// Post sends a body of contentType and decodes the JSON response into out
+func (c *HTTPClient) Post(ctx context.Context, rawURL string, header http.Header, contentType, body string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	return c.doJSON(req, out)
+}
+
+// doJSON sends a request and decodes its JSON response into out
+func (c *HTTPClient) doJSON(req *http.Request, out any) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: failed to decode %s: %w", c.Provider, req.URL.Path, err)
+	}
+	return nil
+}
+
+// statusError describes a response with an unexpected status
+func (c *HTTPClient) statusError(req *http.Request, resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &StatusError{
+		Provider:   c.Provider,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// backoff returns the delay before a retry: the retry delay doubled for every
+// earlier retry, with up to half of it added as jitter, capped at MaxDelay
+func (c *HTTPClient) backoff(attempt int) time.Duration {
+	delay := c.RetryDelay << attempt
+	if delay <= 0 || delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	delay += rand.N(delay/2 + 1)
+	return min(delay, c.MaxDelay)
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// idempotent reports whether a request with method can be sent twice
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// redactURLError drops the request URL from transport errors, since query
+// strings can carry API keys
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient creates a client without a rate limit that retries quickly
+func newTestClient(provider string) *HTTPClient {
+	client := NewHTTPClient(provider)
+	client.Limiter = nil
+	client.RetryDelay = time.Millisecond
+	return client
+}
+
+func TestHTTPClientRetriesRateLimitedAfterRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	var out struct {
+		OK bool `json:"ok"`
+	}
+	start := time.Now()
+	if err := newTestClient("test").GetJSON(context.Background(), server.URL, nil, &out); err != nil {
+		t.Fatalf("GetJSON: %v", err)
+	}
+	if !out.OK {
+		t.Errorf("response wasn't decoded")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("got %d requests, want 2", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the Retry-After of 1s", elapsed)
+	}
+}
+
+func TestHTTPClientFailsWhenRetryAfterExceedsMaxDelay(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	err := newTestClient("test").GetJSON(context.Background(), server.URL, nil, nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.RetryAfter != time.Hour {
+		t.Errorf("got %#v, want a StatusError with a Retry-After of 1h", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d requests, want 1", calls.Load())
+	}
+}
+
+func TestHTTPClientStatusErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   error
+		// requests is how many requests a GET makes
+		requests int32
+	}{
+		{http.StatusBadRequest, ErrBadRequest, 1},
+		{http.StatusUnauthorized, ErrUnauthorized, 1},
+		{http.StatusForbidden, ErrForbidden, 1},
+		{http.StatusNotFound, ErrNotFound, 1},
+		{http.StatusInternalServerError, ErrServer, DefaultMaxRetries + 1},
+		{http.StatusBadGateway, ErrServer, DefaultMaxRetries + 1},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				http.Error(w, "nope", tt.status)
+			}))
+			defer server.Close()
+
+			err := newTestClient("test").GetJSON(context.Background(), server.URL+"/things", nil, nil)
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("got %v, want %v", err, tt.kind)
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("got %T, want a StatusError", err)
+			}
+			if statusErr.StatusCode != tt.status || statusErr.Path != "/things" || statusErr.Body != "nope" {
+				t.Errorf("got %+v", statusErr)
+			}
+			if calls.Load() != tt.requests {
+				t.Errorf("got %d requests, want %d", calls.Load(), tt.requests)
+			}
+		})
+	}
+}
+
+func TestHTTPClientDoesNotRetryPostOnServerError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := newTestClient("test").PostForm(context.Background(), server.URL, nil, url.Values{"a": {"b"}}, nil)
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("got %v, want ErrServer", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d requests, want 1", calls.Load())
+	}
+}
+
+func TestHTTPClientNetworkFailureIsUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := newTestClient("test").GetJSON(context.Background(), server.URL+"?key=secret", nil, nil)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error leaks the key: %v", err)
+	}
+}
+
+func TestRateLimiterWaitsForTokens(t *testing.T) {
+	limiter := NewRateLimiter(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 2 {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("burst took %v, want no wait", elapsed)
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("third request after %v, want about 50ms", elapsed)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.Wait(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v from an empty bucket with a canceled context, want context.Canceled", err)
+	}
+}
+
+func TestSharedRateLimiterUsesProviderLimit(t *testing.T) {
+	limiter := sharedRateLimiter("musicbrainz")
+	if limiter != sharedRateLimiter("musicbrainz") {
+		t.Errorf("clients of a provider got different limiters")
+	}
+	if limiter.rate != 1 || limiter.burst != 1 {
+		t.Errorf("got %v a second with bursts of %v, want 1 and 1", limiter.rate, limiter.burst)
+	}
+
+	other := sharedRateLimiter("test-unknown-provider")
+	if other.rate != defaultRateLimit.rate || other.burst != float64(defaultRateLimit.burst) {
+		t.Errorf("unknown provider got %v and %v, want the default limit", other.rate, other.burst)
+	}
+}
+
+func TestFixtureTransportReplaysRecordedFixtures(t *testing.T) {
+	client := newTestClient("test")
+	client.Client.Transport = &FixtureTransport{Dir: "../testdata/fixtures"}
+
+	// The fixture was recorded with other credentials, which don't count
+	// when matching
+	form := url.Values{"client_id": {"id"}, "client_secret": {"secret"}, "grant_type": {"client_credentials"}}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := client.PostForm(context.Background(), "https://id.twitch.tv/oauth2/token", nil, form, &token); err != nil {
+		t.Fatalf("PostForm: %v", err)
+	}
+	if token.AccessToken != fixtureRedacted || token.ExpiresIn == 0 {
+		t.Errorf("got %+v", token)
+	}
+
+	err := client.GetJSON(context.Background(), "https://id.twitch.tv/unrecorded", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no fixture") {
+		t.Errorf("got %v for a request without a fixture", err)
+	}
+}
+
+func TestFixtureTransportRecordsWithoutSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"live-token","name":"kept"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder := newTestClient("test")
+	recorder.Client.Transport = &FixtureTransport{Dir: dir, Record: true, Next: http.DefaultTransport}
+	if err := recorder.GetJSON(context.Background(), server.URL+"/me?key=live-key", nil, nil); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got fixtures %v, %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "live-token") || strings.Contains(string(data), "live-key") {
+		t.Errorf("fixture keeps secrets: %s", data)
+	}
+
+	server.Close()
+	replayer := newTestClient("test")
+	replayer.Client.Transport = &FixtureTransport{Dir: dir}
+	var out struct {
+		Name string `json:"name"`
+	}
+	if err := replayer.GetJSON(context.Background(), server.URL+"/me?key=other-key", nil, &out); err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if out.Name != "kept" {
+		t.Errorf("got %+v", out)
+	}
+}
@@ -0,0 +1,136 @@
+package integrations
+
+import (
+	"bytes"
+	"nq/db"
+	"nq/graph/model"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// imdbMaxRating is the top of IMDb's ten-point rating scale
+const imdbMaxRating = 10.0
+
+// imdbSource is the external ID source for IMDb title IDs
+const imdbSource = "imdb"
+
+// imdbTitleID matches IMDb title IDs such as tt0113277
+var imdbTitleID = regexp.MustCompile(`^tt\d+$`)
+
+// imdbTitleTypes maps IMDb title types onto media types. The CSV uses the
+// display names in some exports and the API names in others. Episodes and
+// games aren't imported.
+var imdbTitleTypes = map[string]model.MediaType{
+	"movie":          model.MediaTypeMovie,
+	"tv movie":       model.MediaTypeMovie,
+	"tvmovie":        model.MediaTypeMovie,
+	"short":          model.MediaTypeMovie,
+	"tvshort":        model.MediaTypeMovie,
+	"video":          model.MediaTypeMovie,
+	"tv special":     model.MediaTypeMovie,
+	"tvspecial":      model.MediaTypeMovie,
+	"tv series":      model.MediaTypeTvShow,
+	"tvseries":       model.MediaTypeTvShow,
+	"tv mini series": model.MediaTypeTvShow,
+	"tvminiseries":   model.MediaTypeTvShow,
+}
+
+// ParseIMDb reads an IMDb ratings.csv or watchlist.csv export into library
+// entries. Rated titles get a rating and watchlist titles a Planned activity.
+func ParseIMDb(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	rows, err := readCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("can't read IMDb export: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil, nil
+	}
+
+	// The watchlist is the only export with a Position column
+	file := "ratings.csv"
+	watchlist := rows[0].has("Position")
+	if watchlist {
+		file = "watchlist.csv"
+	}
+	if !rows[0].has("Const") || !rows[0].has("Title Type") {
+		return nil, nil, db.ValidationFailedError("file has no Const or Title Type column; is it an IMDb ratings or watchlist export?")
+	}
+
+	var entries []*LibraryEntry
+	var unmatched []*model.UnmatchedRow
+	for _, row := range rows {
+		titleID := row.get("Const")
+		title := row.get("Title")
+		titleType := row.get("Title Type")
+		mediaType, supported := imdbTitleTypes[strings.ToLower(titleType)]
+
+		reason := ""
+		switch {
+		case !imdbTitleID.MatchString(titleID):
+			reason = "row has no IMDb title ID"
+		case title == "":
+			reason = "row has no title"
+		case !supported:
+			reason = "title type " + strconv.Quote(titleType) + " is not supported"
+		}
+		if reason != "" {
+			unmatched = append(unmatched, &model.UnmatchedRow{
+				File:   file,
+				Line:   int32(row.line),
+				Title:  optionalString(title),
+				Reason: reason,
+			})
+			continue
+		}
+
+		media := &model.MediaImportInput{
+			Type:        mediaType,
+			Title:       title,
+			ExternalIds: []*model.ExternalIDInput{{Source: imdbSource, Value: titleID}},
+		}
+		if year, err := strconv.Atoi(row.get("Year")); err == nil && year >= 1000 && year <= 9999 {
+			releaseYear := int32(year)
+			media.ReleaseYear = &releaseYear
+			// A series' release date can fall outside its start year in
+			// some exports, so only keep dates that agree with the year
+			if date := row.get("Release Date"); strings.HasPrefix(date, strconv.Itoa(year)+"-") && optionalDate(date) != nil {
+				media.ReleaseDate = &date
+			}
+		}
+		// Series list their episode length, which isn't a show property
+		if minutes, err := strconv.Atoi(row.get("Runtime (mins)")); err == nil && minutes > 0 && mediaType == model.MediaTypeMovie {
+			runtime := int32(minutes)
+			media.Runtime = &runtime
+		}
+		for _, director := range strings.Split(row.get("Directors"), ",") {
+			if director = strings.TrimSpace(director); director != "" {
+				media.Creators = append(media.Creators, &model.CreatorInput{Name: director, Role: "Director"})
+			}
+		}
+		for _, genre := range strings.Split(row.get("Genres"), ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				media.Tags = append(media.Tags, &model.TagInput{Name: genre, Type: "genre"})
+			}
+		}
+
+		entry := &LibraryEntry{
+			File:  file,
+			Line:  row.line,
+			Media: media,
+		}
+		if rating := scaledRating(row.get("Your Rating"), imdbMaxRating); rating != nil {
+			entry.Rating = &db.RatingImport{Score: *rating, RatedAt: optionalDate(row.get("Date Rated"))}
+		}
+		if watchlist {
+			entry.Activity = &db.ActivityImport{
+				ImportKey: imdbSource + ":watchlist:" + titleID,
+				StatusID:  db.StatusPlanned,
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, unmatched, nil
+}
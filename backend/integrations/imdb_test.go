@@ -0,0 +1,93 @@
+package integrations
+
+import (
+	"errors"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+)
+
+// imdbRatings is a ratings.csv export with a movie, a series, rows IMDb
+// titles we don't import, and malformed rows
+const imdbRatings = `Const,Your Rating,Date Rated,Title,URL,Title Type,IMDb Rating,Runtime (mins),Year,Genres,Num Votes,Release Date,Directors
+tt0113277,9,2024-02-01,Heat,https://www.imdb.com/title/tt0113277/,Movie,8.3,170,1995,"Action, Crime, Drama",700000,1995-12-15,Michael Mann
+tt0903747,10,2024-02-02,Breaking Bad,https://www.imdb.com/title/tt0903747/,TV Series,9.5,49,2008,"Crime, Drama",2000000,2008-01-20,
+tt0959621,8,2024-02-03,Pilot,https://www.imdb.com/title/tt0959621/,TV Episode,8.2,58,2008,Drama,30000,2008-01-20,Vince Gilligan
+notanid,7,2024-02-04,Broken,,Movie,,,,,,,
+tt0000001,7,2024-02-05,,,Movie,,,,,,,
+tt0111161,0,,The Shawshank Redemption,,movie,,,1994,,,1995-03-01,Frank Darabont
+tt0068646
+`
+
+func TestParseIMDbRatings(t *testing.T) {
+	entries, unmatched, err := ParseIMDb([]byte(imdbRatings))
+	if err != nil {
+		t.Fatalf("ParseIMDb: %v", err)
+	}
+
+	wantUnmatched := map[int32]string{
+		4: `title type "TV Episode" is not supported`,
+		5: "row has no IMDb title ID",
+		6: "row has no title",
+		8: "row has no title",
+	}
+	if len(unmatched) != len(wantUnmatched) {
+		t.Fatalf("got %d unmatched rows, want %d", len(unmatched), len(wantUnmatched))
+	}
+	for _, row := range unmatched {
+		if row.File != "ratings.csv" || row.Reason != wantUnmatched[row.Line] {
+			t.Errorf("got %s line %d: %s", row.File, row.Line, row.Reason)
+		}
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	heat := entries[0]
+	if heat.Media.Type != model.MediaTypeMovie || *heat.Media.Runtime != 170 || *heat.Media.ReleaseDate != "1995-12-15" {
+		t.Errorf("got %+v", heat.Media)
+	}
+	if len(heat.Media.Creators) != 1 || heat.Media.Creators[0].Role != "Director" || len(heat.Media.Tags) != 3 {
+		t.Errorf("got creators %v and tags %v", heat.Media.Creators, heat.Media.Tags)
+	}
+	if heat.Rating == nil || heat.Rating.Score != 9 || *heat.Rating.RatedAt != "2024-02-01T00:00:00Z" || heat.Activity != nil {
+		t.Errorf("got rating %+v and activity %+v, want a rating only", heat.Rating, heat.Activity)
+	}
+
+	show := entries[1]
+	if show.Media.Type != model.MediaTypeTvShow || show.Media.Runtime != nil || len(show.Media.Creators) != 0 {
+		t.Errorf("got %+v, want a show without its episode runtime", show.Media)
+	}
+
+	// Unrated, with a release date outside its year
+	shawshank := entries[2]
+	if shawshank.Rating != nil || shawshank.Media.ReleaseDate != nil || *shawshank.Media.ReleaseYear != 1994 {
+		t.Errorf("got %+v", shawshank.Media)
+	}
+}
+
+func TestParseIMDbWatchlist(t *testing.T) {
+	watchlist := "Position,Const,Created,Modified,Description,Title,URL,Title Type,IMDb Rating,Runtime (mins),Year,Genres,Num Votes,Release Date,Directors,Your Rating,Date Rated\n" +
+		"1,tt0113277,2024-01-01,2024-01-01,,Heat,,movie,8.3,170,1995,,,,Michael Mann,,\n"
+
+	entries, unmatched, err := ParseIMDb([]byte(watchlist))
+	if err != nil || len(unmatched) != 0 || len(entries) != 1 {
+		t.Fatalf("got %d entries, %d unmatched and %v", len(entries), len(unmatched), err)
+	}
+	entry := entries[0]
+	if entry.File != "watchlist.csv" || entry.Activity == nil || entry.Activity.StatusID != db.StatusPlanned || entry.Activity.ImportKey != "imdb:watchlist:tt0113277" {
+		t.Errorf("got %+v, want a planned activity", entry.Activity)
+	}
+}
+
+func TestParseIMDbRejectsOtherFiles(t *testing.T) {
+	_, _, err := ParseIMDb([]byte("Title,Year\nHeat,1995\n"))
+	if !errors.Is(err, db.ErrValidation) {
+		t.Errorf("got %v for a file without Const, want a validation error", err)
+	}
+
+	entries, _, err := ParseIMDb([]byte("Const,Title,Title Type\n"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("got %d entries and %v for a header alone", len(entries), err)
+	}
+}
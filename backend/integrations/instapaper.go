@@ -1 +1,135 @@
 package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"strings"
+)
+
+// instapaperSource prefixes the import keys of Instapaper bookmarks
+const instapaperSource = "instapaper"
+
+// instapaperFile is the name of the CSV in unmatched rows
+const instapaperFile = "instapaper-export.csv"
+
+// URLSource is the external ID source for web pages, with the page URL as the
+// value, so the same article saved in several services is matched
+const URLSource = "url"
+
+// Instapaper's built-in folders. Bookmarks in any other folder are unread and
+// keep the folder as a tag.
+const (
+	instapaperUnread  = "Unread"
+	instapaperArchive = "Archive"
+	instapaperStarred = "Starred"
+)
+
+// ParseInstapaper reads an Instapaper CSV export into library entries. Every
+// bookmark becomes an article with an activity: archived bookmarks are
+// Completed and the rest Planned. Starred bookmarks are also added to the
+// user's favorites, and custom folders and tags are kept as tags.
+func ParseInstapaper(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	rows, err := readCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("can't read Instapaper export: %v", err)
+	}
+	if len(rows) > 0 && (!rows[0].has("URL") || !rows[0].has("Folder")) {
+		return nil, nil, db.ValidationFailedError("file has no URL or Folder column; is it an Instapaper CSV export?")
+	}
+
+	var entries []*LibraryEntry
+	var unmatched []*model.UnmatchedRow
+	for _, row := range rows {
+		link := canonicalURL(row.get("URL"))
+		title := row.get("Title")
+		if link == "" {
+			unmatched = append(unmatched, &model.UnmatchedRow{
+				File:   instapaperFile,
+				Line:   int32(row.line),
+				Title:  optionalString(title),
+				Reason: "row has no web URL",
+			})
+			continue
+		}
+		if title == "" {
+			title = link
+		}
+
+		media := &model.MediaImportInput{
+			Type:        model.MediaTypeArticle,
+			Title:       title,
+			Description: optionalString(row.get("Selection")),
+			URL:         &link,
+			ExternalIds: []*model.ExternalIDInput{{Source: URLSource, Value: link}},
+		}
+
+		folder := row.get("Folder")
+		activity := &db.ActivityImport{
+			ImportKey: instapaperSource + ":" + link,
+			StatusID:  db.StatusPlanned,
+			// Only archiving says the user read it; an unread or starred
+			// bookmark shouldn't undo progress recorded elsewhere
+			KeepStatus: true,
+		}
+		switch folder {
+		case instapaperArchive:
+			activity.StatusID = db.StatusCompleted
+			activity.KeepStatus = false
+		case instapaperUnread, instapaperStarred, "":
+		default:
+			activity.Tags = append(activity.Tags, folder)
+		}
+		activity.Tags = append(activity.Tags, instapaperTags(row.get("Tags"))...)
+
+		entries = append(entries, &LibraryEntry{
+			File:     instapaperFile,
+			Line:     row.line,
+			Media:    media,
+			Activity: activity,
+			Favorite: folder == instapaperStarred,
+		})
+	}
+
+	return entries, unmatched, nil
+}
+
+// instapaperTags reads the Tags column, a JSON array in current exports and a
+// comma-separated list in older ones
+func instapaperTags(value string) []string {
+	var tags []string
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			return nil
+		}
+	} else {
+		tags = strings.Split(value, ",")
+	}
+
+	var cleaned []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned
+}
+
+// canonicalURL normalizes a web page URL for matching: the scheme and host
+// are lowercased and the fragment is dropped. It returns "" for anything but
+// an http or https URL.
+func canonicalURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ""
+	}
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	return parsed.String()
+}
@@ -0,0 +1,98 @@
+package integrations
+
+import (
+	"errors"
+	"nq/db"
+	"slices"
+	"testing"
+)
+
+// instapaperExport is an Instapaper CSV export with a bookmark in each folder,
+// both tag formats and rows that can't be imported
+const instapaperExport = `URL,Title,Selection,Folder,Timestamp,Tags
+HTTPS://Example.COM/Long-Read#section-2,A Long Read,The opening paragraph,Archive,1700000000,"[""essays"", "" "", ""longform""]"
+https://example.com/later,,,Unread,1700000001,
+https://example.com/starred,Starred Piece,,Starred,1700000002,"design, ,typography"
+https://example.com/recipes,Soup,,Cooking,1700000003,[broken
+ftp://example.com/file.txt,An FTP Link,,Unread,1700000004,
+instapaper-note,A Note,,Unread,1700000005,
+https://example.com/short,Cut Short
+`
+
+func TestParseInstapaper(t *testing.T) {
+	entries, unmatched, err := ParseInstapaper([]byte(instapaperExport))
+	if err != nil {
+		t.Fatalf("ParseInstapaper: %v", err)
+	}
+	if len(unmatched) != 2 || unmatched[0].Line != 6 || *unmatched[0].Title != "An FTP Link" || unmatched[1].Line != 7 || unmatched[1].File != instapaperFile {
+		t.Errorf("got unmatched %+v, want the rows on lines 6 and 7 without a web URL", unmatched)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+
+	archived := entries[0]
+	link := "https://example.com/Long-Read"
+	if archived.Media.Title != "A Long Read" || *archived.Media.URL != link || *archived.Media.Description != "The opening paragraph" ||
+		archived.Media.ExternalIds[0].Source != URLSource || archived.Media.ExternalIds[0].Value != link {
+		t.Errorf("got %+v, want the URL with its scheme and host lowercased and no fragment", archived.Media)
+	}
+	if archived.Activity.StatusID != db.StatusCompleted || archived.Activity.KeepStatus || archived.Activity.ImportKey != "instapaper:"+link ||
+		!slices.Equal(archived.Activity.Tags, []string{"essays", "longform"}) || archived.Favorite {
+		t.Errorf("got %+v, want an archived bookmark completed with its JSON tags", archived.Activity)
+	}
+
+	unread := entries[1]
+	if unread.Media.Title != "https://example.com/later" || unread.Media.Description != nil || unread.Line != 3 ||
+		unread.Activity.StatusID != db.StatusPlanned || !unread.Activity.KeepStatus || len(unread.Activity.Tags) != 0 {
+		t.Errorf("got %+v and %+v, want an untitled unread bookmark titled by its URL", unread.Media, unread.Activity)
+	}
+
+	starred := entries[2]
+	if !starred.Favorite || starred.Activity.StatusID != db.StatusPlanned || !slices.Equal(starred.Activity.Tags, []string{"design", "typography"}) {
+		t.Errorf("got %+v, want a starred favorite with its comma-separated tags", starred.Activity)
+	}
+
+	folder := entries[3]
+	if !slices.Equal(folder.Activity.Tags, []string{"Cooking"}) || folder.Favorite {
+		t.Errorf("got tags %v, want the folder kept and the broken JSON tags dropped", folder.Activity.Tags)
+	}
+
+	short := entries[4]
+	if short.Media.Title != "Cut Short" || short.Activity.StatusID != db.StatusPlanned || short.Line != 8 {
+		t.Errorf("got %+v, want a row without a folder read as unread", short)
+	}
+}
+
+func TestParseInstapaperMalformedFiles(t *testing.T) {
+	for _, data := range []string{
+		"Title,Folder\nA Long Read,Archive\n",
+		"URL,Title\nhttps://example.com/,A Long Read\n",
+	} {
+		if _, _, err := ParseInstapaper([]byte(data)); !errors.Is(err, db.ErrValidation) {
+			t.Errorf("got %v for %q, want a validation error", err, data)
+		}
+	}
+
+	entries, unmatched, err := ParseInstapaper([]byte("URL,Folder\n"))
+	if err != nil || len(entries) != 0 || len(unmatched) != 0 {
+		t.Errorf("got %v, %v and %v for an empty export, want nothing", entries, unmatched, err)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := map[string]string{
+		" HTTP://Example.com/Path?q=1#top ": "http://example.com/Path?q=1",
+		"https://example.com":               "https://example.com",
+		"mailto:someone@example.com":        "",
+		"example.com/path":                  "",
+		"javascript://example.com/":         "",
+		"https://exa mple.com/":             "",
+		"":                                  "",
+	}
+	for rawURL, want := range tests {
+		if got := canonicalURL(rawURL); got != want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"nq/db"
+	"nq/graph/model"
+	"strconv"
+	"strings"
+)
+
+// letterboxdMaxRating is the top of Letterboxd's half-star rating scale
+const letterboxdMaxRating = 5.0
+
+// letterboxdSource is the external ID source for Letterboxd film URIs
+const letterboxdSource = "letterboxd"
+
+// letterboxdFiles lists the export files we read, in the order they are
+// applied. Reviews come after the diary so they only add to diary entries.
+var letterboxdFiles = []string{"diary.csv", "reviews.csv", "ratings.csv", "watchlist.csv"}
+
+// ParseLetterboxd reads a Letterboxd ZIP export into library entries. Rows
+// that can't be matched to a film, such as rows without a year, are returned
+// as unmatched instead.
+func ParseLetterboxd(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("file is not a ZIP archive")
+	}
+
+	// Only the files at the root; likes/ and deleted/ repeat the same names
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var entries []*LibraryEntry
+	var unmatched []*model.UnmatchedRow
+	found := false
+	for _, name := range letterboxdFiles {
+		file, ok := files[name]
+		if !ok {
+			continue
+		}
+		found = true
+
+		fileEntries, fileUnmatched, err := parseLetterboxdFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, fileEntries...)
+		unmatched = append(unmatched, fileUnmatched...)
+	}
+
+	if !found {
+		return nil, nil, db.ValidationFailedError("archive has none of %s; is it a Letterboxd export?", strings.Join(letterboxdFiles, ", "))
+	}
+
+	return entries, unmatched, nil
+}
+
+// parseLetterboxdFile reads the rows of one export file
+func parseLetterboxdFile(file *zip.File) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("can't read %s: %v", file.Name, err)
+	}
+	defer f.Close()
+
+	rows, err := readCSV(f)
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("can't read %s: %v", file.Name, err)
+	}
+
+	var entries []*LibraryEntry
+	var unmatched []*model.UnmatchedRow
+	for _, row := range rows {
+		name := row.get("Name")
+		year, yearErr := strconv.Atoi(row.get("Year"))
+
+		reason := ""
+		switch {
+		case name == "":
+			reason = "row has no film name"
+		case yearErr != nil:
+			reason = "row has no release year to match the film on"
+		}
+		if reason != "" {
+			unmatched = append(unmatched, &model.UnmatchedRow{
+				File:   file.Name,
+				Line:   int32(row.line),
+				Title:  optionalString(name),
+				Reason: reason,
+			})
+			continue
+		}
+
+		releaseYear := int32(year)
+		entry := &LibraryEntry{
+			File: file.Name,
+			Line: row.line,
+			Media: &model.MediaImportInput{
+				Type:        model.MediaTypeMovie,
+				Title:       name,
+				ReleaseYear: &releaseYear,
+			},
+		}
+
+		uri := row.get("Letterboxd URI")
+		rating := scaledRating(row.get("Rating"), letterboxdMaxRating)
+
+		switch file.Name {
+		case "diary.csv", "reviews.csv":
+			// Diary and review URIs point at the entry, not the film, so they
+			// identify the activity and the film is matched by title and year
+			watched := optionalDate(row.get("Watched Date"))
+			if watched == nil {
+				watched = optionalDate(row.get("Date"))
+			}
+			rewatch := row.get("Rewatch") == "Yes"
+
+			entry.Activity = &db.ActivityImport{
+				ImportKey:  letterboxdKey("diary", uri, name, year),
+				StatusID:   db.StatusCompleted,
+				Rating:     rating,
+				Review:     optionalString(row.get("Review")),
+				FinishedAt: watched,
+				Rewatch:    &rewatch,
+			}
+		case "ratings.csv":
+			if rating == nil {
+				continue
+			}
+			entry.Media.ExternalIds = letterboxdFilmID(uri)
+			entry.Rating = &db.RatingImport{
+				Score:   *rating,
+				RatedAt: optionalDate(row.get("Date")),
+			}
+		case "watchlist.csv":
+			entry.Media.ExternalIds = letterboxdFilmID(uri)
+			entry.Activity = &db.ActivityImport{
+				ImportKey: letterboxdKey("watchlist", uri, name, year),
+				StatusID:  db.StatusPlanned,
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, unmatched, nil
+}
+
+// letterboxdFilmID returns the external ID for a film URI, if there is one
+func letterboxdFilmID(uri string) []*model.ExternalIDInput {
+	if uri == "" {
+		return nil
+	}
+	return []*model.ExternalIDInput{{Source: letterboxdSource, Value: uri}}
+}
+
+// letterboxdKey builds the import key of an activity, falling back to the
+// film when the row has no URI
+func letterboxdKey(kind, uri, name string, year int) string {
+	if uri == "" {
+		uri = name + ":" + strconv.Itoa(year)
+	}
+	return letterboxdSource + ":" + kind + ":" + uri
+}
@@ -0,0 +1,238 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"nq/db"
+	"nq/graph/model"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// LibraryEntry is one row of an export file, ready to be written to the graph.
+// Activity, Rating and Listens get their MediaID once Media has been matched
+// or created, and Favorite adds the media to the user's favorites.
+type LibraryEntry struct {
+	File     string
+	Line     int
+	Media    *model.MediaImportInput
+	Activity *db.ActivityImport
+	Rating   *db.RatingImport
+	Listens  []*db.ListenImport
+	Favorite bool
+}
+
+// LibraryParser turns an export file into library entries and the rows it
+// couldn't use
+type LibraryParser func(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error)
+
+// libraryParsers maps each import source onto the parser for its export
+var libraryParsers = map[model.ImportSource]LibraryParser{
+	model.ImportSourceLetterboxd:   ParseLetterboxd,
+	model.ImportSourceGoodreads:    ParseGoodreads,
+	model.ImportSourceImdb:         ParseIMDb,
+	model.ImportSourceYoutube:      ParseYouTube,
+	model.ImportSourceYoutubeMusic: ParseYouTubeMusic,
+	model.ImportSourceAppleMusic:   ParseAppleMusic,
+	model.ImportSourceInstapaper:   ParseInstapaper,
+}
+
+// ImportExport parses an export file from source and imports it for the user
+func ImportExport(ctx context.Context, repo db.Repository, userID uuid.UUID, source model.ImportSource, file io.Reader) (*model.ImportReport, error) {
+	parse, ok := libraryParsers[source]
+	if !ok {
+		return nil, db.ValidationFailedError("importing from %s is not supported", source)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	entries, unmatched, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return ImportLibrary(ctx, repo, userID, source, entries, unmatched)
+}
+
+// ImportLibrary matches or creates the media of every entry, then writes the
+// entries' activities, ratings, listens and favorites for the user. Entries
+// whose media can't be imported are added to the report's unmatched rows
+// along with the rows the parser already rejected.
+func ImportLibrary(ctx context.Context, repo db.Repository, userID uuid.UUID, source model.ImportSource, entries []*LibraryEntry, unmatched []*model.UnmatchedRow) (*model.ImportReport, error) {
+	if _, err := repo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	items := make([]*model.MediaImportInput, len(entries))
+	for i, entry := range entries {
+		items[i] = entry.Media
+	}
+
+	results, err := repo.ImportMedia(ctx, items, db.ImportOptions{OnExisting: model.ImportConflictPolicyUpdate})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.ImportReport{
+		Source:    source,
+		Unmatched: append([]*model.UnmatchedRow{}, unmatched...),
+	}
+
+	// The same media usually appears in several files, so count it once
+	created := make(map[uuid.UUID]bool)
+	matched := make(map[uuid.UUID]bool)
+
+	activities := make(map[string]*db.ActivityImport)
+	var activityKeys []string
+	var ratings []*db.RatingImport
+	var listens []*db.ListenImport
+	var favorites []uuid.UUID
+	favorited := make(map[uuid.UUID]bool)
+	for i, entry := range entries {
+		result := results[i]
+		if result.Media == nil {
+			reason := "media could not be imported"
+			if result.Error != nil {
+				reason = *result.Error
+			}
+			report.Unmatched = append(report.Unmatched, &model.UnmatchedRow{
+				File:   entry.File,
+				Line:   int32(entry.Line),
+				Title:  &entry.Media.Title,
+				Reason: reason,
+			})
+			continue
+		}
+
+		mediaID := result.Media.GetID()
+		if result.Review != nil {
+			report.MatchesToReview++
+		}
+		if result.Status == model.ImportStatusCreated {
+			created[mediaID] = true
+		} else {
+			matched[mediaID] = true
+		}
+
+		if entry.Activity != nil {
+			entry.Activity.MediaID = mediaID
+			if existing, ok := activities[entry.Activity.ImportKey]; ok {
+				mergeActivityImport(existing, entry.Activity)
+			} else {
+				activities[entry.Activity.ImportKey] = entry.Activity
+				activityKeys = append(activityKeys, entry.Activity.ImportKey)
+			}
+		}
+		if entry.Rating != nil {
+			entry.Rating.MediaID = mediaID
+			ratings = append(ratings, entry.Rating)
+		}
+		for _, listen := range entry.Listens {
+			listen.MediaID = mediaID
+			listens = append(listens, listen)
+		}
+		if entry.Favorite && !favorited[mediaID] {
+			favorited[mediaID] = true
+			favorites = append(favorites, mediaID)
+		}
+	}
+
+	for id := range matched {
+		if !created[id] {
+			report.MediaMatched++
+		}
+	}
+	report.MediaCreated = int32(len(created))
+
+	ordered := make([]*db.ActivityImport, len(activityKeys))
+	for i, key := range activityKeys {
+		ordered[i] = activities[key]
+	}
+
+	activityCount, err := repo.ImportActivities(ctx, userID, ordered)
+	if err != nil {
+		return nil, err
+	}
+	report.ActivitiesImported = int32(activityCount)
+
+	ratingCount, err := repo.ImportRatings(ctx, userID, ratings)
+	if err != nil {
+		return nil, err
+	}
+	report.RatingsImported = int32(ratingCount)
+
+	listenCount, err := repo.ImportListens(ctx, userID, listens)
+	if err != nil {
+		return nil, err
+	}
+	report.ListensImported = int32(listenCount)
+
+	favoriteCount, err := repo.ImportFavorites(ctx, userID, favorites)
+	if err != nil {
+		return nil, err
+	}
+	report.FavoritesImported = int32(favoriteCount)
+
+	return report, nil
+}
+
+// mergeActivityImport fills the fields missing from an activity with those of
+// another row describing the same activity, such as a review of a diary
+// entry. Both rows count the same activity, so the higher count and the
+// further progress are kept rather than added up, and their tags are
+// combined.
+func mergeActivityImport(into, from *db.ActivityImport) {
+	if into.Rating == nil {
+		into.Rating = from.Rating
+	}
+	if into.Review == nil {
+		into.Review = from.Review
+	}
+	if into.StartedAt == nil {
+		into.StartedAt = from.StartedAt
+	}
+	if into.FinishedAt == nil {
+		into.FinishedAt = from.FinishedAt
+	}
+	if into.Rewatch == nil {
+		into.Rewatch = from.Rewatch
+	}
+	if from.Count != nil && (into.Count == nil || *from.Count > *into.Count) {
+		into.Count = from.Count
+	}
+	// Progress in another unit can't be compared, so the first row's is kept
+	if from.Progress != nil && (into.Progress == nil || sameProgressUnit(into, from) && *from.Progress > *into.Progress) {
+		into.Progress, into.ProgressUnit = from.Progress, from.ProgressUnit
+	}
+	for _, tag := range from.Tags {
+		if !slices.Contains(into.Tags, tag) {
+			into.Tags = append(into.Tags, tag)
+		}
+	}
+}
+
+// sameProgressUnit reports whether two activities measure progress in the
+// same unit
+func sameProgressUnit(a, b *db.ActivityImport) bool {
+	if a.ProgressUnit == nil || b.ProgressUnit == nil {
+		return a.ProgressUnit == b.ProgressUnit
+	}
+	return *a.ProgressUnit == *b.ProgressUnit
+}
+
+// scaledRating converts a rating on a 0 to scaleMax scale onto ours. Zero and
+// unparseable values mean the row wasn't rated.
+func scaledRating(value string, scaleMax float64) *float64 {
+	rating, err := strconv.ParseFloat(value, 64)
+	if err != nil || rating <= 0 {
+		return nil
+	}
+	score := db.ScaleScore(rating, scaleMax)
+	return &score
+}
@@ -0,0 +1,59 @@
+package integrations
+
+import (
+	"nq/db"
+	"slices"
+	"testing"
+)
+
+func TestMergeActivityImportCombinesRows(t *testing.T) {
+	count := func(n int32) *int32 { return &n }
+	progress := func(p float64) *float64 { return &p }
+	unit := func(u string) *string { return &u }
+	review := "Loved it"
+
+	into := &db.ActivityImport{
+		ImportKey:    "goodreads:1",
+		Count:        count(1),
+		Progress:     progress(120),
+		ProgressUnit: unit("pages"),
+		Tags:         []string{"to-read", "fantasy"},
+	}
+	mergeActivityImport(into, &db.ActivityImport{
+		ImportKey:    "goodreads:1",
+		Review:       &review,
+		Count:        count(2),
+		Progress:     progress(300),
+		ProgressUnit: unit("pages"),
+		Tags:         []string{"fantasy", "favorites"},
+	})
+
+	if into.Review == nil || *into.Review != review {
+		t.Errorf("got review %v, want the other row's", into.Review)
+	}
+	if *into.Count != 2 {
+		t.Errorf("got count %d, want the higher count rather than the sum", *into.Count)
+	}
+	if *into.Progress != 300 {
+		t.Errorf("got progress %v, want the furthest", *into.Progress)
+	}
+	if want := []string{"to-read", "fantasy", "favorites"}; !slices.Equal(into.Tags, want) {
+		t.Errorf("got tags %v, want %v", into.Tags, want)
+	}
+
+	// Progress in another unit doesn't replace it
+	mergeActivityImport(into, &db.ActivityImport{Progress: progress(90), ProgressUnit: unit("percent")})
+	if *into.Progress != 300 || *into.ProgressUnit != "pages" {
+		t.Errorf("got progress %v %s, want 300 pages kept", *into.Progress, *into.ProgressUnit)
+	}
+}
+
+func TestMergeActivityImportFillsMissingFields(t *testing.T) {
+	into := &db.ActivityImport{ImportKey: "letterboxd:1"}
+	count := int32(3)
+	mergeActivityImport(into, &db.ActivityImport{Count: &count, Tags: []string{"horror"}})
+
+	if into.Count == nil || *into.Count != 3 || !slices.Equal(into.Tags, []string{"horror"}) {
+		t.Errorf("got %+v, want the other row's count and tags", into)
+	}
+}
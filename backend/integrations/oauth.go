@@ -0,0 +1,127 @@
+package integrations
+
+import (
+	"context"
+	"nq/db"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenExpiryMargin refreshes access tokens shortly before they expire, so a
+// token never runs out in the middle of a sync
+const tokenExpiryMargin = time.Minute
+
+// accessTokens keeps users' access tokens in memory until they expire, so a
+// sync doesn't read and decrypt the stored token for every request
+var accessTokens = NewTokenCache()
+
+// tokenLocks serializes token refreshes per user and provider, so concurrent
+// syncs don't both spend a refresh token the provider rotates on use
+var tokenLocks sync.Map
+
+// refreshFunc exchanges a refresh token for a new token set
+type refreshFunc func(ctx context.Context, refreshToken string) (*db.OAuthToken, error)
+
+// oauthAccessToken returns a usable access token for the user, refreshing the
+// stored one when it is about to expire. rejected is an access token the
+// provider refused before it expired, e.g. because the user revoked it; it is
+// refreshed too, unless another sync has replaced it in the meantime.
+func oauthAccessToken(ctx context.Context, repo db.Repository, userID uuid.UUID, provider, rejected string, refresh refreshFunc) (string, error) {
+	key := provider + ":" + userID.String()
+	lock, _ := tokenLocks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if cached, ok := accessTokens.Get(key); ok && cached != rejected {
+		return cached, nil
+	}
+	accessTokens.Delete(key)
+
+	token, err := repo.GetOAuthToken(ctx, userID, provider)
+	if err != nil {
+		return "", err
+	}
+
+	if token.AccessToken != rejected && time.Now().Add(tokenExpiryMargin).Before(token.ExpiresAt) {
+		accessTokens.Set(key, token.AccessToken, token.ExpiresAt)
+		return token.AccessToken, nil
+	}
+	if token.RefreshToken == "" {
+		return "", db.ValidationFailedError("%s access has expired; connect %s again", provider, provider)
+	}
+
+	refreshed, err := refresh(ctx, token.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	// Providers may rotate the refresh token; keep the old one when they don't
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	if refreshed.Scope == "" {
+		refreshed.Scope = token.Scope
+	}
+
+	if err := repo.SaveOAuthToken(ctx, userID, refreshed); err != nil {
+		return "", err
+	}
+	accessTokens.Set(key, refreshed.AccessToken, refreshed.ExpiresAt)
+
+	return refreshed.AccessToken, nil
+}
+
+// forgetAccessToken drops a user's cached access token for a provider
+func forgetAccessToken(provider string, userID uuid.UUID) {
+	accessTokens.Delete(provider + ":" + userID.String())
+}
+
+// TokenCache holds tokens until shortly before they expire
+type TokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+// cachedToken is a token in a TokenCache
+type cachedToken struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewTokenCache creates an empty cache
+func NewTokenCache() *TokenCache {
+	return &TokenCache{tokens: make(map[string]cachedToken)}
+}
+
+// Get returns the token stored under key, unless it expires within
+// tokenExpiryMargin
+func (c *TokenCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	token, ok := c.tokens[key]
+	if !ok {
+		return "", false
+	}
+	if !time.Now().Add(tokenExpiryMargin).Before(token.expiresAt) {
+		delete(c.tokens, key)
+		return "", false
+	}
+	return token.value, true
+}
+
+// Set stores a token until it expires
+func (c *TokenCache) Set(key, value string, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = cachedToken{value: value, expiresAt: expiresAt}
+}
+
+// Delete drops the token stored under key
+func (c *TokenCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, key)
+}
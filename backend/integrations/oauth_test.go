@@ -0,0 +1,140 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"nq/db"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenRepository stores one OAuth token in memory. Other repository
+// methods aren't used by oauthAccessToken and panic.
+type tokenRepository struct {
+	db.Repository
+	token *db.OAuthToken
+	gets  int
+	saves int
+}
+
+func (r *tokenRepository) GetOAuthToken(ctx context.Context, userID uuid.UUID, provider string) (*db.OAuthToken, error) {
+	r.gets++
+	if r.token == nil {
+		return nil, db.NotFoundError(provider + " token")
+	}
+	token := *r.token
+	return &token, nil
+}
+
+func (r *tokenRepository) SaveOAuthToken(ctx context.Context, userID uuid.UUID, token *db.OAuthToken) error {
+	r.saves++
+	saved := *token
+	r.token = &saved
+	return nil
+}
+
+func TestTokenCacheDropsTokensAboutToExpire(t *testing.T) {
+	cache := NewTokenCache()
+
+	cache.Set("fresh", "a", time.Now().Add(time.Hour))
+	if value, ok := cache.Get("fresh"); !ok || value != "a" {
+		t.Errorf("got %q, %v for a token valid for an hour", value, ok)
+	}
+
+	cache.Set("expiring", "b", time.Now().Add(tokenExpiryMargin/2))
+	if _, ok := cache.Get("expiring"); ok {
+		t.Errorf("got a token expiring within the margin")
+	}
+	if _, ok := cache.tokens["expiring"]; ok {
+		t.Errorf("expiring token was kept")
+	}
+
+	cache.Delete("fresh")
+	if _, ok := cache.Get("fresh"); ok {
+		t.Errorf("got a deleted token")
+	}
+}
+
+func TestOAuthAccessTokenCachesUntilExpiry(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	repo := &tokenRepository{token: &db.OAuthToken{
+		Provider:     "test",
+		AccessToken:  "stored",
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}}
+	refreshes := 0
+	refresh := func(ctx context.Context, refreshToken string) (*db.OAuthToken, error) {
+		refreshes++
+		if refreshToken != "refresh" {
+			t.Errorf("refreshed with %q", refreshToken)
+		}
+		return &db.OAuthToken{Provider: "test", AccessToken: "refreshed", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+	defer forgetAccessToken("test", userID)
+
+	for range 3 {
+		token, err := oauthAccessToken(ctx, repo, userID, "test", "", refresh)
+		if err != nil || token != "stored" {
+			t.Fatalf("got %q, %v, want the stored token", token, err)
+		}
+	}
+	if repo.gets != 1 || refreshes != 0 {
+		t.Errorf("read the token %d times and refreshed it %d times, want once and never", repo.gets, refreshes)
+	}
+
+	// A token the provider rejected is refreshed even though it hasn't expired
+	token, err := oauthAccessToken(ctx, repo, userID, "test", "stored", refresh)
+	if err != nil || token != "refreshed" {
+		t.Fatalf("got %q, %v, want the refreshed token", token, err)
+	}
+	if refreshes != 1 || repo.saves != 1 {
+		t.Errorf("refreshed %d times and saved %d times, want once each", refreshes, repo.saves)
+	}
+	if repo.token.RefreshToken != "refresh" {
+		t.Errorf("saved refresh token %q, want the old one kept", repo.token.RefreshToken)
+	}
+
+	token, err = oauthAccessToken(ctx, repo, userID, "test", "", refresh)
+	if err != nil || token != "refreshed" || repo.gets != 2 {
+		t.Errorf("got %q, %v after %d reads, want the cached refreshed token", token, err, repo.gets)
+	}
+}
+
+func TestOAuthAccessTokenRefreshesExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	defer forgetAccessToken("test", userID)
+	repo := &tokenRepository{token: &db.OAuthToken{
+		Provider:     "test",
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		Scope:        "read",
+		ExpiresAt:    time.Now().Add(tokenExpiryMargin / 2),
+	}}
+	refresh := func(ctx context.Context, refreshToken string) (*db.OAuthToken, error) {
+		return &db.OAuthToken{Provider: "test", AccessToken: "fresh", RefreshToken: "rotated", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+
+	token, err := oauthAccessToken(ctx, repo, userID, "test", "", refresh)
+	if err != nil || token != "fresh" {
+		t.Fatalf("got %q, %v, want the refreshed token", token, err)
+	}
+	if repo.token.RefreshToken != "rotated" || repo.token.Scope != "read" {
+		t.Errorf("saved %+v, want the rotated refresh token and the old scope", repo.token)
+	}
+}
+
+func TestOAuthAccessTokenWithoutRefreshToken(t *testing.T) {
+	userID := uuid.New()
+	defer forgetAccessToken("test", userID)
+	repo := &tokenRepository{token: &db.OAuthToken{Provider: "test", AccessToken: "stale", ExpiresAt: time.Now()}}
+
+	_, err := oauthAccessToken(context.Background(), repo, userID, "test", "", nil)
+	if !errors.Is(err, db.ErrValidation) {
+		t.Errorf("got %v, want a validation error asking to reconnect", err)
+	}
+}
@@ -0,0 +1,157 @@
+package integrations
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// plistDict is a dictionary of an XML property list, with the line it starts
+// on for reporting
+type plistDict struct {
+	line   int
+	values map[string]any
+}
+
+// string returns a string value, or "" if the key is missing or not a string
+func (d *plistDict) string(key string) string {
+	value, _ := d.values[key].(string)
+	return strings.TrimSpace(value)
+}
+
+// int returns an integer value, or 0 if the key is missing or not an integer
+func (d *plistDict) int(key string) int64 {
+	value, _ := d.values[key].(int64)
+	return value
+}
+
+// bool returns a boolean value, or false if the key is missing
+func (d *plistDict) bool(key string) bool {
+	value, _ := d.values[key].(bool)
+	return value
+}
+
+// date returns a date value, or the zero time if the key is missing
+func (d *plistDict) date(key string) time.Time {
+	value, _ := d.values[key].(time.Time)
+	return value
+}
+
+// dict returns a nested dictionary, or nil if the key is missing
+func (d *plistDict) dict(key string) *plistDict {
+	value, _ := d.values[key].(*plistDict)
+	return value
+}
+
+// readPlist reads an XML property list. Dictionaries become *plistDict,
+// arrays []any, integers int64, reals float64, dates time.Time and data
+// []byte.
+func readPlist(r io.Reader) (any, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("file has no <plist> element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "plist" {
+				return nil, fmt.Errorf("root element is <%s>, not <plist>", start.Name.Local)
+			}
+			return readPlistValue(decoder, nil)
+		}
+	}
+}
+
+// readPlistValue reads the value starting at start, or at the next element
+// when start is nil
+func readPlistValue(decoder *xml.Decoder, start *xml.StartElement) (any, error) {
+	for start == nil {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			start = &element
+		}
+	}
+
+	switch start.Name.Local {
+	case "dict":
+		line, _ := decoder.InputPos()
+		dict := &plistDict{line: line, values: make(map[string]any)}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch token := token.(type) {
+			case xml.EndElement:
+				return dict, nil
+			case xml.StartElement:
+				if token.Name.Local != "key" {
+					return nil, fmt.Errorf("line %d: expected <key> in <dict>, got <%s>", line, token.Name.Local)
+				}
+				var key string
+				if err := decoder.DecodeElement(&key, &token); err != nil {
+					return nil, err
+				}
+				value, err := readPlistValue(decoder, nil)
+				if err != nil {
+					return nil, err
+				}
+				dict.values[key] = value
+			}
+		}
+	case "array":
+		values := []any{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch token := token.(type) {
+			case xml.EndElement:
+				return values, nil
+			case xml.StartElement:
+				value, err := readPlistValue(decoder, &token)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := decoder.DecodeElement(&text, start); err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(text, 10, 64)
+	case "real":
+		return strconv.ParseFloat(text, 64)
+	case "date":
+		return time.Parse(time.RFC3339, text)
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	}
+	return nil, fmt.Errorf("unknown property list element <%s>", start.Name.Local)
+}
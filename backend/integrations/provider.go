@@ -0,0 +1,263 @@
+package integrations
+
+import (
+	"context"
+	"nq/db"
+	"nq/graph/model"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Provider is an integration users connect an account in and sync their
+// library from. Providers are registered by name in a Registry.
+type Provider interface {
+	// Name identifies the provider in connections and the GraphQL API, e.g.
+	// "steam"
+	Name() string
+	AuthKind() model.IntegrationAuthKind
+	// Connect starts connecting a user's account. ACCOUNT_ID providers check
+	// the account and return it; OAUTH providers return the URL to send the
+	// user to.
+	Connect(ctx context.Context, repo db.Repository, userID uuid.UUID, input ConnectInput) (*ConnectResult, error)
+	// Sync reads what changed in a connected account since cursor, or
+	// everything when cursor is ""
+	Sync(ctx context.Context, repo db.Repository, conn *db.Connection, cursor string) (*SyncResult, error)
+	// Disconnect releases anything the provider holds for the user, such as
+	// a token issued to us. The connection itself is deleted by the registry.
+	Disconnect(ctx context.Context, repo db.Repository, userID uuid.UUID) error
+}
+
+// OAuthProvider is a provider connected with an authorization code flow
+type OAuthProvider interface {
+	Provider
+	// CompleteAuthorization exchanges the code the provider redirected back
+	// with for tokens and returns the user who started connecting
+	CompleteAuthorization(ctx context.Context, repo db.Repository, state, code string) (uuid.UUID, error)
+}
+
+// ConnectInput is what a user gives to connect an account
+type ConnectInput struct {
+	Account string
+	// APIKey is the user's own key, for providers that accept one. Providers
+	// store it with SaveCredential, sealed, and never return it.
+	APIKey string
+}
+
+// ConnectResult is the outcome of Provider.Connect
+type ConnectResult struct {
+	// Account is the account to store on the connection
+	Account string
+	// AuthorizeURL is set when the user must sign in with the provider
+	// before the connection is made
+	AuthorizeURL string
+}
+
+// SyncResult is what a sync read from a provider
+type SyncResult struct {
+	Entries []*LibraryEntry
+	// Follows are written after the entries, so they can link creators to
+	// the media just imported
+	Follows []*db.FollowImport
+	// Cursor is where the next sync starts
+	Cursor string
+}
+
+// Registry holds the providers enabled on this server
+type Registry struct {
+	providers map[string]Provider
+	order     []string
+}
+
+// NewRegistry creates a registry of providers, listed in the order given
+func NewRegistry(providers ...Provider) *Registry {
+	registry := &Registry{providers: make(map[string]Provider)}
+	for _, provider := range providers {
+		if _, ok := registry.providers[provider.Name()]; !ok {
+			registry.order = append(registry.order, provider.Name())
+		}
+		registry.providers[provider.Name()] = provider
+	}
+	return registry
+}
+
+// NewRegistryFromEnv creates a registry of the integrations configured in the
+// environment. Integrations without their settings are left out, except
+// Steam, which users can connect with their own API key.
+func NewRegistryFromEnv() *Registry {
+	providers := []Provider{NewSteamClient()}
+	if spotify := NewSpotifyClient(); spotify != nil {
+		providers = append(providers, spotify)
+	}
+	if twitch := NewTwitchClient(); twitch != nil {
+		twitch.IncludeVODs = os.Getenv("TWITCH_IMPORT_VODS") == "true"
+		providers = append(providers, twitch)
+	}
+	return NewRegistry(providers...)
+}
+
+// Get returns the provider with a name
+func (r *Registry) Get(name string) (Provider, error) {
+	provider, ok := r.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, db.ValidationFailedError("integration %q is not available on this server", name)
+	}
+	return provider, nil
+}
+
+// Providers lists the registered providers
+func (r *Registry) Providers() []Provider {
+	providers := make([]Provider, len(r.order))
+	for i, name := range r.order {
+		providers[i] = r.providers[name]
+	}
+	return providers
+}
+
+// Connect starts connecting a user's account in a provider. The connection
+// is saved right away unless the user has to authorize it first, in which
+// case it is saved by Complete.
+func (r *Registry) Connect(ctx context.Context, repo db.Repository, userID uuid.UUID, name string, input ConnectInput) (*db.Connection, string, error) {
+	provider, err := r.Get(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input.Account = strings.TrimSpace(input.Account)
+	input.APIKey = strings.TrimSpace(input.APIKey)
+	if input.APIKey != "" && provider.AuthKind() == model.IntegrationAuthKindOauth {
+		return nil, "", db.ValidationFailedError("integration %q is connected by signing in, not with an API key", name)
+	}
+
+	result, err := provider.Connect(ctx, repo, userID, input)
+	if err != nil {
+		return nil, "", err
+	}
+	if result.AuthorizeURL != "" {
+		return nil, result.AuthorizeURL, nil
+	}
+
+	conn, err := repo.SaveConnection(ctx, &db.Connection{UserID: userID, Provider: provider.Name(), Account: result.Account})
+	if err != nil {
+		return nil, "", err
+	}
+	return conn, "", nil
+}
+
+// Complete finishes connecting an account after the user authorized it
+func (r *Registry) Complete(ctx context.Context, repo db.Repository, name, state, code string) (*db.Connection, error) {
+	provider, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	oauth, ok := provider.(OAuthProvider)
+	if !ok {
+		return nil, db.ValidationFailedError("integration %q is not connected with OAuth", name)
+	}
+
+	userID, err := oauth.CompleteAuthorization(ctx, repo, state, code)
+	if err != nil {
+		return nil, err
+	}
+	forgetAccessToken(provider.Name(), userID)
+
+	return repo.SaveConnection(ctx, &db.Connection{UserID: userID, Provider: provider.Name()})
+}
+
+// Sync imports what changed in a user's connected account since the last
+// sync, or everything when full is set, and saves where the next sync starts
+func (r *Registry) Sync(ctx context.Context, repo db.Repository, userID uuid.UUID, name string, full bool) (*model.ImportReport, error) {
+	provider, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	source := model.ImportSource(strings.ToUpper(provider.Name()))
+	if !source.IsValid() {
+		return nil, db.ValidationFailedError("integration %q has no import source", name)
+	}
+
+	conn, err := repo.GetConnection(ctx, userID, provider.Name())
+	if err != nil {
+		return nil, err
+	}
+	cursor := conn.Cursor
+	if full {
+		cursor = ""
+	}
+
+	result, err := provider.Sync(ctx, repo, conn, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := ImportLibrary(ctx, repo, userID, source, result.Entries, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Follows) > 0 {
+		followed, err := repo.ImportFollows(ctx, userID, result.Follows)
+		if err != nil {
+			return nil, err
+		}
+		report.CreatorsFollowed = int32(followed)
+	}
+
+	if err := repo.SaveSyncCursor(ctx, userID, provider.Name(), result.Cursor); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Disconnect removes a user's connection to a provider and its tokens
+func (r *Registry) Disconnect(ctx context.Context, repo db.Repository, userID uuid.UUID, name string) error {
+	provider, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if err := provider.Disconnect(ctx, repo, userID); err != nil {
+		return err
+	}
+	forgetAccessToken(provider.Name(), userID)
+
+	return repo.DeleteConnection(ctx, userID, provider.Name())
+}
+
+// ConnectionModel converts a stored connection for the GraphQL API. The sync
+// cursor stays on the server.
+func ConnectionModel(conn *db.Connection) *model.IntegrationConnection {
+	connection := &model.IntegrationConnection{
+		Provider:    conn.Provider,
+		Account:     optionalString(conn.Account),
+		ConnectedAt: conn.ConnectedAt.UTC().Format(time.RFC3339),
+	}
+	if conn.LastSyncedAt != nil {
+		lastSyncedAt := conn.LastSyncedAt.UTC().Format(time.RFC3339)
+		connection.LastSyncedAt = &lastSyncedAt
+	}
+	return connection
+}
+
+// laterTimestamp returns whichever of two RFC 3339 timestamps is later,
+// treating "" as the earliest
+func laterTimestamp(a, b string) string {
+	if a == "" || timestampBefore(a, b) {
+		return b
+	}
+	return a
+}
+
+// timestampBefore reports whether RFC 3339 timestamp a is before b. Values
+// that don't parse are compared as strings.
+func timestampBefore(a, b string) bool {
+	at, errA := time.Parse(time.RFC3339, a)
+	bt, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return at.Before(bt)
+}
@@ -0,0 +1,111 @@
+package integrations
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimit is how many requests a second a provider allows, with bursts of
+// burst
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+// rateLimits are the providers' limits, by the provider name clients are
+// created with. Every limit lives here so that no two clients of a provider
+// can disagree on it.
+var rateLimits = map[string]rateLimit{
+	// Steam allows 100,000 Web API calls a day per key; stay well under bursts
+	"steam": {rate: 4, burst: 10},
+	// Spotify doesn't publish its rolling 30-second limit; this stays under it
+	// and Retry-After covers the rest
+	"spotify": {rate: 5, burst: 10},
+	// Helix refills 800 points a minute, one point per request
+	"twitch": {rate: 12, burst: 20},
+	// TMDB allows around 50 requests a second
+	"tmdb": {rate: 20, burst: 20},
+	// Open Library asks clients to keep to about one request a second
+	"openlibrary": {rate: 1, burst: 5},
+	// MusicBrainz allows one request a second per client
+	"musicbrainz": {rate: 1, burst: 1},
+	// IGDB allows four requests a second
+	"igdb": {rate: 4, burst: 4},
+}
+
+// defaultRateLimit applies to providers missing from rateLimits
+var defaultRateLimit = rateLimit{rate: 1, burst: 5}
+
+// rateLimiters holds one limiter per provider, so every client of a provider
+// shares its limit
+var rateLimiters sync.Map
+
+// RateLimiter is a token bucket: it holds up to burst tokens, refilled at
+// rate tokens per second, and every request takes one
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a full bucket
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// sharedRateLimiter returns the provider's limiter, creating it with the
+// provider's limit from rateLimits on first use
+func sharedRateLimiter(provider string) *RateLimiter {
+	if limiter, ok := rateLimiters.Load(provider); ok {
+		return limiter.(*RateLimiter)
+	}
+	limit, ok := rateLimits[provider]
+	if !ok {
+		limit = defaultRateLimit
+	}
+	limiter, _ := rateLimiters.LoadOrStore(provider, NewRateLimiter(limit.rate, limit.burst))
+	return limiter.(*RateLimiter)
+}
+
+// Wait takes a token, waiting for one to be refilled if the bucket is empty.
+// A nil limiter doesn't limit.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long until one is
+// available
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
@@ -1,53 +1,401 @@
 package integrations
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
+	"nq/db"
+	"nq/graph/model"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Default Spotify endpoints, overridable to test against a stub server
+const (
+	DefaultSpotifyAccountsURL = "https://accounts.spotify.com"
+	DefaultSpotifyAPIURL      = "https://api.spotify.com"
 )
 
-const tokenURL = "https://accounts.spotify.com/api/token"
+// spotifySource is the provider name for tokens and the external ID source
+// for Spotify album IDs
+const spotifySource = "spotify"
+
+// spotifyArtistSource is the external ID source for Spotify artists
+const spotifyArtistSource = "spotify-artist"
+
+// spotifyScope is the access we ask users for
+const spotifyScope = "user-library-read"
+
+// spotifyPageSize is the largest page the saved albums endpoint returns
+const spotifyPageSize = 50
 
+// errSpotifyDisabled is returned by a nil client, when Spotify isn't configured
+var errSpotifyDisabled = db.ValidationFailedError("Spotify import is not enabled on this server")
+
+// SpotifyClient connects user accounts with the authorization code flow with
+// PKCE and reads their libraries from the Web API
+type SpotifyClient struct {
+	ClientID string
+	// ClientSecret is optional; without it the client acts as a public client
+	// and relies on PKCE alone
+	ClientSecret string
+	RedirectURI  string
+	AccountsURL  string
+	APIURL       string
+	HTTPClient   *HTTPClient
+}
+
+// TokenResponse is the token endpoint's response
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SpotifySavedAlbum is an album in a user's library
+type SpotifySavedAlbum struct {
+	AddedAt string       `json:"added_at"`
+	Album   SpotifyAlbum `json:"album"`
 }
 
-func getSpotifyToken(clientID, clientSecret string) (string, error) {
-	// Encode clientID:clientSecret to base64
-	authHeader := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + clientSecret))
+// SpotifyAlbum is the part of Spotify's album object we import
+type SpotifyAlbum struct {
+	ID                   string   `json:"id"`
+	Name                 string   `json:"name"`
+	ReleaseDate          string   `json:"release_date"`
+	ReleaseDatePrecision string   `json:"release_date_precision"`
+	TotalTracks          int      `json:"total_tracks"`
+	Label                string   `json:"label"`
+	Genres               []string `json:"genres"`
+	Artists              []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"artists"`
+	Images []struct {
+		URL string `json:"url"`
+	} `json:"images"`
+	Tracks struct {
+		Total int `json:"total"`
+		Items []struct {
+			DurationMs int `json:"duration_ms"`
+		} `json:"items"`
+	} `json:"tracks"`
+}
+
+// NewSpotifyClient creates a Spotify client from SPOTIFY_CLIENT_ID,
+// SPOTIFY_CLIENT_SECRET and SPOTIFY_REDIRECT_URI. SPOTIFY_ACCOUNTS_BASE_URL
+// and SPOTIFY_API_BASE_URL point it at other servers. It returns nil when no
+// client ID or redirect URI is configured.
+func NewSpotifyClient() *SpotifyClient {
+	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
+	redirectURI := os.Getenv("SPOTIFY_REDIRECT_URI")
+	if clientID == "" || redirectURI == "" {
+		return nil
+	}
 
-	// Prepare request
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
+	accountsURL := os.Getenv("SPOTIFY_ACCOUNTS_BASE_URL")
+	if accountsURL == "" {
+		accountsURL = DefaultSpotifyAccountsURL
+	}
+	apiURL := os.Getenv("SPOTIFY_API_BASE_URL")
+	if apiURL == "" {
+		apiURL = DefaultSpotifyAPIURL
+	}
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
+	return &SpotifyClient{
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("SPOTIFY_CLIENT_SECRET"),
+		RedirectURI:  redirectURI,
+		AccountsURL:  strings.TrimSuffix(accountsURL, "/"),
+		APIURL:       strings.TrimSuffix(apiURL, "/"),
+		HTTPClient:   NewHTTPClient(spotifySource),
+	}
+}
+
+// StartAuthorization begins connecting a user's Spotify account and returns
+// the URL to send the user to. Spotify redirects back to the redirect URI
+// with the code and state to pass to CompleteAuthorization.
+func (c *SpotifyClient) StartAuthorization(ctx context.Context, repo db.Repository, userID uuid.UUID) (string, error) {
+	if c == nil {
+		return "", errSpotifyDisabled
+	}
+
+	verifier, err := randomToken(64)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "Basic "+authHeader)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	state, err := randomToken(32)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	// Decode response
-	var token TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+	pending := &db.OAuthState{
+		UserID:       userID,
+		Provider:     spotifySource,
+		CodeVerifier: verifier,
+	}
+	if err := repo.SaveOAuthState(ctx, state, pending); err != nil {
 		return "", err
 	}
 
-	return token.AccessToken, nil
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.ClientID)
+	query.Set("scope", spotifyScope)
+	query.Set("redirect_uri", c.RedirectURI)
+	query.Set("state", state)
+	query.Set("code_challenge_method", "S256")
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+
+	return c.AccountsURL + "/authorize?" + query.Encode(), nil
+}
+
+// CompleteAuthorization exchanges the code Spotify redirected back with for
+// tokens and stores them for the user who started the authorization
+func (c *SpotifyClient) CompleteAuthorization(ctx context.Context, repo db.Repository, state, code string) (uuid.UUID, error) {
+	if c == nil {
+		return uuid.Nil, errSpotifyDisabled
+	}
+
+	pending, err := repo.TakeOAuthState(ctx, spotifySource, state)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.RedirectURI)
+	form.Set("code_verifier", pending.CodeVerifier)
+
+	token, err := c.requestToken(ctx, form)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := repo.SaveOAuthToken(ctx, pending.UserID, token); err != nil {
+		return uuid.Nil, err
+	}
+
+	return pending.UserID, nil
+}
+
+// accessToken returns a usable access token for the user, refreshing the
+// stored one when it is about to expire or is the rejected token
+func (c *SpotifyClient) accessToken(ctx context.Context, repo db.Repository, userID uuid.UUID, rejected string) (string, error) {
+	return oauthAccessToken(ctx, repo, userID, spotifySource, rejected, func(ctx context.Context, refreshToken string) (*db.OAuthToken, error) {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+		return c.requestToken(ctx, form)
+	})
+}
+
+// requestToken calls the token endpoint with a code or refresh token grant
+func (c *SpotifyClient) requestToken(ctx context.Context, form url.Values) (*db.OAuthToken, error) {
+	if c.ClientSecret == "" {
+		form.Set("client_id", c.ClientID)
+	}
+
+	header := http.Header{}
+	if c.ClientSecret != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.ClientID+":"+c.ClientSecret)))
+	}
+
+	var body TokenResponse
+	err := c.HTTPClient.PostForm(ctx, c.AccountsURL+"/api/token", header, form, &body)
+	if errors.Is(err, ErrBadRequest) {
+		// invalid_grant: the code was used already, or the user revoked access
+		return nil, db.ValidationFailedError("Spotify rejected the authorization; connect Spotify again")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.OAuthToken{
+		Provider:     spotifySource,
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+		Scope:        body.Scope,
+	}, nil
+}
+
+// GetSavedAlbums lists the albums in the user's library, most recently saved
+// first. With since set, it stops at the first album saved at or before it.
+func (c *SpotifyClient) GetSavedAlbums(ctx context.Context, accessToken, since string) ([]SpotifySavedAlbum, error) {
+	var albums []SpotifySavedAlbum
+	for offset := 0; ; offset += spotifyPageSize {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(spotifyPageSize))
+		query.Set("offset", strconv.Itoa(offset))
+
+		var page struct {
+			Items []SpotifySavedAlbum `json:"items"`
+			Total int                 `json:"total"`
+		}
+		if err := c.get(ctx, accessToken, "/v1/me/albums?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			if since != "" && !timestampBefore(since, item.AddedAt) {
+				return albums, nil
+			}
+			albums = append(albums, item)
+		}
+		if len(page.Items) == 0 || offset+len(page.Items) >= page.Total {
+			return albums, nil
+		}
+	}
+}
+
+// get calls a Web API endpoint and decodes its JSON response into out
+func (c *SpotifyClient) get(ctx context.Context, accessToken, path string, out any) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+accessToken)
+	return c.HTTPClient.GetJSON(ctx, c.APIURL+path, header, out)
+}
+
+// Name is the provider name of Spotify connections and tokens
+func (c *SpotifyClient) Name() string {
+	return spotifySource
+}
+
+// AuthKind is OAUTH
+func (c *SpotifyClient) AuthKind() model.IntegrationAuthKind {
+	return model.IntegrationAuthKindOauth
+}
+
+// Connect starts authorizing access to the user's Spotify library
+func (c *SpotifyClient) Connect(ctx context.Context, repo db.Repository, userID uuid.UUID, input ConnectInput) (*ConnectResult, error) {
+	authorizeURL, err := c.StartAuthorization(ctx, repo, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &ConnectResult{AuthorizeURL: authorizeURL}, nil
 }
 
-func GetUserSavedTracks(offset int, limit int) {
-	getSpotifyToken(os.Getenv("SPOTIFY_CLIENT_ID"), os.Getenv("SPOTIFY_CLIENT_SECRET"))
+// Sync reads the albums saved in the user's Spotify library. Saved albums
+// become In Progress activities started when the album was saved. The cursor
+// is when the newest album was saved, so incremental syncs only read albums
+// saved since.
+func (c *SpotifyClient) Sync(ctx context.Context, repo db.Repository, conn *db.Connection, cursor string) (*SyncResult, error) {
+	accessToken, err := c.accessToken(ctx, repo, conn.UserID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	albums, err := c.GetSavedAlbums(ctx, accessToken, cursor)
+	if errors.Is(err, ErrUnauthorized) {
+		// The token was revoked or expired early; refresh once and retry
+		if accessToken, err = c.accessToken(ctx, repo, conn.UserID, accessToken); err != nil {
+			return nil, err
+		}
+		albums, err = c.GetSavedAlbums(ctx, accessToken, cursor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, album := range albums {
+		cursor = laterTimestamp(cursor, album.AddedAt)
+	}
+
+	return &SyncResult{Entries: SpotifyLibraryEntries(albums), Cursor: cursor}, nil
+}
+
+// Disconnect does nothing; Spotify has no endpoint to revoke tokens, so
+// users remove access from their account page. The stored tokens are deleted
+// with the connection.
+func (c *SpotifyClient) Disconnect(ctx context.Context, repo db.Repository, userID uuid.UUID) error {
+	return nil
+}
+
+// SpotifyLibraryEntries turns saved albums into library entries
+func SpotifyLibraryEntries(albums []SpotifySavedAlbum) []*LibraryEntry {
+	playerURL := "https://open.spotify.com"
+
+	entries := make([]*LibraryEntry, 0, len(albums))
+	for i, saved := range albums {
+		album := saved.Album
+
+		media := &model.MediaImportInput{
+			Type:        model.MediaTypeMusicAlbum,
+			Title:       strings.TrimSpace(album.Name),
+			ExternalIds: []*model.ExternalIDInput{{Source: spotifySource, Value: album.ID}},
+			Platforms:   []*model.PlatformInput{{Name: "Spotify", BaseURL: &playerURL}},
+			Label:       optionalString(album.Label),
+		}
+		switch {
+		case album.ReleaseDatePrecision == "day":
+			media.ReleaseDate = &album.ReleaseDate
+		case len(album.ReleaseDate) >= 4:
+			if year, err := strconv.Atoi(album.ReleaseDate[:4]); err == nil {
+				releaseYear := int32(year)
+				media.ReleaseYear = &releaseYear
+			}
+		}
+		if album.TotalTracks > 0 {
+			trackCount := int32(album.TotalTracks)
+			media.TrackCount = &trackCount
+		}
+		// The album object embeds the first page of tracks, so the duration
+		// is only known when that page holds all of them
+		if len(album.Tracks.Items) > 0 && len(album.Tracks.Items) == album.Tracks.Total {
+			milliseconds := 0
+			for _, track := range album.Tracks.Items {
+				milliseconds += track.DurationMs
+			}
+			duration := int32(milliseconds / 1000)
+			media.Duration = &duration
+		}
+		if len(album.Images) > 0 {
+			media.CoverURL = &album.Images[0].URL
+		}
+		for _, artist := range album.Artists {
+			creator := &model.CreatorInput{Name: artist.Name, Role: "Artist"}
+			if artist.ID != "" {
+				creator.ExternalIds = []*model.ExternalIDInput{{Source: spotifyArtistSource, Value: artist.ID}}
+			}
+			media.Creators = append(media.Creators, creator)
+		}
+		for _, genre := range album.Genres {
+			media.Tags = append(media.Tags, &model.TagInput{Name: genre, Type: "genre"})
+		}
+
+		entries = append(entries, &LibraryEntry{
+			File:  "me/albums",
+			Line:  i + 1,
+			Media: media,
+			Activity: &db.ActivityImport{
+				ImportKey:  spotifySource + ":album:" + album.ID,
+				StatusID:   db.StatusInProgress,
+				StartedAt:  optionalString(saved.AddedAt),
+				KeepStatus: true,
+			},
+		})
+	}
+	return entries
+}
+
+// randomToken returns n random bytes encoded for use in URLs
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
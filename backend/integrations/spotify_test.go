@@ -0,0 +1,263 @@
+package integrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"nq/db"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// spotifyRepository keeps pending authorizations and one token in memory
+type spotifyRepository struct {
+	tokenRepository
+	states map[string]*db.OAuthState
+}
+
+func (r *spotifyRepository) SaveOAuthState(ctx context.Context, state string, pending *db.OAuthState) error {
+	if r.states == nil {
+		r.states = map[string]*db.OAuthState{}
+	}
+	r.states[state] = pending
+	return nil
+}
+
+func (r *spotifyRepository) TakeOAuthState(ctx context.Context, provider, state string) (*db.OAuthState, error) {
+	pending, ok := r.states[state]
+	if !ok || pending.Provider != provider {
+		return nil, db.NotFoundError("authorization")
+	}
+	delete(r.states, state)
+	return pending, nil
+}
+
+// spotifyStub is a Spotify accounts and Web API server. It grants tokens for
+// the code "code" with the verifier it is told to expect, refreshes the
+// refresh token "refresh", and serves a library of albums to the access
+// tokens it handed out.
+type spotifyStub struct {
+	*httptest.Server
+	verifier string
+	albums   []SpotifySavedAlbum
+	pages    int
+}
+
+func newSpotifyStub(t *testing.T, albums int) *spotifyStub {
+	stub := &spotifyStub{}
+	// Saved one a day, newest first
+	for i := range albums {
+		saved := SpotifySavedAlbum{AddedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -i).Format(time.RFC3339)}
+		saved.Album.ID = fmt.Sprintf("album%d", i)
+		saved.Album.Name = fmt.Sprintf("Album %d", i)
+		stub.albums = append(stub.albums, saved)
+	}
+
+	respond := func(w http.ResponseWriter, status int, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/token":
+			r.ParseForm()
+			if r.Form.Get("client_id") != "client" {
+				respond(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+				return
+			}
+			switch {
+			case r.Form.Get("grant_type") == "authorization_code" && r.Form.Get("code") == "code" &&
+				r.Form.Get("code_verifier") == stub.verifier && r.Form.Get("redirect_uri") == "http://localhost/callback":
+				respond(w, http.StatusOK, TokenResponse{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600, Scope: spotifyScope})
+			case r.Form.Get("grant_type") == "refresh_token" && r.Form.Get("refresh_token") == "refresh":
+				respond(w, http.StatusOK, TokenResponse{AccessToken: "refreshed", ExpiresIn: 3600})
+			default:
+				respond(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			}
+		case "/v1/me/albums":
+			if auth := r.Header.Get("Authorization"); auth != "Bearer access" && auth != "Bearer refreshed" {
+				respond(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"status": 401}})
+				return
+			}
+			stub.pages++
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			end := min(offset+limit, len(stub.albums))
+			respond(w, http.StatusOK, map[string]any{"items": stub.albums[min(offset, end):end], "total": len(stub.albums)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// newTestSpotifyClient creates a Spotify client, without a secret, for a stub
+func newTestSpotifyClient(t *testing.T, stub *spotifyStub) *SpotifyClient {
+	t.Setenv("SPOTIFY_CLIENT_ID", "client")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "")
+	t.Setenv("SPOTIFY_REDIRECT_URI", "http://localhost/callback")
+	t.Setenv("SPOTIFY_ACCOUNTS_BASE_URL", stub.URL+"/")
+	t.Setenv("SPOTIFY_API_BASE_URL", stub.URL)
+
+	client := NewSpotifyClient()
+	client.HTTPClient.Limiter = nil
+	return client
+}
+
+func TestSpotifyAuthorization(t *testing.T) {
+	stub := newSpotifyStub(t, 0)
+	client := newTestSpotifyClient(t, stub)
+	repo := &spotifyRepository{}
+	userID := uuid.New()
+	defer forgetAccessToken(spotifySource, userID)
+
+	authorizeURL, err := client.StartAuthorization(context.Background(), repo, userID)
+	if err != nil {
+		t.Fatalf("StartAuthorization: %v", err)
+	}
+	parsed, err := url.Parse(authorizeURL)
+	if err != nil || parsed.Host != stub.Listener.Addr().String() || parsed.Path != "/authorize" {
+		t.Fatalf("got authorize URL %s", authorizeURL)
+	}
+	query := parsed.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          "http://localhost/callback",
+		"scope":                 spotifyScope,
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+
+	state := query.Get("state")
+	pending, ok := repo.states[state]
+	if !ok || pending.UserID != userID || pending.Provider != spotifySource {
+		t.Fatalf("state %q isn't saved for the user", state)
+	}
+	// RFC 7636 verifiers are 43 to 128 characters and the challenge is their
+	// unpadded base64url SHA-256
+	if n := len(pending.CodeVerifier); n < 43 || n > 128 {
+		t.Errorf("got a verifier of %d characters", n)
+	}
+	sum := sha256.Sum256([]byte(pending.CodeVerifier))
+	if got := query.Get("code_challenge"); got != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Errorf("got challenge %q, want the S256 of the saved verifier", got)
+	}
+
+	again, _ := client.StartAuthorization(context.Background(), repo, userID)
+	againURL, _ := url.Parse(again)
+	if againState := againURL.Query().Get("state"); againState == state || repo.states[againState].CodeVerifier == pending.CodeVerifier {
+		t.Errorf("a second authorization reused the state or verifier")
+	}
+
+	stub.verifier = pending.CodeVerifier
+	connected, err := client.CompleteAuthorization(context.Background(), repo, state, "code")
+	if err != nil || connected != userID {
+		t.Fatalf("CompleteAuthorization = %s, %v", connected, err)
+	}
+	if token := repo.token; token == nil || token.AccessToken != "access" || token.RefreshToken != "refresh" ||
+		token.Provider != spotifySource || time.Until(token.ExpiresAt) < 59*time.Minute {
+		t.Errorf("saved token %+v", repo.token)
+	}
+
+	// The state is spent once used
+	if _, err := client.CompleteAuthorization(context.Background(), repo, state, "code"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("got %v reusing a state, want not found", err)
+	}
+}
+
+func TestSpotifyCompleteAuthorizationRejectedCode(t *testing.T) {
+	stub := newSpotifyStub(t, 0)
+	client := newTestSpotifyClient(t, stub)
+	repo := &spotifyRepository{}
+	repo.SaveOAuthState(context.Background(), "state", &db.OAuthState{UserID: uuid.New(), Provider: spotifySource, CodeVerifier: "verifier"})
+	stub.verifier = "verifier"
+
+	_, err := client.CompleteAuthorization(context.Background(), repo, "state", "used")
+	if !errors.Is(err, db.ErrValidation) {
+		t.Errorf("got %v, want a validation error asking to connect again", err)
+	}
+	if repo.token != nil {
+		t.Errorf("saved a token for a rejected code")
+	}
+}
+
+func TestSpotifySyncPagesSavedAlbums(t *testing.T) {
+	stub := newSpotifyStub(t, spotifyPageSize+10)
+	client := newTestSpotifyClient(t, stub)
+	userID := uuid.New()
+	defer forgetAccessToken(spotifySource, userID)
+	repo := &spotifyRepository{tokenRepository: tokenRepository{token: &db.OAuthToken{
+		Provider: spotifySource, AccessToken: "access", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour),
+	}}}
+	conn := &db.Connection{UserID: userID, Provider: spotifySource}
+
+	result, err := client.Sync(context.Background(), repo, conn, "")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(result.Entries) != spotifyPageSize+10 || stub.pages != 2 {
+		t.Fatalf("got %d albums from %d pages, want %d from 2", len(result.Entries), stub.pages, spotifyPageSize+10)
+	}
+	if last := result.Entries[len(result.Entries)-1]; last.Media.ExternalIds[0].Value != "album59" {
+		t.Errorf("got %s last, want the oldest album", last.Media.ExternalIds[0].Value)
+	}
+	if result.Cursor != "2024-06-01T00:00:00Z" {
+		t.Errorf("got cursor %q, want the newest album's", result.Cursor)
+	}
+
+	// The next sync stops at the cursor on the first page
+	stub.albums = append([]SpotifySavedAlbum{{AddedAt: "2024-06-02T00:00:00Z"}}, stub.albums...)
+	stub.albums[0].Album.ID = "new"
+	stub.pages = 0
+	result, err = client.Sync(context.Background(), repo, conn, result.Cursor)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].Media.ExternalIds[0].Value != "new" || stub.pages != 1 {
+		t.Errorf("got %d albums from %d pages, want only the new one", len(result.Entries), stub.pages)
+	}
+}
+
+func TestSpotifySyncRefreshesTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		token db.OAuthToken
+	}{
+		{"expired", db.OAuthToken{AccessToken: "access", ExpiresAt: time.Now().Add(-time.Minute)}},
+		// Rejected before it expired, e.g. because the user revoked it
+		{"revoked", db.OAuthToken{AccessToken: "revoked", ExpiresAt: time.Now().Add(time.Hour)}},
+	}
+	for _, tt := range tests {
+		stub := newSpotifyStub(t, 1)
+		client := newTestSpotifyClient(t, stub)
+		userID := uuid.New()
+		token := tt.token
+		token.Provider, token.RefreshToken = spotifySource, "refresh"
+		repo := &spotifyRepository{tokenRepository: tokenRepository{token: &token}}
+
+		result, err := client.Sync(context.Background(), repo, &db.Connection{UserID: userID, Provider: spotifySource}, "")
+		forgetAccessToken(spotifySource, userID)
+		if err != nil || len(result.Entries) != 1 {
+			t.Errorf("%s: got %v, want the library read with a refreshed token", tt.name, err)
+			continue
+		}
+		if repo.token.AccessToken != "refreshed" || repo.token.RefreshToken != "refresh" {
+			t.Errorf("%s: saved %+v, want the refreshed token with the old refresh token", tt.name, repo.token)
+		}
+	}
+}
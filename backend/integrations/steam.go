@@ -1 +1,227 @@
 package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSteamBaseURL is the Steam Web API
+const DefaultSteamBaseURL = "https://api.steampowered.com"
+
+// steamSource is the external ID source for Steam app IDs
+const steamSource = "steam"
+
+// steamID matches 64-bit Steam IDs such as 76561197960287930
+var steamID = regexp.MustCompile(`^\d{17}$`)
+
+// steamAPIKey matches Steam Web API keys
+var steamAPIKey = regexp.MustCompile(`^[0-9A-Fa-f]{32}$`)
+
+// SteamClient reads game libraries from the Steam Web API
+type SteamClient struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *HTTPClient
+}
+
+// SteamGame is a game owned by a Steam account
+type SteamGame struct {
+	AppID int64  `json:"appid"`
+	Name  string `json:"name"`
+	// PlaytimeForever is the total playtime in minutes
+	PlaytimeForever int64 `json:"playtime_forever"`
+	// LastPlayed is a Unix timestamp, or 0 if the game was never launched
+	LastPlayed int64 `json:"rtime_last_played"`
+}
+
+// errNoSteamAPIKey is returned when neither the user nor the server has a
+// Steam Web API key
+var errNoSteamAPIKey = db.ValidationFailedError("this server has no Steam Web API key; connect Steam with your own apiKey")
+
+// NewSteamClient creates a Steam client with the server's STEAM_API_KEY, if
+// any, and, to point it at another server such as a local stub,
+// STEAM_API_BASE_URL. Without a server key, only users who connect with
+// their own key can sync.
+func NewSteamClient() *SteamClient {
+	apiKey := os.Getenv("STEAM_API_KEY")
+
+	baseURL := os.Getenv("STEAM_API_BASE_URL")
+	if baseURL == "" {
+		baseURL = DefaultSteamBaseURL
+	}
+
+	return &SteamClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: NewHTTPClient(steamSource),
+	}
+}
+
+// GetOwnedGames lists the games owned by a Steam account with their playtime,
+// using apiKey, or the server's key when it is ""
+func (c *SteamClient) GetOwnedGames(ctx context.Context, apiKey, steamID string) ([]SteamGame, error) {
+	if apiKey == "" {
+		apiKey = c.APIKey
+	}
+	if apiKey == "" {
+		return nil, errNoSteamAPIKey
+	}
+
+	query := url.Values{}
+	query.Set("key", apiKey)
+	query.Set("steamid", steamID)
+	query.Set("include_appinfo", "1")
+	query.Set("include_played_free_games", "1")
+	query.Set("format", "json")
+
+	var body struct {
+		Response struct {
+			GameCount *int        `json:"game_count"`
+			Games     []SteamGame `json:"games"`
+		} `json:"response"`
+	}
+	err := c.HTTPClient.GetJSON(ctx, c.BaseURL+"/IPlayerService/GetOwnedGames/v1/?"+query.Encode(), nil, &body)
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden) {
+		return nil, fmt.Errorf("steam: API key was rejected: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Private profiles get an empty response rather than an error
+	if body.Response.GameCount == nil {
+		return nil, db.ValidationFailedError("Steam returned no library for %s; the profile's game details must be public", steamID)
+	}
+
+	return body.Response.Games, nil
+}
+
+// Name is the provider name of Steam connections
+func (c *SteamClient) Name() string {
+	return steamSource
+}
+
+// AuthKind is ACCOUNT_ID: libraries are read with our API key from public
+// profiles, so users only give their SteamID64
+func (c *SteamClient) AuthKind() model.IntegrationAuthKind {
+	return model.IntegrationAuthKindAccountID
+}
+
+// Connect checks the SteamID64 a user connects with. Users can also give
+// their own Web API key, which can read their library even when their
+// profile's game details are private, and must when the server has none.
+func (c *SteamClient) Connect(ctx context.Context, repo db.Repository, userID uuid.UUID, input ConnectInput) (*ConnectResult, error) {
+	if !steamID.MatchString(input.Account) {
+		return nil, db.ValidationFailedError("account must be a 17-digit SteamID64")
+	}
+
+	if input.APIKey == "" {
+		if c.APIKey == "" {
+			if _, err := repo.GetCredential(ctx, userID, steamSource, db.CredentialAPIKey); errors.Is(err, db.ErrNotFound) {
+				return nil, errNoSteamAPIKey
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return &ConnectResult{Account: input.Account}, nil
+	}
+
+	if !steamAPIKey.MatchString(input.APIKey) {
+		return nil, db.ValidationFailedError("apiKey must be a 32-character Steam Web API key")
+	}
+	if err := repo.SaveCredential(ctx, userID, steamSource, db.CredentialAPIKey, input.APIKey); err != nil {
+		return nil, err
+	}
+	return &ConnectResult{Account: input.Account}, nil
+}
+
+// Sync reads the games the connected account owns. Played games become In
+// Progress activities with their playtime as progress, and games that were
+// never launched become Planned. The cursor is the latest time a game was
+// played, so incremental syncs only send games played since. Steam doesn't
+// say when a game was bought, so games never launched are only sent by full
+// syncs; the rest show up once they are played.
+func (c *SteamClient) Sync(ctx context.Context, repo db.Repository, conn *db.Connection, cursor string) (*SyncResult, error) {
+	since, _ := strconv.ParseInt(cursor, 10, 64)
+
+	apiKey, err := repo.GetCredential(ctx, conn.UserID, steamSource, db.CredentialAPIKey)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+
+	games, err := c.GetOwnedGames(ctx, apiKey, conn.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := since
+	changed := make([]SteamGame, 0, len(games))
+	for _, game := range games {
+		if game.LastPlayed > since || (cursor == "" && game.LastPlayed == 0) {
+			changed = append(changed, game)
+		}
+		latest = max(latest, game.LastPlayed)
+	}
+
+	return &SyncResult{
+		Entries: SteamLibraryEntries(changed),
+		Cursor:  strconv.FormatInt(latest, 10),
+	}, nil
+}
+
+// Disconnect does nothing; Steam holds nothing for the user
+func (c *SteamClient) Disconnect(ctx context.Context, repo db.Repository, userID uuid.UUID) error {
+	return nil
+}
+
+// SteamLibraryEntries turns owned games into library entries
+func SteamLibraryEntries(games []SteamGame) []*LibraryEntry {
+	entries := make([]*LibraryEntry, 0, len(games))
+	for i, game := range games {
+		appID := strconv.FormatInt(game.AppID, 10)
+		coverURL := "https://cdn.cloudflare.steamstatic.com/steam/apps/" + appID + "/header.jpg"
+		storeURL := "https://store.steampowered.com"
+
+		title := strings.TrimSpace(game.Name)
+		if title == "" {
+			title = "Steam app " + appID
+		}
+
+		activity := &db.ActivityImport{
+			ImportKey:  steamSource + ":app:" + appID,
+			StatusID:   db.StatusPlanned,
+			KeepStatus: true,
+		}
+		if game.PlaytimeForever > 0 || game.LastPlayed > 0 {
+			playtime := float64(game.PlaytimeForever)
+			unit := "minutes"
+			activity.StatusID = db.StatusInProgress
+			activity.Progress = &playtime
+			activity.ProgressUnit = &unit
+		}
+
+		entries = append(entries, &LibraryEntry{
+			File: "GetOwnedGames",
+			Line: i + 1,
+			Media: &model.MediaImportInput{
+				Type:        model.MediaTypeGame,
+				Title:       title,
+				CoverURL:    &coverURL,
+				ExternalIds: []*model.ExternalIDInput{{Source: steamSource, Value: appID}},
+				Platforms:   []*model.PlatformInput{{Name: "Steam", BaseURL: &storeURL}},
+			},
+			Activity: activity,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,183 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"nq/db"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testSteamID     = "76561197960287930"
+	testSteamAPIKey = "0123456789ABCDEF0123456789ABCDEF"
+)
+
+// credentialRepository holds users' credentials in memory. Other repository
+// methods aren't used by Steam and panic.
+type credentialRepository struct {
+	db.Repository
+	credentials map[string]string
+}
+
+func (r *credentialRepository) GetCredential(ctx context.Context, userID uuid.UUID, provider, name string) (string, error) {
+	secret, ok := r.credentials[userID.String()+":"+provider+":"+name]
+	if !ok {
+		return "", db.NotFoundError("credential")
+	}
+	return secret, nil
+}
+
+func (r *credentialRepository) SaveCredential(ctx context.Context, userID uuid.UUID, provider, name, secret string) error {
+	r.credentials[userID.String()+":"+provider+":"+name] = secret
+	return nil
+}
+
+// newSteamStub serves a library of a game played at 1000, one played at
+// 2000 and one never launched, and records the API keys it was asked with
+func newSteamStub(t *testing.T) (*httptest.Server, *[]string) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/IPlayerService/GetOwnedGames/v1/" || r.URL.Query().Get("steamid") != testSteamID {
+			http.NotFound(w, r)
+			return
+		}
+		keys = append(keys, r.URL.Query().Get("key"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"response":{"game_count":3,"games":[
+			{"appid":400,"name":"Portal","playtime_forever":120,"rtime_last_played":1000},
+			{"appid":620,"name":"Portal 2","playtime_forever":600,"rtime_last_played":2000},
+			{"appid":220,"name":"Half-Life 2","playtime_forever":0,"rtime_last_played":0}
+		]}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &keys
+}
+
+// newTestSteamClient creates a Steam client for a stub server
+func newTestSteamClient(t *testing.T, apiKey string) (*SteamClient, *[]string) {
+	t.Setenv("STEAM_API_KEY", apiKey)
+	server, keys := newSteamStub(t)
+	t.Setenv("STEAM_API_BASE_URL", server.URL)
+
+	client := NewSteamClient()
+	client.HTTPClient.Limiter = nil
+	return client, keys
+}
+
+// syncedAppIDs returns the Steam app IDs of a sync's entries, sorted
+func syncedAppIDs(result *SyncResult) []string {
+	var ids []string
+	for _, entry := range result.Entries {
+		ids = append(ids, entry.Media.ExternalIds[0].Value)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSteamSyncSendsUnplayedGamesOnlyOnFullSync(t *testing.T) {
+	client, _ := newTestSteamClient(t, testSteamAPIKey)
+	repo := &credentialRepository{credentials: map[string]string{}}
+	conn := &db.Connection{UserID: uuid.New(), Provider: steamSource, Account: testSteamID}
+
+	full, err := client.Sync(context.Background(), repo, conn, "")
+	if err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	if got, want := syncedAppIDs(full), []string{"220", "400", "620"}; !slices.Equal(got, want) {
+		t.Errorf("full sync sent %v, want %v", got, want)
+	}
+	if full.Cursor != "2000" {
+		t.Errorf("got cursor %q, want 2000", full.Cursor)
+	}
+	for _, entry := range full.Entries {
+		want := db.StatusInProgress
+		if entry.Media.ExternalIds[0].Value == "220" {
+			want = db.StatusPlanned
+		}
+		if entry.Activity.StatusID != want {
+			t.Errorf("%s has status %d, want %d", entry.Media.Title, entry.Activity.StatusID, want)
+		}
+	}
+
+	incremental, err := client.Sync(context.Background(), repo, conn, "1500")
+	if err != nil {
+		t.Fatalf("incremental sync: %v", err)
+	}
+	if got, want := syncedAppIDs(incremental), []string{"620"}; !slices.Equal(got, want) {
+		t.Errorf("incremental sync sent %v, want only the game played since", got)
+	}
+
+	unchanged, err := client.Sync(context.Background(), repo, conn, full.Cursor)
+	if err != nil {
+		t.Fatalf("incremental sync: %v", err)
+	}
+	if len(unchanged.Entries) != 0 || unchanged.Cursor != full.Cursor {
+		t.Errorf("sync with nothing played sent %v with cursor %q", syncedAppIDs(unchanged), unchanged.Cursor)
+	}
+}
+
+func TestSteamWithoutServerKeyUsesUserKey(t *testing.T) {
+	client, keys := newTestSteamClient(t, "")
+	repo := &credentialRepository{credentials: map[string]string{}}
+	userID := uuid.New()
+
+	_, err := client.Connect(context.Background(), repo, userID, ConnectInput{Account: testSteamID})
+	if !errors.Is(err, db.ErrValidation) {
+		t.Fatalf("connecting without any key: got %v, want a validation error", err)
+	}
+	_, err = client.Sync(context.Background(), repo, &db.Connection{UserID: userID, Account: testSteamID}, "")
+	if !errors.Is(err, db.ErrValidation) || len(*keys) != 0 {
+		t.Fatalf("syncing without any key: got %v after %d requests, want a validation error before any", err, len(*keys))
+	}
+
+	if _, err := client.Connect(context.Background(), repo, userID, ConnectInput{Account: testSteamID, APIKey: testSteamAPIKey}); err != nil {
+		t.Fatalf("connecting with a key: %v", err)
+	}
+	result, err := client.Sync(context.Background(), repo, &db.Connection{UserID: userID, Account: testSteamID}, "")
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(result.Entries) != 3 || len(*keys) != 1 || (*keys)[0] != testSteamAPIKey {
+		t.Errorf("got %d entries with keys %v, want the user's key", len(result.Entries), *keys)
+	}
+
+	// Reconnecting keeps the stored key
+	if _, err := client.Connect(context.Background(), repo, userID, ConnectInput{Account: testSteamID}); err != nil {
+		t.Errorf("reconnecting with a stored key: %v", err)
+	}
+}
+
+func TestNewRegistryFromEnvAlwaysHasSteam(t *testing.T) {
+	t.Setenv("STEAM_API_KEY", "")
+	if _, err := NewRegistryFromEnv().Get("steam"); err != nil {
+		t.Errorf("Steam isn't registered without a server key: %v", err)
+	}
+}
+
+func TestSteamRejectedKey(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := &SteamClient{BaseURL: server.URL, APIKey: testSteamAPIKey, HTTPClient: newTestClient(steamSource)}
+	_, err := client.GetOwnedGames(context.Background(), "", testSteamID)
+	if !errors.Is(err, ErrForbidden) || !strings.Contains(err.Error(), "API key was rejected") {
+		t.Errorf("got %v, want the key reported as rejected", err)
+	}
+	if strings.Contains(err.Error(), testSteamAPIKey) {
+		t.Errorf("error leaks the key: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d requests, want 1", calls.Load())
+	}
+}
@@ -0,0 +1,316 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// takeoutEntry is one item of a Google Takeout watch-history.json file. The
+// HTML version of the file is read into the same shape.
+type takeoutEntry struct {
+	Header    string            `json:"header"`
+	Title     string            `json:"title"`
+	TitleURL  string            `json:"titleUrl"`
+	Subtitles []takeoutLinkText `json:"subtitles"`
+	Time      string            `json:"time"`
+	Details   []takeoutLinkText `json:"details"`
+	// line is the entry's position in the file, starting at 1
+	line int
+	// file is the name of the history file the entry came from
+	file string
+	// unknownZone is the zone abbreviation of an HTML entry's time when it
+	// isn't one of takeoutZones, and its time was read as UTC
+	unknownZone string
+}
+
+// takeoutLinkText is a named link, such as the channel of a watched video
+type takeoutLinkText struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// takeoutHistoryFiles are the watch history file names, preferring JSON when
+// an archive has both
+var takeoutHistoryFiles = []string{"watch-history.json", "watch-history.html"}
+
+// Patterns for the HTML history, which has no stable structure beyond these
+// classes
+var (
+	takeoutCell    = regexp.MustCompile(`<div class="outer-cell`)
+	takeoutHeader  = regexp.MustCompile(`(?s)<p class="mdl-typography--title">(.*?)<br`)
+	takeoutContent = regexp.MustCompile(`(?s)<div class="content-cell[^"]*mdl-typography--body-1">(.*?)</div>`)
+	takeoutLink    = regexp.MustCompile(`(?s)<a href="([^"]*)">(.*?)</a>`)
+	takeoutTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	// takeoutZoneOffsetPattern matches zones given as offsets, e.g. GMT+05:30
+	takeoutZoneOffsetPattern = regexp.MustCompile(`^(?:GMT|UTC)([+-])(\d{1,2})(?::(\d{2}))?$`)
+)
+
+// takeoutTimeLayouts are the English date formats of the HTML history,
+// which end in a zone abbreviation
+var takeoutTimeLayouts = []string{
+	"Jan 2, 2006, 3:04:05 PM",
+	"2 Jan 2006, 15:04:05",
+}
+
+// takeoutZones are the UTC offsets, in hours, of the zone abbreviations the
+// HTML history writes times in. Abbreviations that name more than one zone,
+// such as IST or CST, are left out.
+var takeoutZones = map[string]float64{
+	"UTC": 0, "GMT": 0, "WET": 0, "WEST": 1, "BST": 1,
+	"CET": 1, "CEST": 2, "EET": 2, "EEST": 3, "MSK": 3,
+	"HKT": 8, "SGT": 8, "AWST": 8, "JST": 9, "KST": 9,
+	"ACST": 9.5, "ACDT": 10.5, "AEST": 10, "AEDT": 11, "NZST": 12, "NZDT": 13,
+	"HST": -10, "AKST": -9, "AKDT": -8, "PST": -8, "PDT": -7, "MST": -7, "MDT": -6,
+	"CDT": -5, "EST": -5, "EDT": -4,
+}
+
+// readTakeoutHistory reads the watch history from a Takeout ZIP archive, a
+// watch-history.json file or a watch-history.html file
+func readTakeoutHistory(data []byte) ([]*takeoutEntry, error) {
+	if archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		return readTakeoutArchive(archive)
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return parseTakeoutJSON("watch-history.json", trimmed)
+	case bytes.Contains(trimmed, []byte(`class="outer-cell`)):
+		return parseTakeoutHTML("watch-history.html", string(trimmed)), nil
+	}
+
+	return nil, db.ValidationFailedError("file is not a Takeout archive or watch-history.json or .html file")
+}
+
+// readTakeoutArchive reads the watch history file from a Takeout archive
+func readTakeoutArchive(archive *zip.Reader) ([]*takeoutEntry, error) {
+	for _, name := range takeoutHistoryFiles {
+		file := findArchiveFile(archive, name)
+		if file == nil {
+			continue
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return nil, db.ValidationFailedError("can't read %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, db.ValidationFailedError("can't read %s: %v", file.Name, err)
+		}
+
+		if strings.HasSuffix(name, ".json") {
+			return parseTakeoutJSON(name, content)
+		}
+		return parseTakeoutHTML(name, string(content)), nil
+	}
+
+	return nil, db.ValidationFailedError("archive has no %s; is it a YouTube Takeout export?", strings.Join(takeoutHistoryFiles, " or "))
+}
+
+// findArchiveFile returns the file with the given name anywhere in a Takeout
+// archive, or nil. The folder names are localized, so files are found by
+// their name alone.
+func findArchiveFile(archive *zip.Reader, name string) *zip.File {
+	for _, file := range archive.File {
+		if path.Base(file.Name) == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// parseTakeoutJSON reads a watch-history.json file
+func parseTakeoutJSON(file string, data []byte) ([]*takeoutEntry, error) {
+	var entries []*takeoutEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, db.ValidationFailedError("can't read %s: %v", file, err)
+	}
+	for i, entry := range entries {
+		entry.file = file
+		entry.line = i + 1
+	}
+	return entries, nil
+}
+
+// parseTakeoutHTML reads a watch-history.html file. Each entry is an
+// outer-cell holding a header, a content cell with the title link, channel
+// link and time, and a caption cell whose details mark ads.
+func parseTakeoutHTML(file, content string) []*takeoutEntry {
+	var entries []*takeoutEntry
+	cells := takeoutCell.Split(content, -1)
+	for i, cell := range cells[1:] {
+		entry := &takeoutEntry{file: file, line: i + 1}
+		if match := takeoutHeader.FindStringSubmatch(cell); match != nil {
+			entry.Header = takeoutText(match[1])
+		}
+
+		body := takeoutContent.FindStringSubmatch(cell)
+		if body == nil {
+			continue
+		}
+		links := takeoutLink.FindAllStringSubmatch(body[1], -1)
+		if len(links) > 0 {
+			entry.TitleURL = html.UnescapeString(links[0][1])
+			// Keep the verb so titles read the same as in the JSON file
+			entry.Title = takeoutText(body[1][:strings.Index(body[1], "<a ")]) + " " + takeoutText(links[0][2])
+		} else {
+			entry.Title = takeoutText(body[1])
+		}
+		if len(links) > 1 {
+			entry.Subtitles = append(entry.Subtitles, takeoutLinkText{Name: takeoutText(links[1][2]), URL: html.UnescapeString(links[1][1])})
+		}
+
+		// The time is the last line of the content cell
+		lines := strings.Split(body[1], "<br>")
+		for j := len(lines) - 1; j >= 0; j-- {
+			if at, unknownZone := parseTakeoutTime(takeoutText(lines[j])); at != "" {
+				entry.Time, entry.unknownZone = at, unknownZone
+				break
+			}
+		}
+
+		if strings.Contains(cell, "From Google Ads") {
+			entry.Details = append(entry.Details, takeoutLinkText{Name: "From Google Ads"})
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// takeoutText strips tags and entities from an HTML fragment
+func takeoutText(fragment string) string {
+	text := html.UnescapeString(takeoutTag.ReplaceAllString(fragment, ""))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// parseTakeoutTime parses a time from the HTML history into RFC3339, or
+// returns "" if it isn't a time. A time in a zone it doesn't know is read as
+// UTC, and may be off by hours, so the zone is returned with it.
+func parseTakeoutTime(value string) (at string, unknownZone string) {
+	i := strings.LastIndex(value, " ")
+	if i < 0 {
+		return "", ""
+	}
+	local, zone := value[:i], value[i+1:]
+	for _, layout := range takeoutTimeLayouts {
+		parsed, err := time.Parse(layout, local)
+		if err != nil {
+			continue
+		}
+		offset, ok := takeoutZoneOffset(zone)
+		if !ok {
+			unknownZone = zone
+		}
+		return parsed.Add(-offset).Format(time.RFC3339), unknownZone
+	}
+	return "", ""
+}
+
+// takeoutZoneOffset returns the UTC offset of a zone abbreviation from
+// takeoutZones, or of an offset such as GMT+05:30
+func takeoutZoneOffset(zone string) (time.Duration, bool) {
+	if hours, ok := takeoutZones[zone]; ok {
+		return time.Duration(hours * float64(time.Hour)), true
+	}
+	match := takeoutZoneOffsetPattern.FindStringSubmatch(zone)
+	if match == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	offset := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if match[1] == "-" {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// takeoutZoneWarnings reports each zone abbreviation of the HTML history
+// that times were read as UTC in, once per file, at its first entry
+func takeoutZoneWarnings(history []*takeoutEntry) []*model.UnmatchedRow {
+	var warnings []*model.UnmatchedRow
+	seen := map[string]bool{}
+	for _, entry := range history {
+		if entry.unknownZone == "" || seen[entry.file+" "+entry.unknownZone] {
+			continue
+		}
+		seen[entry.file+" "+entry.unknownZone] = true
+		warnings = append(warnings, &model.UnmatchedRow{
+			File: entry.file,
+			Line: int32(entry.line),
+			Reason: fmt.Sprintf("times in the %s zone were read as UTC and may be off by hours; "+
+				"export the history as JSON for exact times", entry.unknownZone),
+		})
+	}
+	return warnings
+}
+
+// isAd reports whether the entry is an ad shown rather than a video the user
+// chose to watch
+func (e *takeoutEntry) isAd() bool {
+	for _, detail := range e.Details {
+		if detail.Name == "From Google Ads" {
+			return true
+		}
+	}
+	return false
+}
+
+// videoID returns the ID of the watched video, or "" for entries that aren't
+// a watched video, such as removed videos, searches and visited posts
+func (e *takeoutEntry) videoID() string {
+	link, err := url.Parse(e.TitleURL)
+	if err != nil {
+		return ""
+	}
+	if link.Host == "youtu.be" {
+		return strings.TrimPrefix(link.Path, "/")
+	}
+	if link.Path != "/watch" {
+		return ""
+	}
+	return link.Query().Get("v")
+}
+
+// title returns the video title without the leading "Watched". Removed and
+// private videos are listed under their URL instead of a title, and "" is
+// returned for them.
+func (e *takeoutEntry) title() string {
+	title := strings.TrimSpace(strings.TrimPrefix(e.Title, "Watched "))
+	if title == "" || title == e.TitleURL || strings.HasPrefix(title, "https://") {
+		return ""
+	}
+	return title
+}
+
+// channel returns the name of the video's channel, or "" if it is not listed
+func (e *takeoutEntry) channel() string {
+	if len(e.Subtitles) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(e.Subtitles[0].Name)
+}
+
+// watchedAt returns the time of the entry, or the zero time if it has none
+func (e *takeoutEntry) watchedAt() time.Time {
+	at, err := time.Parse(time.RFC3339Nano, e.Time)
+	if err != nil {
+		return time.Time{}
+	}
+	return at.UTC()
+}
@@ -0,0 +1,66 @@
+package integrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTakeoutTime(t *testing.T) {
+	tests := []struct {
+		value       string
+		at          string
+		unknownZone string
+	}{
+		{"Mar 1, 2024, 8:00:00 PM UTC", "2024-03-01T20:00:00Z", ""},
+		{"Mar 1, 2024, 8:00:00 PM CET", "2024-03-01T19:00:00Z", ""},
+		{"1 Jul 2024, 20:00:00 CEST", "2024-07-01T18:00:00Z", ""},
+		{"Mar 1, 2024, 8:00:00 PM PST", "2024-03-02T04:00:00Z", ""},
+		{"1 Mar 2024, 20:00:00 GMT+05:30", "2024-03-01T14:30:00Z", ""},
+		{"1 Mar 2024, 20:00:00 GMT-3", "2024-03-01T23:00:00Z", ""},
+		// IST is Indian, Irish or Israel Standard Time
+		{"1 Mar 2024, 20:00:00 IST", "2024-03-01T20:00:00Z", "IST"},
+		{"Some Channel", "", ""},
+	}
+	for _, tt := range tests {
+		at, unknownZone := parseTakeoutTime(tt.value)
+		if at != tt.at || unknownZone != tt.unknownZone {
+			t.Errorf("parseTakeoutTime(%q) = %q, %q, want %q, %q", tt.value, at, unknownZone, tt.at, tt.unknownZone)
+		}
+	}
+}
+
+// takeoutHTMLCell is a watched video in a watch-history.html file
+func takeoutHTMLCell(id, title, at string) string {
+	return `<div class="outer-cell mdl-cell mdl-cell--12-col mdl-shadow--2dp"><div class="mdl-grid">` +
+		`<div class="header-cell mdl-cell mdl-cell--12-col"><p class="mdl-typography--title">YouTube<br></p></div>` +
+		`<div class="content-cell mdl-cell mdl-cell--6-col mdl-typography--body-1">Watched&nbsp;` +
+		`<a href="https://www.youtube.com/watch?v=` + id + `">` + title + `</a><br>` +
+		`<a href="https://www.youtube.com/channel/UC1">A Channel</a><br>` + at + `<br></div></div></div>`
+}
+
+func TestParseYouTubeWarnsAboutUnknownZones(t *testing.T) {
+	history := `<html><body><div class="mdl-grid">` +
+		takeoutHTMLCell("a", "First", "Mar 1, 2024, 8:00:00 PM CET") +
+		takeoutHTMLCell("b", "Second", "1 Mar 2024, 20:00:00 IST") +
+		takeoutHTMLCell("c", "Third", "2 Mar 2024, 20:00:00 IST") +
+		`</div></body></html>`
+
+	entries, unmatched, err := ParseYouTube([]byte(history))
+	if err != nil {
+		t.Fatalf("ParseYouTube: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want every video kept", len(entries))
+	}
+	if got := *entries[0].Activity.StartedAt; got != "2024-03-01T19:00:00Z" {
+		t.Errorf("got %s for a CET time, want it converted to UTC", got)
+	}
+
+	if len(unmatched) != 1 {
+		t.Fatalf("got %d warnings, want one for IST", len(unmatched))
+	}
+	warning := unmatched[0]
+	if warning.File != "watch-history.html" || warning.Line != 2 || !strings.Contains(warning.Reason, "IST") || !strings.Contains(warning.Reason, "JSON") {
+		t.Errorf("got %+v", warning)
+	}
+}
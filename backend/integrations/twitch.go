@@ -1 +1,575 @@
 package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nq/db"
+	"nq/graph/model"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Default Twitch endpoints, overridable to test against a stub server
+const (
+	DefaultTwitchAuthURL = "https://id.twitch.tv"
+	DefaultTwitchAPIURL  = "https://api.twitch.tv/helix"
+)
+
+// Twitch external ID sources. Channel, game and video IDs are all numeric, so
+// each gets its own source.
+const (
+	twitchSource        = "twitch"
+	twitchChannelSource = "twitch-channel"
+	twitchGameSource    = "twitch-game"
+)
+
+// twitchScope is the access we ask users for
+const twitchScope = "user:read:follows"
+
+// twitchPageSize is the largest page Helix endpoints return
+const twitchPageSize = 100
+
+// twitchVODsPerChannel is how many recent past broadcasts are imported per
+// followed channel
+const twitchVODsPerChannel = 10
+
+// twitchStreamerRole is the role of creators imported from Twitch channels
+const twitchStreamerRole = "Streamer"
+
+// twitchNonGameCategories are Twitch categories that aren't games, so aren't
+// imported as Game media
+var twitchNonGameCategories = map[string]bool{
+	"Just Chatting":                 true,
+	"Music":                         true,
+	"Art":                           true,
+	"Talk Shows & Podcasts":         true,
+	"Sports":                        true,
+	"Travel & Outdoors":             true,
+	"Food & Drink":                  true,
+	"Science & Technology":          true,
+	"Software and Game Development": true,
+	"Makers & Crafting":             true,
+	"Fitness & Health":              true,
+	"Politics":                      true,
+	"ASMR":                          true,
+	"Special Events":                true,
+	"Pools, Hot Tubs, and Beaches":  true,
+	"I'm Only Sleeping":             true,
+}
+
+// errTwitchDisabled is returned by a nil client, when Twitch isn't configured
+var errTwitchDisabled = db.ValidationFailedError("Twitch import is not enabled on this server")
+
+// TwitchClient connects user accounts with the authorization code flow and
+// reads their follows from the Helix API
+type TwitchClient struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	AuthURL      string
+	APIURL       string
+	HTTPClient   *HTTPClient
+	// IncludeVODs imports each followed channel's recent past broadcasts as
+	// streams
+	IncludeVODs bool
+}
+
+// TwitchFollow is a channel a user follows
+type TwitchFollow struct {
+	BroadcasterID    string `json:"broadcaster_id"`
+	BroadcasterLogin string `json:"broadcaster_login"`
+	BroadcasterName  string `json:"broadcaster_name"`
+	FollowedAt       string `json:"followed_at"`
+}
+
+// TwitchChannel is a channel's current settings, including the game it is
+// streaming or last streamed
+type TwitchChannel struct {
+	BroadcasterID string `json:"broadcaster_id"`
+	GameID        string `json:"game_id"`
+	GameName      string `json:"game_name"`
+}
+
+// TwitchVideo is a past broadcast of a channel
+type TwitchVideo struct {
+	ID           string `json:"id"`
+	UserID       string `json:"user_id"`
+	UserName     string `json:"user_name"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CreatedAt    string `json:"created_at"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	// Duration is formatted like 3h8m33s
+	Duration string `json:"duration"`
+}
+
+// NewTwitchClient creates a Twitch client from TWITCH_CLIENT_ID,
+// TWITCH_CLIENT_SECRET and TWITCH_REDIRECT_URI. TWITCH_AUTH_BASE_URL and
+// TWITCH_API_BASE_URL point it at other servers. It returns nil when any of
+// the three settings is missing.
+func NewTwitchClient() *TwitchClient {
+	clientID := os.Getenv("TWITCH_CLIENT_ID")
+	clientSecret := os.Getenv("TWITCH_CLIENT_SECRET")
+	redirectURI := os.Getenv("TWITCH_REDIRECT_URI")
+	if clientID == "" || clientSecret == "" || redirectURI == "" {
+		return nil
+	}
+
+	authURL := os.Getenv("TWITCH_AUTH_BASE_URL")
+	if authURL == "" {
+		authURL = DefaultTwitchAuthURL
+	}
+	apiURL := os.Getenv("TWITCH_API_BASE_URL")
+	if apiURL == "" {
+		apiURL = DefaultTwitchAPIURL
+	}
+
+	return &TwitchClient{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		AuthURL:      strings.TrimSuffix(authURL, "/"),
+		APIURL:       strings.TrimSuffix(apiURL, "/"),
+		HTTPClient:   NewHTTPClient(twitchSource),
+	}
+}
+
+// StartAuthorization begins connecting a user's Twitch account and returns
+// the URL to send the user to
+func (c *TwitchClient) StartAuthorization(ctx context.Context, repo db.Repository, userID uuid.UUID) (string, error) {
+	if c == nil {
+		return "", errTwitchDisabled
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	pending := &db.OAuthState{
+		UserID:   userID,
+		Provider: twitchSource,
+	}
+	if err := repo.SaveOAuthState(ctx, state, pending); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.ClientID)
+	query.Set("scope", twitchScope)
+	query.Set("redirect_uri", c.RedirectURI)
+	query.Set("state", state)
+
+	return c.AuthURL + "/oauth2/authorize?" + query.Encode(), nil
+}
+
+// CompleteAuthorization exchanges the code Twitch redirected back with for
+// tokens and stores them for the user who started the authorization
+func (c *TwitchClient) CompleteAuthorization(ctx context.Context, repo db.Repository, state, code string) (uuid.UUID, error) {
+	if c == nil {
+		return uuid.Nil, errTwitchDisabled
+	}
+
+	pending, err := repo.TakeOAuthState(ctx, twitchSource, state)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.RedirectURI)
+
+	token, err := c.requestToken(ctx, form)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := repo.SaveOAuthToken(ctx, pending.UserID, token); err != nil {
+		return uuid.Nil, err
+	}
+
+	return pending.UserID, nil
+}
+
+// accessToken returns a usable access token for the user, refreshing the
+// stored one when it is about to expire or is the rejected token
+func (c *TwitchClient) accessToken(ctx context.Context, repo db.Repository, userID uuid.UUID, rejected string) (string, error) {
+	return oauthAccessToken(ctx, repo, userID, twitchSource, rejected, func(ctx context.Context, refreshToken string) (*db.OAuthToken, error) {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+		return c.requestToken(ctx, form)
+	})
+}
+
+// requestToken calls the token endpoint with a code or refresh token grant
+func (c *TwitchClient) requestToken(ctx context.Context, form url.Values) (*db.OAuthToken, error) {
+	form.Set("client_id", c.ClientID)
+	form.Set("client_secret", c.ClientSecret)
+
+	// Twitch returns the scope as a list rather than a space-separated string
+	var body struct {
+		AccessToken  string   `json:"access_token"`
+		RefreshToken string   `json:"refresh_token"`
+		ExpiresIn    int      `json:"expires_in"`
+		Scope        []string `json:"scope"`
+	}
+	err := c.HTTPClient.PostForm(ctx, c.AuthURL+"/oauth2/token", nil, form, &body)
+	if errors.Is(err, ErrBadRequest) || errors.Is(err, ErrUnauthorized) {
+		return nil, db.ValidationFailedError("Twitch rejected the authorization; connect Twitch again")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.OAuthToken{
+		Provider:     twitchSource,
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+		Scope:        strings.Join(body.Scope, " "),
+	}, nil
+}
+
+// GetUserID returns the Twitch user ID of the token's owner
+func (c *TwitchClient) GetUserID(ctx context.Context, accessToken string) (string, error) {
+	var page struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, accessToken, "/users", &page); err != nil {
+		return "", err
+	}
+	if len(page.Data) == 0 {
+		return "", fmt.Errorf("twitch: /users returned no user for the token")
+	}
+	return page.Data[0].ID, nil
+}
+
+// GetFollowedChannels lists the channels a user follows, most recently
+// followed first. With since set, it stops at the first channel followed at
+// or before it.
+func (c *TwitchClient) GetFollowedChannels(ctx context.Context, accessToken, twitchUserID, since string) ([]TwitchFollow, error) {
+	var follows []TwitchFollow
+	cursor := ""
+	for {
+		query := url.Values{}
+		query.Set("user_id", twitchUserID)
+		query.Set("first", strconv.Itoa(twitchPageSize))
+		if cursor != "" {
+			query.Set("after", cursor)
+		}
+
+		var page struct {
+			Data       []TwitchFollow `json:"data"`
+			Pagination struct {
+				Cursor string `json:"cursor"`
+			} `json:"pagination"`
+		}
+		if err := c.get(ctx, accessToken, "/channels/followed?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+
+		for _, follow := range page.Data {
+			if since != "" && !timestampBefore(since, follow.FollowedAt) {
+				return follows, nil
+			}
+			follows = append(follows, follow)
+		}
+		cursor = page.Pagination.Cursor
+		if cursor == "" || len(page.Data) == 0 {
+			return follows, nil
+		}
+	}
+}
+
+// GetChannels returns the current settings of channels, keyed by broadcaster
+// ID
+func (c *TwitchClient) GetChannels(ctx context.Context, accessToken string, broadcasterIDs []string) (map[string]TwitchChannel, error) {
+	channels := make(map[string]TwitchChannel, len(broadcasterIDs))
+	for start := 0; start < len(broadcasterIDs); start += twitchPageSize {
+		query := url.Values{}
+		for _, id := range broadcasterIDs[start:min(start+twitchPageSize, len(broadcasterIDs))] {
+			query.Add("broadcaster_id", id)
+		}
+
+		var page struct {
+			Data []TwitchChannel `json:"data"`
+		}
+		if err := c.get(ctx, accessToken, "/channels?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, channel := range page.Data {
+			channels[channel.BroadcasterID] = channel
+		}
+	}
+	return channels, nil
+}
+
+// GetVideos lists a channel's most recent past broadcasts
+func (c *TwitchClient) GetVideos(ctx context.Context, accessToken, broadcasterID string, limit int) ([]TwitchVideo, error) {
+	query := url.Values{}
+	query.Set("user_id", broadcasterID)
+	query.Set("type", "archive")
+	query.Set("first", strconv.Itoa(limit))
+
+	var page struct {
+		Data []TwitchVideo `json:"data"`
+	}
+	if err := c.get(ctx, accessToken, "/videos?"+query.Encode(), &page); err != nil {
+		return nil, err
+	}
+	return page.Data, nil
+}
+
+// get calls a Helix endpoint and decodes its JSON response into out
+func (c *TwitchClient) get(ctx context.Context, accessToken, path string, out any) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+accessToken)
+	header.Set("Client-Id", c.ClientID)
+	return c.HTTPClient.GetJSON(ctx, c.APIURL+path, header, out)
+}
+
+// twitchLibrary is what a sync reads from Twitch
+type twitchLibrary struct {
+	follows  []TwitchFollow
+	channels map[string]TwitchChannel
+	videos   []TwitchVideo
+}
+
+// fetchLibrary reads the channels the user followed since a time, their
+// settings and, with IncludeVODs, their recent past broadcasts
+func (c *TwitchClient) fetchLibrary(ctx context.Context, accessToken, since string) (*twitchLibrary, error) {
+	twitchUserID, err := c.GetUserID(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := c.GetFollowedChannels(ctx, accessToken, twitchUserID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(follows))
+	for i, follow := range follows {
+		ids[i] = follow.BroadcasterID
+	}
+	channels, err := c.GetChannels(ctx, accessToken, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	library := &twitchLibrary{follows: follows, channels: channels}
+	if c.IncludeVODs {
+		for _, id := range ids {
+			videos, err := c.GetVideos(ctx, accessToken, id, twitchVODsPerChannel)
+			if err != nil {
+				return nil, err
+			}
+			library.videos = append(library.videos, videos...)
+		}
+	}
+	return library, nil
+}
+
+// Name is the provider name of Twitch connections and tokens
+func (c *TwitchClient) Name() string {
+	return twitchSource
+}
+
+// AuthKind is OAUTH
+func (c *TwitchClient) AuthKind() model.IntegrationAuthKind {
+	return model.IntegrationAuthKindOauth
+}
+
+// Connect starts authorizing access to the user's Twitch follows
+func (c *TwitchClient) Connect(ctx context.Context, repo db.Repository, userID uuid.UUID, input ConnectInput) (*ConnectResult, error) {
+	authorizeURL, err := c.StartAuthorization(ctx, repo, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &ConnectResult{AuthorizeURL: authorizeURL}, nil
+}
+
+// Sync reads the channels the user follows on Twitch as creators with the
+// Streamer role, linked to the games they stream. With IncludeVODs, each
+// channel's recent past broadcasts become Stream media. Helix has no watch
+// history, so streams get no activities. The cursor is when the newest
+// channel was followed, so incremental syncs only read channels followed
+// since.
+func (c *TwitchClient) Sync(ctx context.Context, repo db.Repository, conn *db.Connection, cursor string) (*SyncResult, error) {
+	accessToken, err := c.accessToken(ctx, repo, conn.UserID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	library, err := c.fetchLibrary(ctx, accessToken, cursor)
+	if errors.Is(err, ErrUnauthorized) {
+		// The token was revoked or expired early; refresh once and retry
+		if accessToken, err = c.accessToken(ctx, repo, conn.UserID, accessToken); err != nil {
+			return nil, err
+		}
+		library, err = c.fetchLibrary(ctx, accessToken, cursor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, follow := range library.follows {
+		cursor = laterTimestamp(cursor, follow.FollowedAt)
+	}
+
+	// The follows are written after the games and streams, so they can link
+	// the channels to both
+	return &SyncResult{
+		Entries: append(TwitchGameEntries(library.channels), TwitchStreamEntries(library.videos)...),
+		Follows: TwitchFollowImports(library.follows, library.channels, library.videos),
+		Cursor:  cursor,
+	}, nil
+}
+
+// Disconnect revokes the user's access token, so it can't be used even if
+// it leaks later. Users who never completed connecting have no token.
+func (c *TwitchClient) Disconnect(ctx context.Context, repo db.Repository, userID uuid.UUID) error {
+	token, err := repo.GetOAuthToken(ctx, userID, twitchSource)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("client_id", c.ClientID)
+	form.Set("token", token.AccessToken)
+
+	// Twitch answers 400 for tokens that are already invalid
+	err = c.HTTPClient.PostForm(ctx, c.AuthURL+"/oauth2/revoke", nil, form, nil)
+	if err != nil && !errors.Is(err, ErrBadRequest) {
+		return err
+	}
+	return nil
+}
+
+// TwitchGameEntries turns the games channels stream into library entries.
+// Categories that aren't games, such as Just Chatting, are left out.
+func TwitchGameEntries(channels map[string]TwitchChannel) []*LibraryEntry {
+	// Sort the channels so re-imports list games in the same order
+	ids := make([]string, 0, len(channels))
+	for id := range channels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var entries []*LibraryEntry
+	games := make(map[string]bool)
+	for _, id := range ids {
+		channel := channels[id]
+		name := strings.TrimSpace(channel.GameName)
+		if channel.GameID == "" || name == "" || games[channel.GameID] || twitchNonGameCategories[name] {
+			continue
+		}
+		games[channel.GameID] = true
+
+		entries = append(entries, &LibraryEntry{
+			File: "channels",
+			Line: len(entries) + 1,
+			Media: &model.MediaImportInput{
+				Type:        model.MediaTypeGame,
+				Title:       name,
+				ExternalIds: []*model.ExternalIDInput{{Source: twitchGameSource, Value: channel.GameID}},
+			},
+		})
+	}
+
+	return entries
+}
+
+// TwitchStreamEntries turns past broadcasts into library entries. Their
+// streamers are linked by the follows, which match channels by ID rather than
+// name.
+func TwitchStreamEntries(videos []TwitchVideo) []*LibraryEntry {
+	siteURL := "https://www.twitch.tv"
+
+	entries := make([]*LibraryEntry, 0, len(videos))
+	for i, video := range videos {
+		media := &model.MediaImportInput{
+			Type:        model.MediaTypeStream,
+			Title:       strings.TrimSpace(video.Title),
+			Description: optionalString(video.Description),
+			URL:         optionalString(video.URL),
+			ExternalIds: []*model.ExternalIDInput{{Source: twitchSource, Value: video.ID}},
+			Platforms:   []*model.PlatformInput{{Name: "Twitch", BaseURL: &siteURL}},
+		}
+		if media.Title == "" {
+			media.Title = video.UserName + " broadcast " + video.ID
+		}
+		if len(video.CreatedAt) >= len(time.DateOnly) {
+			releaseDate := video.CreatedAt[:len(time.DateOnly)]
+			media.ReleaseDate = &releaseDate
+		}
+		if duration, err := time.ParseDuration(video.Duration); err == nil {
+			seconds := int32(duration / time.Second)
+			media.Duration = &seconds
+		}
+		if video.ThumbnailURL != "" {
+			coverURL := strings.NewReplacer("%{width}", "640", "%{height}", "360").Replace(video.ThumbnailURL)
+			media.CoverURL = &coverURL
+		}
+
+		entries = append(entries, &LibraryEntry{
+			File:  "videos",
+			Line:  i + 1,
+			Media: media,
+		})
+	}
+
+	return entries
+}
+
+// TwitchFollowImports turns followed channels into follows, linked to the
+// game each channel streams and to its past broadcasts
+func TwitchFollowImports(follows []TwitchFollow, channels map[string]TwitchChannel, videos []TwitchVideo) []*db.FollowImport {
+	videoIDs := make(map[string][]string)
+	for _, video := range videos {
+		videoIDs[video.UserID] = append(videoIDs[video.UserID], video.ID)
+	}
+
+	imports := make([]*db.FollowImport, 0, len(follows))
+	for _, follow := range follows {
+		name := follow.BroadcasterName
+		if name == "" {
+			name = follow.BroadcasterLogin
+		}
+
+		followImport := &db.FollowImport{
+			Source:     twitchChannelSource,
+			ExternalID: follow.BroadcasterID,
+			Name:       name,
+			Role:       twitchStreamerRole,
+			FollowedAt: optionalString(follow.FollowedAt),
+		}
+		if ids := videoIDs[follow.BroadcasterID]; len(ids) > 0 {
+			followImport.MediaSource = twitchSource
+			followImport.MediaIDs = ids
+		}
+		if channel, ok := channels[follow.BroadcasterID]; ok && channel.GameID != "" {
+			followImport.GameSource = twitchGameSource
+			followImport.GameID = channel.GameID
+		}
+		imports = append(imports, followImport)
+	}
+	return imports
+}
@@ -0,0 +1,144 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nq/db"
+	"nq/graph/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTwitchStub serves Helix for a user following channel 100, streaming
+// Portal, and, more recently, channel 200, streaming Just Chatting, with one
+// past broadcast on channel 100
+func newTwitchStub(t *testing.T) *httptest.Server {
+	respond := func(w http.ResponseWriter, data any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" || r.Header.Get("Client-Id") != "client" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users":
+			respond(w, []map[string]string{{"id": "1"}})
+		case "/channels/followed":
+			respond(w, []TwitchFollow{
+				{BroadcasterID: "200", BroadcasterLogin: "chatter", FollowedAt: "2024-05-02T10:00:00Z"},
+				{BroadcasterID: "100", BroadcasterLogin: "speedrunner", BroadcasterName: "Speedrunner", FollowedAt: "2024-05-01T10:00:00Z"},
+			})
+		case "/channels":
+			var channels []TwitchChannel
+			for _, id := range r.URL.Query()["broadcaster_id"] {
+				switch id {
+				case "100":
+					channels = append(channels, TwitchChannel{BroadcasterID: id, GameID: "10", GameName: "Portal"})
+				case "200":
+					channels = append(channels, TwitchChannel{BroadcasterID: id, GameID: "509658", GameName: "Just Chatting"})
+				}
+			}
+			respond(w, channels)
+		case "/videos":
+			var videos []TwitchVideo
+			if r.URL.Query().Get("user_id") == "100" {
+				videos = append(videos, TwitchVideo{ID: "9001", UserID: "100", UserName: "Speedrunner", Title: "Any%", Duration: "1h2m3s"})
+			}
+			respond(w, videos)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestTwitchClient creates a Twitch client for a stub server
+func newTestTwitchClient(t *testing.T) *TwitchClient {
+	t.Setenv("TWITCH_CLIENT_ID", "client")
+	t.Setenv("TWITCH_CLIENT_SECRET", "secret")
+	t.Setenv("TWITCH_REDIRECT_URI", "http://localhost/callback")
+	t.Setenv("TWITCH_API_BASE_URL", newTwitchStub(t).URL)
+
+	client := NewTwitchClient()
+	client.HTTPClient.Limiter = nil
+	client.IncludeVODs = true
+	return client
+}
+
+func TestTwitchSyncImportsStreamers(t *testing.T) {
+	if !db.IsCreatorRole(twitchStreamerRole) {
+		t.Fatalf("%s isn't a seeded creator role", twitchStreamerRole)
+	}
+
+	client := newTestTwitchClient(t)
+	userID := uuid.New()
+	defer forgetAccessToken(twitchSource, userID)
+	repo := &tokenRepository{token: &db.OAuthToken{Provider: twitchSource, AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)}}
+	conn := &db.Connection{UserID: userID, Provider: twitchSource}
+
+	result, err := client.Sync(context.Background(), repo, conn, "")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if result.Cursor != "2024-05-02T10:00:00Z" {
+		t.Errorf("got cursor %q, want the latest follow", result.Cursor)
+	}
+
+	if len(result.Follows) != 2 {
+		t.Fatalf("got %d follows, want 2", len(result.Follows))
+	}
+	for _, follow := range result.Follows {
+		if follow.Role != twitchStreamerRole || follow.Source != twitchChannelSource {
+			t.Errorf("got %+v, want a Streamer matched on its channel ID", follow)
+		}
+	}
+	speedrunner := result.Follows[1]
+	if speedrunner.Name != "Speedrunner" || speedrunner.GameSource != twitchGameSource || speedrunner.GameID != "10" {
+		t.Errorf("got %+v, want Speedrunner linked to Portal", speedrunner)
+	}
+	if speedrunner.MediaSource != twitchSource || len(speedrunner.MediaIDs) != 1 || speedrunner.MediaIDs[0] != "9001" {
+		t.Errorf("got %+v, want Speedrunner credited on the broadcast", speedrunner)
+	}
+	if result.Follows[0].Name != "chatter" {
+		t.Errorf("got name %q, want the login when there is no display name", result.Follows[0].Name)
+	}
+
+	// Portal is a game and the broadcast a stream; Just Chatting is neither
+	var games, streams int
+	for _, entry := range result.Entries {
+		switch entry.Media.Type {
+		case model.MediaTypeGame:
+			games++
+			if entry.Media.Title != "Portal" {
+				t.Errorf("imported %q as a game", entry.Media.Title)
+			}
+		case model.MediaTypeStream:
+			streams++
+		}
+	}
+	if games != 1 || streams != 1 {
+		t.Errorf("got %d games and %d streams, want 1 and 1", games, streams)
+	}
+}
+
+func TestTwitchIncrementalSyncStopsAtCursor(t *testing.T) {
+	client := newTestTwitchClient(t)
+	userID := uuid.New()
+	defer forgetAccessToken(twitchSource, userID)
+	repo := &tokenRepository{token: &db.OAuthToken{Provider: twitchSource, AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)}}
+
+	result, err := client.Sync(context.Background(), repo, &db.Connection{UserID: userID, Provider: twitchSource}, "2024-05-01T10:00:00Z")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(result.Follows) != 1 || result.Follows[0].ExternalID != "200" {
+		t.Errorf("got %d follows, want only the channel followed since", len(result.Follows))
+	}
+}
@@ -1 +1,110 @@
 package integrations
+
+import (
+	"nq/db"
+	"nq/graph/model"
+	"time"
+)
+
+// youtubeSource is the external ID source for YouTube video IDs
+const youtubeSource = "youtube"
+
+// youtubeMusicHeader marks YouTube Music plays in the watch history, which
+// are imported as listening history instead
+const youtubeMusicHeader = "YouTube Music"
+
+// youtubeVideo collects the views of one video across the watch history
+type youtubeVideo struct {
+	entry *takeoutEntry
+	title string
+	views int32
+	first time.Time
+	last  time.Time
+}
+
+// ParseYouTube reads a Google Takeout YouTube watch history, either the
+// Takeout ZIP or the watch-history.json or .html file, into library entries.
+// Repeated views of a video collapse into one Completed activity with the
+// view count and the first and last view times. Ads, removed videos and
+// YouTube Music plays are skipped. Times of the HTML history in zones it
+// can't tell apart are reported as unmatched rows, without skipping their
+// videos.
+func ParseYouTube(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	history, err := readTakeoutHistory(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var order []string
+	videos := make(map[string]*youtubeVideo)
+	for _, entry := range history {
+		if entry.Header == youtubeMusicHeader || entry.isAd() {
+			continue
+		}
+		id := entry.videoID()
+		title := entry.title()
+		if id == "" || title == "" {
+			continue
+		}
+
+		video, ok := videos[id]
+		if !ok {
+			video = &youtubeVideo{entry: entry, title: title}
+			videos[id] = video
+			order = append(order, id)
+		}
+		video.views++
+		if at := entry.watchedAt(); !at.IsZero() {
+			if video.first.IsZero() || at.Before(video.first) {
+				video.first = at
+			}
+			if at.After(video.last) {
+				video.last = at
+			}
+		}
+	}
+
+	entries := make([]*LibraryEntry, 0, len(order))
+	for _, id := range order {
+		video := videos[id]
+		videoURL := "https://www.youtube.com/watch?v=" + id
+		coverURL := "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+		siteURL := "https://www.youtube.com"
+
+		media := &model.MediaImportInput{
+			Type:        model.MediaTypeVideo,
+			Title:       video.title,
+			URL:         &videoURL,
+			CoverURL:    &coverURL,
+			ExternalIds: []*model.ExternalIDInput{{Source: youtubeSource, Value: id}},
+			Platforms:   []*model.PlatformInput{{Name: "YouTube", BaseURL: &siteURL}},
+		}
+		if channel := video.entry.channel(); channel != "" {
+			media.Creators = append(media.Creators, &model.CreatorInput{Name: channel, Role: "Channel"})
+		}
+
+		views := video.views
+		rewatch := views > 1
+		activity := &db.ActivityImport{
+			ImportKey: youtubeSource + ":video:" + id,
+			StatusID:  db.StatusCompleted,
+			Count:     &views,
+			Rewatch:   &rewatch,
+		}
+		if !video.first.IsZero() {
+			startedAt := video.first.Format(time.RFC3339)
+			finishedAt := video.last.Format(time.RFC3339)
+			activity.StartedAt = &startedAt
+			activity.FinishedAt = &finishedAt
+		}
+
+		entries = append(entries, &LibraryEntry{
+			File:     video.entry.file,
+			Line:     video.entry.line,
+			Media:    media,
+			Activity: activity,
+		})
+	}
+
+	return entries, takeoutZoneWarnings(history), nil
+}
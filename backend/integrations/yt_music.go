@@ -1 +1,193 @@
 package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"nq/db"
+	"nq/graph/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// youtubeMusicSource prefixes the import keys of YouTube Music history
+const youtubeMusicSource = "ytmusic"
+
+// youtubeMusicSongsFile is the Takeout file listing the songs in the user's
+// YouTube Music library with their album and artists
+const youtubeMusicSongsFile = "music library songs.csv"
+
+// youtubeMusicSong is a song from the library songs file
+type youtubeMusicSong struct {
+	title   string
+	album   string
+	artists []string
+}
+
+// youtubeMusicAlbum collects the plays of an album's tracks
+type youtubeMusicAlbum struct {
+	entry   *LibraryEntry
+	plays   int32
+	firstAt time.Time
+}
+
+// ParseYouTubeMusic reads the YouTube Music plays from a Google Takeout ZIP
+// into one library entry per album, carrying a listen for every play and an
+// In Progress activity with the album's total play count. The watch history
+// only names the song and artist, so albums come from the library songs file
+// of the same archive; plays of songs missing from it are returned as
+// unmatched, once per song.
+func ParseYouTubeMusic(data []byte) ([]*LibraryEntry, []*model.UnmatchedRow, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, db.ValidationFailedError("file is not a ZIP archive; YouTube Music needs the whole Takeout export, which names each song's album")
+	}
+
+	history, err := readTakeoutArchive(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	songs, err := readYouTubeMusicSongs(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var order []string
+	albums := make(map[string]*youtubeMusicAlbum)
+	var unmatched []*model.UnmatchedRow
+	reported := make(map[string]bool)
+	for _, play := range history {
+		if play.Header != youtubeMusicHeader || play.isAd() {
+			continue
+		}
+		id := play.videoID()
+		if id == "" || play.title() == "" {
+			continue
+		}
+
+		song, ok := songs[id]
+		if !ok {
+			if !reported[id] {
+				reported[id] = true
+				unmatched = append(unmatched, &model.UnmatchedRow{
+					File:   play.file,
+					Line:   int32(play.line),
+					Title:  optionalString(play.title()),
+					Reason: "song is not in " + youtubeMusicSongsFile + ", so its album is unknown",
+				})
+			}
+			continue
+		}
+
+		key := youtubeMusicAlbumKey(song)
+		album, ok := albums[key]
+		if !ok {
+			album = &youtubeMusicAlbum{entry: youtubeMusicAlbumEntry(play, song, key)}
+			albums[key] = album
+			order = append(order, key)
+		}
+
+		playedAt := play.watchedAt()
+		if playedAt.IsZero() {
+			continue
+		}
+		album.plays++
+		if album.firstAt.IsZero() || playedAt.Before(album.firstAt) {
+			album.firstAt = playedAt
+		}
+		album.entry.Listens = append(album.entry.Listens, &db.ListenImport{
+			ImportKey: youtubeMusicSource + ":" + id + ":" + strconv.FormatInt(playedAt.Unix(), 10),
+			Track:     song.title,
+			PlayedAt:  playedAt.Format(time.RFC3339),
+		})
+	}
+
+	entries := make([]*LibraryEntry, 0, len(order))
+	for _, key := range order {
+		album := albums[key]
+		plays := album.plays
+		album.entry.Activity.Count = &plays
+		if !album.firstAt.IsZero() {
+			startedAt := album.firstAt.Format(time.RFC3339)
+			album.entry.Activity.StartedAt = &startedAt
+		}
+		entries = append(entries, album.entry)
+	}
+
+	return entries, unmatched, nil
+}
+
+// readYouTubeMusicSongs reads the library songs file, keyed by video ID
+func readYouTubeMusicSongs(archive *zip.Reader) (map[string]*youtubeMusicSong, error) {
+	songs := make(map[string]*youtubeMusicSong)
+	file := findArchiveFile(archive, youtubeMusicSongsFile)
+	if file == nil {
+		return nil, db.ValidationFailedError("archive has no %s; include YouTube Music in the Takeout export", youtubeMusicSongsFile)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, db.ValidationFailedError("can't read %s: %v", file.Name, err)
+	}
+	defer f.Close()
+
+	rows, err := readCSV(f)
+	if err != nil {
+		return nil, db.ValidationFailedError("can't read %s: %v", file.Name, err)
+	}
+
+	for _, row := range rows {
+		id := row.get("Video ID")
+		title := row.get("Song Title")
+		album := row.get("Album Title")
+		if id == "" || title == "" || album == "" {
+			continue
+		}
+
+		song := &youtubeMusicSong{title: title, album: album}
+		// Songs list up to four artists as Artist Name 1, Artist Name 2...
+		for i := 1; row.has("Artist Name " + strconv.Itoa(i)); i++ {
+			if artist := row.get("Artist Name " + strconv.Itoa(i)); artist != "" {
+				song.artists = append(song.artists, artist)
+			}
+		}
+		songs[id] = song
+	}
+
+	return songs, nil
+}
+
+// youtubeMusicAlbumKey identifies an album by its title and main artist,
+// since the export has no album IDs
+func youtubeMusicAlbumKey(song *youtubeMusicSong) string {
+	key := strings.ToLower(song.album)
+	if len(song.artists) > 0 {
+		key += ":" + strings.ToLower(song.artists[0])
+	}
+	return key
+}
+
+// youtubeMusicAlbumEntry creates the library entry for the album of song
+func youtubeMusicAlbumEntry(play *takeoutEntry, song *youtubeMusicSong, key string) *LibraryEntry {
+	siteURL := "https://music.youtube.com"
+	media := &model.MediaImportInput{
+		Type:      model.MediaTypeMusicAlbum,
+		Title:     song.album,
+		Platforms: []*model.PlatformInput{{Name: "YouTube Music", BaseURL: &siteURL}},
+	}
+	for _, artist := range song.artists {
+		media.Creators = append(media.Creators, &model.CreatorInput{Name: artist, Role: "Artist"})
+	}
+
+	return &LibraryEntry{
+		File:  play.file,
+		Line:  play.line,
+		Media: media,
+		Activity: &db.ActivityImport{
+			ImportKey:  youtubeMusicSource + ":album:" + key,
+			StatusID:   db.StatusInProgress,
+			KeepStatus: true,
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"nq/db"
+	"slices"
+	"testing"
+)
+
+// takeoutArchive builds a Takeout ZIP holding files under their localized
+// folder names
+func takeoutArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create("Takeout/YouTube and YouTube Music/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const youtubeMusicHistory = `[
+	{"header": "YouTube Music", "title": "Watched Track One", "titleUrl": "https://music.youtube.com/watch?v=s1", "time": "2024-03-02T10:00:00.5Z"},
+	{"header": "YouTube Music", "title": "Watched Track Two", "titleUrl": "https://music.youtube.com/watch?v=s2", "time": "2024-03-01T09:00:00Z"},
+	{"header": "YouTube", "title": "Watched A Video", "titleUrl": "https://www.youtube.com/watch?v=v1", "time": "2024-03-01T08:00:00Z"},
+	{"header": "YouTube Music", "title": "Watched An Ad", "titleUrl": "https://music.youtube.com/watch?v=ad", "time": "2024-03-01T08:00:00Z", "details": [{"name": "From Google Ads"}]},
+	{"header": "YouTube Music", "title": "Watched Unknown Song", "titleUrl": "https://music.youtube.com/watch?v=s3", "time": "2024-03-01T07:00:00Z"},
+	{"header": "YouTube Music", "title": "Watched Unknown Song", "titleUrl": "https://music.youtube.com/watch?v=s3", "time": "2024-03-01T06:00:00Z"},
+	{"header": "YouTube Music", "title": "Watched Track One", "titleUrl": "https://music.youtube.com/watch?v=s1", "time": "2024-03-03T10:00:00Z"},
+	{"header": "YouTube Music", "title": "Watched Track Four", "titleUrl": "https://music.youtube.com/watch?v=s4"},
+	{"header": "YouTube Music", "title": "Watched Track Five", "titleUrl": "https://music.youtube.com/watch?v=s5", "time": "2024-03-01T05:00:00Z"},
+	{"header": "YouTube Music", "title": "Watched a video that has been removed"}
+]`
+
+// youtubeMusicSongs lists two songs of Album One, whose artist is written
+// differently, a song of Album Two, one without an album and a row cut short
+const youtubeMusicSongs = "Video ID,Song Title,Album Title,Artist Name 1,Artist Name 2\n" +
+	"s1,Track One,Album One,Artist,Guest\n" +
+	"s2,Track Two,album one,ARTIST,\n" +
+	"s4,Track Four,Album Two,Other,\n" +
+	"s5,Track Five,,Other,\n" +
+	"s6\n"
+
+func TestParseYouTubeMusic(t *testing.T) {
+	data := takeoutArchive(t, map[string]string{
+		"history/watch-history.json":                          youtubeMusicHistory,
+		"music (library and uploads)/music library songs.csv": youtubeMusicSongs,
+	})
+
+	entries, unmatched, err := ParseYouTubeMusic(data)
+	if err != nil {
+		t.Fatalf("ParseYouTubeMusic: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d albums, want 2", len(entries))
+	}
+	albumOne := entries[0]
+	if albumOne.Media.Title != "Album One" || len(albumOne.Media.Creators) != 2 || albumOne.Media.Creators[1].Name != "Guest" {
+		t.Errorf("got %+v, want Album One by Artist and Guest", albumOne.Media)
+	}
+	if *albumOne.Activity.Count != 3 || *albumOne.Activity.StartedAt != "2024-03-01T09:00:00Z" || albumOne.Activity.StatusID != db.StatusInProgress {
+		t.Errorf("got activity %+v, want 3 plays started at the first", albumOne.Activity)
+	}
+	var tracks []string
+	for _, listen := range albumOne.Listens {
+		tracks = append(tracks, listen.Track+" "+listen.PlayedAt)
+	}
+	want := []string{"Track One 2024-03-02T10:00:00Z", "Track Two 2024-03-01T09:00:00Z", "Track One 2024-03-03T10:00:00Z"}
+	if !slices.Equal(tracks, want) {
+		t.Errorf("got listens %v, want %v", tracks, want)
+	}
+
+	// A play without a time still puts the album in the library
+	albumTwo := entries[1]
+	if albumTwo.Media.Title != "Album Two" || *albumTwo.Activity.Count != 0 || albumTwo.Activity.StartedAt != nil || len(albumTwo.Listens) != 0 {
+		t.Errorf("got %+v, want Album Two without plays", albumTwo.Activity)
+	}
+
+	if len(unmatched) != 2 {
+		t.Fatalf("got %d unmatched, want the unknown song once and the song without an album", len(unmatched))
+	}
+	if *unmatched[0].Title != "Unknown Song" || unmatched[0].Line != 5 || *unmatched[1].Title != "Track Five" {
+		t.Errorf("got unmatched %+v and %+v", unmatched[0], unmatched[1])
+	}
+}
+
+func TestParseYouTubeMusicNeedsWholeArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"history file alone", []byte(youtubeMusicHistory)},
+		{"no songs file", takeoutArchive(t, map[string]string{"history/watch-history.json": youtubeMusicHistory})},
+		{"no history", takeoutArchive(t, map[string]string{"music (library and uploads)/music library songs.csv": youtubeMusicSongs})},
+		{"malformed history", takeoutArchive(t, map[string]string{
+			"history/watch-history.json":                          `[{"header": "YouTube Music",`,
+			"music (library and uploads)/music library songs.csv": youtubeMusicSongs,
+		})},
+	}
+	for _, tt := range tests {
+		if _, _, err := ParseYouTubeMusic(tt.data); !errors.Is(err, db.ErrValidation) {
+			t.Errorf("%s: got %v, want a validation error", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package isbn validates International Standard Book Numbers
+package isbn
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Clean strips the hyphens and spaces commonly used to group ISBN digits
+// and upper-cases a trailing ISBN-10 check character
+func Clean(s string) string {
+	s = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s))
+	return strings.ToUpper(s)
+}
+
+// Valid reports whether s is a well-formed ISBN-10 or ISBN-13 with a
+// correct check digit. Hyphens and spaces are ignored.
+func Valid(s string) bool {
+	s = Clean(s)
+	switch len(s) {
+	case 10:
+		return valid10(s)
+	case 13:
+		return valid13(s)
+	}
+	return false
+}
+
+// valid10 checks the mod 11 checksum, where 'X' stands for ten in the last position
+func valid10(s string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var digit int
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			digit = int(s[i] - '0')
+		case s[i] == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+		sum += digit * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// valid13 checks the EAN-13 checksum with alternating weights of 1 and 3
+func valid13(s string) bool {
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		digit := int(s[i] - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// To13 returns the ISBN-13 form of a valid ISBN-10 or ISBN-13, without
+// hyphens. It reports false for invalid ISBNs.
+func To13(s string) (string, bool) {
+	s = Clean(s)
+	if !Valid(s) {
+		return "", false
+	}
+	if len(s) == 13 {
+		return s, true
+	}
+
+	body := "978" + s[:9]
+	sum := 0
+	for i := 0; i < 12; i++ {
+		digit := int(body[i] - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return body + strconv.Itoa((10-sum%10)%10), true
+}
+
+// To10 returns the ISBN-10 form of a valid ISBN-10 or ISBN-13, without
+// hyphens. It reports false for invalid ISBNs and for ISBN-13s with the 979
+// prefix, which have no ISBN-10 form.
+func To10(s string) (string, bool) {
+	s = Clean(s)
+	if !Valid(s) {
+		return "", false
+	}
+	if len(s) == 10 {
+		return s, true
+	}
+	if !strings.HasPrefix(s, "978") {
+		return "", false
+	}
+
+	body := s[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", true
+	}
+	return body + strconv.Itoa(check), true
+}
@@ -0,0 +1,76 @@
+package isbn
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{"0306406152", true},
+		{"0-306-40615-2", true},
+		{"978-0-306-40615-7", true},
+		{"978 0 306 40615 7", true},
+		{" 9780306406157 ", true},
+		{"080442957X", true},
+		{"0-8044-2957-x", true},
+		{"979-10-90636-07-1", true},
+		{"0306406153", false},
+		{"9780306406158", false},
+		{"0804429571", false},
+		// X only stands for ten as the check character
+		{"X804429570", false},
+		{"978030640615X", false},
+		{"030640615", false},
+		{"97803064061577", false},
+		{"03064O6152", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.isbn); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestTo13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		ok   bool
+	}{
+		{"0-306-40615-2", "9780306406157", true},
+		{"080442957x", "9780804429573", true},
+		{"1-86197-271-7", "9781861972712", true},
+		{"978-0-306-40615-7", "9780306406157", true},
+		{"979-10-90636-07-1", "9791090636071", true},
+		{"0-306-40615-3", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := To13(tt.isbn); got != tt.want || ok != tt.ok {
+			t.Errorf("To13(%q) = %q, %v, want %q, %v", tt.isbn, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		ok   bool
+	}{
+		{"978-0-306-40615-7", "0306406152", true},
+		// A check digit of ten is written X
+		{"9780804429573", "080442957X", true},
+		{"978-1-86197-271-2", "1861972717", true},
+		{"0-8044-2957-x", "080442957X", true},
+		// ISBNs starting 979 have no ISBN-10 form
+		{"979-10-90636-07-1", "", false},
+		{"9780306406158", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := To10(tt.isbn); got != tt.want || ok != tt.ok {
+			t.Errorf("To10(%q) = %q, %v, want %q, %v", tt.isbn, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the named schedules ParseCron accepts
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit bounds how far ahead Next looks for a matching minute
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// Cron is a parsed cron schedule. Times are matched in UTC.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a day field starting with "*". When both
+	// day fields are restricted, a day matching either one matches, as in
+	// cron.
+	domAny, dowAny bool
+}
+
+// cronField is the range of one field of a schedule
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a five-field cron schedule: minute, hour, day of month,
+// month and day of week, each "*", a value, a range such as "1-5" or a list
+// of those, optionally with a step such as "*/15". Day of week 0 and 7 are
+// Sunday. The macros @hourly, @daily, @weekly, @monthly and @yearly are
+// accepted too.
+func ParseCron(spec string) (*Cron, error) {
+	expanded := strings.TrimSpace(spec)
+	if macro, ok := cronMacros[strings.ToLower(expanded)]; ok {
+		expanded = macro
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron schedule %q must have 5 fields", spec)
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron schedule %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	cron := &Cron{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	if cron.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron schedule %q never runs", spec)
+	}
+	return cron, nil
+}
+
+// parseCronField parses one field into a bit set of the values it matches
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, bounds.name)
+			}
+		}
+
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", bounds.name, rangePart)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return 0, fmt.Errorf("invalid %s %q", bounds.name, rangePart)
+				}
+			} else if hasStep {
+				// "5/15" means from 5 to the end in steps of 15
+				high = bounds.max
+			}
+		}
+
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%s %q is out of range %d-%d", bounds.name, rangePart, bounds.min, bounds.max)
+		}
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the schedule matches, or the zero time
+// if it doesn't match in the next five years
+func (c *Cron) Next(t time.Time) time.Time {
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := next.Add(cronSearchLimit)
+
+	for next.Before(limit) {
+		switch {
+		case c.month&(1<<int(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<next.Hour()) == 0:
+			next = next.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<next.Minute()) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches checks both day fields against t's date
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
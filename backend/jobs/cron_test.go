@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.DateTime, value)
+		if err != nil {
+			t.Fatalf("bad test time %s: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		spec  string
+		after string
+		want  string
+	}{
+		{"* * * * *", "2024-01-01 10:00:30", "2024-01-01 10:01:00"},
+		{"*/15 * * * *", "2024-01-01 10:01:00", "2024-01-01 10:15:00"},
+		{"*/15 * * * *", "2024-01-01 10:45:00", "2024-01-01 11:00:00"},
+		{"5/20 * * * *", "2024-01-01 10:30:00", "2024-01-01 10:45:00"},
+		{"0 9-17/4 * * *", "2024-01-01 13:00:00", "2024-01-01 17:00:00"},
+		{"0 9-17/4 * * *", "2024-01-01 17:00:00", "2024-01-02 09:00:00"},
+		{"0 8,12,18 * * *", "2024-01-01 12:00:00", "2024-01-01 18:00:00"},
+		{"30 2 * * 1-5", "2024-01-05 03:00:00", "2024-01-08 02:30:00"},
+		// Sunday is both 0 and 7
+		{"0 0 * * 0", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"0 0 * * 7", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"@weekly", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		// With both day fields restricted either one matches: the 15th, or
+		// any Friday
+		{"0 0 15 * 5", "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"0 0 15 * 5", "2024-01-12 00:00:00", "2024-01-15 00:00:00"},
+		// With one of them "*" both must match
+		{"0 0 15 * *", "2024-01-01 00:00:00", "2024-01-15 00:00:00"},
+		// "*/10" starts with "*", so days must be both the 1st, 11th, 21st
+		// or 31st and a Friday
+		{"0 0 */10 * 5", "2024-01-01 00:00:00", "2024-03-01 00:00:00"},
+		{"0 0 * * 5", "2024-01-06 00:00:00", "2024-01-12 00:00:00"},
+		// Rolling over months and years
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"@monthly", "2024-12-31 23:59:00", "2025-01-01 00:00:00"},
+		{"@yearly", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		{"59 23 31 12 *", "2024-12-31 23:59:00", "2025-12-31 23:59:00"},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := cron.Next(at(tt.after)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s: got %s, want %s", tt.spec, tt.after, got.Format(time.DateTime), tt.want)
+		}
+	}
+}
+
+func TestCronNextIsUTC(t *testing.T) {
+	cron, err := ParseCron("@daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	got := cron.Next(time.Date(2024, 1, 1, 8, 0, 0, 0, tokyo))
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %s, want midnight UTC", got)
+	}
+}
+
+func TestParseCronRejects(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@often",
+		// February never has 30 days
+		"0 0 30 2 *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", spec)
+		}
+	}
+}
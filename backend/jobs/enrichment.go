@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"nq/db"
+	"nq/enrichment"
+
+	"github.com/google/uuid"
+)
+
+// EnrichMediaKind fills in a media item's metadata from metadata services
+const EnrichMediaKind = "media.enrich"
+
+// EnrichMediaPayload is the payload of an EnrichMediaKind job
+type EnrichMediaPayload struct {
+	MediaID uuid.UUID `json:"mediaId"`
+}
+
+// RegisterEnrichmentJobs adds the handler that enriches media with pipeline
+func RegisterEnrichmentJobs(s *Scheduler, pipeline *enrichment.Pipeline) error {
+	s.Handle(EnrichMediaKind, func(ctx context.Context, job *db.Job) error {
+		var payload EnrichMediaPayload
+		if err := DecodePayload(job, &payload); err != nil {
+			return err
+		}
+
+		result, err := pipeline.Enrich(ctx, s.repo, payload.MediaID)
+		if err != nil {
+			return err
+		}
+		if result.Source != nil {
+			log.Printf("jobs: enriched media %s from %s: %d fields", payload.MediaID, *result.Source, len(result.Fields))
+		}
+		return nil
+	})
+	return nil
+}
+
+// EnqueueEnrichMedia queues enrichment of a media item, unless it is already
+// queued
+func EnqueueEnrichMedia(ctx context.Context, s *Scheduler, mediaID uuid.UUID) (*db.Job, error) {
+	return s.Enqueue(ctx, EnrichMediaKind, EnrichMediaKey(mediaID), EnrichMediaPayload{MediaID: mediaID})
+}
+
+// EnrichMediaKey deduplicates enrichment of a media item
+func EnrichMediaKey(mediaID uuid.UUID) string {
+	return EnrichMediaKind + ":" + mediaID.String()
+}
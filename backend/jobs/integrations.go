@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"nq/db"
+	"nq/integrations"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of integration jobs
+const (
+	// SyncIntegrationKind syncs one user's connection
+	SyncIntegrationKind = "integration.sync"
+	// SyncIntegrationsKind enqueues a sync of every connection of every
+	// configured provider
+	SyncIntegrationsKind = "integration.sync-all"
+)
+
+// DefaultSyncSchedule syncs connected integrations every six hours
+const DefaultSyncSchedule = "0 */6 * * *"
+
+// SyncIntegrationPayload is the payload of a SyncIntegrationKind job
+type SyncIntegrationPayload struct {
+	UserID   uuid.UUID `json:"userId"`
+	Provider string    `json:"provider"`
+	Full     bool      `json:"full,omitempty"`
+}
+
+// RegisterIntegrationJobs adds the integration sync handlers and schedules
+// periodic syncs of all connections as set by JOBS_SYNC_SCHEDULE
+func RegisterIntegrationJobs(s *Scheduler, registry *integrations.Registry) error {
+	s.Handle(SyncIntegrationKind, func(ctx context.Context, job *db.Job) error {
+		var payload SyncIntegrationPayload
+		if err := DecodePayload(job, &payload); err != nil {
+			return err
+		}
+
+		report, err := registry.Sync(ctx, s.repo, payload.UserID, payload.Provider, payload.Full)
+		if err != nil {
+			return err
+		}
+		log.Printf("jobs: synced %s for user %s: %d media created, %d matched, %d activities, %d unmatched",
+			payload.Provider, payload.UserID, report.MediaCreated, report.MediaMatched, report.ActivitiesImported, len(report.Unmatched))
+		return nil
+	})
+
+	s.Handle(SyncIntegrationsKind, func(ctx context.Context, job *db.Job) error {
+		for _, provider := range registry.Providers() {
+			connections, err := s.repo.GetAllConnections(ctx, provider.Name())
+			if err != nil {
+				return err
+			}
+			for _, conn := range connections {
+				payload := SyncIntegrationPayload{UserID: conn.UserID, Provider: conn.Provider}
+				if _, err := s.Enqueue(ctx, SyncIntegrationKind, SyncIntegrationKey(conn.UserID, conn.Provider), payload); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	return scheduleFromEnv(s, "integration-sync", "JOBS_SYNC_SCHEDULE", DefaultSyncSchedule, SyncIntegrationsKind)
+}
+
+// SyncIntegrationKey deduplicates syncs of a connection, so a scheduled sync
+// isn't queued behind one that hasn't run yet
+func SyncIntegrationKey(userID uuid.UUID, provider string) string {
+	return SyncIntegrationKind + ":" + userID.String() + ":" + provider
+}
@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nq/db"
+	"os"
+	"time"
+)
+
+// Kinds of maintenance jobs
+const (
+	// PurgeJobsKind deletes finished jobs older than JobRetention
+	PurgeJobsKind = "maintenance.purge-jobs"
+	// PurgeOAuthStatesKind deletes OAuth authorizations that were never
+	// completed
+	PurgeOAuthStatesKind = "maintenance.purge-oauth-states"
+)
+
+// JobRetention is how long finished jobs are kept for the jobs query
+const JobRetention = 30 * 24 * time.Hour
+
+// RegisterMaintenanceJobs adds the handlers and schedules of housekeeping
+// jobs
+func RegisterMaintenanceJobs(s *Scheduler) error {
+	s.Handle(PurgeJobsKind, func(ctx context.Context, job *db.Job) error {
+		purged, err := s.repo.PurgeJobs(ctx, time.Now().Add(-JobRetention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("jobs: purged %d finished jobs", purged)
+		}
+		return nil
+	})
+
+	s.Handle(PurgeOAuthStatesKind, func(ctx context.Context, job *db.Job) error {
+		_, err := s.repo.PurgeOAuthStates(ctx)
+		return err
+	})
+
+	if err := s.Schedule("purge-jobs", "@daily", PurgeJobsKind, nil); err != nil {
+		return err
+	}
+	return s.Schedule("purge-oauth-states", "@hourly", PurgeOAuthStatesKind, nil)
+}
+
+// scheduleFromEnv schedules a job with the cron spec in an environment
+// variable, or defaultSpec when it is unset. "off" disables the schedule.
+func scheduleFromEnv(s *Scheduler, name, variable, defaultSpec, kind string) error {
+	spec := os.Getenv(variable)
+	switch spec {
+	case "":
+		spec = defaultSpec
+	case "off":
+		return nil
+	}
+
+	if err := s.Schedule(name, spec, kind, nil); err != nil {
+		return fmt.Errorf("%s: %w", variable, err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nq/db"
+	"nq/recommend"
+)
+
+// RefreshRecommendationsKind runs every recommender for every user
+const RefreshRecommendationsKind = "recommendations.refresh"
+
+// DefaultRecommendSchedule refreshes recommendations every night
+const DefaultRecommendSchedule = "0 4 * * *"
+
+// RegisterRecommendationJobs adds the handler that runs recommenders and
+// schedules it as set by JOBS_RECOMMEND_SCHEDULE
+func RegisterRecommendationJobs(s *Scheduler, recommenders []recommend.Recommender) error {
+	s.Handle(RefreshRecommendationsKind, func(ctx context.Context, job *db.Job) error {
+		for _, recommender := range recommenders {
+			written, err := recommender.Recommend(ctx, s.repo)
+			if err != nil {
+				return fmt.Errorf("%s: %w", recommender.Source(), err)
+			}
+			log.Printf("jobs: wrote %d %s recommendations", written, recommender.Source())
+		}
+		return nil
+	})
+
+	if len(recommenders) == 0 {
+		return nil
+	}
+	return scheduleFromEnv(s, "recommendations", "JOBS_RECOMMEND_SCHEDULE", DefaultRecommendSchedule, RefreshRecommendationsKind)
+}
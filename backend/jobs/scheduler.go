@@ -0,0 +1,421 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"nq/db"
+	"nq/graph/model"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for Scheduler
+const (
+	DefaultWorkers          = 4
+	DefaultLease            = 5 * time.Minute
+	DefaultPollInterval     = 5 * time.Second
+	DefaultScheduleInterval = 30 * time.Second
+	DefaultRetryDelay       = 30 * time.Second
+	DefaultMaxRetryDelay    = time.Hour
+)
+
+// finishTimeout bounds recording a job's outcome after it ran, which happens
+// even while the scheduler shuts down
+const finishTimeout = 10 * time.Second
+
+// Handler runs a job. An error retries the job with backoff until it runs
+// out of attempts, unless it is permanent (see Permanent).
+type Handler func(ctx context.Context, job *db.Job) error
+
+// permanentError is an error retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying won't fix, so the job fails right
+// away. Validation and not found errors from the repository are treated as
+// permanent without it.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// schedule is a recurring job
+type schedule struct {
+	name    string
+	spec    string
+	cron    *Cron
+	kind    string
+	payload string
+}
+
+// Scheduler runs background jobs stored in the graph with a bounded pool of
+// workers. Jobs are leased while they run and the lease is extended as long
+// as the worker is alive, so several server instances can share the queue
+// and a job left behind by a stopped instance is picked up by another once
+// its lease expires.
+type Scheduler struct {
+	repo db.Repository
+	// Owner identifies this instance in job leases
+	Owner string
+	// Workers is how many jobs run at once. With 0 the scheduler only
+	// enqueues jobs, leaving them to other instances.
+	Workers int
+	// Lease is how long a claimed job stays leased without being extended
+	Lease            time.Duration
+	PollInterval     time.Duration
+	ScheduleInterval time.Duration
+	// RetryDelay is the delay before the first retry, doubled on each retry
+	// up to MaxRetryDelay
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	handlers  map[string]Handler
+	schedules []*schedule
+	// wake asks the worker loop to look for jobs before its next poll
+	wake chan struct{}
+}
+
+// NewScheduler creates a scheduler with the default settings
+func NewScheduler(repo db.Repository, workers int) *Scheduler {
+	return &Scheduler{
+		repo:             repo,
+		Owner:            newOwnerID(),
+		Workers:          workers,
+		Lease:            DefaultLease,
+		PollInterval:     DefaultPollInterval,
+		ScheduleInterval: DefaultScheduleInterval,
+		RetryDelay:       DefaultRetryDelay,
+		MaxRetryDelay:    DefaultMaxRetryDelay,
+		handlers:         make(map[string]Handler),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+// NewSchedulerFromEnv creates a scheduler running JOBS_WORKERS jobs at once,
+// DefaultWorkers when unset
+func NewSchedulerFromEnv(repo db.Repository) (*Scheduler, error) {
+	workers := DefaultWorkers
+	if value := os.Getenv("JOBS_WORKERS"); value != "" {
+		var err error
+		if workers, err = strconv.Atoi(value); err != nil || workers < 0 {
+			return nil, fmt.Errorf("JOBS_WORKERS must be a number of workers, got %q", value)
+		}
+	}
+	return NewScheduler(repo, workers), nil
+}
+
+// newOwnerID names this instance after its host and process, with a random
+// suffix in case those repeat, e.g. in containers
+func newOwnerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Handle registers the handler for a kind of job. Jobs of kinds without a
+// handler stay queued for instances that have one.
+func (s *Scheduler) Handle(kind string, handler Handler) {
+	s.handlers[kind] = handler
+}
+
+// Schedule enqueues a job of kind with payload whenever the cron spec is
+// due. A run is skipped while the schedule's previous job is still queued or
+// running.
+func (s *Scheduler) Schedule(name, spec, kind string, payload any) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodePayload(payload)
+	if err != nil {
+		return err
+	}
+
+	s.schedules = append(s.schedules, &schedule{name: name, spec: spec, cron: cron, kind: kind, payload: encoded})
+	return nil
+}
+
+// Enqueue stores a job of kind with payload, encoded as JSON, to run as soon
+// as a worker is free. With a key, a job with the same key that is still
+// queued or running is returned instead of adding another.
+func (s *Scheduler) Enqueue(ctx context.Context, kind, key string, payload any) (*db.Job, error) {
+	encoded, err := encodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.repo.EnqueueJob(ctx, &db.JobInput{Kind: kind, Key: key, Payload: encoded})
+	if err != nil {
+		return nil, err
+	}
+	s.notify()
+	return job, nil
+}
+
+// Run registers the schedules and runs jobs until ctx is done, then waits for
+// running jobs to stop
+func (s *Scheduler) Run(ctx context.Context) error {
+	now := time.Now()
+	for _, sched := range s.schedules {
+		if err := s.repo.SaveJobSchedule(ctx, sched.name, sched.spec, sched.cron.Next(now)); err != nil {
+			return fmt.Errorf("failed to save schedule %s: %w", sched.name, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	if len(s.schedules) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.scheduleLoop(ctx)
+		}()
+	}
+	if s.Workers > 0 && len(s.handlers) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.workLoop(ctx)
+		}()
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// scheduleLoop enqueues the jobs of due schedules
+func (s *Scheduler) scheduleLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.ScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		for _, sched := range s.schedules {
+			input := &db.JobInput{
+				Kind:     sched.kind,
+				Payload:  sched.payload,
+				Key:      "schedule:" + sched.name,
+				Schedule: sched.name,
+			}
+			job, err := s.repo.EnqueueScheduledJob(ctx, sched.name, now, sched.cron.Next(now), input)
+			if err != nil {
+				log.Printf("jobs: failed to enqueue schedule %s: %v", sched.name, err)
+				continue
+			}
+			if job != nil {
+				s.notify()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// workLoop claims due jobs while workers are free and runs them
+func (s *Scheduler) workLoop(ctx context.Context) {
+	kinds := make([]string, 0, len(s.handlers))
+	for kind := range s.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	workers := make(chan struct{}, s.Workers)
+
+	for {
+		if free := s.Workers - len(workers); free > 0 {
+			claimed, err := s.repo.ClaimJobs(ctx, s.Owner, kinds, free, s.Lease)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("jobs: failed to claim jobs: %v", err)
+			}
+			for _, job := range claimed {
+				workers <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.run(ctx, job)
+					<-workers
+					s.notify()
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// run runs a claimed job, extending its lease while it runs, and records the
+// outcome
+func (s *Scheduler) run(ctx context.Context, job *db.Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.keepLease(jobCtx, cancel, job)
+
+	err := s.call(jobCtx, job)
+	cancel()
+
+	finishCtx, finished := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer finished()
+
+	switch {
+	case err == nil:
+		err = s.repo.CompleteJob(finishCtx, job.ID, s.Owner)
+	case ctx.Err() != nil:
+		// Shutting down; hand the job to whichever instance polls next
+		now := time.Now()
+		err = s.repo.FailJob(finishCtx, job.ID, s.Owner, "interrupted by shutdown", &now)
+	case permanent(err) || job.Attempts >= job.MaxAttempts:
+		log.Printf("jobs: %s job %s failed: %v", job.Kind, job.ID, err)
+		err = s.repo.FailJob(finishCtx, job.ID, s.Owner, err.Error(), nil)
+	default:
+		retryAt := time.Now().Add(s.backoff(job.Attempts))
+		log.Printf("jobs: %s job %s failed attempt %d of %d, retrying at %s: %v", job.Kind, job.ID, job.Attempts, job.MaxAttempts, retryAt.Format(time.RFC3339), err)
+		err = s.repo.FailJob(finishCtx, job.ID, s.Owner, err.Error(), &retryAt)
+	}
+
+	if errors.Is(err, db.ErrNotFound) {
+		log.Printf("jobs: %s job %s lost its lease before finishing", job.Kind, job.ID)
+	} else if err != nil {
+		log.Printf("jobs: failed to record outcome of %s job %s: %v", job.Kind, job.ID, err)
+	}
+}
+
+// call runs a job's handler, turning a panic into an error
+func (s *Scheduler) call(ctx context.Context, job *db.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("jobs: %s job %s panicked: %v\n%s", job.Kind, job.ID, recovered, debug.Stack())
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return s.handlers[job.Kind](ctx, job)
+}
+
+// keepLease extends a running job's lease until ctx is done. If the lease is
+// lost, e.g. because it expired and another instance claimed the job, the job
+// is cancelled.
+func (s *Scheduler) keepLease(ctx context.Context, cancel context.CancelFunc, job *db.Job) {
+	ticker := time.NewTicker(s.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := s.repo.ExtendJobLease(ctx, job.ID, s.Owner, s.Lease)
+		if errors.Is(err, db.ErrNotFound) {
+			log.Printf("jobs: %s job %s lost its lease, cancelling it", job.Kind, job.ID)
+			cancel()
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("jobs: failed to extend lease of %s job %s: %v", job.Kind, job.ID, err)
+		}
+	}
+}
+
+// backoff returns the delay before retrying a job that failed its attempt-th
+// attempt
+func (s *Scheduler) backoff(attempt int) time.Duration {
+	delay := s.RetryDelay << max(attempt-1, 0)
+	if delay <= 0 || delay > s.MaxRetryDelay {
+		delay = s.MaxRetryDelay
+	}
+	return delay
+}
+
+// notify wakes the worker loop without blocking
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// permanent reports whether retrying err is pointless
+func permanent(err error) bool {
+	var permanentErr *permanentError
+	return errors.As(err, &permanentErr) || errors.Is(err, db.ErrValidation) || errors.Is(err, db.ErrNotFound)
+}
+
+// encodePayload encodes a job payload as JSON; nil encodes as ""
+func encodePayload(payload any) (string, error) {
+	if payload == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	return string(data), nil
+}
+
+// DecodePayload decodes a job's JSON payload into out
+func DecodePayload(job *db.Job, out any) error {
+	if job.Payload == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(job.Payload), out); err != nil {
+		return Permanent(fmt.Errorf("invalid payload for %s job: %w", job.Kind, err))
+	}
+	return nil
+}
+
+// JobModel converts a stored job to its GraphQL type
+func JobModel(job *db.Job) *model.Job {
+	result := &model.Job{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		State:       job.State,
+		Attempts:    int32(job.Attempts),
+		MaxAttempts: int32(job.MaxAttempts),
+		RunAfter:    job.RunAfter.UTC().Format(time.RFC3339),
+		CreatedAt:   job.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if job.Schedule != "" {
+		result.Schedule = &job.Schedule
+	}
+	if job.LastError != "" {
+		result.LastError = &job.LastError
+	}
+	if job.StartedAt != nil {
+		startedAt := job.StartedAt.UTC().Format(time.RFC3339)
+		result.StartedAt = &startedAt
+	}
+	if job.FinishedAt != nil {
+		finishedAt := job.FinishedAt.UTC().Format(time.RFC3339)
+		result.FinishedAt = &finishedAt
+	}
+	return result
+}
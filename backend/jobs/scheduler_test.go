@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nq/db"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeJobs records how the scheduler finishes jobs and can refuse to extend
+// a lease, as when it expired and another instance claimed the job
+type fakeJobs struct {
+	db.Repository
+
+	mu         sync.Mutex
+	completed  int
+	failures   []jobFailure
+	extensions int
+	leaseLost  bool
+}
+
+type jobFailure struct {
+	message string
+	retryAt *time.Time
+}
+
+func (f *fakeJobs) CompleteJob(ctx context.Context, id uuid.UUID, owner string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completed++
+	return nil
+}
+
+func (f *fakeJobs) FailJob(ctx context.Context, id uuid.UUID, owner, message string, retryAt *time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, jobFailure{message, retryAt})
+	return nil
+}
+
+func (f *fakeJobs) ExtendJobLease(ctx context.Context, id uuid.UUID, owner string, lease time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.extensions++
+	if f.leaseLost {
+		return db.NotFoundError("job")
+	}
+	return nil
+}
+
+// extended returns how many times a lease was extended. The lease may still
+// be extended once more as a job finishes.
+func (f *fakeJobs) extended() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.extensions
+}
+
+func newTestScheduler(repo *fakeJobs, handler Handler) *Scheduler {
+	s := NewScheduler(repo, 1)
+	s.Handle("test", handler)
+	return s
+}
+
+func TestBackoff(t *testing.T) {
+	s := NewScheduler(nil, 1)
+	s.RetryDelay = 30 * time.Second
+	s.MaxRetryDelay = 5 * time.Minute
+
+	for attempt, want := range map[int]time.Duration{
+		0: 30 * time.Second,
+		1: 30 * time.Second,
+		2: time.Minute,
+		3: 2 * time.Minute,
+		4: 4 * time.Minute,
+		5: 5 * time.Minute,
+		// Shifting this far overflows, which must not wrap to a short delay
+		70: 5 * time.Minute,
+	} {
+		if got := s.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestRunRetriesWithBackoff(t *testing.T) {
+	repo := &fakeJobs{}
+	s := newTestScheduler(repo, func(ctx context.Context, job *db.Job) error {
+		return errors.New("provider timed out")
+	})
+	s.RetryDelay = time.Minute
+
+	before := time.Now()
+	s.run(context.Background(), &db.Job{ID: uuid.New(), Kind: "test", Attempts: 3, MaxAttempts: 5})
+
+	if len(repo.failures) != 1 {
+		t.Fatalf("got %d failures recorded, want 1", len(repo.failures))
+	}
+	failure := repo.failures[0]
+	if failure.message != "provider timed out" || failure.retryAt == nil {
+		t.Fatalf("got %+v, want a retry", failure)
+	}
+	if delay := failure.retryAt.Sub(before); delay < 4*time.Minute || delay > 4*time.Minute+time.Second {
+		t.Errorf("retrying in %s after the third attempt, want 4m", delay)
+	}
+}
+
+func TestRunFailsPermanently(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"last attempt", errors.New("provider timed out"), 5},
+		{"permanent", Permanent(errors.New("bad payload")), 1},
+		{"validation", db.ValidationFailedError("bad input"), 1},
+		{"not found", fmt.Errorf("syncing: %w", db.NotFoundError("user")), 1},
+		{"panic", nil, 5},
+	}
+	for _, tt := range tests {
+		repo := &fakeJobs{}
+		s := newTestScheduler(repo, func(ctx context.Context, job *db.Job) error {
+			if tt.err == nil {
+				panic("boom")
+			}
+			return tt.err
+		})
+
+		s.run(context.Background(), &db.Job{ID: uuid.New(), Kind: "test", Attempts: tt.attempts, MaxAttempts: 5})
+
+		if len(repo.failures) != 1 || repo.failures[0].retryAt != nil {
+			t.Errorf("%s: got %+v, want the job failed without a retry", tt.name, repo.failures)
+		}
+	}
+}
+
+func TestRunCompletes(t *testing.T) {
+	repo := &fakeJobs{}
+	s := newTestScheduler(repo, func(ctx context.Context, job *db.Job) error { return nil })
+
+	s.run(context.Background(), &db.Job{ID: uuid.New(), Kind: "test", Attempts: 1, MaxAttempts: 5})
+
+	if repo.completed != 1 || len(repo.failures) != 0 {
+		t.Errorf("got %d completed and failures %+v", repo.completed, repo.failures)
+	}
+}
+
+func TestRunExtendsLease(t *testing.T) {
+	repo := &fakeJobs{}
+	s := newTestScheduler(repo, func(ctx context.Context, job *db.Job) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	s.Lease = 30 * time.Millisecond
+
+	s.run(context.Background(), &db.Job{ID: uuid.New(), Kind: "test", Attempts: 1, MaxAttempts: 5})
+
+	if extended := repo.extended(); extended < 2 || repo.completed != 1 {
+		t.Errorf("got %d extensions and %d completed, want the lease extended while the job ran", extended, repo.completed)
+	}
+}
+
+func TestRunCancelsJobThatLostItsLease(t *testing.T) {
+	repo := &fakeJobs{leaseLost: true}
+	s := newTestScheduler(repo, func(ctx context.Context, job *db.Job) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	s.Lease = 30 * time.Millisecond
+
+	started := time.Now()
+	s.run(context.Background(), &db.Job{ID: uuid.New(), Kind: "test", Attempts: 1, MaxAttempts: 5})
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("job ran for %s after losing its lease", elapsed)
+	}
+	if repo.completed != 0 {
+		t.Errorf("completed a job whose lease was lost")
+	}
+}
+
+func TestRunHandsBackJobsOnShutdown(t *testing.T) {
+	repo := &fakeJobs{}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newTestScheduler(repo, func(ctx context.Context, job *db.Job) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	s.run(ctx, &db.Job{ID: uuid.New(), Kind: "test", Attempts: 5, MaxAttempts: 5})
+
+	if len(repo.failures) != 1 || repo.failures[0].retryAt == nil || repo.failures[0].message != "interrupted by shutdown" {
+		t.Errorf("got %+v, want the job released to run again", repo.failures)
+	}
+}
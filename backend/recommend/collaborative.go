@@ -0,0 +1,289 @@
+package recommend
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"nq/db"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// SourceCollaborative is the source of collaborative filtering
+// recommendations
+const SourceCollaborative = "collaborative"
+
+// Defaults for collaborative filtering
+const (
+	DefaultNeighbors  = 20
+	DefaultMinOverlap = 2
+	DefaultLimit      = 20
+)
+
+// Collaborative recommends media that users with similar taste liked
+// (user-based) and media similar to what a user liked (item-based), both
+// measured with adjusted cosine similarity over ratings and other feedback
+type Collaborative struct {
+	// Neighbors is how many of the most similar users, and of the items most
+	// similar to a candidate, a prediction is drawn from
+	Neighbors int
+	// MinOverlap is how many media two users, or users two media, must share
+	// for their similarity to count
+	MinOverlap int
+	// Limit is how many recommendations each user gets
+	Limit int
+}
+
+// neighbor is a user or media item with its similarity to another
+type neighbor struct {
+	id         uuid.UUID
+	similarity float64
+}
+
+// feedbackMatrix holds every user's feedback as deviations from their mean
+// score, by user and by media
+type feedbackMatrix struct {
+	byUser map[uuid.UUID]map[uuid.UUID]float64
+	byItem map[uuid.UUID]map[uuid.UUID]float64
+	means  map[uuid.UUID]float64
+	// consumed holds every media item a user has interacted with, feedback
+	// or not
+	consumed map[uuid.UUID]map[uuid.UUID]bool
+}
+
+// NewCollaborativeFromEnv creates a collaborative recommender tuned by
+// RECOMMEND_NEIGHBORS, RECOMMEND_MIN_OVERLAP and RECOMMEND_LIMIT
+func NewCollaborativeFromEnv() (*Collaborative, error) {
+	neighbors, err := intFromEnv("RECOMMEND_NEIGHBORS", DefaultNeighbors)
+	if err != nil {
+		return nil, err
+	}
+	minOverlap, err := intFromEnv("RECOMMEND_MIN_OVERLAP", DefaultMinOverlap)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intFromEnv("RECOMMEND_LIMIT", DefaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &Collaborative{Neighbors: neighbors, MinOverlap: minOverlap, Limit: limit}, nil
+}
+
+// Source is the source of the recommendations
+func (c *Collaborative) Source() string {
+	return SourceCollaborative
+}
+
+// Recommend replaces the collaborative recommendations of every user who has
+// interacted with media
+func (c *Collaborative) Recommend(ctx context.Context, repo db.Repository) (int, error) {
+	interactions, err := repo.GetInteractions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	matrix := newFeedbackMatrix(interactions)
+
+	written := 0
+	for _, userID := range sortedIDs(matrix.consumed) {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, err := replace(ctx, repo, userID, SourceCollaborative, c.recommendFor(matrix, userID))
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// recommendFor predicts a user's scores for the media they haven't consumed
+// and returns the best above their mean score. The user-based and item-based
+// predictions are averaged where both exist.
+func (c *Collaborative) recommendFor(matrix *feedbackMatrix, userID uuid.UUID) []Scored {
+	own := matrix.byUser[userID]
+	if len(own) == 0 {
+		return nil
+	}
+
+	predictions := map[uuid.UUID][]float64{}
+	for mediaID, deviation := range c.userBased(matrix, userID) {
+		predictions[mediaID] = append(predictions[mediaID], deviation)
+	}
+	for mediaID, deviation := range c.itemBased(matrix, userID) {
+		predictions[mediaID] = append(predictions[mediaID], deviation)
+	}
+
+	var scored []Scored
+	for mediaID, deviations := range predictions {
+		var sum float64
+		for _, deviation := range deviations {
+			sum += deviation
+		}
+		deviation := sum / float64(len(deviations))
+		if deviation <= 0 {
+			continue
+		}
+		score := min(max(matrix.means[userID]+deviation, 0), db.MaxScore)
+		scored = append(scored, Scored{MediaID: mediaID, Score: score})
+	}
+	return best(scored, c.Limit)
+}
+
+// userBased predicts the user's deviation from their mean for media their
+// nearest neighbors gave feedback on, as the neighbors' deviations weighted
+// by similarity
+func (c *Collaborative) userBased(matrix *feedbackMatrix, userID uuid.UUID) map[uuid.UUID]float64 {
+	own := matrix.byUser[userID]
+	var neighbors []neighbor
+	for otherID, other := range matrix.byUser {
+		if otherID == userID {
+			continue
+		}
+		if similarity, overlap := cosine(own, other); overlap >= c.MinOverlap && similarity > 0 {
+			neighbors = append(neighbors, neighbor{id: otherID, similarity: similarity})
+		}
+	}
+	neighbors = nearest(neighbors, c.Neighbors)
+
+	weighted := map[uuid.UUID]float64{}
+	weights := map[uuid.UUID]float64{}
+	for _, n := range neighbors {
+		for mediaID, deviation := range matrix.byUser[n.id] {
+			if matrix.consumed[userID][mediaID] {
+				continue
+			}
+			weighted[mediaID] += n.similarity * deviation
+			weights[mediaID] += n.similarity
+		}
+	}
+
+	for mediaID := range weighted {
+		weighted[mediaID] /= weights[mediaID]
+	}
+	return weighted
+}
+
+// itemBased predicts the user's deviation from their mean for each media
+// item they haven't consumed, as their deviations on the items most similar
+// to it weighted by similarity
+func (c *Collaborative) itemBased(matrix *feedbackMatrix, userID uuid.UUID) map[uuid.UUID]float64 {
+	// Media with fewer users than MinOverlap can't be similar to anything
+	var own []uuid.UUID
+	for mediaID := range matrix.byUser[userID] {
+		if len(matrix.byItem[mediaID]) >= c.MinOverlap {
+			own = append(own, mediaID)
+		}
+	}
+
+	predicted := map[uuid.UUID]float64{}
+	for candidateID, raters := range matrix.byItem {
+		if matrix.consumed[userID][candidateID] || len(raters) < c.MinOverlap {
+			continue
+		}
+
+		var neighbors []neighbor
+		for _, mediaID := range own {
+			if similarity, overlap := cosine(raters, matrix.byItem[mediaID]); overlap >= c.MinOverlap && similarity > 0 {
+				neighbors = append(neighbors, neighbor{id: mediaID, similarity: similarity})
+			}
+		}
+		if len(neighbors) == 0 {
+			continue
+		}
+
+		var weighted, weights float64
+		for _, n := range nearest(neighbors, c.Neighbors) {
+			weighted += n.similarity * matrix.byUser[userID][n.id]
+			weights += n.similarity
+		}
+		predicted[candidateID] = weighted / weights
+	}
+	return predicted
+}
+
+// newFeedbackMatrix centers every user's feedback scores on their mean
+func newFeedbackMatrix(interactions []*db.Interaction) *feedbackMatrix {
+	matrix := &feedbackMatrix{
+		byUser:   map[uuid.UUID]map[uuid.UUID]float64{},
+		byItem:   map[uuid.UUID]map[uuid.UUID]float64{},
+		means:    map[uuid.UUID]float64{},
+		consumed: map[uuid.UUID]map[uuid.UUID]bool{},
+	}
+
+	for _, interaction := range interactions {
+		if matrix.consumed[interaction.UserID] == nil {
+			matrix.consumed[interaction.UserID] = map[uuid.UUID]bool{}
+		}
+		matrix.consumed[interaction.UserID][interaction.MediaID] = true
+
+		score, ok := feedbackScore(interaction)
+		if !ok {
+			continue
+		}
+		if matrix.byUser[interaction.UserID] == nil {
+			matrix.byUser[interaction.UserID] = map[uuid.UUID]float64{}
+		}
+		matrix.byUser[interaction.UserID][interaction.MediaID] = score
+	}
+
+	for userID, scores := range matrix.byUser {
+		var sum float64
+		for _, score := range scores {
+			sum += score
+		}
+		mean := sum / float64(len(scores))
+		matrix.means[userID] = mean
+
+		for mediaID, score := range scores {
+			scores[mediaID] = score - mean
+			if matrix.byItem[mediaID] == nil {
+				matrix.byItem[mediaID] = map[uuid.UUID]float64{}
+			}
+			matrix.byItem[mediaID][userID] = score - mean
+		}
+	}
+	return matrix
+}
+
+// cosine returns the cosine similarity of two vectors over the keys they
+// share, and how many keys they share
+func cosine(a, b map[uuid.UUID]float64) (float64, int) {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	var dot, normA, normB float64
+	overlap := 0
+	for key, x := range a {
+		y, ok := b[key]
+		if !ok {
+			continue
+		}
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		overlap++
+	}
+	if normA == 0 || normB == 0 {
+		return 0, overlap
+	}
+	return dot / math.Sqrt(normA*normB), overlap
+}
+
+// nearest returns the k most similar neighbors
+func nearest(neighbors []neighbor, k int) []neighbor {
+	slices.SortFunc(neighbors, func(a, b neighbor) int {
+		return cmp.Or(cmp.Compare(b.similarity, a.similarity), cmp.Compare(a.id.String(), b.id.String()))
+	})
+	return neighbors[:min(k, len(neighbors))]
+}
+
+// best returns the limit highest scored media, best first
+func best(scored []Scored, limit int) []Scored {
+	slices.SortFunc(scored, func(a, b Scored) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.MediaID.String(), b.MediaID.String()))
+	})
+	return scored[:min(limit, len(scored))]
+}
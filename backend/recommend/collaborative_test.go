@@ -0,0 +1,134 @@
+package recommend
+
+import (
+	"maps"
+	"math"
+	"nq/db"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func rated(userID, mediaID uuid.UUID, rating float64) *db.Interaction {
+	return &db.Interaction{UserID: userID, MediaID: mediaID, Rating: &rating}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// equalPredictions compares predictions by media ID within rounding
+func equalPredictions(got, want map[uuid.UUID]float64) bool {
+	return maps.EqualFunc(got, want, closeTo)
+}
+
+func TestCosine(t *testing.T) {
+	x, y, z := uuid.New(), uuid.New(), uuid.New()
+	tests := []struct {
+		name       string
+		a, b       map[uuid.UUID]float64
+		similarity float64
+		overlap    int
+	}{
+		{"parallel", map[uuid.UUID]float64{x: 1, y: 2}, map[uuid.UUID]float64{x: 2, y: 4, z: 9}, 1, 2},
+		{"opposite", map[uuid.UUID]float64{x: 1, y: -1}, map[uuid.UUID]float64{x: -1, y: 1}, -1, 2},
+		{"at 45 degrees", map[uuid.UUID]float64{x: 1, y: 0}, map[uuid.UUID]float64{x: 1, y: 1}, 1 / math.Sqrt2, 2},
+		{"no deviation", map[uuid.UUID]float64{x: 0, y: 0}, map[uuid.UUID]float64{x: 1, y: 1}, 0, 2},
+		{"disjoint", map[uuid.UUID]float64{x: 1}, map[uuid.UUID]float64{y: 1}, 0, 0},
+	}
+	for _, tt := range tests {
+		similarity, overlap := cosine(tt.a, tt.b)
+		if !closeTo(similarity, tt.similarity) || overlap != tt.overlap {
+			t.Errorf("%s: got %g over %d, want %g over %d", tt.name, similarity, overlap, tt.similarity, tt.overlap)
+		}
+	}
+}
+
+func TestNewFeedbackMatrixCentersOnMean(t *testing.T) {
+	user, rated8, rated6, planned := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	matrix := newFeedbackMatrix([]*db.Interaction{
+		rated(user, rated8, 8),
+		rated(user, rated6, 6),
+		{UserID: user, MediaID: planned, Statuses: []int32{db.StatusPlanned}},
+	})
+
+	if !closeTo(matrix.means[user], 7) {
+		t.Errorf("got mean %g, want 7", matrix.means[user])
+	}
+	if want := map[uuid.UUID]float64{rated8: 1, rated6: -1}; !equalPredictions(matrix.byUser[user], want) {
+		t.Errorf("got deviations %v, want %v", matrix.byUser[user], want)
+	}
+	if got := matrix.byItem[rated6][user]; !closeTo(got, -1) {
+		t.Errorf("got %g by item, want -1", got)
+	}
+	if !matrix.consumed[user][planned] {
+		t.Errorf("planned media isn't consumed")
+	}
+	if _, ok := matrix.byItem[planned]; ok {
+		t.Errorf("planned media without feedback has feedback")
+	}
+}
+
+func TestUserBased(t *testing.T) {
+	target, twin, opposite, light, close := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	a, b, planned, d, e, f, g := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	// Deviations from each user's mean of 7 or 8:
+	//   target   a +2, b -2, and planned without a rating
+	//   twin     a +2, b -2, d +2, e -2   similarity 1
+	//   opposite a -2, b +2, d -2, e +2   similarity -1
+	//   light    a +2, planned -4, f +2   shares only a with target
+	//   close    a +1, b -2, g +1         similarity 6/sqrt(40)
+	matrix := newFeedbackMatrix([]*db.Interaction{
+		rated(target, a, 9), rated(target, b, 5),
+		{UserID: target, MediaID: planned, Statuses: []int32{db.StatusPlanned}},
+		rated(twin, a, 9), rated(twin, b, 5), rated(twin, d, 9), rated(twin, e, 5),
+		rated(opposite, a, 5), rated(opposite, b, 9), rated(opposite, d, 5), rated(opposite, e, 9),
+		rated(light, a, 9), rated(light, planned, 3), rated(light, f, 9),
+		rated(close, a, 9), rated(close, b, 6), rated(close, g, 9),
+	})
+
+	tests := []struct {
+		name      string
+		collab    Collaborative
+		predicted map[uuid.UUID]float64
+	}{
+		// opposite is dissimilar and light shares too little; what target
+		// already has is never predicted
+		{"defaults", Collaborative{Neighbors: 20, MinOverlap: 2}, map[uuid.UUID]float64{d: 2, e: -2, g: 1}},
+		{"one neighbor", Collaborative{Neighbors: 1, MinOverlap: 2}, map[uuid.UUID]float64{d: 2, e: -2}},
+		{"overlap of one", Collaborative{Neighbors: 20, MinOverlap: 1}, map[uuid.UUID]float64{d: 2, e: -2, f: 2, g: 1}},
+	}
+	for _, tt := range tests {
+		if got := tt.collab.userBased(matrix, target); !equalPredictions(got, tt.predicted) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.predicted)
+		}
+	}
+}
+
+func TestItemBasedAndRecommendFor(t *testing.T) {
+	target, first, second := uuid.New(), uuid.New(), uuid.New()
+	a, b, planned, d, e, h := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	// d and planned are rated like a, and e and h like b, so target, who
+	// liked a over b, is predicted to like d and not e or h. planned is
+	// already on target's list.
+	matrix := newFeedbackMatrix([]*db.Interaction{
+		rated(target, a, 9), rated(target, b, 5),
+		{UserID: target, MediaID: planned, Statuses: []int32{db.StatusPlanned}},
+		rated(first, a, 9), rated(first, b, 5), rated(first, d, 9), rated(first, e, 5), rated(first, planned, 9), rated(first, h, 5),
+		rated(second, a, 5), rated(second, b, 9), rated(second, d, 5), rated(second, e, 9), rated(second, planned, 5), rated(second, h, 9),
+	})
+	collab := Collaborative{Neighbors: 20, MinOverlap: 2, Limit: 10}
+
+	if got, want := collab.itemBased(matrix, target), (map[uuid.UUID]float64{d: 2, e: -2, h: -2}); !equalPredictions(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Only predictions above target's mean of 7 are recommended
+	scored := collab.recommendFor(matrix, target)
+	if len(scored) != 1 || scored[0].MediaID != d || !closeTo(scored[0].Score, 9) {
+		t.Errorf("got %v, want d at 9", scored)
+	}
+}
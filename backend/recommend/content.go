@@ -0,0 +1,187 @@
+package recommend
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"nq/db"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SourceContent is the source of content-based recommendations
+const SourceContent = "content"
+
+// Weights of what a liked media item shares with a candidate: a creator says
+// the most about taste, a platform the least
+var pathWeights = map[string]float64{
+	"CREATED":     1.0,
+	"TAGGED_WITH": 0.5,
+	"HOSTS":       0.2,
+}
+
+// Defaults for content-based recommendations
+const (
+	// DefaultLikedScore is the feedback score from which media counts as
+	// liked
+	DefaultLikedScore = 8.0
+	// DefaultHalfLife is how long until liking a media item counts half
+	DefaultHalfLife = 365 * 24 * time.Hour
+)
+
+// Limits on how far content-based recommendations walk the graph
+const (
+	// maxSeeds caps the liked media recommendations start from, keeping the
+	// most strongly liked
+	maxSeeds = 100
+	// maxLinkDegree skips creators, tags and platforms shared by so many
+	// media that they say little about taste
+	maxLinkDegree = 500
+)
+
+// Content recommends media that share creators, tags and platforms with
+// media a user rated highly or favorited
+type Content struct {
+	// LikedScore is the feedback score from which media counts as liked.
+	// Favorites always count.
+	LikedScore float64
+	// HalfLife is how long until liking a media item counts half as much
+	HalfLife time.Duration
+	// Limit is how many recommendations each user gets
+	Limit int
+}
+
+// seed is a liked media item with how much it counts
+type seed struct {
+	mediaID uuid.UUID
+	weight  float64
+}
+
+// NewContentFromEnv creates a content-based recommender giving
+// RECOMMEND_LIMIT recommendations, with liking media counting half after
+// RECOMMEND_HALF_LIFE_DAYS
+func NewContentFromEnv() (*Content, error) {
+	limit, err := intFromEnv("RECOMMEND_LIMIT", DefaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	halfLife, err := intFromEnv("RECOMMEND_HALF_LIFE_DAYS", int(DefaultHalfLife/(24*time.Hour)))
+	if err != nil {
+		return nil, err
+	}
+	return &Content{
+		LikedScore: DefaultLikedScore,
+		HalfLife:   time.Duration(halfLife) * 24 * time.Hour,
+		Limit:      limit,
+	}, nil
+}
+
+// Source is the source of the recommendations
+func (c *Content) Source() string {
+	return SourceContent
+}
+
+// Recommend replaces the content-based recommendations of every user who has
+// interacted with media
+func (c *Content) Recommend(ctx context.Context, repo db.Repository) (int, error) {
+	interactions, err := repo.GetInteractions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	byUser := map[uuid.UUID][]*db.Interaction{}
+	for _, interaction := range interactions {
+		byUser[interaction.UserID] = append(byUser[interaction.UserID], interaction)
+	}
+
+	written := 0
+	now := time.Now()
+	for _, userID := range sortedIDs(byUser) {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		scored, err := c.recommendFor(ctx, repo, byUser[userID], now)
+		if err != nil {
+			return written, err
+		}
+		n, err := replace(ctx, repo, userID, SourceContent, scored)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// recommendFor scores the media reached from a user's liked media. Each path
+// adds the liked media's weight times the path's, divided by the log of how
+// many media share the creator, tag or platform, and scores approach
+// MaxScore as paths add up.
+func (c *Content) recommendFor(ctx context.Context, repo db.Repository, interactions []*db.Interaction, now time.Time) ([]Scored, error) {
+	seeds := c.seeds(interactions, now)
+	if len(seeds) == 0 {
+		return nil, nil
+	}
+
+	weights := map[uuid.UUID]float64{}
+	ids := make([]uuid.UUID, len(seeds))
+	for i, s := range seeds {
+		weights[s.mediaID] = s.weight
+		ids[i] = s.mediaID
+	}
+	links, err := repo.GetContentLinks(ctx, ids, maxLinkDegree)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := map[uuid.UUID]bool{}
+	for _, interaction := range interactions {
+		consumed[interaction.MediaID] = true
+	}
+
+	evidence := map[uuid.UUID]float64{}
+	for _, link := range links {
+		if consumed[link.ToID] {
+			continue
+		}
+		evidence[link.ToID] += weights[link.FromID] * pathWeights[link.Via] / math.Log2(1+float64(max(link.Degree, 2)))
+	}
+
+	scored := make([]Scored, 0, len(evidence))
+	for mediaID, sum := range evidence {
+		scored = append(scored, Scored{MediaID: mediaID, Score: db.MaxScore * (1 - math.Exp(-sum))})
+	}
+	return best(scored, c.Limit), nil
+}
+
+// seeds returns the media a user liked, weighted by their score and by how
+// recently they rated, tracked or favorited it, strongest first. Media liked
+// at an unknown time counts as liked a half-life ago.
+func (c *Content) seeds(interactions []*db.Interaction, now time.Time) []seed {
+	var seeds []seed
+	for _, interaction := range interactions {
+		score, ok := feedbackScore(interaction)
+		if interaction.Favorite {
+			score, ok = max(score, FavoriteScore), true
+		}
+		if !ok || (!interaction.Favorite && score < c.LikedScore) {
+			continue
+		}
+
+		halfLives := 1.0
+		if interaction.At != nil && c.HalfLife > 0 {
+			halfLives = max(now.Sub(*interaction.At), 0).Hours() / c.HalfLife.Hours()
+		}
+		seeds = append(seeds, seed{
+			mediaID: interaction.MediaID,
+			weight:  score / db.MaxScore * math.Pow(0.5, halfLives),
+		})
+	}
+
+	slices.SortFunc(seeds, func(a, b seed) int {
+		return cmp.Or(cmp.Compare(b.weight, a.weight), cmp.Compare(a.mediaID.String(), b.mediaID.String()))
+	})
+	return seeds[:min(maxSeeds, len(seeds))]
+}
@@ -0,0 +1,129 @@
+package recommend
+
+import (
+	"context"
+	"math"
+	"nq/db"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// contentLinks serves fixed content links and records which media they were
+// asked for
+type contentLinks struct {
+	db.Repository
+	links []*db.ContentLink
+	asked []uuid.UUID
+}
+
+func (r *contentLinks) GetContentLinks(ctx context.Context, mediaIDs []uuid.UUID, maxDegree int) ([]*db.ContentLink, error) {
+	r.asked = mediaIDs
+	return r.links, nil
+}
+
+func TestSeedsWeighsScoreAndRecency(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+	halfLife := 100 * 24 * time.Hour
+	c := &Content{LikedScore: 8, HalfLife: halfLife, Limit: 10}
+	user := uuid.New()
+	interaction := func(rating float64, at *time.Time) *db.Interaction {
+		return &db.Interaction{UserID: user, MediaID: uuid.New(), Rating: &rating, At: at}
+	}
+
+	fresh := interaction(9, ago(0))
+	old := interaction(9, ago(halfLife))
+	undated := interaction(10, nil)
+	future := interaction(8, ago(-time.Hour))
+	disliked := interaction(7, ago(0))
+	favorite := &db.Interaction{UserID: user, MediaID: uuid.New(), Favorite: true, At: ago(2 * halfLife)}
+	favoriteRatedLow := interaction(5, ago(0))
+	favoriteRatedLow.Favorite = true
+	planned := &db.Interaction{UserID: user, MediaID: uuid.New(), Statuses: []int32{db.StatusPlanned}, At: ago(0)}
+
+	seeds := c.seeds([]*db.Interaction{disliked, old, planned, favorite, fresh, undated, future, favoriteRatedLow}, now)
+
+	// Liking counts half after a half-life, and half again after another.
+	// Media liked at an unknown time counts as liked a half-life ago, and
+	// favorites count however they were rated.
+	want := []seed{
+		{fresh.MediaID, 0.9},
+		{favoriteRatedLow.MediaID, 0.9},
+		{future.MediaID, 0.8},
+		{undated.MediaID, 0.5},
+		{old.MediaID, 0.45},
+		{favorite.MediaID, 0.225},
+	}
+	if len(seeds) != len(want) {
+		t.Fatalf("got %d seeds, want %d: %v", len(seeds), len(want), seeds)
+	}
+	// fresh and favoriteRatedLow tie, so they are in media ID order
+	if seeds[0].mediaID != want[0].mediaID {
+		want[0], want[1] = want[1], want[0]
+	}
+	for i := range want {
+		if seeds[i].mediaID != want[i].mediaID || !closeTo(seeds[i].weight, want[i].weight) {
+			t.Errorf("seed %d: got %v, want %v", i, seeds[i], want[i])
+		}
+	}
+}
+
+func TestContentRecommendForWeighsPaths(t *testing.T) {
+	now := time.Now()
+	user, liked, owned, byCreator, byPlatform := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	rating := 10.0
+	interactions := []*db.Interaction{
+		{UserID: user, MediaID: liked, Rating: &rating, At: &now},
+		{UserID: user, MediaID: owned, Statuses: []int32{db.StatusPlanned}},
+	}
+	repo := &contentLinks{links: []*db.ContentLink{
+		// A creator of one media item counts as if shared by two
+		{FromID: liked, ToID: byCreator, Via: "CREATED", Degree: 1},
+		{FromID: liked, ToID: byCreator, Via: "TAGGED_WITH", Degree: 3},
+		{FromID: liked, ToID: byPlatform, Via: "HOSTS", Degree: 7},
+		{FromID: liked, ToID: owned, Via: "CREATED", Degree: 2},
+	}}
+	c := &Content{LikedScore: 8, HalfLife: DefaultHalfLife, Limit: 10}
+
+	scored, err := c.recommendFor(context.Background(), repo, interactions, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(repo.asked, []uuid.UUID{liked}) {
+		t.Errorf("walked from %v, want only the liked media", repo.asked)
+	}
+	// Each path adds its weight over log2(1 + degree)
+	creatorEvidence := 1/math.Log2(3) + 0.5/math.Log2(4)
+	platformEvidence := 0.2 / math.Log2(8)
+	want := []Scored{
+		{MediaID: byCreator, Score: db.MaxScore * (1 - math.Exp(-creatorEvidence))},
+		{MediaID: byPlatform, Score: db.MaxScore * (1 - math.Exp(-platformEvidence))},
+	}
+	if len(scored) != len(want) {
+		t.Fatalf("got %v, want %v without media the user already has", scored, want)
+	}
+	for i := range want {
+		if scored[i].MediaID != want[i].MediaID || !closeTo(scored[i].Score, want[i].Score) {
+			t.Errorf("got %v, want %v", scored[i], want[i])
+		}
+	}
+}
+
+func TestContentRecommendForWithoutLikedMedia(t *testing.T) {
+	rating := 5.0
+	interactions := []*db.Interaction{{UserID: uuid.New(), MediaID: uuid.New(), Rating: &rating}}
+	c := &Content{LikedScore: 8, HalfLife: DefaultHalfLife, Limit: 10}
+
+	repo := &contentLinks{}
+	scored, err := c.recommendFor(context.Background(), repo, interactions, time.Now())
+	if err != nil || scored != nil || repo.asked != nil {
+		t.Errorf("got %v, %v, want nothing and no walk from media the user didn't like", scored, err)
+	}
+}
@@ -0,0 +1,140 @@
+package recommend
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"nq/db"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRecommenders are the recommenders run when RECOMMENDERS is not set
+const DefaultRecommenders = "collaborative,content"
+
+// Scores given to feedback without a rating, on the rating scale
+const (
+	FavoriteScore   = 9.0
+	CompletedScore  = 7.0
+	InProgressScore = 6.0
+	DroppedScore    = 3.0
+)
+
+// Recommender writes recommendations for every user
+type Recommender interface {
+	// Source is recorded on the recommendations the recommender writes
+	Source() string
+	// Recommend replaces the recommender's recommendations for every user
+	// with feedback and returns how many it wrote
+	Recommend(ctx context.Context, repo db.Repository) (int, error)
+}
+
+// Scored is a media item recommended to a user with its score
+type Scored struct {
+	MediaID uuid.UUID
+	Score   float64
+}
+
+// NewFromEnv creates the recommenders listed in RECOMMENDERS, comma
+// separated, in order
+func NewFromEnv() ([]Recommender, error) {
+	names := os.Getenv("RECOMMENDERS")
+	if names == "" {
+		names = DefaultRecommenders
+	}
+
+	var recommenders []Recommender
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "", "off":
+			continue
+		case SourceCollaborative:
+			collaborative, err := NewCollaborativeFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			recommenders = append(recommenders, collaborative)
+		case SourceContent:
+			content, err := NewContentFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			recommenders = append(recommenders, content)
+		default:
+			log.Printf("Warning: Unknown recommender %q in RECOMMENDERS", name)
+		}
+	}
+	return recommenders, nil
+}
+
+// feedbackScore reads how much a user liked a media item: their rating, or
+// else a score for having favorited, finished, started or dropped it. Media
+// that is only planned gives no feedback.
+func feedbackScore(interaction *db.Interaction) (float64, bool) {
+	if interaction.Rating != nil {
+		return *interaction.Rating, true
+	}
+	if interaction.Favorite {
+		return FavoriteScore, true
+	}
+
+	score, ok := 0.0, false
+	for _, status := range interaction.Statuses {
+		var statusScore float64
+		switch status {
+		case db.StatusCompleted:
+			statusScore = CompletedScore
+		case db.StatusInProgress, db.StatusOnHold:
+			statusScore = InProgressScore
+		case db.StatusDropped:
+			statusScore = DroppedScore
+		default:
+			continue
+		}
+		if !ok || statusScore > score {
+			score, ok = statusScore, true
+		}
+	}
+	return score, ok
+}
+
+// replace swaps a user's recommendations from source for scored, with
+// scores rounded to two decimals, returning how many it wrote
+func replace(ctx context.Context, repo db.Repository, userID uuid.UUID, source string, scored []Scored) (int, error) {
+	rounded := make([]db.ScoredMedia, len(scored))
+	for i, item := range scored {
+		rounded[i] = db.ScoredMedia{MediaID: item.MediaID, Score: math.Round(item.Score*100) / 100}
+	}
+	return repo.ReplaceRecommendations(ctx, userID, source, rounded)
+}
+
+// sortedIDs returns the keys of a map by user or media ID in order, so runs
+// are repeatable
+func sortedIDs[V any](m map[uuid.UUID]V) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return cmp.Compare(a.String(), b.String()) })
+	return ids
+}
+
+// intFromEnv reads a positive number from an environment variable, or
+// fallback when it is unset
+func intFromEnv(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive number, got %q", name, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,46 @@
+package recommend
+
+import (
+	"context"
+	"nq/db"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// recommendationRepository records the recommendations written. Other
+// repository methods aren't used by replace and panic.
+type recommendationRepository struct {
+	db.Repository
+	calls  int
+	source string
+	scored []db.ScoredMedia
+}
+
+func (r *recommendationRepository) ReplaceRecommendations(ctx context.Context, userID uuid.UUID, source string, scored []db.ScoredMedia) (int, error) {
+	r.calls++
+	r.source, r.scored = source, scored
+	return len(scored), nil
+}
+
+func TestReplaceWritesRoundedScoresAtOnce(t *testing.T) {
+	repo := &recommendationRepository{}
+	first, second := uuid.New(), uuid.New()
+
+	written, err := replace(context.Background(), repo, uuid.New(), SourceContent, []Scored{
+		{MediaID: first, Score: 0.87654},
+		{MediaID: second, Score: 0.123},
+	})
+	if err != nil || written != 2 {
+		t.Fatalf("got %d, %v", written, err)
+	}
+
+	if repo.calls != 1 || repo.source != SourceContent {
+		t.Errorf("replaced %d times from %q, want once from %q", repo.calls, repo.source, SourceContent)
+	}
+	want := []db.ScoredMedia{{MediaID: first, Score: 0.88}, {MediaID: second, Score: 0.12}}
+	if !slices.Equal(repo.scored, want) {
+		t.Errorf("wrote %v, want %v", repo.scored, want)
+	}
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"nq/db"
+	"nq/enrichment"
 	"nq/graph"
+	"nq/integrations"
+	"nq/jobs"
+	"nq/recommend"
+	"nq/vault"
 	"os"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -18,6 +24,9 @@ import (
 
 const defaultPort = "8080"
 
+// maxUploadSize caps the size of uploaded export files
+const maxUploadSize = 64 << 20
+
 func GraphQL() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -37,28 +46,103 @@ func GraphQL() {
 		log.Printf("Warning: Failed to initialize database constraints: %v", err)
 	}
 
+	// Load the master key that seals integration tokens and credentials
+	keyring, err := vault.NewKeyringFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load vault master key: %v", err)
+	}
+	if keyring == nil {
+		log.Printf("Warning: VAULT_MASTER_KEY is not set; integrations can't store tokens or credentials")
+	}
+
 	// Create repository
-	repo := db.NewNeo4jRepository(database)
+	repo := db.NewNeo4jRepository(database, keyring)
 
-	// Create resolver with repository
-	resolver := graph.NewResolver(repo)
+	// Move secrets sealed with a previous master key onto the current one
+	if keyring != nil {
+		rotated, err := repo.RotateSecrets(ctx, os.Getenv("VAULT_REENCRYPT") == "true")
+		if err != nil {
+			log.Printf("Warning: Failed to rotate secrets: %v", err)
+		} else if rotated > 0 {
+			log.Printf("Rotated %d secrets to master key %s", rotated, keyring.CurrentKeyID())
+		}
+	}
+
+	registry := integrations.NewRegistryFromEnv()
+	pipeline := enrichment.NewPipelineFromEnv()
+	recommenders, err := recommend.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure recommenders: %v", err)
+	}
+
+	// Run background jobs, such as scheduled integration syncs, alongside the
+	// API; other instances share the queue
+	scheduler, err := jobs.NewSchedulerFromEnv(repo)
+	if err != nil {
+		log.Fatalf("Failed to configure jobs: %v", err)
+	}
+	if err := jobs.RegisterIntegrationJobs(scheduler, registry); err != nil {
+		log.Fatalf("Failed to schedule integration syncs: %v", err)
+	}
+	if err := jobs.RegisterMaintenanceJobs(scheduler); err != nil {
+		log.Fatalf("Failed to schedule maintenance jobs: %v", err)
+	}
+	if err := jobs.RegisterEnrichmentJobs(scheduler, pipeline); err != nil {
+		log.Fatalf("Failed to register enrichment jobs: %v", err)
+	}
+	if err := jobs.RegisterRecommendationJobs(scheduler, recommenders); err != nil {
+		log.Fatalf("Failed to schedule recommendations: %v", err)
+	}
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	go func() {
+		if err := scheduler.Run(jobsCtx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Warning: Background jobs stopped: %v", err)
+		}
+	}()
+
+	// Create resolver with repository, integration clients, enrichers and
+	// the job queue
+	resolver := graph.NewResolver(repo, registry, pipeline, scheduler)
 
 	// Create GraphQL server
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  resolver,
+		Directives: graph.Directives(),
+	}))
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
+	// Export files for importLibrary arrive as multipart uploads
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: maxUploadSize,
+		MaxMemory:     maxUploadSize,
+	})
 
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
+	// Report typed error codes and keep panics off the wire
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.SetRecoverFunc(graph.Recover)
+
+	// Validate every argument before resolvers run
+	srv.AroundRootFields(graph.CollectValidationErrors)
+	srv.AroundFields(graph.ValidateArguments)
+
 	srv.Use(extension.Introspection{})
 	srv.Use(extension.AutomaticPersistedQuery{
 		Cache: lru.New[string](100),
 	})
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	// Scope bookmarks to each request so reads observe earlier writes, and
+	// let requests with ADMIN_TOKEN use admin mutations
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Printf("Warning: ADMIN_TOKEN is not set; admin mutations such as mergeMedia are disabled")
+	}
+	http.Handle("/query", db.BookmarkMiddleware(graph.AdminMiddleware(adminToken, srv)))
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
@@ -0,0 +1,83 @@
+// Package validation checks GraphQL inputs before they reach the repository.
+// Field rules are declared in the schema with @constraint and cross-field
+// rules live in Go, one validator per input type. Both report into the same
+// Errors collector so a client sees every problem with a request at once.
+package validation
+
+import (
+	"context"
+	"nq/db"
+	"strings"
+)
+
+// FieldError describes a single invalid input field. Path starts at the
+// field that took the input, e.g. ["createUser", "input", "email"].
+type FieldError struct {
+	Path    []string `json:"path"`
+	Message string   `json:"message"`
+}
+
+// Error reports every invalid field of an input at once. It is a
+// db.ErrValidation, so it is reported like the repository's validation
+// errors.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = strings.Join(field.Path, ".") + ": " + field.Message
+	}
+	return "invalid input: " + strings.Join(messages, "; ")
+}
+
+// Unwrap makes errors.Is(err, db.ErrValidation) hold
+func (e *Error) Unwrap() error {
+	return db.ErrValidation
+}
+
+// InvalidFields returns an error listing every invalid field
+func InvalidFields(fields []FieldError) error {
+	return &Error{Fields: fields}
+}
+
+// Errors collects field errors for a single root field
+type Errors struct {
+	fields []FieldError
+}
+
+// Add records a problem with the field at path
+func (e *Errors) Add(path []string, message string) {
+	e.fields = append(e.fields, FieldError{
+		Path:    append([]string(nil), path...),
+		Message: message,
+	})
+}
+
+// Err returns a validation error listing every recorded field, or nil if the
+// input was valid
+func (e *Errors) Err() error {
+	if e == nil || len(e.fields) == 0 {
+		return nil
+	}
+	return InvalidFields(e.fields)
+}
+
+type errorsKey struct{}
+
+// WithErrors returns a context carrying a fresh collector
+func WithErrors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errorsKey{}, &Errors{})
+}
+
+// FromContext returns the collector installed by WithErrors, or nil
+func FromContext(ctx context.Context) *Errors {
+	errs, _ := ctx.Value(errorsKey{}).(*Errors)
+	return errs
+}
+
+// child extends path with the name of a nested field
+func child(path []string, name string) []string {
+	return append(append([]string(nil), path...), name)
+}
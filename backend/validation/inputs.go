@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"nq/graph/model"
+	"strings"
+)
+
+// Input runs the Go validator for a resolver argument, if its type has one.
+// These cover rules that span several fields and so can't be expressed
+// with @constraint.
+func Input(errs *Errors, path []string, value any) {
+	switch in := value.(type) {
+	case model.UpdateUserInput:
+		updateUserInput(errs, path, in)
+	case model.CreateTVShowInput:
+		createTVShowInput(errs, path, in)
+	case model.CreateGameInput:
+		createGameInput(errs, path, in)
+	case model.CreateActivityInput:
+		createActivityInput(errs, path, in)
+	case model.UpdateActivityInput:
+		updateActivityInput(errs, path, in)
+	}
+}
+
+func updateUserInput(errs *Errors, path []string, in model.UpdateUserInput) {
+	if in.Name == nil && in.Email == nil {
+		errs.Add(path, "must set at least one field")
+	}
+}
+
+func createTVShowInput(errs *Errors, path []string, in model.CreateTVShowInput) {
+	if in.Seasons != nil && in.Episodes != nil && *in.Episodes < *in.Seasons {
+		errs.Add(child(path, "episodes"), "must be at least the number of seasons")
+	}
+}
+
+func createGameInput(errs *Errors, path []string, in model.CreateGameInput) {
+	seen := make(map[string]bool, len(in.Genre))
+	for _, genre := range in.Genre {
+		key := strings.ToLower(strings.TrimSpace(genre))
+		if key == "" {
+			errs.Add(child(path, "genre"), "must not contain blank genres")
+			continue
+		}
+		if seen[key] {
+			errs.Add(child(path, "genre"), "must not repeat "+genre)
+		}
+		seen[key] = true
+	}
+}
+
+func createActivityInput(errs *Errors, path []string, in model.CreateActivityInput) {
+	if in.StartedAt == nil || in.FinishedAt == nil {
+		return
+	}
+
+	// Malformed timestamps are already reported by @constraint
+	startedAt, err := ParseDateTime(*in.StartedAt)
+	if err != nil {
+		return
+	}
+	finishedAt, err := ParseDateTime(*in.FinishedAt)
+	if err != nil {
+		return
+	}
+
+	if finishedAt.Before(startedAt) {
+		errs.Add(child(path, "finishedAt"), "must not be before startedAt")
+	}
+}
+
+func updateActivityInput(errs *Errors, path []string, in model.UpdateActivityInput) {
+	if in.StatusID == nil && in.Rating == nil && in.Review == nil && in.FinishedAt == nil {
+		errs.Add(path, "must set at least one field")
+	}
+}
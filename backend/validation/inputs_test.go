@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"nq/graph/model"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInput(t *testing.T) {
+	str := func(s string) *string { return &s }
+	count := func(n int32) *int32 { return &n }
+	path := []string{"field", "input"}
+
+	tests := []struct {
+		name  string
+		input any
+		want  []string
+	}{
+		{"update without fields", model.UpdateUserInput{}, []string{"field.input: must set at least one field"}},
+		{"update with a name", model.UpdateUserInput{Name: str("Reader")}, nil},
+		{"fewer episodes than seasons", model.CreateTVShowInput{Seasons: count(3), Episodes: count(2)}, []string{"field.input.episodes: must be at least the number of seasons"}},
+		{"episodes without seasons", model.CreateTVShowInput{Episodes: count(2)}, nil},
+		{"repeated genre", model.CreateGameInput{Genre: []string{"RPG", " rpg "}}, []string{"field.input.genre: must not repeat  rpg "}},
+		{"blank genre", model.CreateGameInput{Genre: []string{"RPG", " "}}, []string{"field.input.genre: must not contain blank genres"}},
+		{"finished before started", model.CreateActivityInput{StartedAt: str("2024-03-01T10:00:00Z"), FinishedAt: str("2024-03-01T09:00:00Z")}, []string{"field.input.finishedAt: must not be before startedAt"}},
+		{"finished in another zone", model.CreateActivityInput{StartedAt: str("2024-03-01T10:00:00Z"), FinishedAt: str("2024-03-01T11:30:00+01:00")}, nil},
+		{"finished without a start", model.CreateActivityInput{FinishedAt: str("2024-03-01T09:00:00Z")}, nil},
+		// Malformed timestamps are reported by @constraint, not here
+		{"malformed start", model.CreateActivityInput{StartedAt: str("yesterday"), FinishedAt: str("2024-03-01T09:00:00Z")}, nil},
+		{"activity update without fields", model.UpdateActivityInput{}, []string{"field.input: must set at least one field"}},
+		{"activity update with finishedAt", model.UpdateActivityInput{FinishedAt: str("2024-03-01T09:00:00Z")}, nil},
+		{"input without a validator", model.CreateUserInput{}, nil},
+	}
+	for _, tt := range tests {
+		errs := &Errors{}
+		Input(errs, path, tt.input)
+
+		var got []string
+		for _, field := range errs.fields {
+			got = append(got, strings.Join(field.Path, ".")+": "+field.Message)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorsErr(t *testing.T) {
+	errs := &Errors{}
+	if err := errs.Err(); err != nil {
+		t.Errorf("got %v for a valid input", err)
+	}
+
+	errs.Add([]string{"createUser", "input", "email"}, "must be a valid email address")
+	errs.Add([]string{"createUser", "input", "name"}, "must not be empty")
+	err := errs.Err()
+	if want := "invalid input: createUser.input.email: must be a valid email address; createUser.input.name: must not be empty"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"nq/isbn"
+	"time"
+	"unicode/utf8"
+)
+
+// Constraint mirrors the arguments of the @constraint schema directive
+type Constraint struct {
+	Min       *float64
+	Max       *float64
+	MinLength *int32
+	MaxLength *int32
+	Format    *string
+}
+
+// Check returns a message for every rule the value breaks. Null values
+// always pass; whether a field is required is left to the schema.
+func (c Constraint) Check(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return c.checkString(v)
+	case *string:
+		if v != nil {
+			return c.checkString(*v)
+		}
+	case int32:
+		return c.checkNumber(float64(v))
+	case *int32:
+		if v != nil {
+			return c.checkNumber(float64(*v))
+		}
+	case float64:
+		return c.checkNumber(v)
+	case *float64:
+		if v != nil {
+			return c.checkNumber(*v)
+		}
+	}
+	return nil
+}
+
+func (c Constraint) checkNumber(v float64) []string {
+	var messages []string
+	if c.Min != nil && v < *c.Min {
+		messages = append(messages, fmt.Sprintf("must be at least %g", *c.Min))
+	}
+	if c.Max != nil && v > *c.Max {
+		messages = append(messages, fmt.Sprintf("must be at most %g", *c.Max))
+	}
+	return messages
+}
+
+func (c Constraint) checkString(v string) []string {
+	var messages []string
+	length := int32(utf8.RuneCountInString(v))
+	if c.MinLength != nil && length < *c.MinLength {
+		if *c.MinLength == 1 {
+			messages = append(messages, "must not be empty")
+		} else {
+			messages = append(messages, fmt.Sprintf("must be at least %d characters", *c.MinLength))
+		}
+	}
+	if c.MaxLength != nil && length > *c.MaxLength {
+		messages = append(messages, fmt.Sprintf("must be at most %d characters", *c.MaxLength))
+	}
+	if c.Format != nil {
+		if message := checkFormat(*c.Format, v); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// checkFormat validates the named format, returning an empty string on success
+func checkFormat(format, v string) string {
+	switch format {
+	case "email":
+		if !IsEmail(v) {
+			return "must be a valid email address"
+		}
+	case "url":
+		if !IsURL(v) {
+			return "must be an absolute http or https URL"
+		}
+	case "isbn":
+		if !isbn.Valid(v) {
+			return "must be a valid ISBN-10 or ISBN-13"
+		}
+	case "date":
+		if _, err := time.Parse(time.DateOnly, v); err != nil {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	case "date-time":
+		if _, err := ParseDateTime(v); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	default:
+		return fmt.Sprintf("has unknown format %q", format)
+	}
+	return ""
+}
+
+// IsEmail reports whether v is a bare email address such as a@example.com
+func IsEmail(v string) bool {
+	address, err := mail.ParseAddress(v)
+	return err == nil && address.Address == v
+}
+
+// IsURL reports whether v is an absolute http or https URL
+func IsURL(v string) bool {
+	u, err := url.ParseRequestURI(v)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ParseDateTime parses the RFC 3339 format used by DateTime fields
+func ParseDateTime(v string) (time.Time, error) {
+	return time.Parse(time.RFC3339, v)
+}
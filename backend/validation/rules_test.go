@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	format := func(f string) Constraint { return Constraint{Format: &f} }
+	number := func(n float64) *float64 { return &n }
+	length := func(n int32) *int32 { return &n }
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name       string
+		constraint Constraint
+		value      any
+		want       []string
+	}{
+		{"email", format("email"), "reader@example.com", nil},
+		{"email with a display name", format("email"), "Reader <reader@example.com>", []string{"must be a valid email address"}},
+		{"email without a domain", format("email"), "reader@", []string{"must be a valid email address"}},
+		{"url", format("url"), "https://example.com/cover.jpg", nil},
+		{"relative url", format("url"), "/cover.jpg", []string{"must be an absolute http or https URL"}},
+		{"ftp url", format("url"), "ftp://example.com/cover.jpg", []string{"must be an absolute http or https URL"}},
+		{"isbn-13", format("isbn"), "978-0-306-40615-7", nil},
+		{"isbn-10", format("isbn"), "0-8044-2957-X", nil},
+		{"isbn with a bad checksum", format("isbn"), "978-0-306-40615-8", []string{"must be a valid ISBN-10 or ISBN-13"}},
+		{"date", format("date"), "2024-02-29", nil},
+		{"impossible date", format("date"), "2023-02-29", []string{"must be a date in YYYY-MM-DD format"}},
+		{"date with a time", format("date"), "2024-02-29T10:00:00Z", []string{"must be a date in YYYY-MM-DD format"}},
+		{"date-time", format("date-time"), "2024-02-29T10:00:00+01:00", nil},
+		{"date-time without a zone", format("date-time"), "2024-02-29T10:00:00", []string{"must be an RFC 3339 date-time"}},
+		{"unknown format", format("colour"), "red", []string{`has unknown format "colour"`}},
+		{"null string", format("email"), (*string)(nil), nil},
+		{"string pointer", format("email"), str("nope"), []string{"must be a valid email address"}},
+		{"empty", Constraint{MinLength: length(1)}, "", []string{"must not be empty"}},
+		{"too short", Constraint{MinLength: length(3)}, "ab", []string{"must be at least 3 characters"}},
+		{"counts runes", Constraint{MaxLength: length(3)}, "été", nil},
+		{"too long", Constraint{MaxLength: length(3)}, "abcd", []string{"must be at most 3 characters"}},
+		{"in range", Constraint{Min: number(0), Max: number(10)}, 7.5, nil},
+		{"below min", Constraint{Min: number(0), Max: number(10)}, int32(-1), []string{"must be at least 0"}},
+		{"above max", Constraint{Min: number(0), Max: number(10)}, number(10.5), []string{"must be at most 10"}},
+	}
+	for _, tt := range tests {
+		if got := tt.constraint.Check(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
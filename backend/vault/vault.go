@@ -0,0 +1,277 @@
+// Package vault encrypts secrets at rest with envelope encryption. Every
+// secret is encrypted with its own random data key, and the data key is
+// stored alongside it encrypted with a master key. Rotating the master key
+// only re-encrypts the data keys.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of master and data keys, for AES-256
+const KeySize = 32
+
+// format prefixes sealed secrets, so the layout can change later
+const format = "v1"
+
+var (
+	// ErrNoMasterKey is returned when sealing without a configured master key
+	ErrNoMasterKey = errors.New("vault: no master key configured")
+	// ErrUnknownKey is returned when a secret was sealed with a master key
+	// that isn't in the keyring
+	ErrUnknownKey = errors.New("vault: secret was sealed with an unknown master key")
+	// ErrMalformed is returned for values that aren't sealed secrets
+	ErrMalformed = errors.New("vault: malformed sealed secret")
+)
+
+// Keyring holds the current master key, which seals new secrets, and the
+// previous ones, which can still open secrets sealed before a rotation
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates a keyring that seals with current and opens with any of
+// the keys
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range append([][]byte{current}, previous...) {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("vault: master keys must be %d bytes, key %d is %d", KeySize, i+1, len(key))
+		}
+		keyring.keys[KeyID(key)] = key
+	}
+	keyring.current = KeyID(current)
+	return keyring, nil
+}
+
+// NewKeyringFromEnv creates a keyring from VAULT_MASTER_KEY and, while
+// rotating, the comma-separated VAULT_PREVIOUS_MASTER_KEYS. Keys are 32
+// random bytes in base64, e.g. from `openssl rand -base64 32`. It returns
+// nil when no master key is configured.
+func NewKeyringFromEnv() (*Keyring, error) {
+	encoded := os.Getenv("VAULT_MASTER_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+
+	current, err := ParseKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("VAULT_MASTER_KEY: %w", err)
+	}
+
+	var previous [][]byte
+	for _, encoded := range strings.Split(os.Getenv("VAULT_PREVIOUS_MASTER_KEYS"), ",") {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		key, err := ParseKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_PREVIOUS_MASTER_KEYS: %w", err)
+		}
+		previous = append(previous, key)
+	}
+
+	return NewKeyring(current, previous...)
+}
+
+// ParseKey decodes a base64 master key
+func ParseKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(encoded); err == nil {
+			if len(key) != KeySize {
+				return nil, fmt.Errorf("key is %d bytes, want %d", len(key), KeySize)
+			}
+			return key, nil
+		}
+	}
+	return nil, errors.New("key is not valid base64")
+}
+
+// KeyID identifies a master key without revealing it
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// CurrentKeyID is the ID of the master key new secrets are sealed with
+func (k *Keyring) CurrentKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.current
+}
+
+// Seal encrypts a secret with a new data key. The context, such as the
+// record and field the secret is stored in, must be given again to open it,
+// so a sealed value copied to another record can't be opened there.
+func (k *Keyring) Seal(plaintext, context []byte) (string, error) {
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	ciphertext, err := encrypt(dataKey, plaintext, context)
+	if err != nil {
+		return "", err
+	}
+
+	return k.wrap(dataKey, ciphertext)
+}
+
+// Open decrypts a sealed secret
+func (k *Keyring) Open(sealed string, context []byte) ([]byte, error) {
+	dataKey, ciphertext, err := k.unwrap(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decrypt(dataKey, ciphertext, context)
+	if err != nil {
+		return nil, fmt.Errorf("vault: secret can't be decrypted: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap re-encrypts a sealed secret's data key with the current master key,
+// leaving the secret itself untouched. Secrets already sealed with the
+// current key are returned as they are.
+func (k *Keyring) Rewrap(sealed string) (string, error) {
+	if id, err := SealedKeyID(sealed); err == nil && id == k.CurrentKeyID() {
+		return sealed, nil
+	}
+
+	dataKey, ciphertext, err := k.unwrap(sealed)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(dataKey, ciphertext)
+}
+
+// Reencrypt opens a sealed secret and seals it again with a new data key
+func (k *Keyring) Reencrypt(sealed string, context []byte) (string, error) {
+	plaintext, err := k.Open(sealed, context)
+	if err != nil {
+		return "", err
+	}
+	return k.Seal(plaintext, context)
+}
+
+// SealedKeyID returns the ID of the master key a secret was sealed with
+func SealedKeyID(sealed string) (string, error) {
+	parts := strings.Split(sealed, ".")
+	if len(parts) != 4 || parts[0] != format {
+		return "", ErrMalformed
+	}
+	return parts[1], nil
+}
+
+// IsSealed reports whether a stored value is a sealed secret rather than
+// plaintext written before secrets were encrypted
+func IsSealed(value string) bool {
+	_, err := SealedKeyID(value)
+	return err == nil
+}
+
+// wrap encrypts a data key with the current master key and joins it with the
+// ciphertext it encrypted, as v1.<key ID>.<wrapped data key>.<ciphertext>
+func (k *Keyring) wrap(dataKey, ciphertext []byte) (string, error) {
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+
+	wrapped, err := encrypt(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		format,
+		k.current,
+		base64.RawURLEncoding.EncodeToString(wrapped),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+	}, "."), nil
+}
+
+// unwrap splits a sealed secret and decrypts its data key
+func (k *Keyring) unwrap(sealed string) (dataKey, ciphertext []byte, err error) {
+	keyID, err := SealedKeyID(sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k == nil {
+		return nil, nil, ErrNoMasterKey
+	}
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w %s", ErrUnknownKey, keyID)
+	}
+
+	parts := strings.Split(sealed, ".")
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, ErrMalformed
+	}
+	ciphertext, err = base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, nil, ErrMalformed
+	}
+
+	dataKey, err = decrypt(masterKey, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("vault: data key can't be decrypted: %w", err)
+	}
+	return dataKey, ciphertext, nil
+}
+
+// encrypt seals plaintext with AES-GCM, returning the nonce followed by the
+// ciphertext
+func encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// decrypt opens a value produced by encrypt
+func decrypt(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// newGCM creates an AES-GCM cipher for a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func testKeyring(t *testing.T, current []byte, previous ...[]byte) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func seal(t *testing.T, keyring *Keyring, plaintext, context string) string {
+	t.Helper()
+	sealed, err := keyring.Seal([]byte(plaintext), []byte(context))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	return sealed
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	keyring := testKeyring(t, testKey(1))
+
+	sealed := seal(t, keyring, "refresh-token", "user:1:spotify")
+	if strings.Contains(sealed, "refresh-token") {
+		t.Fatalf("sealed value %s contains the plaintext", sealed)
+	}
+	if !IsSealed(sealed) || IsSealed("refresh-token") {
+		t.Errorf("IsSealed doesn't tell sealed values from plaintext")
+	}
+	if id, _ := SealedKeyID(sealed); id != keyring.CurrentKeyID() {
+		t.Errorf("sealed with %s, want the current key %s", id, keyring.CurrentKeyID())
+	}
+
+	plaintext, err := keyring.Open(sealed, []byte("user:1:spotify"))
+	if err != nil || string(plaintext) != "refresh-token" {
+		t.Fatalf("Open = %q, %v", plaintext, err)
+	}
+
+	again := seal(t, keyring, "refresh-token", "user:1:spotify")
+	if again == sealed {
+		t.Errorf("sealing twice gave the same value, want a new data key and nonce each time")
+	}
+}
+
+func TestOpenRejectsAnotherContext(t *testing.T) {
+	keyring := testKeyring(t, testKey(1))
+	sealed := seal(t, keyring, "refresh-token", "user:1:spotify")
+
+	if _, err := keyring.Open(sealed, []byte("user:2:spotify")); err == nil {
+		t.Errorf("opened a secret copied to another record")
+	}
+	if _, err := keyring.Open(sealed, nil); err == nil {
+		t.Errorf("opened a secret without its context")
+	}
+}
+
+func TestOpenRejectsUnknownKey(t *testing.T) {
+	sealed := seal(t, testKeyring(t, testKey(1)), "refresh-token", "ctx")
+
+	_, err := testKeyring(t, testKey(2)).Open(sealed, []byte("ctx"))
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+
+	var keyring *Keyring
+	if _, err := keyring.Open(sealed, []byte("ctx")); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("got %v from a missing keyring, want ErrNoMasterKey", err)
+	}
+	if _, err := keyring.Seal([]byte("secret"), nil); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("got %v sealing without a keyring, want ErrNoMasterKey", err)
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	first, second := testKey(1), testKey(2)
+	keyring := testKeyring(t, first, second)
+	sealed := seal(t, keyring, "refresh-token", "ctx")
+	parts := strings.Split(sealed, ".")
+
+	flip := func(encoded string) string {
+		raw, _ := base64.RawURLEncoding.DecodeString(encoded)
+		raw[len(raw)-1] ^= 1
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	tests := []struct {
+		name   string
+		sealed string
+	}{
+		{"ciphertext", strings.Join([]string{parts[0], parts[1], parts[2], flip(parts[3])}, ".")},
+		{"wrapped data key", strings.Join([]string{parts[0], parts[1], flip(parts[2]), parts[3]}, ".")},
+		// The key ID is bound to the wrapped data key, so pointing it at
+		// another key in the keyring doesn't work either
+		{"key ID", strings.Join([]string{parts[0], KeyID(second), parts[2], parts[3]}, ".")},
+		{"format", strings.Join([]string{"v0", parts[1], parts[2], parts[3]}, ".")},
+		{"truncated", strings.Join(parts[:3], ".")},
+		{"not base64", strings.Join([]string{parts[0], parts[1], parts[2], "!!"}, ".")},
+	}
+	for _, tt := range tests {
+		if plaintext, err := keyring.Open(tt.sealed, []byte("ctx")); err == nil {
+			t.Errorf("%s: opened a tampered secret as %q", tt.name, plaintext)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey := testKey(1), testKey(2)
+	sealed := seal(t, testKeyring(t, oldKey), "refresh-token", "ctx")
+
+	rotating := testKeyring(t, newKey, oldKey)
+	if plaintext, err := rotating.Open(sealed, []byte("ctx")); err != nil || string(plaintext) != "refresh-token" {
+		t.Fatalf("Open with the previous key = %q, %v", plaintext, err)
+	}
+
+	rewrapped, err := rotating.Rewrap(sealed)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if id, _ := SealedKeyID(rewrapped); id != KeyID(newKey) {
+		t.Errorf("rewrapped with %s, want the new key %s", id, KeyID(newKey))
+	}
+	if strings.Split(rewrapped, ".")[3] != strings.Split(sealed, ".")[3] {
+		t.Errorf("Rewrap changed the ciphertext, want only the data key re-encrypted")
+	}
+	if again, err := rotating.Rewrap(rewrapped); err != nil || again != rewrapped {
+		t.Errorf("Rewrap of a current secret = %v, want it unchanged", err)
+	}
+
+	reencrypted, err := rotating.Reencrypt(sealed, []byte("ctx"))
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+
+	// Once the old key is retired only rotated secrets can be opened
+	rotated := testKeyring(t, newKey)
+	for _, value := range []string{rewrapped, reencrypted} {
+		if plaintext, err := rotated.Open(value, []byte("ctx")); err != nil || string(plaintext) != "refresh-token" {
+			t.Errorf("Open after rotation = %q, %v", plaintext, err)
+		}
+	}
+	if _, err := rotated.Open(sealed, []byte("ctx")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v for a secret that wasn't rotated, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if _, err := NewKeyring(testKey(1), make([]byte, 16)); err == nil {
+		t.Errorf("accepted a 16 byte previous key")
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(testKey(7))
+	for _, value := range []string{encoded, " " + encoded + "\n", base64.RawURLEncoding.EncodeToString(testKey(7))} {
+		if key, err := ParseKey(value); err != nil || !bytes.Equal(key, testKey(7)) {
+			t.Errorf("ParseKey(%q) = %v", value, err)
+		}
+	}
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString(make([]byte, 16))); err == nil {
+		t.Errorf("ParseKey accepted a short key")
+	}
+	if _, err := ParseKey("not a key"); err == nil {
+		t.Errorf("ParseKey accepted invalid base64")
+	}
+}